@@ -23,7 +23,10 @@ func main() {
 	stdLogger := stdlogadapter.New(log.Default())
 
 	limiterStore := store.NewMemory(ctx, 10*time.Minute)
-	limiter := ratelimiter.NewTokenBucket(limiterStore, 1.0, 5)
+	limiter, err := ratelimiter.NewTokenBucket(limiterStore, 1.0, 5)
+	if err != nil {
+		log.Fatalf("Failed to create limiter: %v", err)
+	}
 
 	config := []ratelimiter.Option{
 		ratelimiter.WithLogger(stdLogger),
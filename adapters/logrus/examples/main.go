@@ -28,7 +28,10 @@ func main() {
 	logrusLogger := logrusadapter.New(logger)
 
 	limiterStore := store.NewMemory(ctx, 10*time.Minute)
-	limiter := ratelimiter.NewTokenBucket(limiterStore, 1.0, 5)
+	limiter, err := ratelimiter.NewTokenBucket(limiterStore, 1.0, 5)
+	if err != nil {
+		log.Fatalf("Failed to create limiter: %v", err)
+	}
 
 	config := []ratelimiter.Option{
 		ratelimiter.WithLogger(logrusLogger),
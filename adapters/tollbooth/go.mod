@@ -0,0 +1,12 @@
+module github.com/jassus213/go-rate-limiter/adapters/tollbooth
+
+go 1.25.4
+
+require (
+	github.com/didip/tollbooth/v7 v7.0.2
+	github.com/jassus213/go-rate-limiter v0.0.1
+)
+
+require github.com/go-pkgz/expirable-cache/v3 v3.0.0 // indirect
+
+replace github.com/jassus213/go-rate-limiter => ../..
@@ -0,0 +1,81 @@
+// Package tollboothadapter connects github.com/didip/tollbooth/v7 with
+// github.com/jassus213/go-rate-limiter, so that codebases using tollbooth can
+// migrate incrementally.
+//
+// Use Limiter to enforce an existing tollbooth limiter through this package's
+// middleware and combinators, and MultiKeyFunc to key our limiters the way
+// tollbooth does while moving the counters to a shared store.
+package tollboothadapter
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/didip/tollbooth/v7"
+	"github.com/didip/tollbooth/v7/limiter"
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// Limiter returns a ratelimiter.Limiter backed by lmt. Tollbooth keeps its
+// buckets in process memory, so the limit is enforced per instance.
+//
+// Example:
+//
+//	lmt := tollbooth.NewLimiter(10, nil)
+//	handler := nethttp.Middleware(tollboothadapter.Limiter(lmt))(mux)
+func Limiter(lmt *limiter.Limiter) ratelimiter.Limiter {
+	return &tollboothLimiter{limiter: lmt}
+}
+
+// tollboothLimiter is the ratelimiter.Limiter returned by Limiter.
+type tollboothLimiter struct {
+	limiter *limiter.Limiter
+}
+
+// Allow takes a token for key from the tollbooth limiter.
+func (l *tollboothLimiter) Allow(ctx context.Context, key string) (ratelimiter.Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN takes n tokens for key from the tollbooth limiter, one at a time,
+// stopping at the first token that is not available.
+func (l *tollboothLimiter) AllowN(ctx context.Context, key string, n int64) (ratelimiter.Result, error) {
+	perSecond := l.limiter.GetMax()
+	result := ratelimiter.Result{
+		Allowed:   true,
+		Limit:     int64(l.limiter.GetBurst()),
+		Algorithm: ratelimiter.AlgorithmTokenBucket,
+	}
+	if perSecond > 0 {
+		result.RefillInterval = time.Duration(float64(time.Second) / perSecond)
+	}
+	for i := int64(0); i < n; i++ {
+		if l.limiter.LimitReached(key) {
+			result.Allowed = false
+			result.ResetAfter = result.RefillInterval
+			result.RetryAt = time.Now().Add(result.RefillInterval)
+			break
+		}
+	}
+	return result, nil
+}
+
+// MultiKeyFunc returns a ratelimiter.MultiKeyFunc producing the keys tollbooth
+// would build for r with lmt's IP lookups, methods, headers, and basic auth
+// users, joining each key's parts with "|".
+//
+// Example:
+//
+//	nethttp.Middleware(limiter, ratelimiter.WithMultiKeyFunc(tollboothadapter.MultiKeyFunc(lmt)))
+func MultiKeyFunc(lmt *limiter.Limiter) ratelimiter.MultiKeyFunc {
+	return func(ctx context.Context, r *http.Request) ([]string, error) {
+		sets := tollbooth.BuildKeys(lmt, r)
+		keys := make([]string, 0, len(sets))
+		for _, parts := range sets {
+			keys = append(keys, strings.Join(parts, "|"))
+		}
+		return keys, nil
+	}
+}
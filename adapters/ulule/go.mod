@@ -0,0 +1,12 @@
+module github.com/jassus213/go-rate-limiter/adapters/ulule
+
+go 1.25.4
+
+require (
+	github.com/jassus213/go-rate-limiter v0.0.1
+	github.com/ulule/limiter/v3 v3.11.2
+)
+
+require github.com/pkg/errors v0.9.1 // indirect
+
+replace github.com/jassus213/go-rate-limiter => ../..
@@ -0,0 +1,207 @@
+// Package ululeadapter connects github.com/ulule/limiter/v3 with
+// github.com/jassus213/go-rate-limiter in both directions, so that codebases
+// using ulule/limiter can migrate incrementally.
+//
+// Use Store to run ulule/limiter on one of our stores, Limiter to use a
+// ulule/limiter instance wherever a ratelimiter.Limiter is expected, and
+// FromStore to use an existing ulule/limiter store for our fixed windows.
+package ululeadapter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	"github.com/ulule/limiter/v3"
+)
+
+// ErrorTokenBucketUnsupported is returned by stores created with FromStore for
+// token bucket operations, which ulule/limiter stores cannot hold.
+var ErrorTokenBucketUnsupported = errors.New("token buckets not supported by ulule/limiter stores")
+
+// Store returns a limiter.Store keeping ulule/limiter's counters in s, e.g.
+// to share them with limiters of this package during a migration.
+//
+// Peek requires s to implement ratelimiter.Inspector and Reset
+// ratelimiter.Resetter. Increments by more than one use ratelimiter.CostStore
+// when s implements it.
+//
+// Example:
+//
+//	instance := limiter.New(ululeadapter.Store(store.NewRedis(client)), limiter.Rate{Period: time.Minute, Limit: 100})
+func Store(s ratelimiter.Store) limiter.Store {
+	return &store{store: s}
+}
+
+// store is the limiter.Store returned by Store.
+type store struct {
+	store ratelimiter.Store
+}
+
+func (s *store) Get(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	return s.Increment(ctx, key, 1, rate)
+}
+
+func (s *store) Increment(ctx context.Context, key string, count int64, rate limiter.Rate) (limiter.Context, error) {
+	var current int64
+	var ttl time.Duration
+	var err error
+	if costs, ok := ratelimiter.StoreAs[ratelimiter.CostStore](s.store); ok {
+		current, ttl, err = costs.IncrementBy(ctx, key, count, rate.Period)
+	} else {
+		for i := int64(0); i < count; i++ {
+			if current, ttl, err = s.store.Increment(ctx, key, rate.Period); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return limiter.Context{}, err
+	}
+	return newContext(rate, current, ttl), nil
+}
+
+func (s *store) Peek(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	states, err := ratelimiter.Inspect(ctx, s.store, escapePattern(key), 1)
+	if err != nil {
+		return limiter.Context{}, err
+	}
+	for _, state := range states {
+		if state.Key == key && state.Algorithm == ratelimiter.AlgorithmFixedWindow {
+			return newContext(rate, state.Count, time.Duration(state.TTL)), nil
+		}
+	}
+	return newContext(rate, 0, rate.Period), nil
+}
+
+func (s *store) Reset(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	resetter, ok := s.store.(ratelimiter.Resetter)
+	if !ok {
+		return limiter.Context{}, errors.New("store does not support reset")
+	}
+	if err := resetter.Reset(ctx, key); err != nil {
+		return limiter.Context{}, err
+	}
+	return newContext(rate, 0, rate.Period), nil
+}
+
+// newContext builds the limiter.Context for a counter at count expiring after ttl.
+func newContext(rate limiter.Rate, count int64, ttl time.Duration) limiter.Context {
+	return limiter.Context{
+		Limit:     rate.Limit,
+		Remaining: max(rate.Limit-count, 0),
+		Reset:     time.Now().Add(ttl).Unix(),
+		Reached:   count > rate.Limit,
+	}
+}
+
+// escapePattern escapes the path.Match metacharacters of key.
+func escapePattern(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Limiter returns a ratelimiter.Limiter backed by instance, so that an
+// existing ulule/limiter configuration can be used with this package's
+// middleware, Manager, or combinators.
+//
+// Example:
+//
+//	handler := nethttp.Middleware(ululeadapter.Limiter(instance))(mux)
+func Limiter(instance *limiter.Limiter) ratelimiter.Limiter {
+	return &ululeLimiter{instance: instance}
+}
+
+// ululeLimiter is the ratelimiter.Limiter returned by Limiter.
+type ululeLimiter struct {
+	instance *limiter.Limiter
+}
+
+// Allow counts a request for key with the ulule/limiter instance.
+func (l *ululeLimiter) Allow(ctx context.Context, key string) (ratelimiter.Result, error) {
+	lctx, err := l.instance.Get(ctx, key)
+	if err != nil {
+		return ratelimiter.Result{Allowed: false}, err
+	}
+	return result(lctx), nil
+}
+
+// AllowN counts a request costing n units for key with the ulule/limiter instance.
+func (l *ululeLimiter) AllowN(ctx context.Context, key string, n int64) (ratelimiter.Result, error) {
+	lctx, err := l.instance.Increment(ctx, key, n)
+	if err != nil {
+		return ratelimiter.Result{Allowed: false}, err
+	}
+	return result(lctx), nil
+}
+
+// result converts a limiter.Context to a ratelimiter.Result.
+func result(lctx limiter.Context) ratelimiter.Result {
+	retryAt := time.Unix(lctx.Reset, 0)
+	return ratelimiter.Result{
+		Allowed:         !lctx.Reached,
+		Limit:           lctx.Limit,
+		Remaining:       lctx.Remaining,
+		ResetAfter:      max(time.Until(retryAt), 0),
+		RemainingTokens: float64(lctx.Remaining),
+		RetryAt:         retryAt,
+		Algorithm:       ratelimiter.AlgorithmFixedWindow,
+		WindowEnd:       retryAt,
+	}
+}
+
+// FromStore returns a ratelimiter.Store keeping fixed window counters in s, so
+// that limiters of this package can share an existing ulule/limiter store.
+// Token bucket operations return ErrorTokenBucketUnsupported.
+//
+// Example:
+//
+//	limiter := ratelimiter.MustNewFixedWindow(ululeadapter.FromStore(ululeRedisStore), 100, time.Minute)
+func FromStore(s limiter.Store) ratelimiter.Store {
+	return &fromStore{store: s}
+}
+
+// fromStore is the ratelimiter.Store returned by FromStore.
+type fromStore struct {
+	store limiter.Store
+}
+
+// unlimited is the rate passed to ulule/limiter stores, which derive the
+// counter from the remaining quota.
+func unlimited(window time.Duration) limiter.Rate {
+	return limiter.Rate{Period: window, Limit: 1 << 62}
+}
+
+func (s *fromStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	return s.IncrementBy(ctx, key, 1, window)
+}
+
+func (s *fromStore) IncrementBy(ctx context.Context, key string, n int64, window time.Duration) (int64, time.Duration, error) {
+	rate := unlimited(window)
+	lctx, err := s.store.Increment(ctx, key, n, rate)
+	if err != nil {
+		return 0, 0, err
+	}
+	return rate.Limit - lctx.Remaining, max(time.Until(time.Unix(lctx.Reset, 0)), 0), nil
+}
+
+func (s *fromStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, error) {
+	return false, 0, ErrorTokenBucketUnsupported
+}
+
+func (s *fromStore) TakeTokens(ctx context.Context, key string, n int64, rate float64, burst int64) (bool, float64, error) {
+	return false, 0, ErrorTokenBucketUnsupported
+}
+
+func (s *fromStore) Reset(ctx context.Context, key string) error {
+	_, err := s.store.Reset(ctx, key, unlimited(time.Second))
+	return err
+}
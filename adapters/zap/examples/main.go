@@ -37,7 +37,10 @@ func main() {
 	limiterStore := store.NewMemory(ctx, 10*time.Minute)
 
 	// Настраиваем Token Bucket Limiter: 1 токен/сек, максимум 5 токенов (burst)
-	limiter := ratelimiter.NewTokenBucket(limiterStore, 1.0, 5)
+	limiter, err := ratelimiter.NewTokenBucket(limiterStore, 1.0, 5)
+	if err != nil {
+		log.Fatalf("Failed to create limiter: %v", err)
+	}
 
 	// Конфигурация RateLimiter с адаптером Zap
 	config := []ratelimiter.Option{
@@ -27,7 +27,10 @@ func main() {
 	zeroLogger := zerologadapter.New(&log.Logger)
 
 	limiterStore := store.NewMemory(ctx, 10*time.Minute)
-	limiter := ratelimiter.NewTokenBucket(limiterStore, 1.0, 5)
+	limiter, err := ratelimiter.NewTokenBucket(limiterStore, 1.0, 5)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create limiter")
+	}
 
 	config := []ratelimiter.Option{
 		ratelimiter.WithLogger(zeroLogger),
@@ -0,0 +1,241 @@
+// Package admin provides an HTTP handler for operating rate limiters at runtime.
+//
+// The handler exposes the operational switches of a ratelimiter.Manager, so
+// limiting can be suspended or tightened during an incident without a
+// redeploy, along with decision statistics and an optional embedded
+// dashboard for triage. It performs no authentication: mount it on an
+// internal listener or behind your own auth middleware.
+//
+// Example usage:
+//
+//	manager := ratelimiter.NewManager()
+//	mux.Handle("/ratelimit/", http.StripPrefix("/ratelimit", admin.Handler(manager,
+//	    admin.WithStore(store),
+//	    admin.WithDashboard(),
+//	)))
+//
+//	// curl -X PUT localhost:8080/ratelimit/mode -d '{"mode":"allow_all"}'
+//	// curl -X POST localhost:8080/ratelimit/deny -d '{"patterns":["203.0.113.*"]}'
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// defaultStateLimit is the number of keys returned by GET /state without a limit parameter.
+const defaultStateLimit = 1000
+
+// status is the body returned by the mode and deny endpoints.
+type status struct {
+	Mode     ratelimiter.Mode `json:"mode"`
+	Denied   []string         `json:"denied"`
+	Limiters []string         `json:"limiters"`
+}
+
+// modeRequest is the body accepted by PUT /mode.
+type modeRequest struct {
+	Mode ratelimiter.Mode `json:"mode"`
+}
+
+// denyRequest is the body accepted by POST and DELETE /deny.
+type denyRequest struct {
+	Patterns []string `json:"patterns"`
+}
+
+// Option configures the admin handler.
+type Option func(*config)
+
+// config holds the settings collected from Option values.
+type config struct {
+	store     ratelimiter.Store
+	usage     *ratelimiter.UsageTracker
+	dashboard bool
+}
+
+// WithStore reports the health of store in GET /stats, for stores
+// implementing ratelimiter.Pinger, and exposes its state in GET /state, for
+// stores implementing ratelimiter.Inspector.
+func WithStore(store ratelimiter.Store) Option {
+	return func(c *config) {
+		c.store = store
+	}
+}
+
+// WithUsage exposes the usage accumulated by tracker in GET /usage.
+func WithUsage(tracker *ratelimiter.UsageTracker) Option {
+	return func(c *config) {
+		c.usage = tracker
+	}
+}
+
+// WithDashboard serves an embedded HTML dashboard at GET / showing live
+// allow and deny rates, top denied keys, store health, and the configuration
+// of every policy.
+func WithDashboard() Option {
+	return func(c *config) {
+		c.dashboard = true
+	}
+}
+
+// Handler returns an http.Handler serving the admin API for manager.
+//
+// Routes:
+//
+//   - GET /mode: current mode, denied key patterns, and limiter names
+//   - PUT /mode: switch mode, body {"mode":"normal"} or {"mode":"allow_all"}
+//   - POST /deny: reject keys matching patterns, body {"patterns":["ip:203.0.113.*"]}
+//   - DELETE /deny: lift denials, same body as POST
+//   - GET /stats: per-policy decision counts, top denied keys, and store health
+//   - GET /state?pattern=login:*&limit=100: state held by the store for matching
+//     keys, for stores implementing ratelimiter.Inspector
+//   - GET /usage?key=tenant:acme&period=day: usage of a key in the current
+//     period, if enabled with WithUsage
+//   - GET /: the dashboard, if enabled with WithDashboard
+//
+// The mode and deny routes respond with the resulting status as JSON.
+func Handler(manager *ratelimiter.Manager, opts ...Option) http.Handler {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, stats{
+			Mode:     manager.Mode(),
+			Store:    storeHealth(r.Context(), cfg.store),
+			Policies: manager.Stats(),
+		})
+	})
+
+	mux.HandleFunc("GET /state", func(w http.ResponseWriter, r *http.Request) {
+		if cfg.store == nil {
+			http.Error(w, "no store configured", http.StatusNotImplemented)
+			return
+		}
+
+		pattern := r.URL.Query().Get("pattern")
+		if pattern == "" {
+			pattern = "*"
+		}
+		limit := defaultStateLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		states, err := ratelimiter.Inspect(r.Context(), cfg.store, pattern, limit)
+		if errors.Is(err, ratelimiter.ErrorInspectUnsupported) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if states == nil {
+			states = []ratelimiter.KeyState{}
+		}
+		writeJSON(w, states)
+	})
+
+	mux.HandleFunc("GET /usage", func(w http.ResponseWriter, r *http.Request) {
+		if cfg.usage == nil {
+			http.Error(w, "usage tracking not configured", http.StatusNotImplemented)
+			return
+		}
+
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			http.Error(w, "missing key", http.StatusBadRequest)
+			return
+		}
+		period := ratelimiter.Period(r.URL.Query().Get("period"))
+		if period == "" {
+			period = ratelimiter.PeriodDay
+		}
+
+		usage, err := cfg.usage.Usage(r.Context(), key, period)
+		if errors.Is(err, ratelimiter.ErrorInvalidConfig) {
+			http.Error(w, "unknown period", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, usage)
+	})
+
+	if cfg.dashboard {
+		mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write(dashboardHTML)
+		})
+	}
+
+	mux.HandleFunc("GET /mode", func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, manager)
+	})
+
+	mux.HandleFunc("PUT /mode", func(w http.ResponseWriter, r *http.Request) {
+		var req modeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Mode != ratelimiter.ModeNormal && req.Mode != ratelimiter.ModeAllowAll {
+			http.Error(w, "unknown mode", http.StatusBadRequest)
+			return
+		}
+		manager.SetMode(req.Mode)
+		writeStatus(w, manager)
+	})
+
+	mux.HandleFunc("POST /deny", func(w http.ResponseWriter, r *http.Request) {
+		var req denyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Patterns) == 0 {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		manager.DenyKeys(req.Patterns...)
+		writeStatus(w, manager)
+	})
+
+	mux.HandleFunc("DELETE /deny", func(w http.ResponseWriter, r *http.Request) {
+		var req denyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Patterns) == 0 {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		manager.AllowKeys(req.Patterns...)
+		writeStatus(w, manager)
+	})
+
+	return mux
+}
+
+// writeStatus writes the manager's current status as JSON.
+func writeStatus(w http.ResponseWriter, manager *ratelimiter.Manager) {
+	writeJSON(w, status{
+		Mode:     manager.Mode(),
+		Denied:   manager.DeniedKeys(),
+		Limiters: manager.Names(),
+	})
+}
+
+// writeJSON writes v as a JSON response body.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
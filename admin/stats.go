@@ -0,0 +1,38 @@
+package admin
+
+import (
+	"context"
+	_ "embed"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// stats is the body returned by GET /stats.
+type stats struct {
+	Mode     ratelimiter.Mode          `json:"mode"`
+	Store    health                    `json:"store"`
+	Policies []ratelimiter.PolicyStats `json:"policies"`
+}
+
+// health describes the reachability of the store.
+type health struct {
+	// Status is "ok", "error", or "unknown" when no store is configured or the
+	// store cannot be pinged.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// storeHealth pings store if it supports it.
+func storeHealth(ctx context.Context, store ratelimiter.Store) health {
+	pinger, ok := store.(ratelimiter.Pinger)
+	if !ok {
+		return health{Status: "unknown"}
+	}
+	if err := pinger.Ping(ctx); err != nil {
+		return health{Status: "error", Error: err.Error()}
+	}
+	return health{Status: "ok"}
+}
@@ -0,0 +1,203 @@
+// Package banlist rejects keys found on externally maintained ban lists, such
+// as those produced by fail2ban or a threat-intelligence feed.
+//
+// A List periodically loads entries from one or more sources (a file, an HTTP
+// URL, or a Redis set) and wraps a limiter so that banned keys are denied
+// before the limiter or its store is consulted. Entries are matched exactly
+// against keys, and entries in CIDR notation also match every IP key in the
+// range.
+//
+// Example usage:
+//
+//	bans := banlist.New(ctx, []banlist.Source{
+//	    banlist.File("/var/lib/fail2ban/banned.txt"),
+//	    banlist.URL("https://feeds.example.com/blocklist.txt"),
+//	}, banlist.WithInterval(5*time.Minute))
+//
+//	mux.Handle("/", nethttp.Middleware(bans.Limiter(limiter))(handler))
+package banlist
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// Policy is the Result.Policy reported for banned keys.
+const Policy = "banlist"
+
+// Option configures a List.
+type Option func(*config)
+
+// config holds the settings collected from Option values.
+type config struct {
+	interval time.Duration
+	logger   ratelimiter.Logger
+}
+
+// WithInterval sets how often the sources are reloaded. The default is one minute.
+func WithInterval(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.interval = d
+		}
+	}
+}
+
+// WithLogger sets the Logger used to report failed reloads.
+func WithLogger(l ratelimiter.Logger) Option {
+	return func(c *config) {
+		if l != nil {
+			c.logger = l
+		}
+	}
+}
+
+// List is a set of banned keys loaded from external sources.
+type List struct {
+	sources []Source
+	cfg     config
+
+	mu       sync.RWMutex
+	loaded   [][]string // last successful load of each source
+	keys     map[string]struct{}
+	prefixes []netip.Prefix
+}
+
+// New creates a List, loads all sources once, and reloads them in the
+// background until ctx is canceled.
+//
+// A source that fails to load keeps its previous entries, so a flaky feed
+// never lifts bans; failures are logged.
+func New(ctx context.Context, sources []Source, opts ...Option) *List {
+	cfg := config{interval: time.Minute, logger: noopLogger{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	l := &List{
+		sources: sources,
+		cfg:     cfg,
+		loaded:  make([][]string, len(sources)),
+		keys:    make(map[string]struct{}),
+	}
+	l.reload(ctx)
+	go l.run(ctx)
+	return l
+}
+
+// run reloads the sources every interval until ctx is canceled.
+func (l *List) run(ctx context.Context) {
+	ticker := time.NewTicker(l.cfg.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.reload(ctx)
+		}
+	}
+}
+
+// reload loads every source and replaces the entries of the List.
+func (l *List) reload(ctx context.Context) {
+	for i, source := range l.sources {
+		entries, err := source.Load(ctx)
+		if err != nil {
+			l.cfg.logger.Errorf("[RateLimiter] banlist: loading %s: %v", source, err)
+			continue
+		}
+		l.loaded[i] = entries
+	}
+
+	keys := make(map[string]struct{})
+	var prefixes []netip.Prefix
+	for _, entries := range l.loaded {
+		for _, entry := range entries {
+			if prefix, err := netip.ParsePrefix(entry); err == nil {
+				prefixes = append(prefixes, prefix.Masked())
+				continue
+			}
+			keys[entry] = struct{}{}
+		}
+	}
+
+	l.mu.Lock()
+	l.keys, l.prefixes = keys, prefixes
+	l.mu.Unlock()
+
+	l.cfg.logger.Debugf("[RateLimiter] banlist: loaded %d keys and %d ranges", len(keys), len(prefixes))
+}
+
+// Contains reports whether key is banned.
+func (l *List) Contains(key string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if _, ok := l.keys[key]; ok {
+		return true
+	}
+	if len(l.prefixes) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(key)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range l.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Limiter returns a Limiter that denies banned keys and delegates every other
+// key to inner.
+//
+// Keys are checked as passed to the limiter, so the List must hold keys in the
+// same form: IP ban lists only match when the middleware keys by IP and key
+// hashing is disabled.
+func (l *List) Limiter(inner ratelimiter.Limiter) ratelimiter.Limiter {
+	return &bannedLimiter{list: l, inner: inner}
+}
+
+// bannedLimiter is the Limiter returned by List.Limiter.
+type bannedLimiter struct {
+	list  *List
+	inner ratelimiter.Limiter
+}
+
+// Allow denies key if it is banned and delegates to the inner limiter otherwise.
+func (b *bannedLimiter) Allow(ctx context.Context, key string) (ratelimiter.Result, error) {
+	return b.AllowN(ctx, key, 1)
+}
+
+// AllowN denies key if it is banned and charges n units to the inner limiter otherwise.
+func (b *bannedLimiter) AllowN(ctx context.Context, key string, n int64) (ratelimiter.Result, error) {
+	if b.list.Contains(key) {
+		return ratelimiter.Result{Allowed: false, ResetAfter: b.list.cfg.interval, Policy: Policy}, nil
+	}
+	return ratelimiter.AllowN(ctx, b.inner, key, n)
+}
+
+// Refund gives n units back to the inner limiter.
+func (b *bannedLimiter) Refund(ctx context.Context, key string, n int64) error {
+	refunder, ok := b.inner.(ratelimiter.Refunder)
+	if !ok {
+		return ratelimiter.ErrorRefundUnsupported
+	}
+	return refunder.Refund(ctx, key, n)
+}
+
+// noopLogger is a private default logger that does nothing.
+type noopLogger struct{}
+
+func (noopLogger) Debugf(format string, args ...interface{}) {}
+func (noopLogger) Errorf(format string, args ...interface{}) {}
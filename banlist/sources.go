@@ -0,0 +1,109 @@
+package banlist
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Source loads the entries of a ban list.
+type Source interface {
+	// Load returns the current entries: keys, IP addresses, or CIDR ranges.
+	Load(ctx context.Context) ([]string, error)
+}
+
+// File returns a Source reading path, with one entry per line.
+//
+// Blank lines and text after a '#' are ignored, and only the first field of
+// each line is used, so most fail2ban and feed exports can be read as is.
+func File(path string) Source {
+	return fileSource(path)
+}
+
+// fileSource is the Source returned by File.
+type fileSource string
+
+func (f fileSource) Load(ctx context.Context) ([]string, error) {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parse(file)
+}
+
+func (f fileSource) String() string { return "file " + string(f) }
+
+// URL returns a Source fetching url with an HTTP GET request. The body uses
+// the same format as File.
+func URL(url string) Source {
+	return urlSource{url: url, client: http.DefaultClient}
+}
+
+// URLWithClient is like URL but uses client for the requests, e.g. to set a
+// timeout or authentication.
+func URLWithClient(url string, client *http.Client) Source {
+	return urlSource{url: url, client: client}
+}
+
+// urlSource is the Source returned by URL.
+type urlSource struct {
+	url    string
+	client *http.Client
+}
+
+func (u urlSource) Load(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return parse(resp.Body)
+}
+
+func (u urlSource) String() string { return "url " + u.url }
+
+// RedisSet returns a Source reading the members of the Redis set at key, e.g.
+// one shared by several fail2ban actions.
+func RedisSet(client *redis.Client, key string) Source {
+	return redisSource{client: client, key: key}
+}
+
+// redisSource is the Source returned by RedisSet.
+type redisSource struct {
+	client *redis.Client
+	key    string
+}
+
+func (r redisSource) Load(ctx context.Context) ([]string, error) {
+	return r.client.SMembers(ctx, r.key).Result()
+}
+
+func (r redisSource) String() string { return "redis set " + r.key }
+
+// parse reads one entry per line from r.
+func parse(r io.Reader) ([]string, error) {
+	var entries []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if fields := strings.Fields(line); len(fields) > 0 {
+			entries = append(entries, fields[0])
+		}
+	}
+	return entries, scanner.Err()
+}
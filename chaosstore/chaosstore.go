@@ -0,0 +1,320 @@
+// Package chaosstore injects faults into rate-limiter stores, so that the
+// fail-open and fallback configuration of an application can be exercised in
+// CI and during game days without breaking a real Redis.
+//
+// A Store wraps any ratelimiter.Store and, for a configurable share of
+// operations, delays them, fails them before they reach the wrapped store, or
+// lets them succeed in the wrapped store but reports an error, as when a
+// connection drops after Redis has applied a script. Injection can be turned
+// on and off at runtime.
+//
+// Example usage:
+//
+//	chaos := chaosstore.New(store.NewRedis(client),
+//	    chaosstore.WithLatency(50*time.Millisecond, 500*time.Millisecond, 0.2),
+//	    chaosstore.WithErrorRate(0.1),
+//	    chaosstore.WithPartialFailureRate(0.05),
+//	)
+//	limiter := ratelimiter.MustNewTokenBucket(chaos, 10, 50)
+package chaosstore
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// ErrorInjected is the error returned by injected failures unless another
+// one is set with WithError.
+var ErrorInjected = errors.New("chaosstore: injected failure")
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithLatency delays the given share of operations, between 0 and 1, by a
+// random duration between minDelay and maxDelay. Delays end early, with the
+// context's error, when the operation's context is canceled.
+func WithLatency(minDelay, maxDelay time.Duration, rate float64) Option {
+	return func(s *Store) {
+		s.minLatency, s.maxLatency = minDelay, max(maxDelay, minDelay)
+		s.latencyRate = rate
+	}
+}
+
+// WithErrorRate fails the given share of operations, between 0 and 1,
+// without calling the wrapped store.
+func WithErrorRate(rate float64) Option {
+	return func(s *Store) {
+		s.errorRate = rate
+	}
+}
+
+// WithPartialFailureRate makes the given share of operations, between 0 and
+// 1, succeed in the wrapped store but report an error, so that quota is
+// consumed although the caller sees a failure.
+func WithPartialFailureRate(rate float64) Option {
+	return func(s *Store) {
+		s.partialRate = rate
+	}
+}
+
+// WithError sets the error returned by injected failures, e.g.
+// context.DeadlineExceeded or redis.ErrClosed to match what the application
+// handles in production. The default is ErrorInjected.
+func WithError(err error) Option {
+	return func(s *Store) {
+		if err != nil {
+			s.err = err
+		}
+	}
+}
+
+// WithSeed makes the injected faults reproducible across runs.
+func WithSeed(seed uint64) Option {
+	return func(s *Store) {
+		s.rand = rand.New(rand.NewPCG(seed, seed))
+	}
+}
+
+// Store wraps a store and injects faults into its operations.
+//
+// It implements ratelimiter.CostStore, ratelimiter.RefundStore,
+// ratelimiter.Resetter, ratelimiter.Pinger and ratelimiter.StoreWrapper, so
+// that ratelimiter.StoreAs reports cost and refund support only if the
+// wrapped store has it; operations the wrapped store lacks fail with
+// ratelimiter.ErrorCostUnsupported or ratelimiter.ErrorRefundUnsupported. Ping is subject to faults like any
+// other operation, so that health checks see the outage.
+type Store struct {
+	store ratelimiter.Store
+
+	minLatency  time.Duration
+	maxLatency  time.Duration
+	latencyRate float64
+	errorRate   float64
+	partialRate float64
+	err         error
+
+	enabled atomic.Bool
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// New creates a Store injecting faults into s. Injection starts enabled.
+func New(s ratelimiter.Store, opts ...Option) *Store {
+	c := &Store{
+		store: s,
+		err:   ErrorInjected,
+		rand:  rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.enabled.Store(true)
+	return c
+}
+
+// Unwrap returns the wrapped store.
+func (s *Store) Unwrap() []ratelimiter.Store {
+	return []ratelimiter.Store{s.store}
+}
+
+// Enable turns fault injection on.
+func (s *Store) Enable() {
+	s.enabled.Store(true)
+}
+
+// Disable turns fault injection off, so that operations reach the wrapped
+// store unchanged.
+func (s *Store) Disable() {
+	s.enabled.Store(false)
+}
+
+// Enabled reports whether fault injection is on.
+func (s *Store) Enabled() bool {
+	return s.enabled.Load()
+}
+
+// fault is the outcome drawn for one operation.
+type fault struct {
+	delay   time.Duration
+	fail    bool
+	partial bool
+}
+
+// draw decides which faults to inject into the next operation.
+func (s *Store) draw() fault {
+	if !s.enabled.Load() {
+		return fault{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var f fault
+	if s.latencyRate > 0 && s.rand.Float64() < s.latencyRate {
+		f.delay = s.minLatency
+		if spread := s.maxLatency - s.minLatency; spread > 0 {
+			f.delay += time.Duration(s.rand.Int64N(int64(spread)))
+		}
+	}
+	switch p := s.rand.Float64(); {
+	case p < s.errorRate:
+		f.fail = true
+	case p < s.errorRate+s.partialRate:
+		f.partial = true
+	}
+	return f
+}
+
+// do runs op, the operation on the wrapped store, with the faults drawn for
+// it.
+func (s *Store) do(ctx context.Context, op func() error) error {
+	f := s.draw()
+	if f.delay > 0 {
+		timer := time.NewTimer(f.delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	if f.fail {
+		return s.err
+	}
+	if err := op(); err != nil {
+		return err
+	}
+	if f.partial {
+		return s.err
+	}
+	return nil
+}
+
+// Increment increments the counter for key in the wrapped store, subject to
+// the injected faults.
+func (s *Store) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	var (
+		count int64
+		ttl   time.Duration
+	)
+	err := s.do(ctx, func() (err error) {
+		count, ttl, err = s.store.Increment(ctx, key, window)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return count, ttl, nil
+}
+
+// TakeToken takes a token from the bucket for key in the wrapped store,
+// subject to the injected faults.
+func (s *Store) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, error) {
+	var (
+		allowed bool
+		tokens  float64
+	)
+	err := s.do(ctx, func() (err error) {
+		allowed, tokens, err = s.store.TakeToken(ctx, key, rate, burst)
+		return err
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed, tokens, nil
+}
+
+// IncrementBy increments the counter for key by n in the wrapped store,
+// subject to the injected faults.
+func (s *Store) IncrementBy(ctx context.Context, key string, n int64, window time.Duration) (int64, time.Duration, error) {
+	costs, ok := ratelimiter.StoreAs[ratelimiter.CostStore](s.store)
+	if !ok {
+		return 0, 0, ratelimiter.ErrorCostUnsupported
+	}
+	var (
+		count int64
+		ttl   time.Duration
+	)
+	err := s.do(ctx, func() (err error) {
+		count, ttl, err = costs.IncrementBy(ctx, key, n, window)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return count, ttl, nil
+}
+
+// TakeTokens takes n tokens from the bucket for key in the wrapped store,
+// subject to the injected faults.
+func (s *Store) TakeTokens(ctx context.Context, key string, n int64, rate float64, burst int64) (bool, float64, error) {
+	costs, ok := ratelimiter.StoreAs[ratelimiter.CostStore](s.store)
+	if !ok {
+		return false, 0, ratelimiter.ErrorCostUnsupported
+	}
+	var (
+		allowed bool
+		tokens  float64
+	)
+	err := s.do(ctx, func() (err error) {
+		allowed, tokens, err = costs.TakeTokens(ctx, key, n, rate, burst)
+		return err
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed, tokens, nil
+}
+
+// Decrement lowers the counter for key in the wrapped store, subject to the
+// injected faults.
+func (s *Store) Decrement(ctx context.Context, key string, n int64) error {
+	refunds, ok := ratelimiter.StoreAs[ratelimiter.RefundStore](s.store)
+	if !ok {
+		return ratelimiter.ErrorRefundUnsupported
+	}
+	return s.do(ctx, func() error {
+		return refunds.Decrement(ctx, key, n)
+	})
+}
+
+// ReturnTokens adds tokens back to the bucket for key in the wrapped store,
+// subject to the injected faults.
+func (s *Store) ReturnTokens(ctx context.Context, key string, n float64, burst int64) error {
+	refunds, ok := ratelimiter.StoreAs[ratelimiter.RefundStore](s.store)
+	if !ok {
+		return ratelimiter.ErrorRefundUnsupported
+	}
+	return s.do(ctx, func() error {
+		return refunds.ReturnTokens(ctx, key, n, burst)
+	})
+}
+
+// Reset removes the state for key from the wrapped store, if it supports it,
+// subject to the injected faults.
+func (s *Store) Reset(ctx context.Context, key string) error {
+	return s.do(ctx, func() error {
+		if resetter, ok := s.store.(ratelimiter.Resetter); ok {
+			return resetter.Reset(ctx, key)
+		}
+		return nil
+	})
+}
+
+// Ping checks the wrapped store, if it supports it, subject to the injected
+// faults.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.do(ctx, func() error {
+		if pinger, ok := s.store.(ratelimiter.Pinger); ok {
+			return pinger.Ping(ctx)
+		}
+		return nil
+	})
+}
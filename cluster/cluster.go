@@ -0,0 +1,352 @@
+// Package cluster enforces strict global limits across many instances without
+// a store round trip per request.
+//
+// One elected instance, the leader, owns a token bucket per key. The other
+// instances lease allowances (small batches of tokens) from it over gRPC and
+// spend them locally, so only one request in a batch leaves the process.
+// Allowances expire quickly, which bounds how far a crashed or partitioned
+// instance can overshoot the limit. If the leader becomes unreachable, the next
+// peer in the list takes over; its buckets start full.
+//
+// Example usage:
+//
+//	peers := []string{"10.0.0.1:7946", "10.0.0.2:7946", "10.0.0.3:7946"}
+//	node, err := cluster.NewNode(os.Getenv("SELF_ADDR"), peers, 100, 200)
+//
+//	server := grpc.NewServer()
+//	node.Register(server)
+//	go server.Serve(lis)
+//
+//	mux.Handle("/", nethttp.Middleware(node)(handler))
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// ErrorNoLeader is returned by Node.Allow when no peer can act as leader.
+var ErrorNoLeader = errors.New("no reachable rate limiter leader")
+
+// Option configures a Node.
+type Option func(*config)
+
+// config holds the settings collected from Option values.
+type config struct {
+	batch       int64
+	allowance   time.Duration
+	elector     Elector
+	dialOptions []grpc.DialOption
+	failOpen    bool
+	logger      ratelimiter.Logger
+}
+
+// WithBatch sets how many tokens an instance leases from the leader at once.
+// Larger batches mean fewer remote calls but coarser fairness between
+// instances. The default is 10.
+func WithBatch(n int64) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.batch = n
+		}
+	}
+}
+
+// WithAllowanceTTL sets how long leased tokens may be spent before they are
+// discarded. The default is one second.
+func WithAllowanceTTL(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.allowance = d
+		}
+	}
+}
+
+// WithElector replaces the default election, which picks the first peer in
+// list order that answers a ping, e.g. with one backed by a lock in etcd.
+func WithElector(e Elector) Option {
+	return func(c *config) {
+		if e != nil {
+			c.elector = e
+		}
+	}
+}
+
+// WithDialOptions sets the options used to connect to peers. The default
+// connects without transport security.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(c *config) {
+		c.dialOptions = opts
+	}
+}
+
+// WithFailOpen admits requests while no leader is reachable instead of
+// returning ErrorNoLeader.
+func WithFailOpen() Option {
+	return func(c *config) {
+		c.failOpen = true
+	}
+}
+
+// WithLogger sets the Logger used to report leader changes and failed calls.
+func WithLogger(l ratelimiter.Logger) Option {
+	return func(c *config) {
+		if l != nil {
+			c.logger = l
+		}
+	}
+}
+
+// Node is one instance of a cluster-wide limiter. It implements
+// ratelimiter.Limiter and serves allowances when it is the leader.
+type Node struct {
+	self  string
+	peers []string
+	rate  float64
+	burst int64
+	cfg   config
+
+	buckets *buckets
+
+	mu          sync.Mutex
+	allowances  map[string]*allowance
+	connections map[string]*grpc.ClientConn
+	lastSweep   time.Time
+}
+
+// allowance is the part of the global bucket leased to this instance for a key.
+type allowance struct {
+	mu        sync.Mutex
+	tokens    int64
+	expiresAt time.Time
+}
+
+// NewNode creates a Node listening as self, one of peers, that limits every
+// key to rate tokens per second with bursts of up to burst across the cluster.
+//
+// Every instance must be given the same peers, in the same order, so that they
+// agree on the leader. It returns an error wrapping
+// ratelimiter.ErrorInvalidConfig if self is not among peers or rate or burst
+// are not positive.
+func NewNode(self string, peers []string, rate float64, burst int64, opts ...Option) (*Node, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("%w: rate must be positive, got %g", ratelimiter.ErrorInvalidConfig, rate)
+	}
+	if burst < 1 {
+		return nil, fmt.Errorf("%w: burst must be at least 1, got %d", ratelimiter.ErrorInvalidConfig, burst)
+	}
+	found := false
+	for _, peer := range peers {
+		found = found || peer == self
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %q is not among the peers", ratelimiter.ErrorInvalidConfig, self)
+	}
+
+	n := &Node{
+		self:        self,
+		peers:       peers,
+		rate:        rate,
+		burst:       burst,
+		buckets:     newBuckets(rate, burst),
+		allowances:  make(map[string]*allowance),
+		connections: make(map[string]*grpc.ClientConn),
+	}
+	n.cfg = config{
+		batch:       10,
+		allowance:   time.Second,
+		dialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		logger:      noopLogger{},
+	}
+	for _, opt := range opts {
+		opt(&n.cfg)
+	}
+	if n.cfg.elector == nil {
+		n.cfg.elector = &pingElector{node: n, interval: 5 * time.Second}
+	}
+	return n, nil
+}
+
+// Allow spends a token of the local allowance for key, leasing a new batch
+// from the leader when the allowance is exhausted or expired.
+func (n *Node) Allow(ctx context.Context, key string) (ratelimiter.Result, error) {
+	n.mu.Lock()
+	n.sweep(time.Now())
+	a, ok := n.allowances[key]
+	if !ok {
+		a = &allowance{}
+		n.allowances[key] = a
+	}
+	n.mu.Unlock()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if a.tokens > 0 && now.Before(a.expiresAt) {
+		a.tokens--
+		return n.result(true, a.tokens, 0), nil
+	}
+
+	grant, err := n.lease(ctx, key)
+	if err != nil {
+		if n.cfg.failOpen {
+			n.cfg.logger.Errorf("[RateLimiter] cluster: admitting request without leader: %v", err)
+			return n.result(true, 0, 0), nil
+		}
+		return ratelimiter.Result{Allowed: false}, err
+	}
+	if grant.Granted == 0 {
+		a.tokens = 0
+		return n.result(false, 0, time.Duration(grant.RetryAfter)), nil
+	}
+
+	a.tokens, a.expiresAt = grant.Granted-1, now.Add(n.cfg.allowance)
+	return n.result(true, a.tokens, 0), nil
+}
+
+// sweep drops the allowances that have expired, at most once per allowance
+// TTL. The caller must hold n.mu.
+func (n *Node) sweep(now time.Time) {
+	if now.Sub(n.lastSweep) < n.cfg.allowance {
+		return
+	}
+	n.lastSweep = now
+
+	for key, a := range n.allowances {
+		if a.mu.TryLock() {
+			if now.After(a.expiresAt) {
+				delete(n.allowances, key)
+			}
+			a.mu.Unlock()
+		}
+	}
+}
+
+// result builds a Result from the local view of the bucket.
+func (n *Node) result(allowed bool, remaining int64, resetAfter time.Duration) ratelimiter.Result {
+	return ratelimiter.Result{
+		Allowed:         allowed,
+		Limit:           n.burst,
+		Remaining:       remaining,
+		ResetAfter:      resetAfter,
+		RemainingTokens: float64(remaining),
+		RefillInterval:  time.Duration(float64(time.Second) / n.rate),
+		RetryAt:         time.Now().Add(resetAfter),
+		Algorithm:       ratelimiter.AlgorithmTokenBucket,
+	}
+}
+
+// lease asks the leader for a batch of tokens for key.
+func (n *Node) lease(ctx context.Context, key string) (*grantResponse, error) {
+	return n.call(ctx, &grantRequest{Key: key, Tokens: n.cfg.batch})
+}
+
+// call sends req to the leader, serving it locally if this node leads. A
+// failed call triggers a new election and one retry.
+func (n *Node) call(ctx context.Context, req *grantRequest) (*grantResponse, error) {
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		leader, err := n.cfg.elector.Leader(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrorNoLeader, err)
+		}
+		if leader == n.self {
+			return n.buckets.grant(req), nil
+		}
+
+		conn, err := n.conn(leader)
+		if err != nil {
+			return nil, err
+		}
+		resp := new(grantResponse)
+		lastErr = conn.Invoke(ctx, grantMethod, req, resp, grpc.CallContentSubtype(codecName))
+		if lastErr == nil {
+			return resp, nil
+		}
+
+		n.cfg.logger.Errorf("[RateLimiter] cluster: leasing from leader %s: %v", leader, lastErr)
+		if failed, ok := n.cfg.elector.(interface{ leaderFailed(string) }); ok {
+			failed.leaderFailed(leader)
+		}
+	}
+	return nil, lastErr
+}
+
+// conn returns the client connection to peer, creating it on first use.
+func (n *Node) conn(peer string) (*grpc.ClientConn, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if conn, ok := n.connections[peer]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.NewClient(peer, n.cfg.dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	n.connections[peer] = conn
+	return conn, nil
+}
+
+// Shutdown gives the unspent tokens of every unexpired allowance back to the
+// leader, so that they are not lost for the rest of the cluster, then closes
+// the connections to the other peers. It returns the first error, or
+// ctx.Err() if ctx expires while tokens are being returned.
+func (n *Node) Shutdown(ctx context.Context) error {
+	n.mu.Lock()
+	returns := make(map[string]int64)
+	now := time.Now()
+	for key, a := range n.allowances {
+		a.mu.Lock()
+		if a.tokens > 0 && now.Before(a.expiresAt) {
+			returns[key] = a.tokens
+			a.tokens = 0
+		}
+		a.mu.Unlock()
+	}
+	n.mu.Unlock()
+
+	var firstErr error
+	for key, tokens := range returns {
+		if ctx.Err() != nil {
+			firstErr = ctx.Err()
+			break
+		}
+		if _, err := n.call(ctx, &grantRequest{Key: key, Returned: tokens}); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := n.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// Close closes the connections to the other peers.
+func (n *Node) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var firstErr error
+	for peer, conn := range n.connections {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(n.connections, peer)
+	}
+	return firstErr
+}
+
+// noopLogger is a private default logger that does nothing.
+type noopLogger struct{}
+
+func (noopLogger) Debugf(format string, args ...interface{}) {}
+func (noopLogger) Errorf(format string, args ...interface{}) {}
@@ -0,0 +1,211 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// staticElector always elects the same leader, or fails with err.
+type staticElector struct {
+	leader string
+	err    error
+}
+
+func (e staticElector) Leader(ctx context.Context) (string, error) {
+	return e.leader, e.err
+}
+
+// testCluster serves nodes over in-memory connections, keyed by address.
+type testCluster struct {
+	listeners map[string]*bufconn.Listener
+	servers   map[string]*grpc.Server
+}
+
+func newTestCluster() *testCluster {
+	return &testCluster{listeners: make(map[string]*bufconn.Listener), servers: make(map[string]*grpc.Server)}
+}
+
+// dialOption connects to the node serving the dialed address.
+func (c *testCluster) dialOption() grpc.DialOption {
+	return grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		lis, ok := c.listeners[addr]
+		if !ok {
+			return nil, errors.New("no node at " + addr)
+		}
+		return lis.DialContext(ctx)
+	})
+}
+
+// node creates the node self among peers, serving it unless it is down.
+func (c *testCluster) node(t *testing.T, self string, peers []string, burst int64, opts ...Option) *Node {
+	t.Helper()
+	opts = append([]Option{WithDialOptions(grpc.WithTransportCredentials(insecure.NewCredentials()), c.dialOption())}, opts...)
+	n, err := NewNode("passthrough:///"+self, peers, 0.001, burst, opts...)
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	t.Cleanup(func() { _ = n.Close() })
+
+	lis := bufconn.Listen(1 << 16)
+	server := grpc.NewServer()
+	n.Register(server)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+	c.listeners[self], c.servers[self] = lis, server
+	return n
+}
+
+// peers returns the addresses of the named nodes.
+func peers(names ...string) []string {
+	addrs := make([]string, len(names))
+	for i, name := range names {
+		addrs[i] = "passthrough:///" + name
+	}
+	return addrs
+}
+
+func TestNewNodeValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		self  string
+		rate  float64
+		burst int64
+	}{
+		{name: "not a peer", self: "c", rate: 1, burst: 1},
+		{name: "zero rate", self: "a", rate: 0, burst: 1},
+		{name: "zero burst", self: "a", rate: 1, burst: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewNode(tt.self, []string{"a", "b"}, tt.rate, tt.burst); !errors.Is(err, ratelimiter.ErrorInvalidConfig) {
+				t.Errorf("error = %v, want %v", err, ratelimiter.ErrorInvalidConfig)
+			}
+		})
+	}
+}
+
+func TestBucketsGrant(t *testing.T) {
+	b := newBuckets(0.001, 10)
+
+	steps := []struct {
+		name        string
+		req         grantRequest
+		wantGranted int64
+		wantRetry   bool
+	}{
+		{name: "batch", req: grantRequest{Key: "key", Tokens: 4}, wantGranted: 4},
+		{name: "rest of the burst", req: grantRequest{Key: "key", Tokens: 10}, wantGranted: 6},
+		{name: "empty", req: grantRequest{Key: "key", Tokens: 1}, wantRetry: true},
+		{name: "return only", req: grantRequest{Key: "key", Returned: 3}},
+		{name: "returned tokens", req: grantRequest{Key: "key", Tokens: 5}, wantGranted: 3},
+		{name: "other key", req: grantRequest{Key: "other", Tokens: 10}, wantGranted: 10},
+		{name: "returns capped at burst", req: grantRequest{Key: "other", Tokens: 20, Returned: 50}, wantGranted: 10},
+	}
+	for _, step := range steps {
+		resp := b.grant(&step.req)
+		if resp.Granted != step.wantGranted || (resp.RetryAfter > 0) != step.wantRetry {
+			t.Fatalf("%s: granted %d, retry after %s, want %d, retry %v",
+				step.name, resp.Granted, time.Duration(resp.RetryAfter), step.wantGranted, step.wantRetry)
+		}
+	}
+}
+
+func TestNodeLeasesFromLeader(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCluster()
+	all := peers("a", "b")
+	leader := c.node(t, "a", all, 10, WithElector(staticElector{leader: all[0]}), WithBatch(4))
+	follower := c.node(t, "b", all, 10, WithElector(staticElector{leader: all[0]}), WithBatch(4))
+
+	// The follower leases batches of 4, the leader takes them locally: the
+	// burst of 10 is shared across both.
+	allowed := 0
+	for i := range 20 {
+		node := follower
+		if i%2 == 1 {
+			node = leader
+		}
+		result, err := node.Allow(ctx, "key")
+		if err != nil {
+			t.Fatalf("Allow %d: %v", i, err)
+		}
+		if result.Allowed {
+			allowed++
+		}
+	}
+	if allowed != 10 {
+		t.Errorf("allowed %d requests across the cluster, want the burst of 10", allowed)
+	}
+}
+
+func TestNodeShutdownReturnsTokens(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCluster()
+	all := peers("a", "b")
+	leader := c.node(t, "a", all, 10, WithElector(staticElector{leader: all[0]}), WithBatch(5))
+	follower := c.node(t, "b", all, 10, WithElector(staticElector{leader: all[0]}), WithBatch(5))
+
+	if result, err := follower.Allow(ctx, "key"); err != nil || !result.Allowed {
+		t.Fatalf("Allow = %+v, %v", result, err)
+	}
+	if err := follower.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	resp := leader.buckets.grant(&grantRequest{Key: "key", Tokens: 10})
+	if resp.Granted != 9 {
+		t.Errorf("leader granted %d tokens after the follower returned its allowance, want 9", resp.Granted)
+	}
+}
+
+func TestNodeWithoutLeader(t *testing.T) {
+	ctx := context.Background()
+	elector := WithElector(staticElector{err: errors.New("lock unavailable")})
+
+	n, err := NewNode("a", []string{"a"}, 1, 1, elector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.Allow(ctx, "key"); !errors.Is(err, ErrorNoLeader) {
+		t.Errorf("Allow error = %v, want %v", err, ErrorNoLeader)
+	}
+
+	n, err = NewNode("a", []string{"a"}, 1, 1, elector, WithFailOpen())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := n.Allow(ctx, "key"); err != nil || !result.Allowed {
+		t.Errorf("Allow with fail-open = %+v, %v, want allowed", result, err)
+	}
+}
+
+func TestPingElectorFailover(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCluster()
+	all := peers("a", "b")
+	c.node(t, "a", all, 10)
+	follower := c.node(t, "b", all, 10, WithBatch(1), WithAllowanceTTL(time.Millisecond))
+	elector := follower.cfg.elector.(*pingElector)
+
+	if leader, err := elector.Leader(ctx); err != nil || leader != all[0] {
+		t.Fatalf("leader = %q, %v, want %q", leader, err, all[0])
+	}
+
+	// With the first peer gone, the failed lease triggers an election the
+	// follower wins, and its own bucket serves the request.
+	c.servers["a"].Stop()
+	if result, err := follower.Allow(ctx, "key"); err != nil || !result.Allowed {
+		t.Fatalf("Allow after the leader failed = %+v, %v", result, err)
+	}
+	if leader, _ := elector.Leader(ctx); leader != all[1] {
+		t.Errorf("leader after failover = %q, want %q", leader, all[1])
+	}
+}
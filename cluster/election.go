@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Elector decides which peer leads the cluster.
+type Elector interface {
+	// Leader returns the address of the current leader.
+	Leader(ctx context.Context) (string, error)
+}
+
+// pingElector elects the first peer, in list order, that answers a ping. A
+// node always considers itself reachable, so a node cut off from every peer
+// before it in the list leads on its own side of the partition.
+//
+// The choice is cached for interval, after which it is made again, so a
+// recovered peer earlier in the list takes leadership back.
+type pingElector struct {
+	node     *Node
+	interval time.Duration
+
+	mu        sync.Mutex
+	leader    string
+	checkedAt time.Time
+}
+
+// Leader returns the cached leader or elects a new one.
+func (e *pingElector) Leader(ctx context.Context) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.leader != "" && time.Since(e.checkedAt) < e.interval {
+		return e.leader, nil
+	}
+
+	for _, peer := range e.node.peers {
+		if peer == e.node.self || e.ping(ctx, peer) == nil {
+			if peer != e.leader {
+				e.node.cfg.logger.Debugf("[RateLimiter] cluster: %s is now the leader", peer)
+			}
+			e.leader, e.checkedAt = peer, time.Now()
+			return peer, nil
+		}
+	}
+	return "", ErrorNoLeader
+}
+
+// leaderFailed forgets leader so that the next call elects again.
+func (e *pingElector) leaderFailed(leader string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.leader == leader {
+		e.leader = ""
+	}
+}
+
+// ping checks that peer serves allowances.
+func (e *pingElector) ping(ctx context.Context, peer string) error {
+	conn, err := e.node.conn(peer)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	return conn.Invoke(ctx, pingMethod, &pingRequest{}, &pingResponse{}, grpc.CallContentSubtype(codecName))
+}
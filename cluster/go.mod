@@ -0,0 +1,18 @@
+module github.com/jassus213/go-rate-limiter/cluster
+
+go 1.25.4
+
+require (
+	github.com/jassus213/go-rate-limiter v0.0.1
+	google.golang.org/grpc v1.76.0
+)
+
+require (
+	golang.org/x/net v0.42.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/text v0.27.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250804133106-a7a43d27e69b // indirect
+	google.golang.org/protobuf v1.36.9 // indirect
+)
+
+replace github.com/jassus213/go-rate-limiter => ..
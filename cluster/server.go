@@ -0,0 +1,171 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// codecName is the gRPC content subtype of the allowance service. Messages are
+// encoded as JSON so that no generated protobuf code is needed.
+const codecName = "ratelimit-json"
+
+const (
+	serviceName = "ratelimiter.cluster.v1.Allowance"
+	grantMethod = "/" + serviceName + "/Grant"
+	pingMethod  = "/" + serviceName + "/Ping"
+)
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec is the gRPC codec used by the allowance service.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                       { return codecName }
+
+// grantRequest asks the leader for up to Tokens tokens for Key, after giving
+// back Returned unspent tokens.
+type grantRequest struct {
+	Key      string `json:"key"`
+	Tokens   int64  `json:"tokens"`
+	Returned int64  `json:"returned,omitempty"`
+}
+
+// grantResponse carries the tokens granted and, when none were, how long to
+// wait for the next one.
+type grantResponse struct {
+	Granted    int64 `json:"granted"`
+	RetryAfter int64 `json:"retry_after_ns,omitempty"`
+}
+
+type pingRequest struct{}
+
+type pingResponse struct{}
+
+// allowanceServer is the handler type of the allowance service.
+type allowanceServer interface {
+	grant(ctx context.Context, req *grantRequest) (*grantResponse, error)
+}
+
+// Register registers the allowance service on server, so that peers can lease
+// tokens from this node when it leads.
+func (n *Node) Register(server *grpc.Server) {
+	server.RegisterService(&serviceDesc, n)
+}
+
+// grant serves a lease request from a peer.
+func (n *Node) grant(ctx context.Context, req *grantRequest) (*grantResponse, error) {
+	return n.buckets.grant(req), nil
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*allowanceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Grant", Handler: grantHandler},
+		{MethodName: "Ping", Handler: pingHandler},
+	},
+	Metadata: "cluster",
+}
+
+func grantHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	req := new(grantRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(allowanceServer).grant(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: grantMethod}
+	return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+		return srv.(allowanceServer).grant(ctx, req.(*grantRequest))
+	})
+}
+
+func pingHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	req := new(pingRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return &pingResponse{}, nil
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: pingMethod}
+	return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+		return &pingResponse{}, nil
+	})
+}
+
+// buckets holds the global token buckets owned by the leader.
+type buckets struct {
+	rate  float64
+	burst int64
+
+	mu        sync.Mutex
+	entries   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket is the state of one global token bucket.
+type bucket struct {
+	tokens      float64
+	lastUpdated time.Time
+}
+
+// newBuckets creates empty buckets refilling at rate up to burst.
+func newBuckets(rate float64, burst int64) *buckets {
+	return &buckets{rate: rate, burst: burst, entries: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+// grant takes up to req.Tokens whole tokens from the bucket for req.Key.
+func (b *buckets) grant(req *grantRequest) *grantResponse {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.sweep(now)
+
+	e, ok := b.entries[req.Key]
+	if !ok {
+		e = &bucket{tokens: float64(b.burst), lastUpdated: now}
+		b.entries[req.Key] = e
+	}
+	e.tokens = min(float64(b.burst), e.tokens+now.Sub(e.lastUpdated).Seconds()*b.rate+float64(max(req.Returned, 0)))
+	e.lastUpdated = now
+	if req.Tokens <= 0 {
+		return &grantResponse{}
+	}
+
+	granted := min(req.Tokens, int64(e.tokens))
+	if granted <= 0 {
+		wait := (1 - e.tokens) / b.rate
+		return &grantResponse{RetryAfter: int64(wait * float64(time.Second))}
+	}
+	e.tokens -= float64(granted)
+	return &grantResponse{Granted: granted}
+}
+
+// sweep drops the buckets that have refilled completely, since a new bucket
+// starts full anyway. It runs at most once per time needed to refill a bucket.
+// The caller must hold b.mu.
+func (b *buckets) sweep(now time.Time) {
+	fill := time.Duration(float64(b.burst) / b.rate * float64(time.Second))
+	if now.Sub(b.lastSweep) < fill {
+		return
+	}
+	b.lastSweep = now
+
+	for key, e := range b.entries {
+		if e.tokens+now.Sub(e.lastUpdated).Seconds()*b.rate >= float64(b.burst) {
+			delete(b.entries, key)
+		}
+	}
+}
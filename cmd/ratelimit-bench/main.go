@@ -0,0 +1,269 @@
+// Command ratelimit-bench drives concurrent load against a limiter and store
+// combination and reports throughput, latency percentiles and accuracy, so
+// that Redis can be sized and algorithms chosen with data.
+//
+// Accuracy compares the requests admitted for each key with the number an
+// ideal limiter would admit when the key is saturated for the whole run:
+// burst + rate × duration for token buckets and limit × the number of windows
+// the run spans for windowed algorithms. Admitting more is over-admission;
+// admitting fewer, although the key was sent enough requests, is
+// under-admission. Keys that are not saturated only show under-admission if
+// requests were wrongly denied.
+//
+// Stores are "memory", "redis", and "tiered", the latter enforcing limits in
+// memory and reconciling them with Redis as store.NewMultiRegion does.
+//
+// Usage:
+//
+//	ratelimit-bench -store memory -algorithm token_bucket -rate 100 -burst 200 -keys 1000 -concurrency 64 -duration 30s
+//	ratelimit-bench -store redis -redis redis://localhost:6379/0 -algorithm fixed_window -limit 100 -window 1s
+//	ratelimit-bench -store tiered -algorithm token_bucket -rate 10 -burst 10 -keys 10
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	mrand "math/rand/v2"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	"github.com/jassus213/go-rate-limiter/store"
+	"github.com/redis/go-redis/v9"
+)
+
+// config holds the command-line flags.
+type config struct {
+	store       string
+	redisURL    string
+	spec        ratelimiter.Spec
+	keys        int
+	concurrency int
+	duration    time.Duration
+}
+
+// keyCounts counts the requests sent and admitted for one key.
+type keyCounts struct {
+	attempted int64
+	admitted  int64
+}
+
+// workerStats is what one worker measured.
+type workerStats struct {
+	latencies []time.Duration
+	errors    int64
+	keys      []keyCounts
+}
+
+func main() {
+	var cfg config
+	var window time.Duration
+	flag.StringVar(&cfg.store, "store", "memory", "store: memory, redis, or tiered")
+	flag.StringVar(&cfg.redisURL, "redis", "redis://localhost:6379/0", "Redis URL for the redis and tiered stores")
+	flag.StringVar(&cfg.spec.Algorithm, "algorithm", ratelimiter.AlgorithmTokenBucket, "algorithm, as registered with ratelimiter.RegisterAlgorithm")
+	flag.Int64Var(&cfg.spec.Limit, "limit", 100, "requests per window for windowed algorithms")
+	flag.DurationVar(&window, "window", time.Second, "window for windowed algorithms")
+	flag.Float64Var(&cfg.spec.Rate, "rate", 100, "tokens per second for token buckets")
+	flag.Int64Var(&cfg.spec.Burst, "burst", 100, "bucket capacity for token buckets")
+	flag.IntVar(&cfg.keys, "keys", 100, "number of distinct keys")
+	flag.IntVar(&cfg.concurrency, "concurrency", 32, "number of concurrent clients")
+	flag.DurationVar(&cfg.duration, "duration", 10*time.Second, "length of the run")
+	flag.Parse()
+
+	if cfg.keys < 1 || cfg.concurrency < 1 || cfg.duration <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cfg.spec.Name = "bench"
+	cfg.spec.Window = ratelimiter.Duration(window)
+	cfg.spec.KeyPrefix = runPrefix()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, cfg); err != nil {
+		log.Fatalf("ratelimit-bench: %v", err)
+	}
+}
+
+// runPrefix returns a key prefix unique to this run, so that runs against a
+// shared Redis do not see each other's state.
+func runPrefix() string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return "bench:" + hex.EncodeToString(b[:]) + ":"
+}
+
+// run benchmarks the configured limiter and prints the report.
+func run(ctx context.Context, cfg config) error {
+	s, err := open(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closer, ok := s.(ratelimiter.Closer); ok {
+			_ = closer.Close(context.Background())
+		}
+	}()
+
+	limiter, err := cfg.spec.Build(s)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+
+	stats := make([]workerStats, cfg.concurrency)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := range stats {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stats[i] = work(ctx, limiter, cfg.keys)
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	report(cfg, stats, elapsed)
+	return nil
+}
+
+// open returns the store named by cfg.store.
+func open(ctx context.Context, cfg config) (ratelimiter.Store, error) {
+	if cfg.store == "memory" {
+		return store.NewMemory(ctx, time.Minute), nil
+	}
+	if cfg.store != "redis" && cfg.store != "tiered" {
+		return nil, fmt.Errorf("unknown store %q", cfg.store)
+	}
+
+	options, err := redis.ParseURL(cfg.redisURL)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("connecting to Redis: %w", err)
+	}
+	if cfg.store == "redis" {
+		return store.NewRedis(client), nil
+	}
+	return store.NewMultiRegion(ctx, store.NewMemory(ctx, time.Minute), store.NewRedis(client)), nil
+}
+
+// work sends requests for random keys until ctx is done.
+func work(ctx context.Context, limiter ratelimiter.Limiter, keys int) workerStats {
+	stats := workerStats{keys: make([]keyCounts, keys)}
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = strconv.Itoa(i)
+	}
+
+	for ctx.Err() == nil {
+		k := mrand.IntN(keys)
+		begin := time.Now()
+		result, err := limiter.Allow(ctx, names[k])
+		latency := time.Since(begin)
+		if err != nil {
+			if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+				stats.errors++
+			}
+			continue
+		}
+		stats.latencies = append(stats.latencies, latency)
+		stats.keys[k].attempted++
+		if result.Allowed {
+			stats.keys[k].admitted++
+		}
+	}
+	return stats
+}
+
+// ideal returns the number of requests an exact limiter admits for a key
+// saturated during elapsed, or -1 if it is unknown for the algorithm.
+func ideal(spec ratelimiter.Spec, elapsed time.Duration) float64 {
+	switch spec.Algorithm {
+	case ratelimiter.AlgorithmTokenBucket:
+		return float64(spec.Burst) + spec.Rate*elapsed.Seconds()
+	case ratelimiter.AlgorithmFixedWindow, ratelimiter.AlgorithmSlidingLog:
+		windows := math.Ceil(elapsed.Seconds() / time.Duration(spec.Window).Seconds())
+		return float64(spec.Limit) * windows
+	default:
+		return -1
+	}
+}
+
+// report prints the results of a run.
+func report(cfg config, stats []workerStats, elapsed time.Duration) {
+	var (
+		latencies []time.Duration
+		errs      int64
+		keys      = make([]keyCounts, cfg.keys)
+	)
+	for _, s := range stats {
+		latencies = append(latencies, s.latencies...)
+		errs += s.errors
+		for k, c := range s.keys {
+			keys[k].attempted += c.attempted
+			keys[k].admitted += c.admitted
+		}
+	}
+	slices.Sort(latencies)
+
+	var attempted, admitted int64
+	for _, c := range keys {
+		attempted += c.attempted
+		admitted += c.admitted
+	}
+
+	fmt.Printf("store=%s algorithm=%s keys=%d concurrency=%d duration=%s\n",
+		cfg.store, cfg.spec.Algorithm, cfg.keys, cfg.concurrency, elapsed.Round(time.Millisecond))
+	fmt.Printf("requests: %d (%.0f/s), admitted: %d, errors: %d\n",
+		attempted, float64(attempted)/elapsed.Seconds(), admitted, errs)
+	if len(latencies) > 0 {
+		fmt.Printf("latency: p50=%s p90=%s p99=%s p99.9=%s max=%s\n",
+			percentile(latencies, 0.5), percentile(latencies, 0.9), percentile(latencies, 0.99),
+			percentile(latencies, 0.999), latencies[len(latencies)-1])
+	}
+
+	perKey := ideal(cfg.spec, elapsed)
+	if perKey < 0 {
+		fmt.Println("accuracy: unknown for this algorithm")
+		return
+	}
+	var over, under float64
+	for _, c := range keys {
+		expected := math.Min(perKey, float64(c.attempted))
+		over += math.Max(float64(c.admitted)-perKey, 0)
+		under += math.Max(expected-float64(c.admitted), 0)
+	}
+	fmt.Printf("accuracy: over-admitted %.0f (%.2f%%), under-admitted %.0f (%.2f%%) of %d admitted\n",
+		over, share(over, admitted), under, share(under, admitted), admitted)
+}
+
+// percentile returns the p-th percentile of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// share returns n as a percentage of total.
+func share(n float64, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * n / float64(total)
+}
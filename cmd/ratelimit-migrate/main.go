@@ -0,0 +1,136 @@
+// Command ratelimit-migrate copies rate limiter state between stores, so that
+// infrastructure can be migrated without resetting every client's quota.
+//
+// Stores are given as Redis URLs or as paths to snapshot files in the format
+// of store.Export: CSV if the file name ends in ".csv", JSON otherwise. JSON
+// arrays of ratelimiter.KeyState values are also accepted as a source.
+//
+// Usage:
+//
+//	ratelimit-migrate -from redis://old:6379/0 -to redis://new:6379/0 -pattern 'api:*'
+//	ratelimit-migrate -from snapshot.json -to redis://new:6379/0 -remap api:=api:v2:
+//	ratelimit-migrate -from redis://old:6379/0 -to backup.json
+//	ratelimit-migrate -from redis://old:6379/0 -to audit.csv
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	"github.com/jassus213/go-rate-limiter/store"
+	"github.com/redis/go-redis/v9"
+)
+
+func main() {
+	from := flag.String("from", "", "source store: Redis URL or snapshot file")
+	to := flag.String("to", "", "destination store: Redis URL or snapshot file")
+	pattern := flag.String("pattern", "*", "copy only keys matching this pattern")
+	batch := flag.Int("batch", 500, "keys written per batch")
+	var opts []store.MigrateOption
+	flag.Func("remap", "rewrite a key prefix, as from=to; may be repeated", func(v string) error {
+		old, replacement, ok := strings.Cut(v, "=")
+		if !ok {
+			return errors.New("expected from=to")
+		}
+		opts = append(opts, store.WithPrefixRemap(old, replacement))
+		return nil
+	})
+	flag.Parse()
+
+	if *from == "" || *to == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	opts = append(opts, store.WithMigratePattern(*pattern), store.WithMigrateBatchSize(*batch))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	n, err := run(ctx, *from, *to, opts)
+	if err != nil {
+		log.Fatalf("ratelimit-migrate: %v", err)
+	}
+	log.Printf("ratelimit-migrate: copied %d keys", n)
+}
+
+// run copies the state of from into to.
+func run(ctx context.Context, from, to string, opts []store.MigrateOption) (int, error) {
+	src, err := open(ctx, from, true)
+	if err != nil {
+		return 0, err
+	}
+	dst, err := open(ctx, to, false)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := store.Migrate(ctx, src, dst, opts...)
+	if err != nil {
+		return n, err
+	}
+	if isRedisURL(to) {
+		return n, nil
+	}
+	return n, writeSnapshot(ctx, dst, to)
+}
+
+// isRedisURL reports whether target names a Redis server rather than a file.
+func isRedisURL(target string) bool {
+	return strings.HasPrefix(target, "redis://") || strings.HasPrefix(target, "rediss://")
+}
+
+// snapshotFormat returns the export format of the snapshot file at path.
+func snapshotFormat(path string) store.Format {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return store.FormatCSV
+	}
+	return store.FormatJSON
+}
+
+// open returns the store named by target. Snapshot files are loaded into a
+// MemoryStore if they are a source and written from one afterwards otherwise.
+func open(ctx context.Context, target string, source bool) (ratelimiter.Store, error) {
+	if isRedisURL(target) {
+		options, err := redis.ParseURL(target)
+		if err != nil {
+			return nil, err
+		}
+		return store.NewRedis(redis.NewClient(options)), nil
+	}
+
+	mem := store.NewMemory(ctx, 0)
+	if !source {
+		return mem, nil
+	}
+
+	f, err := os.Open(target)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := store.Import(ctx, f, mem, snapshotFormat(target)); err != nil {
+		return nil, fmt.Errorf("reading snapshot %s: %w", target, err)
+	}
+	return mem, nil
+}
+
+// writeSnapshot writes the state held by s to path.
+func writeSnapshot(ctx context.Context, s ratelimiter.Store, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := store.Export(ctx, s, f, snapshotFormat(path)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,200 @@
+// Package configmap hot-reloads limiter policies from files, such as a
+// Kubernetes ConfigMap or Secret mounted into the pod.
+//
+// Kubernetes updates mounted ConfigMaps in place by swapping a symlink, so
+// the files are polled and compared by checksum rather than watched for
+// events. When the content changes, the policies are decoded and applied to a
+// ratelimiter.Manager as a whole; an invalid update is logged and ignored,
+// leaving the previous limits in force.
+//
+// Two layouts are supported:
+//
+//   - a file holding a JSON array of ratelimiter.Spec values
+//   - a directory holding one JSON ratelimiter.Spec per file, as produced by
+//     mounting a ConfigMap with one key per policy; the policy name defaults to
+//     the file name without its .json extension
+//
+// Example usage:
+//
+//	manager := ratelimiter.NewManager()
+//	go configmap.Watch(ctx, "/etc/ratelimit", manager, store)
+package configmap
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// Option configures Watch.
+type Option func(*config)
+
+// config holds the settings collected from Option values.
+type config struct {
+	interval time.Duration
+	logger   ratelimiter.Logger
+}
+
+// WithInterval sets how often the files are checked for changes. The default
+// is ten seconds.
+func WithInterval(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.interval = d
+		}
+	}
+}
+
+// WithLogger sets the Logger used to report applied and rejected updates.
+func WithLogger(l ratelimiter.Logger) Option {
+	return func(c *config) {
+		if l != nil {
+			c.logger = l
+		}
+	}
+}
+
+// Load reads the policies stored at path, which may be a file or a directory.
+func Load(path string) ([]ratelimiter.Spec, error) {
+	specs, _, err := load(path)
+	return specs, err
+}
+
+// Watch applies the policies stored at path to manager, building their
+// limiters on top of store, and re-applies them whenever the content changes.
+// Policies that disappear from path are removed from manager.
+//
+// The initial load must succeed; Watch returns its error otherwise. Later
+// failures are logged and retried on the next check. Watch then blocks until
+// ctx is canceled and returns nil.
+func Watch(ctx context.Context, path string, manager *ratelimiter.Manager, store ratelimiter.Store, opts ...Option) error {
+	cfg := config{interval: 10 * time.Second, logger: noopLogger{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	specs, checksum, err := load(path)
+	if err != nil {
+		return err
+	}
+	if err := manager.Apply(store, specs...); err != nil {
+		return err
+	}
+	applied := names(specs)
+
+	ticker := time.NewTicker(cfg.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+
+		specs, sum, err := load(path)
+		if err != nil {
+			cfg.logger.Errorf("[RateLimiter] Failed to load policies from %s: %v", path, err)
+			continue
+		}
+		if sum == checksum {
+			continue
+		}
+		if err := manager.Apply(store, specs...); err != nil {
+			cfg.logger.Errorf("[RateLimiter] Rejected policy update from %s: %v", path, err)
+			continue
+		}
+
+		current := names(specs)
+		for name := range applied {
+			if !current[name] {
+				manager.Remove(name)
+			}
+		}
+		applied, checksum = current, sum
+		cfg.logger.Debugf("[RateLimiter] Applied %d policies from %s", len(specs), path)
+	}
+}
+
+// load reads the policies stored at path together with a checksum of the
+// content they were decoded from.
+func load(path string) ([]ratelimiter.Spec, [sha256.Size]byte, error) {
+	var checksum [sha256.Size]byte
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, checksum, err
+	}
+
+	if !info.IsDir() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, checksum, err
+		}
+		var specs []ratelimiter.Spec
+		if err := json.Unmarshal(data, &specs); err != nil {
+			return nil, checksum, fmt.Errorf("%s: %w", path, err)
+		}
+		return specs, sha256.Sum256(data), nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, checksum, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	h := sha256.New()
+	var specs []ratelimiter.Spec
+	for _, entry := range entries {
+		// Kubernetes keeps its bookkeeping in hidden entries such as ..data.
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		file := filepath.Join(path, entry.Name())
+		if info, err := os.Stat(file); err != nil || info.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, checksum, err
+		}
+		var spec ratelimiter.Spec
+		if err := json.Unmarshal(data, &spec); err != nil {
+			return nil, checksum, fmt.Errorf("%s: %w", file, err)
+		}
+		if spec.Name == "" {
+			spec.Name = strings.TrimSuffix(entry.Name(), ".json")
+		}
+		specs = append(specs, spec)
+
+		h.Write([]byte(entry.Name()))
+		h.Write(data)
+	}
+	copy(checksum[:], h.Sum(nil))
+	return specs, checksum, nil
+}
+
+// names returns the set of policy names in specs.
+func names(specs []ratelimiter.Spec) map[string]bool {
+	set := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		set[spec.Name] = true
+	}
+	return set
+}
+
+// noopLogger is a private default logger that does nothing.
+type noopLogger struct{}
+
+func (noopLogger) Debugf(format string, args ...interface{}) {}
+func (noopLogger) Errorf(format string, args ...interface{}) {}
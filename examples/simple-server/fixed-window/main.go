@@ -29,7 +29,10 @@ func main() {
 	limiterStore := store.NewMemory(ctx, 10*time.Minute)
 
 	// --- Step 3: Create a limiter instance ---
-	limiter := ratelimiter.NewFixedWindow(limiterStore, 5, time.Minute)
+	limiter, err := ratelimiter.NewFixedWindow(limiterStore, 5, time.Minute)
+	if err != nil {
+		log.Fatalf("Failed to create limiter: %v", err)
+	}
 
 	// --- Step 4: Set up and run the Gin server ---
 	router := gin.Default()
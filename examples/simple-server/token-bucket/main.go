@@ -43,7 +43,10 @@ func main() {
 	// is limited to a sustained 5 requests per second.
 	const rate = 5.0
 	const burst = 20
-	limiter := ratelimiter.NewTokenBucket(limiterStore, rate, burst)
+	limiter, err := ratelimiter.NewTokenBucket(limiterStore, rate, burst)
+	if err != nil {
+		log.Fatalf("Failed to create limiter: %v", err)
+	}
 	log.Printf("Token Bucket Limiter configured: rate=%.2f/s, burst=%d", rate, burst)
 
 	// --- Step 4: Set up Gin server and apply the middleware ---
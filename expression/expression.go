@@ -0,0 +1,181 @@
+// Package expression builds rate limiter keys, costs, skip conditions, and
+// rule matchers from expressions written in configuration, using the
+// expr-lang/expr engine, so that policies can be changed by operators without
+// code changes.
+//
+// Expressions see a single variable, request, with the following fields:
+//
+//   - method, path, host: from the request line
+//   - remote_addr: the peer address; ip: its host part
+//   - header: the first value of every header, by canonical name
+//   - query: the first value of every query parameter
+//   - content_length: the declared body size, or -1 if unknown
+//
+// Example usage:
+//
+//	key, err := expression.Key(`request.header["X-Org"] + ":" + request.path`)
+//	cost, err := expression.Cost(`request.method == "POST" ? 5 : 1`)
+//	skip, err := expression.Skip(`request.path startsWith "/healthz"`)
+//
+//	handler := nethttp.Middleware(limiter,
+//	    ratelimiter.WithKeyFuncCtx(key),
+//	    ratelimiter.WithCostFunc(cost),
+//	    ratelimiter.WithEnabledFunc(skip),
+//	)(mux)
+package expression
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// Request is the view of an HTTP request exposed to expressions as request.
+type Request struct {
+	Method        string            `expr:"method"`
+	Path          string            `expr:"path"`
+	Host          string            `expr:"host"`
+	RemoteAddr    string            `expr:"remote_addr"`
+	IP            string            `expr:"ip"`
+	Header        map[string]string `expr:"header"`
+	Query         map[string]string `expr:"query"`
+	ContentLength int64             `expr:"content_length"`
+}
+
+// env is the environment expressions are compiled and run against.
+type env struct {
+	Request Request `expr:"request"`
+}
+
+// newEnv builds the environment describing r.
+func newEnv(r *http.Request) env {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	header := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		if len(values) > 0 {
+			header[name] = values[0]
+		}
+	}
+
+	query := make(map[string]string)
+	for name, values := range r.URL.Query() {
+		if len(values) > 0 {
+			query[name] = values[0]
+		}
+	}
+
+	return env{Request: Request{
+		Method:        r.Method,
+		Path:          r.URL.Path,
+		Host:          r.Host,
+		RemoteAddr:    r.RemoteAddr,
+		IP:            ip,
+		Header:        header,
+		Query:         query,
+		ContentLength: r.ContentLength,
+	}}
+}
+
+// compile compiles source for the given result type.
+func compile(source string, opts ...expr.Option) (*vm.Program, error) {
+	program, err := expr.Compile(source, append([]expr.Option{expr.Env(env{})}, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: expression %q: %v", ratelimiter.ErrorInvalidConfig, source, err)
+	}
+	return program, nil
+}
+
+// Key compiles source, which must evaluate to a string, into a key function.
+//
+// Example:
+//
+//	key, err := expression.Key(`request.header["X-Org"] + ":" + request.path`)
+func Key(source string) (ratelimiter.KeyFuncCtx, error) {
+	program, err := compile(source, expr.AsKind(reflect.String))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, r *http.Request) (string, error) {
+		out, err := expr.Run(program, newEnv(r))
+		if err != nil {
+			return "", err
+		}
+		return out.(string), nil
+	}, nil
+}
+
+// Cost compiles source, which must evaluate to an integer, into a cost function.
+// Costs below one are reported as errors.
+//
+// Example:
+//
+//	cost, err := expression.Cost(`request.content_length > 1048576 ? 10 : 1`)
+func Cost(source string) (ratelimiter.CostFunc, error) {
+	program, err := compile(source, expr.AsInt64())
+	if err != nil {
+		return nil, err
+	}
+
+	return func(r *http.Request) (int64, error) {
+		out, err := expr.Run(program, newEnv(r))
+		if err != nil {
+			return 0, err
+		}
+		cost := out.(int64)
+		if cost < 1 {
+			return 0, fmt.Errorf("expression %q returned cost %d", source, cost)
+		}
+		return cost, nil
+	}, nil
+}
+
+// Skip compiles source, which must evaluate to a boolean, into an EnabledFunc
+// that exempts requests for which the expression is true. Requests for which
+// evaluation fails are rate limited.
+//
+// Example:
+//
+//	skip, err := expression.Skip(`request.ip in ["10.0.0.1", "10.0.0.2"]`)
+func Skip(source string) (ratelimiter.EnabledFunc, error) {
+	match, err := Match(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, r *http.Request) bool {
+		return !match(r)
+	}, nil
+}
+
+// Match compiles source, which must evaluate to a boolean, into a rule Matcher.
+// Requests for which evaluation fails do not match.
+//
+// Example:
+//
+//	match, err := expression.Match(`request.method == "POST" && request.path matches "^/api/v[12]/upload"`)
+//	rule := ratelimiter.Rule{Name: "uploads", Match: match, Limiter: uploads}
+func Match(source string) (ratelimiter.Matcher, error) {
+	program, err := compile(source, expr.AsBool())
+	if err != nil {
+		return nil, err
+	}
+
+	return func(r *http.Request) bool {
+		out, err := expr.Run(program, newEnv(r))
+		if err != nil {
+			return false
+		}
+		return out.(bool)
+	}, nil
+}
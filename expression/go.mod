@@ -0,0 +1,10 @@
+module github.com/jassus213/go-rate-limiter/expression
+
+go 1.25.4
+
+require (
+	github.com/expr-lang/expr v1.17.5
+	github.com/jassus213/go-rate-limiter v0.0.1
+)
+
+replace github.com/jassus213/go-rate-limiter => ..
@@ -4,7 +4,10 @@ go 1.23.0
 
 toolchain go1.25.4
 
-require github.com/redis/go-redis/v9 v9.16.0
+require (
+	github.com/alicebob/miniredis/v2 v2.39.0
+	github.com/redis/go-redis/v9 v9.16.0
+)
 
 require (
 	github.com/bytedance/sonic v1.14.0 // indirect
@@ -31,6 +34,7 @@ require (
 	github.com/quic-go/quic-go v0.54.0 // indirect
 	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
 	github.com/ugorji/go/codec v1.3.0 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 	go.uber.org/mock v0.5.0 // indirect
 	golang.org/x/arch v0.20.0 // indirect
 	golang.org/x/crypto v0.40.0 // indirect
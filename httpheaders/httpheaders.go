@@ -0,0 +1,256 @@
+// Package httpheaders writes rate-limit response headers for results produced
+// by github.com/jassus213/go-rate-limiter.
+//
+// It is used by the bundled middleware and is meant for custom middleware and
+// for code calling a Limiter directly, so that header formatting does not have
+// to be reimplemented.
+//
+// Two formats are supported:
+//
+//   - Legacy: X-RateLimit-Limit, X-RateLimit-Remaining, and X-RateLimit-Reset
+//     (Unix timestamp), as emitted by GitHub, Twitter, and most APIs
+//   - Draft: RateLimit-Limit, RateLimit-Remaining, and RateLimit-Reset (seconds
+//     until reset), as defined by the IETF RateLimit header fields draft
+//
+// Example usage:
+//
+//	result, err := limiter.Allow(ctx, key)
+//	httpheaders.Set(w, result, httpheaders.WithFormat(httpheaders.Both))
+package httpheaders
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// ScopeHeader is the response header naming the limit a response was checked
+// against; see WithScope.
+const ScopeHeader = "X-RateLimit-Scope"
+
+// Format selects which rate-limit headers Set writes.
+type Format int
+
+const (
+	// Legacy writes the X-RateLimit-* headers. It is the default.
+	Legacy Format = iota
+	// Draft writes the RateLimit-* headers of the IETF draft.
+	Draft
+	// Both writes the legacy and the draft headers.
+	Both
+)
+
+// Option configures Set.
+type Option func(*options)
+
+// options holds the settings collected from Option values.
+type options struct {
+	format Format
+	rule   string
+	scope  bool
+	now    func() time.Time
+
+	// values caches header values across the calls sharing these options.
+	values *valueCache
+}
+
+// WithFormat selects the header format.
+func WithFormat(f Format) Option {
+	return func(o *options) {
+		o.format = f
+	}
+}
+
+// WithRule reports the name of the matched rule in the X-RateLimit-Rule header.
+// An empty name writes nothing.
+func WithRule(name string) Option {
+	return func(o *options) {
+		o.rule = name
+	}
+}
+
+// WithScope writes the X-RateLimit-Scope header naming the limit that applied:
+// the matched rule set with WithRule or, failing that, the limiter's policy
+// name (see ratelimiter.WithName). Nothing is written for unnamed limits.
+//
+// It lets client developers and support engineers tell which limit they hit,
+// e.g. "login" or "per-org".
+func WithScope() Option {
+	return func(o *options) {
+		o.scope = true
+	}
+}
+
+// Set writes the rate-limit headers describing result to w.
+//
+// Example:
+//
+//	httpheaders.Set(w, result)
+//	httpheaders.Set(w, result, httpheaders.WithFormat(httpheaders.Draft))
+func Set(w http.ResponseWriter, result ratelimiter.Result, opts ...Option) {
+	o := newOptions(opts)
+	o.write(w, result)
+}
+
+// Writer returns a ratelimiter.HeaderWriter that writes headers with Set and
+// the given options, for use with ratelimiter.WithHeaderWriter.
+//
+// Options are applied once, and header values are cached across requests, so
+// that writing headers for results with fewer than 1024 remaining units does
+// not allocate. The header value slices are shared between responses: replace
+// them, e.g. with http.Header.Set, rather than modifying them in place.
+//
+// Example:
+//
+//	handler := nethttp.Middleware(limiter,
+//	    ratelimiter.WithHeaderWriter(httpheaders.Writer(httpheaders.WithFormat(httpheaders.Both))),
+//	)(mux)
+func Writer(opts ...Option) ratelimiter.HeaderWriter {
+	base := newOptions(opts)
+
+	return func(w http.ResponseWriter, result ratelimiter.Result, rule string) {
+		o := base
+		o.rule = rule
+		o.write(w, result)
+	}
+}
+
+// valueCache holds the header values last written, which are the common case
+// for limits, resets, rules, and scopes.
+type valueCache struct {
+	limit, reset, draftReset intCache
+	rule, scope              stringCache
+}
+
+// headerValue is a header value slice of length and capacity one, so that
+// appending to it never modifies it.
+func headerValue(s string) []string {
+	return []string{s}
+}
+
+// smallIntCount is the number of integers, from zero, whose header values are
+// allocated once and shared.
+const smallIntCount = 1024
+
+// smallInts returns the header values of the integers below smallIntCount.
+var smallInts = sync.OnceValue(func() [][]string {
+	values := make([][]string, smallIntCount)
+	for i := range values {
+		values[i] = headerValue(strconv.Itoa(i))
+	}
+	return values
+})
+
+// intValue returns the header value of n.
+func intValue(n int64) []string {
+	if n >= 0 && n < smallIntCount {
+		return smallInts()[n]
+	}
+	return headerValue(strconv.FormatInt(n, 10))
+}
+
+// intCache remembers the header value of the last integer formatted.
+type intCache struct {
+	last atomic.Pointer[cachedInt]
+}
+
+// cachedInt is an integer together with its header value.
+type cachedInt struct {
+	n     int64
+	value []string
+}
+
+// value returns the header value of n.
+func (c *intCache) value(n int64) []string {
+	if n >= 0 && n < smallIntCount {
+		return intValue(n)
+	}
+	if f := c.last.Load(); f != nil && f.n == n {
+		return f.value
+	}
+	f := &cachedInt{n: n, value: intValue(n)}
+	c.last.Store(f)
+	return f.value
+}
+
+// stringCache remembers the header value of the last string written.
+type stringCache struct {
+	last atomic.Pointer[[]string]
+}
+
+// value returns the header value of s.
+func (c *stringCache) value(s string) []string {
+	if v := c.last.Load(); v != nil && (*v)[0] == s {
+		return *v
+	}
+	v := headerValue(s)
+	c.last.Store(&v)
+	return v
+}
+
+// Canonical header names, so that writing them skips canonicalization.
+var (
+	legacyLimitHeader     = http.CanonicalHeaderKey("X-RateLimit-Limit")
+	legacyRemainingHeader = http.CanonicalHeaderKey("X-RateLimit-Remaining")
+	legacyResetHeader     = http.CanonicalHeaderKey("X-RateLimit-Reset")
+	draftLimitHeader      = http.CanonicalHeaderKey("RateLimit-Limit")
+	draftRemainingHeader  = http.CanonicalHeaderKey("RateLimit-Remaining")
+	draftResetHeader      = http.CanonicalHeaderKey("RateLimit-Reset")
+	ruleHeader            = http.CanonicalHeaderKey(ratelimiter.RuleHeader)
+	scopeHeader           = http.CanonicalHeaderKey(ScopeHeader)
+)
+
+// newOptions applies opts on top of the defaults.
+func newOptions(opts []Option) options {
+	o := options{format: Legacy, now: time.Now, values: &valueCache{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// write writes the headers describing result to w.
+//
+// Values are stored directly in the header map, bypassing the
+// canonicalization and allocation of http.Header.Set.
+func (o *options) write(w http.ResponseWriter, result ratelimiter.Result) {
+	h := w.Header()
+	limit := o.values.limit.value(result.Limit)
+	remaining := intValue(result.Remaining)
+
+	if o.format == Legacy || o.format == Both {
+		retryAt := result.RetryAt
+		if retryAt.IsZero() {
+			retryAt = o.now().Add(result.ResetAfter)
+		}
+		h[legacyLimitHeader] = limit
+		h[legacyRemainingHeader] = remaining
+		h[legacyResetHeader] = o.values.reset.value(retryAt.Unix())
+	}
+
+	if o.format == Draft || o.format == Both {
+		reset := int64(math.Ceil(result.ResetAfter.Seconds()))
+		h[draftLimitHeader] = limit
+		h[draftRemainingHeader] = remaining
+		h[draftResetHeader] = o.values.draftReset.value(max(reset, 0))
+	}
+
+	if o.rule != "" {
+		h[ruleHeader] = o.values.rule.value(o.rule)
+	}
+
+	if o.scope {
+		scope := o.rule
+		if scope == "" {
+			scope = result.Policy
+		}
+		if scope != "" {
+			h[scopeHeader] = o.values.scope.value(scope)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package httpheaders
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// headerRecorder is a ResponseWriter whose header map is reused across
+// responses, as a server reusing connections would.
+type headerRecorder struct {
+	header http.Header
+}
+
+func (r *headerRecorder) Header() http.Header         { return r.header }
+func (r *headerRecorder) Write(b []byte) (int, error) { return len(b), nil }
+func (r *headerRecorder) WriteHeader(int)             {}
+
+func newHeaderRecorder() *headerRecorder {
+	return &headerRecorder{header: make(http.Header)}
+}
+
+// allowedResult is the result of a request allowed by a fixed window limiter.
+func allowedResult() ratelimiter.Result {
+	return ratelimiter.Result{
+		Allowed:    true,
+		Limit:      100,
+		Remaining:  42,
+		ResetAfter: 30 * time.Second,
+		RetryAt:    time.Unix(1_700_000_030, 0),
+		Policy:     "api",
+	}
+}
+
+func TestWriterHeaders(t *testing.T) {
+	w := newHeaderRecorder()
+	write := Writer(WithFormat(Both), WithScope())
+	write(w, allowedResult(), "")
+
+	want := map[string]string{
+		"X-RateLimit-Limit":     "100",
+		"X-RateLimit-Remaining": "42",
+		"X-RateLimit-Reset":     "1700000030",
+		"RateLimit-Limit":       "100",
+		"RateLimit-Remaining":   "42",
+		"RateLimit-Reset":       "30",
+		"X-RateLimit-Scope":     "api",
+	}
+	for key, value := range want {
+		if got := w.Header().Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+	if got := len(w.Header()); got != len(want) {
+		t.Errorf("wrote %d headers, want %d", got, len(want))
+	}
+}
+
+func TestWriterSharedValues(t *testing.T) {
+	write := Writer()
+	first, second := newHeaderRecorder(), newHeaderRecorder()
+	write(first, allowedResult(), "")
+	write(second, allowedResult(), "")
+
+	first.Header().Add("X-RateLimit-Limit", "other")
+	if got := second.Header().Values("X-RateLimit-Limit"); len(got) != 1 || got[0] != "100" {
+		t.Errorf("X-RateLimit-Limit of another response = %q, want [100]", got)
+	}
+}
+
+func TestWriterAllocs(t *testing.T) {
+	w := newHeaderRecorder()
+	write := Writer(WithFormat(Both), WithScope())
+	result := allowedResult()
+	write(w, result, "default")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		clear(w.header)
+		write(w, result, "default")
+	})
+	if allocs != 0 {
+		t.Errorf("Writer allocates %.1f times per call, want 0", allocs)
+	}
+}
+
+func benchmarkWrite(b *testing.B, write ratelimiter.HeaderWriter) {
+	w := newHeaderRecorder()
+	result := allowedResult()
+	b.ReportAllocs()
+	for range b.N {
+		clear(w.header)
+		write(w, result, "default")
+	}
+}
+
+func BenchmarkWriterLegacy(b *testing.B) {
+	benchmarkWrite(b, Writer())
+}
+
+func BenchmarkWriterBoth(b *testing.B) {
+	benchmarkWrite(b, Writer(WithFormat(Both), WithScope()))
+}
+
+func BenchmarkSet(b *testing.B) {
+	benchmarkWrite(b, func(w http.ResponseWriter, result ratelimiter.Result, rule string) {
+		Set(w, result, WithFormat(Both), WithRule(rule))
+	})
+}
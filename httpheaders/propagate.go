@@ -0,0 +1,144 @@
+// Package httpheaders writes rate-limit response headers for results produced
+// by github.com/jassus213/go-rate-limiter.
+//
+// This file contains the propagation of rate-limit decisions from the edge to
+// internal services through the StateHeader request header.
+package httpheaders
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// StateHeader is the request header carrying a rate-limit decision from the
+// edge to internal services; see Inject.
+const StateHeader = "X-RateLimit-State"
+
+var stateHeader = http.CanonicalHeaderKey(StateHeader)
+
+// Inject writes result to the StateHeader of an outgoing request, so that
+// services behind the edge can tell how much quota the client has left, e.g.
+// to skip limiting it again or to shed optional work when it runs low,
+// without another store lookup.
+//
+// The value is a URL-encoded query string, such as
+// "allowed=1&limit=100&policy=api&remaining=42&reset_ms=1500". The edge must
+// remove StateHeader from incoming requests, or clients can claim any state;
+// the bundled net/http and gin middleware do so before calling the handler.
+//
+// Example:
+//
+//	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://billing.internal/usage", nil)
+//	httpheaders.Inject(req.Header, result)
+func Inject(h http.Header, result ratelimiter.Result) {
+	v := url.Values{}
+	v.Set("allowed", "0")
+	if result.Allowed {
+		v.Set("allowed", "1")
+	}
+	v.Set("limit", strconv.FormatInt(result.Limit, 10))
+	v.Set("remaining", strconv.FormatInt(result.Remaining, 10))
+	v.Set("reset_ms", strconv.FormatInt(result.ResetAfter.Milliseconds(), 10))
+	if result.Policy != "" {
+		v.Set("policy", result.Policy)
+	}
+	if result.Algorithm != "" {
+		v.Set("algorithm", result.Algorithm)
+	}
+	h[stateHeader] = []string{v.Encode()}
+}
+
+// Extract parses the StateHeader written by Inject. It reports false if the
+// header is absent or malformed. RetryAt is computed from the time of the
+// call, so the result is only as fresh as the request.
+//
+// Example:
+//
+//	if result, ok := httpheaders.Extract(r.Header); ok && result.Remaining < 10 {
+//	    skipRecommendations = true
+//	}
+func Extract(h http.Header) (ratelimiter.Result, bool) {
+	raw := h.Get(StateHeader)
+	if raw == "" {
+		return ratelimiter.Result{}, false
+	}
+	v, err := url.ParseQuery(raw)
+	if err != nil {
+		return ratelimiter.Result{}, false
+	}
+
+	limit, err1 := strconv.ParseInt(v.Get("limit"), 10, 64)
+	remaining, err2 := strconv.ParseInt(v.Get("remaining"), 10, 64)
+	resetMillis, err3 := strconv.ParseInt(v.Get("reset_ms"), 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return ratelimiter.Result{}, false
+	}
+
+	resetAfter := time.Duration(resetMillis) * time.Millisecond
+	return ratelimiter.Result{
+		Allowed:         v.Get("allowed") == "1",
+		Limit:           limit,
+		Remaining:       remaining,
+		ResetAfter:      resetAfter,
+		RemainingTokens: float64(remaining),
+		RetryAt:         time.Now().Add(resetAfter),
+		Policy:          v.Get("policy"),
+		Algorithm:       v.Get("algorithm"),
+	}, true
+}
+
+// resultKey is the context key under which NewContext stores a Result.
+type resultKey struct{}
+
+// NewContext returns a copy of ctx carrying result. The bundled net/http and
+// gin middleware call it for every allowed request, so that handlers can find
+// the decision with FromContext and Transport can forward it.
+func NewContext(ctx context.Context, result ratelimiter.Result) context.Context {
+	return context.WithValue(ctx, resultKey{}, result)
+}
+
+// FromContext returns the Result stored in ctx by NewContext, and false if
+// there is none.
+func FromContext(ctx context.Context) (ratelimiter.Result, bool) {
+	result, ok := ctx.Value(resultKey{}).(ratelimiter.Result)
+	return result, ok
+}
+
+// Transport returns an http.RoundTripper that injects the Result found in
+// each outgoing request's context into its StateHeader before passing it to
+// base, or http.DefaultTransport if base is nil. Requests without a Result are
+// sent unchanged.
+//
+// Example:
+//
+//	client := &http.Client{Transport: httpheaders.Transport(nil)}
+//	req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, "http://search.internal/q", nil)
+//	resp, err := client.Do(req)
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		result, ok := FromContext(req.Context())
+		if !ok {
+			return base.RoundTrip(req)
+		}
+		// A RoundTripper must not modify the caller's request.
+		req = req.Clone(req.Context())
+		Inject(req.Header, result)
+		return base.RoundTrip(req)
+	})
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
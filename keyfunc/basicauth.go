@@ -0,0 +1,28 @@
+package keyfunc
+
+import (
+	"net/http"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// BasicAuthUser returns a KeyFunc that keys requests by the username of HTTP
+// Basic authentication.
+//
+// Only the username is read; the password is discarded immediately and never
+// appears in keys, errors, or logs. The credentials are not verified, so the
+// key function must run behind the handler or middleware that authenticates
+// them. Requests without Basic credentials fail with ErrorMissingKey.
+//
+// Example:
+//
+//	keyFunc := keyfunc.BasicAuthUser()
+func BasicAuthUser() ratelimiter.KeyFunc {
+	return func(r *http.Request) (string, error) {
+		user, _, ok := r.BasicAuth()
+		if !ok || user == "" {
+			return "", ErrorMissingKey
+		}
+		return user, nil
+	}
+}
@@ -0,0 +1,29 @@
+package keyfunc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// ContextValue returns a context-aware key function that uses the value stored
+// in the request context under ctxKey, e.g. the user ID set by authentication
+// middleware.
+//
+// Values are formatted with fmt.Sprint. Requests whose context holds no value
+// under ctxKey fail with ErrorMissingKey.
+//
+// Example:
+//
+//	cfg := ratelimiter.NewConfig(ratelimiter.WithKeyFuncCtx(keyfunc.ContextValue(auth.UserIDKey)))
+func ContextValue(ctxKey interface{}) ratelimiter.KeyFuncCtx {
+	return func(ctx context.Context, r *http.Request) (string, error) {
+		value := ctx.Value(ctxKey)
+		if value == nil {
+			return "", ErrorMissingKey
+		}
+		return fmt.Sprint(value), nil
+	}
+}
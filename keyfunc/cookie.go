@@ -0,0 +1,118 @@
+package keyfunc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// CookieValidator validates a raw cookie value and returns the session
+// identifier it carries.
+type CookieValidator func(value string) (string, bool)
+
+// VerifyCookieHMAC returns a CookieValidator for values of the form
+// "<id>.<signature>", where signature is the unpadded base64url-encoded
+// HMAC-SHA256 of id keyed by secret.
+func VerifyCookieHMAC(secret []byte) CookieValidator {
+	return func(value string) (string, bool) {
+		i := strings.LastIndexByte(value, '.')
+		if i <= 0 {
+			return "", false
+		}
+		id, sig := value[:i], value[i+1:]
+
+		signature, err := base64.RawURLEncoding.DecodeString(sig)
+		if err != nil {
+			return "", false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(id))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return "", false
+		}
+		return id, true
+	}
+}
+
+// CookieOption configures the Cookie key function.
+type CookieOption func(*cookieConfig)
+
+// cookieConfig holds the settings applied by CookieOption values.
+type cookieConfig struct {
+	validator CookieValidator
+	fallback  ratelimiter.KeyFunc
+}
+
+// WithCookieValidator validates cookie values with v before using them as keys.
+func WithCookieValidator(v CookieValidator) CookieOption {
+	return func(c *cookieConfig) {
+		c.validator = v
+	}
+}
+
+// WithCookieFallback keys requests without a valid cookie using f, e.g.
+// keyfunc.ClientIP().
+func WithCookieFallback(f ratelimiter.KeyFunc) CookieOption {
+	return func(c *cookieConfig) {
+		c.fallback = f
+	}
+}
+
+// Cookie returns a KeyFunc that keys requests by the value of the named
+// session cookie.
+//
+// Without a validator, clients can bypass the limit by rotating cookie values;
+// use WithCookieValidator (e.g. VerifyCookieHMAC) unless the cookie is checked
+// by earlier middleware. Requests whose cookie is missing or fails validation
+// fail with ErrorMissingKey, or are keyed by the fallback if one is set. With a
+// fallback, keys are namespaced as "cookie:<id>" and "fallback:<key>".
+//
+// Example:
+//
+//	keyFunc := keyfunc.Cookie("session_id",
+//	    keyfunc.WithCookieValidator(keyfunc.VerifyCookieHMAC(secret)),
+//	    keyfunc.WithCookieFallback(keyfunc.ClientIP()),
+//	)
+func Cookie(name string, opts ...CookieOption) ratelimiter.KeyFunc {
+	cfg := &cookieConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(r *http.Request) (string, error) {
+		id, ok := cfg.session(r, name)
+
+		if cfg.fallback == nil {
+			if !ok {
+				return "", ErrorMissingKey
+			}
+			return id, nil
+		}
+
+		if ok {
+			return "cookie:" + id, nil
+		}
+
+		key, err := cfg.fallback(r)
+		if err != nil {
+			return "", err
+		}
+		return "fallback:" + key, nil
+	}
+}
+
+// session returns the validated session identifier carried by the named cookie.
+func (c *cookieConfig) session(r *http.Request, name string) (string, bool) {
+	cookie, err := r.Cookie(name)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	if c.validator == nil {
+		return cookie.Value, true
+	}
+	return c.validator(cookie.Value)
+}
@@ -0,0 +1,150 @@
+package keyfunc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// defaultFingerprintHeaders are the headers whose values Fingerprint uses by
+// default. Accept-Language in particular varies little within a client but a
+// lot between clients.
+var defaultFingerprintHeaders = []string{"User-Agent", "Accept", "Accept-Language", "Accept-Encoding"}
+
+// fingerprintPresenceHeaders are the headers whose presence, but not value,
+// Fingerprint uses. They are sent or omitted depending on the client software
+// rather than on the request, unlike e.g. Cookie, Authorization, Referer or
+// Content-Type, which would give one client a different key per endpoint.
+var fingerprintPresenceHeaders = [...]string{
+	"Accept",
+	"Accept-Encoding",
+	"Accept-Language",
+	"Cache-Control",
+	"Connection",
+	"Dnt",
+	"Pragma",
+	"Priority",
+	"Sec-Ch-Ua",
+	"Sec-Ch-Ua-Mobile",
+	"Sec-Ch-Ua-Platform",
+	"Sec-Fetch-Dest",
+	"Sec-Fetch-Mode",
+	"Sec-Fetch-Site",
+	"Sec-Fetch-User",
+	"Te",
+	"Upgrade-Insecure-Requests",
+	"User-Agent",
+}
+
+// FingerprintOption configures the Fingerprint key function.
+type FingerprintOption func(*fingerprintConfig)
+
+// fingerprintConfig holds the settings applied by FingerprintOption values.
+type fingerprintConfig struct {
+	ja3     func(r *http.Request) string
+	headers []string
+}
+
+// WithJA3Header uses the JA3 hash of the client's TLS handshake found in the
+// named header, as set by TLS-terminating proxies that compute it. Go's TLS
+// server does not expose the ClientHello fields JA3 needs, so the hash must
+// come from the proxy; the header must be set or stripped by that proxy, or
+// clients can choose their own fingerprint.
+func WithJA3Header(name string) FingerprintOption {
+	return WithJA3(func(r *http.Request) string {
+		return strings.TrimSpace(r.Header.Get(name))
+	})
+}
+
+// WithJA3 uses the JA3 hash returned by f, e.g. one recorded in the request
+// context by a custom listener. f returns "" when no hash is available.
+func WithJA3(f func(r *http.Request) string) FingerprintOption {
+	return func(c *fingerprintConfig) {
+		c.ja3 = f
+	}
+}
+
+// WithFingerprintHeaders sets the headers whose values make up the
+// fingerprint, replacing User-Agent, Accept, Accept-Language and
+// Accept-Encoding.
+func WithFingerprintHeaders(names ...string) FingerprintOption {
+	return func(c *fingerprintConfig) {
+		c.headers = names
+	}
+}
+
+// Fingerprint returns a KeyFunc that keys requests by a fingerprint of the
+// client software rather than its address, so that a client rotating IP
+// addresses keeps the same key.
+//
+// The fingerprint is a hash of the JA3 hash of the TLS handshake, when
+// WithJA3Header or WithJA3 provides one, the negotiated TLS version, cipher
+// suite and ALPN protocol, which of a fixed list of browser and client
+// headers (such as Accept-Language, Sec-Fetch-Mode or Upgrade-Insecure-Requests)
+// are present, and the normalized values of User-Agent, Accept,
+// Accept-Language and Accept-Encoding. Since net/http does not preserve the
+// order of headers, only their presence is used, and headers that vary per
+// request or are added by proxies are ignored. Keys have the form "fp:<hash>".
+//
+// Fingerprints are shared by every client running the same software with the
+// same settings, so they identify classes of clients, not individuals. Use
+// Fingerprint as a fallback identity, with limits generous enough for a
+// popular browser, rather than as the only key.
+//
+// Example:
+//
+//	keyFunc := keyfunc.Header("X-API-Key",
+//	    keyfunc.WithFallback(keyfunc.Fingerprint(keyfunc.WithJA3Header("X-JA3-Hash"))),
+//	)
+func Fingerprint(opts ...FingerprintOption) ratelimiter.KeyFunc {
+	cfg := &fingerprintConfig{headers: defaultFingerprintHeaders}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(r *http.Request) (string, error) {
+		h := sha256.New()
+		write := func(s string) {
+			h.Write([]byte(s))
+			h.Write([]byte{0})
+		}
+
+		if cfg.ja3 != nil {
+			write(cfg.ja3(r))
+		}
+		if r.TLS != nil {
+			write(strconv.Itoa(int(r.TLS.Version)))
+			write(strconv.Itoa(int(r.TLS.CipherSuite)))
+			write(r.TLS.NegotiatedProtocol)
+		} else {
+			write("")
+		}
+
+		var present [len(fingerprintPresenceHeaders)]byte
+		for i, name := range fingerprintPresenceHeaders {
+			present[i] = '0'
+			if _, ok := r.Header[name]; ok {
+				present[i] = '1'
+			}
+		}
+		write(string(present[:]))
+
+		for _, name := range cfg.headers {
+			write(normalizeFingerprintValue(r.Header.Values(name)))
+		}
+
+		return "fp:" + hex.EncodeToString(h.Sum(nil)[:16]), nil
+	}
+}
+
+// normalizeFingerprintValue joins the values of a header, lowercased and with
+// whitespace removed, so that insignificant formatting differences introduced
+// by proxies do not change the fingerprint.
+func normalizeFingerprintValue(values []string) string {
+	joined := strings.ToLower(strings.Join(values, ","))
+	return strings.Join(strings.Fields(joined), "")
+}
@@ -0,0 +1,120 @@
+package keyfunc
+
+import (
+	"strings"
+)
+
+// ForwardedHeader is the standard forwarding header defined by RFC 7239.
+const ForwardedHeader = "Forwarded"
+
+// ForwardedElement is one hop of an RFC 7239 Forwarded header.
+//
+// Node identifiers (For and By) are returned as written, without quotes; they
+// may contain a port ("192.0.2.60:8080"), be bracketed IPv6 addresses
+// ("[2001:db8::1]:4711"), or be obfuscated identifiers ("unknown", "_hidden").
+type ForwardedElement struct {
+	// For identifies the node making the request to the proxy.
+	For string
+	// By identifies the proxy interface that received the request.
+	By string
+	// Proto is the protocol used by the incoming request, e.g. "https".
+	Proto string
+	// Host is the Host request header as received by the proxy.
+	Host string
+}
+
+// ParseForwarded parses the values of RFC 7239 Forwarded headers into their
+// elements, in order from the first hop to the last.
+//
+// Parameters with unknown names are ignored. Malformed pairs are skipped.
+//
+// Example:
+//
+//	elements := keyfunc.ParseForwarded(r.Header.Values("Forwarded"))
+func ParseForwarded(values []string) []ForwardedElement {
+	var elements []ForwardedElement
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			var e ForwardedElement
+			for _, pair := range splitQuoted(element, ';') {
+				name, val, ok := strings.Cut(pair, "=")
+				if !ok {
+					continue
+				}
+				val = unquote(strings.TrimSpace(val))
+
+				switch strings.ToLower(strings.TrimSpace(name)) {
+				case "for":
+					e.For = val
+				case "by":
+					e.By = val
+				case "proto":
+					e.Proto = strings.ToLower(val)
+				case "host":
+					e.Host = val
+				}
+			}
+			elements = append(elements, e)
+		}
+	}
+	return elements
+}
+
+// splitQuoted splits s on sep, ignoring separators inside quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	inQuotes, escaped, start := false, false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\' && inQuotes:
+			escaped = true
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// unquote removes the surrounding quotes and escapes of an RFC 7230 quoted-string.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+
+	var b strings.Builder
+	escaped := false
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// parseNode parses a Forwarded node identifier into an address. Obfuscated
+// and "unknown" identifiers are rejected.
+func parseNode(node string) (string, bool) {
+	if strings.HasPrefix(node, "[") {
+		end := strings.IndexByte(node, ']')
+		if end < 0 {
+			return "", false
+		}
+		return node[1:end], true
+	}
+	if node == "" || node == "unknown" || strings.HasPrefix(node, "_") {
+		return "", false
+	}
+	if host, _, ok := strings.Cut(node, ":"); ok && strings.Count(node, ":") == 1 {
+		return host, true
+	}
+	return node, true
+}
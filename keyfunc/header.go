@@ -0,0 +1,74 @@
+package keyfunc
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// ErrorMissingKey is returned by key functions that require a client
+// identifier (such as an API key header) when the request does not carry one.
+var ErrorMissingKey = errors.New("rate limit key missing from request")
+
+// HeaderOption configures the Header key function.
+type HeaderOption func(*headerConfig)
+
+// headerConfig holds the settings applied by HeaderOption values.
+type headerConfig struct {
+	fallback ratelimiter.KeyFunc
+}
+
+// FallbackToIP keys requests without the header by client IP, as ClientIP
+// configured with the given options would.
+func FallbackToIP(opts ...IPOption) HeaderOption {
+	return WithFallback(ClientIP(opts...))
+}
+
+// WithFallback keys requests without the header using f.
+func WithFallback(f ratelimiter.KeyFunc) HeaderOption {
+	return func(c *headerConfig) {
+		c.fallback = f
+	}
+}
+
+// Header returns a KeyFunc that keys requests by the value of the named header,
+// e.g. "X-API-Key".
+//
+// By default the header is required and requests without it fail with
+// ErrorMissingKey. With a fallback (FallbackToIP or WithFallback), such
+// requests are keyed by the fallback instead. In that case keys are namespaced
+// as "header:<value>" and "fallback:<key>" so that a client cannot share
+// another client's quota by sending its IP address as a header value.
+//
+// Example:
+//
+//	keyFunc := keyfunc.Header("X-API-Key", keyfunc.FallbackToIP())
+func Header(name string, opts ...HeaderOption) ratelimiter.KeyFunc {
+	cfg := &headerConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(r *http.Request) (string, error) {
+		value := strings.TrimSpace(r.Header.Get(name))
+
+		if cfg.fallback == nil {
+			if value == "" {
+				return "", ErrorMissingKey
+			}
+			return value, nil
+		}
+
+		if value != "" {
+			return "header:" + value, nil
+		}
+
+		key, err := cfg.fallback(r)
+		if err != nil {
+			return "", err
+		}
+		return "fallback:" + key, nil
+	}
+}
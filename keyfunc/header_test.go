@@ -0,0 +1,103 @@
+package keyfunc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+func TestHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []HeaderOption
+		value   string
+		want    string
+		wantErr error
+	}{
+		{name: "value", value: "key-1", want: "key-1"},
+		{name: "trimmed", value: "  key-1 ", want: "key-1"},
+		{name: "missing", wantErr: ErrorMissingKey},
+		{name: "namespaced with fallback", opts: []HeaderOption{FallbackToIP()}, value: "key-1", want: "header:key-1"},
+		{name: "fallback", opts: []HeaderOption{FallbackToIP()}, want: "fallback:192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			if tt.value != "" {
+				r.Header.Set("X-API-Key", tt.value)
+			}
+			got, err := Header("X-API-Key", tt.opts...)(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCookie(t *testing.T) {
+	secret := []byte("secret")
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("session-1"))
+	signed := "session-1." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	fallback := WithCookieFallback(func(r *http.Request) (string, error) { return "ip", nil })
+
+	tests := []struct {
+		name    string
+		opts    []CookieOption
+		value   string
+		want    string
+		wantErr error
+	}{
+		{name: "value", value: "session-1", want: "session-1"},
+		{name: "missing", wantErr: ErrorMissingKey},
+		{name: "signed", opts: []CookieOption{WithCookieValidator(VerifyCookieHMAC(secret))}, value: signed, want: "session-1"},
+		{name: "forged", opts: []CookieOption{WithCookieValidator(VerifyCookieHMAC(secret))}, value: "session-2.AAAA", wantErr: ErrorMissingKey},
+		{name: "unsigned", opts: []CookieOption{WithCookieValidator(VerifyCookieHMAC(secret))}, value: "session-1", wantErr: ErrorMissingKey},
+		{name: "namespaced with fallback", opts: []CookieOption{fallback}, value: "session-1", want: "cookie:session-1"},
+		{name: "fallback", opts: []CookieOption{WithCookieValidator(VerifyCookieHMAC(secret)), fallback}, value: "forged", want: "fallback:ip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.value != "" {
+				r.AddCookie(&http.Cookie{Name: "sid", Value: tt.value})
+			}
+			got, err := Cookie("sid", tt.opts...)(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoin(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/search", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+
+	if got, _ := Join(ClientIP(), Method(), Path())(r); got != "192.0.2.1|POST|/search" {
+		t.Errorf("Join = %q", got)
+	}
+	if got, _ := JoinWith("/", Method(), Path())(r); got != "POST//search" {
+		t.Errorf("JoinWith = %q", got)
+	}
+
+	failing := func(r *http.Request) (string, error) { return "", ErrorMissingKey }
+	if _, err := Join(Path(), ratelimiter.KeyFunc(failing))(r); !errors.Is(err, ErrorMissingKey) {
+		t.Errorf("Join error = %v, want %v", err, ErrorMissingKey)
+	}
+}
@@ -0,0 +1,242 @@
+// Package keyfunc provides ready-made ratelimiter.KeyFunc implementations.
+//
+// Key functions extract the identity a request is rate limited by, such as the
+// client IP address. They plug into any middleware through
+// ratelimiter.WithKeyFunc.
+//
+// Example usage:
+//
+//	mw := nethttp.Middleware(limiter, ratelimiter.WithKeyFunc(keyfunc.ClientIP()))
+package keyfunc
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// ErrorNoClientIP is returned when the client IP address cannot be determined.
+var ErrorNoClientIP = errors.New("client IP not found")
+
+// IPOption configures the ClientIP key function.
+type IPOption func(*ipConfig)
+
+// ipConfig holds the settings applied by IPOption values.
+type ipConfig struct {
+	ipv4Bits       int
+	ipv6Bits       int
+	trustedProxies []netip.Prefix
+	headers        []string
+}
+
+// WithTrustedProxies sets the proxies whose forwarding headers are trusted.
+//
+// Entries may be CIDR ranges ("10.0.0.0/8") or single addresses ("192.0.2.1").
+// Invalid entries are ignored, which fails safe: requests from an unrecognized
+// peer are keyed by the peer address and headers are never consulted.
+func WithTrustedProxies(cidrs ...string) IPOption {
+	return func(c *ipConfig) {
+		for _, cidr := range cidrs {
+			if prefix, err := netip.ParsePrefix(cidr); err == nil {
+				c.trustedProxies = append(c.trustedProxies, prefix.Masked())
+			} else if addr, err := netip.ParseAddr(cidr); err == nil {
+				addr = addr.Unmap()
+				c.trustedProxies = append(c.trustedProxies, netip.PrefixFrom(addr, addr.BitLen()))
+			}
+		}
+	}
+}
+
+// WithHeaders sets the headers, in order of preference, from which the client
+// IP is read when the request comes from a trusted proxy.
+//
+// The default is "Forwarded" (RFC 7239), then "X-Forwarded-For", then "X-Real-IP".
+func WithHeaders(names ...string) IPOption {
+	return func(c *ipConfig) {
+		c.headers = names
+	}
+}
+
+// WithIPv4Prefix aggregates IPv4 clients by the given prefix length.
+//
+// The default of 32 keys every address separately; 24 groups clients by /24 network.
+func WithIPv4Prefix(bits int) IPOption {
+	return func(c *ipConfig) {
+		if bits >= 0 && bits <= 32 {
+			c.ipv4Bits = bits
+		}
+	}
+}
+
+// WithIPv6Prefix aggregates IPv6 clients by the given prefix length.
+//
+// The default is 64, the size of a typical end-user allocation: per-address
+// limits are trivially bypassed by clients rotating addresses within their /64.
+func WithIPv6Prefix(bits int) IPOption {
+	return func(c *ipConfig) {
+		if bits >= 0 && bits <= 128 {
+			c.ipv6Bits = bits
+		}
+	}
+}
+
+// ClientIP returns a KeyFunc that keys requests by client IP address.
+//
+// By default the peer address (r.RemoteAddr) is used. When the peer is one of
+// the proxies configured with WithTrustedProxies, the real client IP is read
+// from the forwarding headers instead. In Forwarded (the for= parameter) and
+// X-Forwarded-For, addresses are walked from right to left and the first one
+// that is not a trusted proxy is used, so values prepended by the client can
+// neither spoof the key nor, being unparsable, make it fall back to the proxy.
+//
+// IPv6 addresses are aggregated by /64 and IPv4 addresses are keyed
+// individually unless configured otherwise. Aggregated keys are formatted as
+// prefixes, e.g. "2001:db8:1:2::/64".
+//
+// Example:
+//
+//	keyFunc := keyfunc.ClientIP(
+//	    keyfunc.WithTrustedProxies("10.0.0.0/8"),
+//	    keyfunc.WithHeaders("X-Forwarded-For", "X-Real-IP"),
+//	    keyfunc.WithIPv4Prefix(24),
+//	)
+func ClientIP(opts ...IPOption) ratelimiter.KeyFunc {
+	resolve := ClientIPResolver(opts...)
+	return func(r *http.Request) (string, error) {
+		return resolve(r.RemoteAddr, r.Header)
+	}
+}
+
+// ClientIPResolver returns the client IP logic of ClientIP as a function of the
+// peer address and forwarding headers, for transports other than net/http
+// (e.g. gRPC, where headers are carried in metadata).
+//
+// Example:
+//
+//	resolve := keyfunc.ClientIPResolver(keyfunc.WithTrustedProxies("10.0.0.0/8"))
+//	key, err := resolve(peerAddr.String(), header)
+func ClientIPResolver(opts ...IPOption) func(remoteAddr string, header http.Header) (string, error) {
+	cfg := &ipConfig{
+		ipv4Bits: 32,
+		ipv6Bits: 64,
+		headers:  []string{ForwardedHeader, "X-Forwarded-For", "X-Real-IP"},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(remoteAddr string, header http.Header) (string, error) {
+		addr, err := cfg.clientIP(remoteAddr, header)
+		if err != nil {
+			return "", err
+		}
+		return cfg.key(addr), nil
+	}
+}
+
+// clientIP returns the client address, honoring forwarding headers set by
+// trusted proxies.
+func (c *ipConfig) clientIP(remoteAddr string, header http.Header) (netip.Addr, error) {
+	peer, err := parseIP(remoteAddr)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if !c.trusted(peer) {
+		return peer, nil
+	}
+
+	for _, name := range c.headers {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if addr, ok := c.fromHeader(name, values); ok {
+			return addr, nil
+		}
+	}
+	return peer, nil
+}
+
+// fromHeader extracts the client address from the hops listed in the values
+// of a forwarding header.
+//
+// Hops are walked from right to left, skipping trusted proxies, and the first
+// untrusted one is the client. A hop that cannot be parsed ends the walk, as
+// it was not appended by a trusted proxy; the last hop before it is then used,
+// so that a client prepending garbage is still keyed by the address its proxy
+// saw.
+func (c *ipConfig) fromHeader(name string, values []string) (netip.Addr, bool) {
+	var hops []netip.Addr
+	if strings.EqualFold(name, ForwardedHeader) {
+		for _, element := range ParseForwarded(values) {
+			var addr netip.Addr
+			if host, ok := parseNode(element.For); ok {
+				addr, _ = parseIP(host)
+			}
+			hops = append(hops, addr)
+		}
+		values = nil
+	}
+
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			addr, _ := parseIP(strings.TrimSpace(part))
+			hops = append(hops, addr)
+		}
+	}
+
+	var last netip.Addr
+	for i := len(hops) - 1; i >= 0 && hops[i].IsValid(); i-- {
+		if !c.trusted(hops[i]) {
+			return hops[i], true
+		}
+		last = hops[i]
+	}
+	return last, last.IsValid()
+}
+
+// trusted reports whether addr belongs to a trusted proxy.
+func (c *ipConfig) trusted(addr netip.Addr) bool {
+	for _, prefix := range c.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// key formats addr, masked to the configured prefix length.
+func (c *ipConfig) key(addr netip.Addr) string {
+	bits := c.ipv6Bits
+	if addr.Is4() {
+		bits = c.ipv4Bits
+	}
+	if bits == addr.BitLen() {
+		return addr.String()
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return addr.String()
+	}
+	return prefix.String()
+}
+
+// parseIP parses an IP address with an optional port, unmapping IPv4-mapped
+// IPv6 addresses and dropping any zone.
+func parseIP(s string) (netip.Addr, error) {
+	host := s
+	if h, _, err := net.SplitHostPort(s); err == nil {
+		host = h
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, ErrorNoClientIP
+	}
+	return addr.Unmap().WithZone(""), nil
+}
@@ -0,0 +1,114 @@
+package keyfunc
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []IPOption
+		remoteAddr string
+		header     http.Header
+		want       string
+		wantErr    error
+	}{
+		{name: "peer", remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		{name: "peer without port", remoteAddr: "192.0.2.1", want: "192.0.2.1"},
+		{name: "mapped ipv4", remoteAddr: "[::ffff:192.0.2.1]:1234", want: "192.0.2.1"},
+		{name: "ipv6 aggregated by /64", remoteAddr: "[2001:db8:1:2:3:4:5:6]:1234", want: "2001:db8:1:2::/64"},
+		{name: "ipv4 prefix", opts: []IPOption{WithIPv4Prefix(24)}, remoteAddr: "192.0.2.77:1", want: "192.0.2.0/24"},
+		{name: "ipv6 full", opts: []IPOption{WithIPv6Prefix(128)}, remoteAddr: "[2001:db8::1]:1", want: "2001:db8::1"},
+		{name: "unparsable peer", remoteAddr: "pipe", wantErr: ErrorNoClientIP},
+		{
+			name:       "untrusted peer ignores headers",
+			remoteAddr: "192.0.2.1:1",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.7"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "x-forwarded-for",
+			opts:       []IPOption{WithTrustedProxies("10.0.0.0/8")},
+			remoteAddr: "10.0.0.1:1",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.7, 10.0.0.2"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "spoofed hop before the client",
+			opts:       []IPOption{WithTrustedProxies("10.0.0.0/8")},
+			remoteAddr: "10.0.0.1:1",
+			header:     http.Header{"X-Forwarded-For": {"203.0.113.9, 198.51.100.7"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "garbage hop before trusted ones",
+			opts:       []IPOption{WithTrustedProxies("10.0.0.0/8")},
+			remoteAddr: "10.0.0.1:1",
+			header:     http.Header{"X-Forwarded-For": {"garbage, 10.0.0.3"}},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "forwarded preferred",
+			opts:       []IPOption{WithTrustedProxies("10.0.0.1")},
+			remoteAddr: "10.0.0.1:1",
+			header: http.Header{
+				"Forwarded":       {`for="[2001:db8::7]:4711";proto=https`},
+				"X-Forwarded-For": {"198.51.100.7"},
+			},
+			want: "2001:db8::/64",
+		},
+		{
+			name:       "custom headers",
+			opts:       []IPOption{WithTrustedProxies("10.0.0.0/8"), WithHeaders("X-Real-IP")},
+			remoteAddr: "10.0.0.1:1",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.7"}, "X-Real-Ip": {"198.51.100.8"}},
+			want:       "198.51.100.8",
+		},
+		{
+			name:       "invalid trusted proxy ignored",
+			opts:       []IPOption{WithTrustedProxies("not-a-cidr")},
+			remoteAddr: "10.0.0.1:1",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.7"}},
+			want:       "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &http.Request{RemoteAddr: tt.remoteAddr, Header: tt.header}
+			if r.Header == nil {
+				r.Header = http.Header{}
+			}
+			got, err := ClientIP(tt.opts...)(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseForwarded(t *testing.T) {
+	got := ParseForwarded([]string{
+		`for=192.0.2.60;proto=HTTP;by=203.0.113.43`,
+		`for="[2001:db8:cafe::17]:4711", for=unknown;host="example.com", bogus`,
+	})
+	want := []ForwardedElement{
+		{For: "192.0.2.60", By: "203.0.113.43", Proto: "http"},
+		{For: "[2001:db8:cafe::17]:4711"},
+		{For: "unknown", Host: "example.com"},
+		{},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("elements = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("element %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package keyfunc
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// DefaultSeparator separates the parts of keys built by Join.
+//
+// It is chosen so that it cannot appear in IP addresses or prefixes, which
+// contain colons.
+const DefaultSeparator = "|"
+
+// Path returns a KeyFunc that keys requests by URL path.
+func Path() ratelimiter.KeyFunc {
+	return func(r *http.Request) (string, error) {
+		return r.URL.Path, nil
+	}
+}
+
+// Method returns a KeyFunc that keys requests by HTTP method.
+func Method() ratelimiter.KeyFunc {
+	return func(r *http.Request) (string, error) {
+		return r.Method, nil
+	}
+}
+
+// Join returns a KeyFunc that concatenates the keys returned by funcs,
+// separated by DefaultSeparator.
+//
+// It makes multi-dimensional limits, such as per-IP-per-endpoint, a one-liner.
+// If any of funcs fails, its error is returned.
+//
+// Example:
+//
+//	keyFunc := keyfunc.Join(keyfunc.ClientIP(), keyfunc.Method(), keyfunc.Path())
+//	// "203.0.113.7|GET|/search"
+func Join(funcs ...ratelimiter.KeyFunc) ratelimiter.KeyFunc {
+	return JoinWith(DefaultSeparator, funcs...)
+}
+
+// JoinWith is like Join but uses sep to separate the parts.
+func JoinWith(sep string, funcs ...ratelimiter.KeyFunc) ratelimiter.KeyFunc {
+	return func(r *http.Request) (string, error) {
+		parts := make([]string, len(funcs))
+		for i, f := range funcs {
+			part, err := f(r)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = part
+		}
+		return strings.Join(parts, sep), nil
+	}
+}
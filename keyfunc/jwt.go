@@ -0,0 +1,257 @@
+package keyfunc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// ErrorInvalidToken is returned when a bearer token cannot be parsed, fails
+// verification, is expired, or lacks the requested claim.
+var ErrorInvalidToken = errors.New("invalid bearer token")
+
+// TokenVerifier checks the signature of a JWT.
+//
+// signingInput is the "header.payload" part of the token and signature is the
+// decoded third part. header holds the decoded JOSE header (e.g. "alg", "kid").
+type TokenVerifier func(header map[string]interface{}, signingInput string, signature []byte) error
+
+// VerifyHS256 returns a TokenVerifier accepting tokens signed with HMAC-SHA256
+// using secret.
+func VerifyHS256(secret []byte) TokenVerifier {
+	return func(header map[string]interface{}, signingInput string, signature []byte) error {
+		if header["alg"] != "HS256" {
+			return ErrorInvalidToken
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrorInvalidToken
+		}
+		return nil
+	}
+}
+
+// JWTOption configures the JWTClaim key function.
+type JWTOption func(*jwtConfig)
+
+// jwtConfig holds the settings applied by JWTOption values.
+type jwtConfig struct {
+	verifier  TokenVerifier
+	fallback  ratelimiter.KeyFunc
+	cacheSize int
+}
+
+// WithTokenVerifier verifies token signatures with v before trusting claims.
+func WithTokenVerifier(v TokenVerifier) JWTOption {
+	return func(c *jwtConfig) {
+		c.verifier = v
+	}
+}
+
+// WithTokenFallback keys requests without a bearer token using f.
+func WithTokenFallback(f ratelimiter.KeyFunc) JWTOption {
+	return func(c *jwtConfig) {
+		c.fallback = f
+	}
+}
+
+// WithTokenCacheSize sets how many parsed tokens are cached. The default is
+// 10000; 0 disables caching.
+func WithTokenCacheSize(n int) JWTOption {
+	return func(c *jwtConfig) {
+		if n >= 0 {
+			c.cacheSize = n
+		}
+	}
+}
+
+// JWTClaim returns a KeyFunc that keys requests by a claim of the bearer token
+// in the Authorization header, e.g. "sub" or "org_id".
+//
+// Keys are formatted as "<claim>:<value>". Requests without a bearer token fail
+// with ErrorMissingKey unless a fallback is set, in which case they are keyed
+// as "fallback:<key>". Malformed, expired, or unverifiable tokens fail with
+// ErrorInvalidToken.
+//
+// Signatures are only checked when a verifier is set with WithTokenVerifier.
+// Without one, claims are client-controlled and JWTClaim must only be used
+// behind middleware that has already authenticated the token.
+//
+// Tokens are rejected with ErrorInvalidToken before their "nbf" claim and
+// from their "exp" claim. Valid tokens are cached until "exp", so that each
+// token is decoded and verified once; tokens that are not yet valid are not
+// cached.
+//
+// Example:
+//
+//	keyFunc := keyfunc.JWTClaim("sub",
+//	    keyfunc.WithTokenVerifier(keyfunc.VerifyHS256(secret)),
+//	    keyfunc.WithTokenFallback(keyfunc.ClientIP()),
+//	)
+func JWTClaim(claim string, opts ...JWTOption) ratelimiter.KeyFunc {
+	cfg := &jwtConfig{cacheSize: 10000}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	cache := &tokenCache{size: cfg.cacheSize, entries: make(map[string]tokenCacheEntry)}
+
+	return func(r *http.Request) (string, error) {
+		token, ok := bearerToken(r)
+		if !ok {
+			if cfg.fallback == nil {
+				return "", ErrorMissingKey
+			}
+			key, err := cfg.fallback(r)
+			if err != nil {
+				return "", err
+			}
+			return "fallback:" + key, nil
+		}
+
+		now := time.Now()
+		if key, ok := cache.get(token, now); ok {
+			return key, nil
+		}
+
+		claims, err := cfg.parse(token)
+		if err != nil {
+			return "", err
+		}
+
+		expiresAt := now.Add(time.Hour)
+		if exp, ok := claims["exp"].(float64); ok {
+			expiresAt = time.Unix(int64(exp), 0)
+			if !now.Before(expiresAt) {
+				return "", ErrorInvalidToken
+			}
+		}
+		if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0)) {
+			return "", ErrorInvalidToken
+		}
+
+		value, ok := claimString(claims[claim])
+		if !ok {
+			return "", ErrorInvalidToken
+		}
+
+		key := claim + ":" + value
+		cache.put(token, key, expiresAt)
+		return key, nil
+	}
+}
+
+// parse decodes token, verifies it if a verifier is configured, and returns its claims.
+func (c *jwtConfig) parse(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrorInvalidToken
+	}
+
+	var header, claims map[string]interface{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if c.verifier != nil {
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			return nil, ErrorInvalidToken
+		}
+		if err := c.verifier(header, parts[0]+"."+parts[1], signature); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url-encoded JSON token segment into v.
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrorInvalidToken
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrorInvalidToken
+	}
+	return nil
+}
+
+// bearerToken extracts the bearer token from the Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// claimString formats a string or numeric claim value.
+func claimString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, v != ""
+	case float64:
+		return fmt.Sprintf("%.0f", v), true
+	default:
+		return "", false
+	}
+}
+
+// tokenCacheEntry is a cached key for a parsed token.
+type tokenCacheEntry struct {
+	key       string
+	expiresAt time.Time
+}
+
+// tokenCache maps raw tokens to the keys derived from them.
+//
+// When full, the cache is cleared rather than evicting individual entries,
+// which keeps it simple and bounded.
+type tokenCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]tokenCacheEntry
+}
+
+func (c *tokenCache) get(token string, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[token]
+	if !ok {
+		return "", false
+	}
+	if !now.Before(e.expiresAt) {
+		delete(c.entries, token)
+		return "", false
+	}
+	return e.key, true
+}
+
+func (c *tokenCache) put(token, key string, expiresAt time.Time) {
+	if c.size == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.size {
+		c.entries = make(map[string]tokenCacheEntry)
+	}
+	c.entries[token] = tokenCacheEntry{key: key, expiresAt: expiresAt}
+}
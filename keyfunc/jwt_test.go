@@ -0,0 +1,133 @@
+package keyfunc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// signHS256 returns a token carrying claims signed with secret.
+func signHS256(t *testing.T, secret []byte, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// bearerRequest returns a request authenticated with token.
+func bearerRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestJWTClaim(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name    string
+		opts    []JWTOption
+		token   string
+		want    string
+		wantErr error
+	}{
+		{name: "string claim", token: signHS256(t, secret, map[string]interface{}{"sub": "alice"}), want: "sub:alice"},
+		{name: "numeric claim", token: signHS256(t, secret, map[string]interface{}{"sub": 42}), want: "sub:42"},
+		{name: "missing claim", token: signHS256(t, secret, map[string]interface{}{"org": "acme"}), wantErr: ErrorInvalidToken},
+		{name: "expired", token: signHS256(t, secret, map[string]interface{}{"sub": "alice", "exp": now - 1}), wantErr: ErrorInvalidToken},
+		{name: "not expired", token: signHS256(t, secret, map[string]interface{}{"sub": "alice", "exp": now + 60}), want: "sub:alice"},
+		{name: "not yet valid", token: signHS256(t, secret, map[string]interface{}{"sub": "alice", "nbf": now + 60}), wantErr: ErrorInvalidToken},
+		{name: "valid since nbf", token: signHS256(t, secret, map[string]interface{}{"sub": "alice", "nbf": now - 1}), want: "sub:alice"},
+		{name: "malformed", token: "not.a-token", wantErr: ErrorInvalidToken},
+		{name: "no token", wantErr: ErrorMissingKey},
+		{
+			name:  "fallback",
+			opts:  []JWTOption{WithTokenFallback(func(r *http.Request) (string, error) { return "ip", nil })},
+			token: "",
+			want:  "fallback:ip",
+		},
+		{
+			name:  "verified",
+			opts:  []JWTOption{WithTokenVerifier(VerifyHS256(secret))},
+			token: signHS256(t, secret, map[string]interface{}{"sub": "alice"}),
+			want:  "sub:alice",
+		},
+		{
+			name:    "bad signature",
+			opts:    []JWTOption{WithTokenVerifier(VerifyHS256([]byte("other")))},
+			token:   signHS256(t, secret, map[string]interface{}{"sub": "alice"}),
+			wantErr: ErrorInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JWTClaim("sub", tt.opts...)(bearerRequest(tt.token))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWTClaimCache(t *testing.T) {
+	verified := 0
+	keyFunc := JWTClaim("sub", WithTokenVerifier(func(header map[string]interface{}, signingInput string, signature []byte) error {
+		verified++
+		return nil
+	}))
+	token := signHS256(t, []byte("secret"), map[string]interface{}{"sub": "alice"})
+
+	for range 3 {
+		if key, err := keyFunc(bearerRequest(token)); err != nil || key != "sub:alice" {
+			t.Fatalf("key = %q, %v", key, err)
+		}
+	}
+	if verified != 1 {
+		t.Errorf("token verified %d times, want once", verified)
+	}
+}
+
+func TestTokenCache(t *testing.T) {
+	now := time.Now()
+	cache := &tokenCache{size: 2, entries: make(map[string]tokenCacheEntry)}
+
+	cache.put("a", "sub:a", now.Add(time.Minute))
+	if key, ok := cache.get("a", now); !ok || key != "sub:a" {
+		t.Errorf("get = %q, %v, want the cached key", key, ok)
+	}
+	if _, ok := cache.get("a", now.Add(time.Minute)); ok {
+		t.Error("expired entry returned")
+	}
+
+	cache.put("b", "sub:b", now.Add(time.Minute))
+	cache.put("c", "sub:c", now.Add(time.Minute))
+	cache.put("d", "sub:d", now.Add(time.Minute))
+	if len(cache.entries) != 1 {
+		t.Errorf("cache holds %d entries after filling up, want 1", len(cache.entries))
+	}
+
+	disabled := &tokenCache{entries: make(map[string]tokenCacheEntry)}
+	disabled.put("a", "sub:a", now.Add(time.Minute))
+	if _, ok := disabled.get("a", now); ok {
+		t.Error("disabled cache returned an entry")
+	}
+}
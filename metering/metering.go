@@ -0,0 +1,298 @@
+// Package metering exports per-key usage records to a pluggable sink, so the
+// rate limiter can double as the metering source for usage-based billing.
+//
+// An Exporter wraps limiters, counts the units each key is allowed and denied
+// per interval on the local instance, and writes one Record per key and
+// interval to a Sink. Records from several instances are summed by key and
+// interval downstream.
+//
+// Example usage:
+//
+//	exporter := metering.New(ctx, metering.File("/var/log/ratelimit/usage.jsonl"),
+//	    metering.WithInterval(time.Minute),
+//	    metering.WithInstance(os.Getenv("HOSTNAME")),
+//	)
+//	limiter := exporter.Limiter("api", ratelimiter.MustNewFixedWindow(store, 1000, time.Minute))
+package metering
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// SchemaVersion is the version of the Record schema, written in every record.
+const SchemaVersion = 1
+
+// Record is the usage of one key during one interval on one instance.
+//
+// Records are encoded as JSON objects with the following fields:
+//
+//	{
+//	  "version": 1,                          // SchemaVersion
+//	  "instance": "api-7f9c",                // set with WithInstance, may be empty
+//	  "policy": "api",                       // name given to Exporter.Limiter
+//	  "key": "tenant:acme",                  // rate limit key
+//	  "start": "2024-05-01T12:00:00Z",       // interval start, inclusive (RFC 3339)
+//	  "end": "2024-05-01T12:01:00Z",         // interval end, exclusive (RFC 3339)
+//	  "allowed": 950,                        // units admitted
+//	  "denied": 12                           // units rejected
+//	}
+//
+// Fields may be added in later versions without changing SchemaVersion;
+// existing fields only change with a new SchemaVersion.
+type Record struct {
+	Version  int       `json:"version"`
+	Instance string    `json:"instance,omitempty"`
+	Policy   string    `json:"policy"`
+	Key      string    `json:"key"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Allowed  int64     `json:"allowed"`
+	Denied   int64     `json:"denied"`
+}
+
+// Option configures an Exporter.
+type Option func(*config)
+
+// config holds the settings collected from Option values.
+type config struct {
+	interval   time.Duration
+	instance   string
+	maxPending int
+	logger     ratelimiter.Logger
+}
+
+// WithInterval sets the length of the intervals records cover, which is also
+// how often records are written. The default is one minute.
+func WithInterval(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.interval = d
+		}
+	}
+}
+
+// WithInstance sets the instance identifier written in every record.
+func WithInstance(id string) Option {
+	return func(c *config) {
+		c.instance = id
+	}
+}
+
+// WithMaxPending sets how many records are kept for a later attempt while the
+// sink fails. Older records are dropped beyond it. The default is 100000.
+func WithMaxPending(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.maxPending = n
+		}
+	}
+}
+
+// WithLogger sets the Logger used to report failed writes and dropped records.
+func WithLogger(l ratelimiter.Logger) Option {
+	return func(c *config) {
+		if l != nil {
+			c.logger = l
+		}
+	}
+}
+
+// usageKey identifies the counts of one key under one policy.
+type usageKey struct {
+	policy string
+	key    string
+}
+
+// counts are the units allowed and denied for a usageKey in the current interval.
+type counts struct {
+	allowed int64
+	denied  int64
+}
+
+// Exporter accumulates usage and writes it to a Sink.
+type Exporter struct {
+	sink   Sink
+	cfg    config
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	start   time.Time
+	usage   map[usageKey]*counts
+	pending []Record
+}
+
+// New creates an Exporter writing to sink. Records are written by a background
+// goroutine at the end of every interval until ctx is canceled or Close is
+// called, at which point the current partial interval is written once.
+//
+// Records the sink fails to write are retried with the next interval's records.
+func New(ctx context.Context, sink Sink, opts ...Option) *Exporter {
+	cfg := config{
+		interval:   time.Minute,
+		maxPending: 100000,
+		logger:     noopLogger{},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	e := &Exporter{
+		sink:   sink,
+		cfg:    cfg,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		start:  time.Now().UTC().Truncate(cfg.interval),
+		usage:  make(map[usageKey]*counts),
+	}
+	go func() {
+		defer close(e.done)
+		e.run(ctx)
+	}()
+	return e
+}
+
+// Close stops the Exporter and waits until the current partial interval has
+// been written, or ctx expires.
+func (e *Exporter) Close(ctx context.Context) error {
+	e.cancel()
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Add records a decision about n units for key under policy.
+func (e *Exporter) Add(policy, key string, n int64, allowed bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	k := usageKey{policy: policy, key: key}
+	c, ok := e.usage[k]
+	if !ok {
+		c = &counts{}
+		e.usage[k] = c
+	}
+	if allowed {
+		c.allowed += n
+	} else {
+		c.denied += n
+	}
+}
+
+// run writes the records of every interval as it ends.
+func (e *Exporter) run(ctx context.Context) {
+	for {
+		e.mu.Lock()
+		next := e.start.Add(e.cfg.interval)
+		e.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			e.flush(ctx, next)
+		case <-ctx.Done():
+			timer.Stop()
+			// ctx is done, so the final flush gets a fresh one.
+			e.flush(context.Background(), time.Now().UTC())
+			return
+		}
+	}
+}
+
+// flush closes the current interval at end and writes its records along with
+// those left from failed writes.
+func (e *Exporter) flush(ctx context.Context, end time.Time) {
+	e.mu.Lock()
+	records := e.pending
+	for k, c := range e.usage {
+		records = append(records, Record{
+			Version:  SchemaVersion,
+			Instance: e.cfg.instance,
+			Policy:   k.policy,
+			Key:      k.key,
+			Start:    e.start,
+			End:      end,
+			Allowed:  c.allowed,
+			Denied:   c.denied,
+		})
+	}
+	e.usage = make(map[usageKey]*counts)
+	e.start = end
+	e.pending = nil
+	e.mu.Unlock()
+
+	if len(records) == 0 {
+		return
+	}
+	err := e.sink.Write(ctx, records)
+	if err == nil {
+		return
+	}
+
+	if dropped := len(records) - e.cfg.maxPending; dropped > 0 {
+		e.cfg.logger.Errorf("[RateLimiter] metering: dropping %d records: %v", dropped, err)
+		records = records[dropped:]
+	} else {
+		e.cfg.logger.Errorf("[RateLimiter] metering: writing %d records: %v", len(records), err)
+	}
+
+	e.mu.Lock()
+	e.pending = append(records, e.pending...)
+	e.mu.Unlock()
+}
+
+// Limiter returns a Limiter that delegates to inner and records every
+// decision under policy.
+func (e *Exporter) Limiter(policy string, inner ratelimiter.Limiter) ratelimiter.Limiter {
+	return &meteredLimiter{exporter: e, policy: policy, inner: inner}
+}
+
+// meteredLimiter is the Limiter returned by Exporter.Limiter.
+type meteredLimiter struct {
+	exporter *Exporter
+	policy   string
+	inner    ratelimiter.Limiter
+}
+
+// Allow delegates to the inner limiter and records the decision.
+func (l *meteredLimiter) Allow(ctx context.Context, key string) (ratelimiter.Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN delegates n units to the inner limiter and records the decision.
+func (l *meteredLimiter) AllowN(ctx context.Context, key string, n int64) (ratelimiter.Result, error) {
+	result, err := ratelimiter.AllowN(ctx, l.inner, key, n)
+	if err != nil {
+		return result, err
+	}
+	l.exporter.Add(l.policy, key, n, result.Allowed)
+	return result, nil
+}
+
+// Refund gives n units back to the inner limiter and removes them from the
+// allowed units of the current interval.
+func (l *meteredLimiter) Refund(ctx context.Context, key string, n int64) error {
+	refunder, ok := l.inner.(ratelimiter.Refunder)
+	if !ok {
+		return ratelimiter.ErrorRefundUnsupported
+	}
+	if err := refunder.Refund(ctx, key, n); err != nil {
+		return err
+	}
+	l.exporter.Add(l.policy, key, -n, true)
+	return nil
+}
+
+// noopLogger is a private default logger that does nothing.
+type noopLogger struct{}
+
+func (noopLogger) Debugf(format string, args ...interface{}) {}
+func (noopLogger) Errorf(format string, args ...interface{}) {}
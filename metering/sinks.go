@@ -0,0 +1,118 @@
+package metering
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Sink receives usage records.
+type Sink interface {
+	// Write stores records. A returned error makes the Exporter retry all of
+	// them with the next interval, so sinks should write a batch atomically or
+	// tolerate duplicates.
+	Write(ctx context.Context, records []Record) error
+}
+
+// SinkFunc adapts a function to a Sink, e.g. to publish records to a message
+// queue.
+//
+// Example, with github.com/segmentio/kafka-go:
+//
+//	writer := &kafka.Writer{Addr: kafka.TCP("kafka:9092"), Topic: "usage"}
+//	sink := metering.SinkFunc(func(ctx context.Context, records []metering.Record) error {
+//	    messages := make([]kafka.Message, len(records))
+//	    for i, record := range records {
+//	        value, _ := json.Marshal(record)
+//	        messages[i] = kafka.Message{Key: []byte(record.Key), Value: value}
+//	    }
+//	    return writer.WriteMessages(ctx, messages...)
+//	})
+type SinkFunc func(ctx context.Context, records []Record) error
+
+// Write calls f(ctx, records).
+func (f SinkFunc) Write(ctx context.Context, records []Record) error {
+	return f(ctx, records)
+}
+
+// File returns a Sink appending records to path as JSON lines, one record per
+// line. The file is created if needed.
+func File(path string) Sink {
+	return &fileSink{path: path}
+}
+
+// fileSink is the Sink returned by File.
+type fileSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (s *fileSink) Write(ctx context.Context, records []Record) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Webhook returns a Sink posting records to url as a JSON body of the form
+// {"records": [...]}. Any non-2xx response is an error.
+func Webhook(url string) Sink {
+	return WebhookWithClient(url, http.DefaultClient)
+}
+
+// WebhookWithClient is like Webhook but uses client for the requests, e.g. to
+// set a timeout or authentication.
+func WebhookWithClient(url string, client *http.Client) Sink {
+	return &webhookSink{url: url, client: client}
+}
+
+// webhookSink is the Sink returned by Webhook.
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookSink) Write(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(struct {
+		Records []Record `json:"records"`
+	}{records})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -8,16 +8,18 @@
 // Example usage:
 //
 //	import (
+//	    "context"
 //	    "time"
 //	    "github.com/gin-gonic/gin"
-//	    ratelimiter "github.com/jassus213/go-rate-limiter"
+//	    "github.com/jassus213/go-rate-limiter/ratelimiter"
+//	    "github.com/jassus213/go-rate-limiter/store"
 //	    "github.com/jassus213/go-rate-limiter/middleware/gin"
 //	)
 //
 //	func main() {
 //	    // Create a rate limiter instance (fixed window example)
-//	    store := ratelimiter.NewMemoryStore()
-//	    limiter := ratelimiter.NewFixedWindow(store, 100, time.Minute)
+//	    s := store.NewMemory(context.Background(), time.Minute)
+//	    limiter := ratelimiter.MustNewFixedWindow(s, 100, time.Minute)
 //
 //	    router := gin.Default()
 //
@@ -33,14 +35,58 @@
 package gin
 
 import (
+	"context"
 	"net/http"
-	"strconv"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	ratelimiter "github.com/jassus213/go-rate-limiter"
+	"github.com/jassus213/go-rate-limiter/httpheaders"
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
 )
 
+// ErrorHandler handles a request denied by the rate limiter with access to
+// the gin.Context, so that responses can be rendered with c.JSON or
+// c.AbortWithStatusJSON and errors recorded with c.Error for gin's error
+// aggregation. The middleware aborts the request after it returns.
+type ErrorHandler func(c *gin.Context, err error, result ratelimiter.Result)
+
+// ginContextKey is the request context key under which the middleware
+// passes the gin.Context to the handler set with WithGinErrorHandler.
+type ginContextKey struct{}
+
+// WithGinErrorHandler returns an Option that handles denied requests with h
+// instead of the http.ResponseWriter-based ratelimiter.ErrorHandler. Used
+// with other middleware, such as nethttp, denied requests are handled by the
+// error handler configured before this option.
+//
+// Example:
+//
+//	// ratelimitgin is this package; gin is github.com/gin-gonic/gin.
+//	router.Use(ratelimitgin.RateLimiter(limiter, ratelimitgin.WithGinErrorHandler(
+//	    func(c *gin.Context, err error, result ratelimiter.Result) {
+//	        _ = c.Error(err)
+//	        c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+//	            "error":       "rate_limited",
+//	            "retry_after": result.ResetAfter.Seconds(),
+//	        })
+//	    },
+//	)))
+func WithGinErrorHandler(h ErrorHandler) ratelimiter.Option {
+	return func(cfg *ratelimiter.Config) {
+		if h == nil {
+			return
+		}
+		fallback := cfg.ErrorHandler
+		cfg.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error, result ratelimiter.Result) {
+			if c, ok := r.Context().Value(ginContextKey{}).(*gin.Context); ok {
+				h(c, err, result)
+				return
+			}
+			fallback(w, r, err, result)
+		}
+	}
+}
+
 // RateLimiter creates a Gin middleware handler that enforces rate limiting.
 //
 // It uses the provided Limiter instance (the core rate-limiting logic) to check
@@ -53,6 +99,21 @@ import (
 //   - X-RateLimit-Remaining: the number of requests remaining in the current window
 //   - X-RateLimit-Reset: Unix timestamp when the limit will reset
 //
+// Use WithHeaderWriter with the httpheaders package to emit the IETF draft
+// RateLimit-* headers instead of, or in addition to, these.
+//
+// When rules are configured with WithRules, the matching rule's limiter is
+// used instead of limiter and its name is reported in the X-RateLimit-Rule header.
+// Requests matching no rule are passed through if limiter is nil.
+//
+// Requests exempted by the configuration, such as those carrying a valid
+// bypass token (see WithBypassTokens) or disabled by a feature flag (see
+// WithEnabledFunc), are passed through without touching the limiter or its store.
+//
+// Allowed requests carry the decision in their context, for
+// httpheaders.FromContext and httpheaders.Transport. The httpheaders.StateHeader
+// of every incoming request is removed, so that clients cannot claim a state.
+//
 // Logging: the middleware logs debug and error information using the provided Logger
 // (or the default noop logger if none is provided).
 //
@@ -62,43 +123,85 @@ import (
 //	router.Use(gin.RateLimiter(limiter))
 func RateLimiter(limiter ratelimiter.Limiter, options ...ratelimiter.Option) gin.HandlerFunc {
 	cfg := ratelimiter.NewConfig(options...)
+	writeHeaders := cfg.HeaderWriter
+	if writeHeaders == nil {
+		var headerOpts []httpheaders.Option
+		if cfg.ScopeHeader {
+			headerOpts = append(headerOpts, httpheaders.WithScope())
+		}
+		writeHeaders = httpheaders.Writer(headerOpts...)
+	}
 
 	return func(c *gin.Context) {
-		key, err := cfg.KeyFunc(c.Request)
+		// Only this middleware may vouch for the rate-limit state.
+		c.Request.Header.Del(httpheaders.StateHeader)
+
+		if cfg.Skip(c.Request) {
+			cfg.Logger.Debugf("[RateLimiter] Request bypassed rate limiting")
+			c.Next()
+			return
+		}
+
+		active, rule := cfg.Resolve(c.Request, limiter)
+		if active == nil {
+			c.Next()
+			return
+		}
+
+		keys, err := cfg.Keys(c.Request.Context(), c.Request)
 		if err != nil {
 			cfg.Logger.Errorf("[RateLimiter] Failed to extract key: %v", err)
-			c.AbortWithStatus(http.StatusInternalServerError)
+			cfg.KeyErrorHandler(c.Writer, c.Request, err)
+			c.Abort()
 			return
 		}
 
-		result, err := limiter.Allow(c.Request.Context(), key)
+		cost, err := cfg.Cost(c.Request)
 		if err != nil {
-			cfg.Logger.Errorf("[RateLimiter] Limiter failed for key '%s': %v", key, err)
-			c.AbortWithStatus(http.StatusInternalServerError)
+			cfg.Logger.Errorf("[RateLimiter] Failed to compute request cost: %v", err)
+			c.AbortWithStatus(http.StatusBadRequest)
 			return
 		}
 
-		c.Header("X-RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
-		c.Header("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+		key := strings.Join(keys, ", ")
+		result, err := ratelimiter.AllowKeys(c.Request.Context(), active, keys, cost)
+		if err != nil {
+			cfg.Logger.Errorf("[RateLimiter] Limiter failed for key '%s' (rule '%s'): %v", key, rule, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
 
-		resetTimestamp := time.Now().Add(result.ResetAfter).Unix()
-		c.Header("X-RateLimit-Reset", strconv.FormatInt(resetTimestamp, 10))
+		writeHeaders(c.Writer, result, rule)
 
 		if !result.Allowed {
 			cfg.Logger.Debugf(
-				"[RateLimiter]Request denied for key '%s'. Remaining: %d, Limit: %d",
-				key, result.Remaining, result.Limit,
+				"[RateLimiter]Request denied for key '%s' (rule '%s'). Remaining: %d, Limit: %d",
+				key, rule, result.Remaining, result.Limit,
 			)
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), ginContextKey{}, c))
 			cfg.ErrorHandler(c.Writer, c.Request, ratelimiter.ErrorExceeded, result)
 			c.Abort()
 			return
 		}
 
-		cfg.Logger.Debugf(
-			"[RateLimiter]Request allowed for key '%s'. Remaining: %d, Limit: %d",
-			key, result.Remaining, result.Limit,
-		)
+		if cfg.DebugEnabled() {
+			cfg.Logger.Debugf(
+				"[RateLimiter]Request allowed for key '%s' (rule '%s'). Remaining: %d, Limit: %d",
+				key, rule, result.Remaining, result.Limit,
+			)
+		}
 
+		ctx := httpheaders.NewContext(c.Request.Context(), result)
+		if !cfg.SettlementsEnabled() {
+			c.Request = c.Request.WithContext(ctx)
+			c.Next()
+			return
+		}
+		ctx, settlement := ratelimiter.NewSettlement(ctx, cost)
+		c.Request = c.Request.WithContext(ctx)
 		c.Next()
+		if err := settlement.Settle(context.WithoutCancel(ctx), active, keys); err != nil {
+			cfg.Logger.Errorf("[RateLimiter] Failed to settle cost for key '%s' (rule '%s'): %v", key, rule, err)
+		}
 	}
 }
@@ -0,0 +1,56 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StreamConcurrencyInterceptor returns a stream server interceptor that caps
+// the number of streams each key may have open at the same time.
+//
+// A lease is acquired when the stream opens, kept alive while it runs, and
+// released when the handler returns. Streams over the cap fail with
+// codes.ResourceExhausted. Combine it with StreamServerInterceptor to limit
+// the rate at which streams are opened as well.
+//
+// Example:
+//
+//	streams := ratelimiter.MustNewConcurrency(store, 10, time.Minute)
+//	server := grpc.NewServer(grpc.ChainStreamInterceptor(
+//	    grpcmw.StreamConcurrencyInterceptor(streams),
+//	    grpcmw.StreamServerInterceptor(limiter),
+//	))
+func StreamConcurrencyInterceptor(limiter *ratelimiter.ConcurrencyLimiter, opts ...Option) grpc.StreamServerInterceptor {
+	cfg := newConfig(opts)
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+
+		key, err := cfg.KeyFunc(ctx, info.FullMethod)
+		if err != nil {
+			cfg.Logger.Errorf("[RateLimiter] Failed to extract key: %v", err)
+			return status.Error(codes.Internal, "rate limiter: failed to extract key")
+		}
+
+		lease, result, err := limiter.Acquire(ctx, key)
+		if err != nil {
+			cfg.Logger.Errorf("[RateLimiter] Concurrency limiter failed for key '%s': %v", key, err)
+			return status.Error(codes.Internal, "rate limiter unavailable")
+		}
+		if !result.Allowed {
+			cfg.Logger.Debugf(
+				"[RateLimiter] Stream %s denied for key '%s': %d concurrent streams open",
+				info.FullMethod, key, result.Limit-result.Remaining,
+			)
+			return status.Error(codes.ResourceExhausted, "too many concurrent streams")
+		}
+		defer lease.Release(context.Background())
+		go lease.KeepAlive(ctx)
+
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,143 @@
+// Package grpc provides gRPC server interceptors for
+// github.com/jassus213/go-rate-limiter.
+//
+// The interceptors check every unary call or stream against a Limiter and
+// reject denied calls with codes.ResourceExhausted. Rate-limit information is
+// returned to clients as `x-ratelimit-*` header metadata.
+//
+// Example usage:
+//
+//	limiter := ratelimiter.MustNewTokenBucket(store, 10, 20)
+//
+//	server := grpc.NewServer(
+//	    grpc.UnaryInterceptor(grpcmw.UnaryServerInterceptor(limiter,
+//	        grpcmw.WithKeyFunc(grpcmw.Join(grpcmw.PeerIP(), grpcmw.FullMethod())),
+//	    )),
+//	    grpc.StreamInterceptor(grpcmw.StreamServerInterceptor(limiter)),
+//	)
+package grpc
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Config holds all configurable options for the gRPC interceptors.
+type Config struct {
+	KeyFunc KeyFunc
+	Logger  ratelimiter.Logger
+}
+
+// Option defines a functional option type for configuring the interceptors.
+type Option func(*Config)
+
+// WithKeyFunc returns an Option to set a custom KeyFunc.
+//
+// The default keys calls by peer IP address.
+func WithKeyFunc(f KeyFunc) Option {
+	return func(c *Config) {
+		if f != nil {
+			c.KeyFunc = f
+		}
+	}
+}
+
+// WithLogger returns an Option to set a custom Logger.
+func WithLogger(l ratelimiter.Logger) Option {
+	return func(c *Config) {
+		if l != nil {
+			c.Logger = l
+		}
+	}
+}
+
+// newConfig creates a Config with default settings, then applies opts.
+func newConfig(opts []Option) *Config {
+	cfg := &Config{
+		KeyFunc: PeerIP(),
+		Logger:  noopLogger{},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// UnaryServerInterceptor returns a unary server interceptor that enforces rate limiting.
+//
+// Denied calls fail with codes.ResourceExhausted; key extraction and limiter
+// errors fail with codes.Internal.
+func UnaryServerInterceptor(limiter ratelimiter.Limiter, opts ...Option) grpc.UnaryServerInterceptor {
+	cfg := newConfig(opts)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := cfg.check(ctx, limiter, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a stream server interceptor that enforces
+// rate limiting when a stream is opened.
+//
+// Messages exchanged on an admitted stream are not counted.
+func StreamServerInterceptor(limiter ratelimiter.Limiter, opts ...Option) grpc.StreamServerInterceptor {
+	cfg := newConfig(opts)
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := cfg.check(ss.Context(), limiter, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// check runs the limiter for the call and returns a gRPC status error if it is
+// denied or cannot be checked.
+func (c *Config) check(ctx context.Context, limiter ratelimiter.Limiter, fullMethod string) error {
+	key, err := c.KeyFunc(ctx, fullMethod)
+	if err != nil {
+		c.Logger.Errorf("[RateLimiter] Failed to extract key: %v", err)
+		return status.Error(codes.Internal, "rate limiter: failed to extract key")
+	}
+
+	result, err := limiter.Allow(ctx, key)
+	if err != nil {
+		c.Logger.Errorf("[RateLimiter] Limiter failed for key '%s': %v", key, err)
+		return status.Error(codes.Internal, "rate limiter unavailable")
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(
+		"x-ratelimit-limit", strconv.FormatInt(result.Limit, 10),
+		"x-ratelimit-remaining", strconv.FormatInt(result.Remaining, 10),
+		"x-ratelimit-reset", strconv.FormatInt(time.Now().Add(result.ResetAfter).Unix(), 10),
+	))
+
+	if !result.Allowed {
+		c.Logger.Debugf(
+			"[RateLimiter] Call %s denied for key '%s'. Remaining: %d, Limit: %d",
+			fullMethod, key, result.Remaining, result.Limit,
+		)
+		return status.Error(codes.ResourceExhausted, ratelimiter.ErrorExceeded.Error())
+	}
+
+	c.Logger.Debugf(
+		"[RateLimiter] Call %s allowed for key '%s'. Remaining: %d, Limit: %d",
+		fullMethod, key, result.Remaining, result.Limit,
+	)
+	return nil
+}
+
+// noopLogger is a private default logger that does nothing.
+type noopLogger struct{}
+
+func (noopLogger) Debugf(format string, args ...interface{}) {}
+func (noopLogger) Errorf(format string, args ...interface{}) {}
@@ -0,0 +1,123 @@
+package grpc
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"strings"
+
+	"github.com/jassus213/go-rate-limiter/keyfunc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// KeyFunc extracts the rate-limiting key of a gRPC call.
+//
+// fullMethod is the full RPC method name, e.g. "/pkg.Service/Method".
+type KeyFunc func(ctx context.Context, fullMethod string) (string, error)
+
+// PeerIP returns a KeyFunc that keys calls by client IP address.
+//
+// The peer address is taken from the connection. When the peer is one of the
+// proxies configured with keyfunc.WithTrustedProxies, the client address is
+// read from the forwarding metadata ("forwarded", "x-forwarded-for",
+// "x-real-ip") with the same right-to-left validation as keyfunc.ClientIP.
+// Prefix aggregation options apply as well.
+//
+// Example:
+//
+//	keyFunc := grpcmw.PeerIP(keyfunc.WithTrustedProxies("10.0.0.0/8"), keyfunc.WithIPv6Prefix(64))
+func PeerIP(opts ...keyfunc.IPOption) KeyFunc {
+	resolve := keyfunc.ClientIPResolver(opts...)
+
+	return func(ctx context.Context, fullMethod string) (string, error) {
+		p, ok := peer.FromContext(ctx)
+		if !ok || p.Addr == nil {
+			return "", keyfunc.ErrorNoClientIP
+		}
+
+		header := http.Header{}
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			for name, values := range md {
+				for _, v := range values {
+					header.Add(name, v)
+				}
+			}
+		}
+		return resolve(p.Addr.String(), header)
+	}
+}
+
+// Metadata returns a KeyFunc that keys calls by the first value of the named
+// incoming metadata entry, e.g. "x-api-key".
+//
+// Calls without the entry fail with keyfunc.ErrorMissingKey.
+func Metadata(name string) KeyFunc {
+	name = strings.ToLower(name)
+
+	return func(ctx context.Context, fullMethod string) (string, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(name)
+		if len(values) == 0 || strings.TrimSpace(values[0]) == "" {
+			return "", keyfunc.ErrorMissingKey
+		}
+		return strings.TrimSpace(values[0]), nil
+	}
+}
+
+// CertSubject returns a KeyFunc that keys calls by the subject of the client's
+// mTLS certificate.
+//
+// The verified chain's leaf is preferred; calls on connections without a
+// client certificate fail with keyfunc.ErrorMissingKey.
+func CertSubject() KeyFunc {
+	return func(ctx context.Context, fullMethod string) (string, error) {
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return "", keyfunc.ErrorMissingKey
+		}
+		tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+		if !ok {
+			return "", keyfunc.ErrorMissingKey
+		}
+
+		var leaf *x509.Certificate
+		if chains := tlsInfo.State.VerifiedChains; len(chains) > 0 && len(chains[0]) > 0 {
+			leaf = chains[0][0]
+		} else if certs := tlsInfo.State.PeerCertificates; len(certs) > 0 {
+			leaf = certs[0]
+		}
+		if leaf == nil {
+			return "", keyfunc.ErrorMissingKey
+		}
+		return leaf.Subject.String(), nil
+	}
+}
+
+// FullMethod returns a KeyFunc that keys calls by full method name.
+func FullMethod() KeyFunc {
+	return func(ctx context.Context, fullMethod string) (string, error) {
+		return fullMethod, nil
+	}
+}
+
+// Join returns a KeyFunc that concatenates the keys returned by funcs,
+// separated by keyfunc.DefaultSeparator.
+//
+// Example:
+//
+//	keyFunc := grpcmw.Join(grpcmw.Metadata("x-api-key"), grpcmw.FullMethod())
+func Join(funcs ...KeyFunc) KeyFunc {
+	return func(ctx context.Context, fullMethod string) (string, error) {
+		parts := make([]string, len(funcs))
+		for i, f := range funcs {
+			part, err := f(ctx, fullMethod)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = part
+		}
+		return strings.Join(parts, keyfunc.DefaultSeparator), nil
+	}
+}
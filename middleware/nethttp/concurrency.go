@@ -0,0 +1,62 @@
+package nethttp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// ConcurrencyMiddleware returns a middleware that caps the number of requests
+// each key may have in flight at the same time.
+//
+// It is meant for long-lived connections such as WebSockets or server-sent
+// events: a lease is acquired when the request starts, kept alive while the
+// handler runs, and released when it returns. Requests over the cap are passed
+// to the configured ErrorHandler.
+//
+// Example:
+//
+//	sockets := ratelimiter.MustNewConcurrency(store, 3, time.Minute)
+//	mux.Handle("/ws", nethttp.ConcurrencyMiddleware(sockets)(wsHandler))
+func ConcurrencyMiddleware(limiter *ratelimiter.ConcurrencyLimiter, options ...ratelimiter.Option) func(http.Handler) http.Handler {
+	cfg := ratelimiter.NewConfig(options...)
+	writeHeaders := headerWriter(cfg)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			dropClientState(r)
+
+			if cfg.Skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key, err := cfg.KeyFuncCtx(r.Context(), r)
+			if err != nil {
+				cfg.Logger.Errorf("[RateLimiter] Failed to extract key: %v", err)
+				cfg.KeyErrorHandler(w, r, err)
+				return
+			}
+
+			lease, result, err := limiter.Acquire(r.Context(), key)
+			if err != nil {
+				cfg.Logger.Errorf("[RateLimiter] Concurrency limiter failed for key '%s': %v", key, err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			writeHeaders(w, result, "")
+
+			if !result.Allowed {
+				cfg.Logger.Debugf("[RateLimiter] Request denied for key '%s': %d concurrent requests in flight", key, result.Limit-result.Remaining)
+				cfg.ErrorHandler(w, r, ratelimiter.ErrorExceeded, result)
+				return
+			}
+			defer lease.Release(context.Background())
+			go lease.KeepAlive(r.Context())
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
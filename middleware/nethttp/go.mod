@@ -1,5 +1,7 @@
-module github.com/jassus213/go-rate-limier/middleware/nethttp
+module github.com/jassus213/go-rate-limiter/middleware/nethttp
 
 go 1.25.4
 
-require github.com/jassus213/go-rate-limiter v0.0.1 // indirect
+require github.com/jassus213/go-rate-limiter v0.0.1
+
+replace github.com/jassus213/go-rate-limiter => ../..
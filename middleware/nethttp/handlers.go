@@ -0,0 +1,48 @@
+package nethttp
+
+import (
+	"net/http"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// LimitHandler wraps a single http.Handler with rate limiting.
+//
+// It is a one-line shorthand for Middleware(limiter, options...)(next), for
+// users who do not build middleware chains.
+//
+// Example:
+//
+//	http.Handle("/search", nethttp.LimitHandler(limiter, searchHandler))
+func LimitHandler(limiter ratelimiter.Limiter, next http.Handler, options ...ratelimiter.Option) http.Handler {
+	return Middleware(limiter, options...)(next)
+}
+
+// LimitFuncHandler wraps a handler function with rate limiting.
+//
+// Example:
+//
+//	http.Handle("/search", nethttp.LimitFuncHandler(limiter, func(w http.ResponseWriter, r *http.Request) {
+//	    w.Write([]byte("results"))
+//	}))
+func LimitFuncHandler(limiter ratelimiter.Limiter, fn func(http.ResponseWriter, *http.Request), options ...ratelimiter.Option) http.Handler {
+	return Middleware(limiter, options...)(http.HandlerFunc(fn))
+}
+
+// Limit returns a helper that wraps handler functions with rate limiting.
+//
+// The configuration is built once and shared by every wrapped handler, which
+// keeps per-route wiring short when several handlers share a limiter.
+//
+// Example:
+//
+//	limit := nethttp.Limit(limiter)
+//	http.HandleFunc("/search", limit(handleSearch))
+//	http.HandleFunc("/export", limit(handleExport))
+func Limit(limiter ratelimiter.Limiter, options ...ratelimiter.Option) func(http.HandlerFunc) http.HandlerFunc {
+	mw := Middleware(limiter, options...)
+
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return mw(h).ServeHTTP
+	}
+}
@@ -17,7 +17,7 @@
 //
 //	func main() {
 //	    store := ratelimiter.NewMemoryStore()
-//	    limiter := ratelimiter.NewFixedWindow(store, 100, time.Minute)
+//	    limiter := ratelimiter.MustNewFixedWindow(store, 100, time.Minute)
 //
 //	    mux := http.NewServeMux()
 //	    mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -32,10 +32,11 @@
 package nethttp
 
 import (
+	"context"
 	"net/http"
-	"strconv"
-	"time"
+	"strings"
 
+	"github.com/jassus213/go-rate-limiter/httpheaders"
 	"github.com/jassus213/go-rate-limiter/ratelimiter"
 )
 
@@ -48,46 +49,118 @@ import (
 //   - X-RateLimit-Remaining: the number of requests remaining in the current window
 //   - X-RateLimit-Reset: Unix timestamp when the limit will reset
 //
+// Use WithHeaderWriter with the httpheaders package to emit the IETF draft
+// RateLimit-* headers instead of, or in addition to, these.
+//
+// When rules are configured with WithRules, the matching rule's limiter is
+// used instead of limiter and its name is reported in the X-RateLimit-Rule header.
+// Requests matching no rule are passed through if limiter is nil.
+//
+// Requests exempted by the configuration, such as those carrying a valid
+// bypass token (see WithBypassTokens) or disabled by a feature flag (see
+// WithEnabledFunc), are passed through without touching the limiter or its store.
+//
+// Allowed requests carry the decision in their context, for
+// httpheaders.FromContext and httpheaders.Transport. The httpheaders.StateHeader
+// of every incoming request is removed, so that clients cannot claim a state.
+// With WithSettlements, handlers may adjust the cost charged with
+// ratelimiter.Charge and ratelimiter.Refund.
+//
 // Behavior can be customized using functional options such as WithKeyFunc,
 // WithErrorHandler, or WithLogger.
 func Middleware(limiter ratelimiter.Limiter, options ...ratelimiter.Option) func(http.Handler) http.Handler {
 	cfg := ratelimiter.NewConfig(options...)
+	writeHeaders := headerWriter(cfg)
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			key, err := cfg.KeyFunc(r)
+			dropClientState(r)
+
+			if cfg.Skip(r) {
+				cfg.Logger.Debugf("[RateLimiter] Request bypassed rate limiting")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			active, rule := cfg.Resolve(r, limiter)
+			if active == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			keys, err := cfg.Keys(r.Context(), r)
 			if err != nil {
 				cfg.Logger.Errorf("[RateLimiter] Failed to extract key: %v", err)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				cfg.KeyErrorHandler(w, r, err)
+				return
+			}
+
+			cost, err := cfg.Cost(r)
+			if err != nil {
+				cfg.Logger.Errorf("[RateLimiter] Failed to compute request cost: %v", err)
+				http.Error(w, "Bad Request", http.StatusBadRequest)
 				return
 			}
 
-			result, err := limiter.Allow(r.Context(), key)
+			key := strings.Join(keys, ", ")
+			result, err := ratelimiter.AllowKeys(r.Context(), active, keys, cost)
 			if err != nil {
-				cfg.Logger.Errorf("[RateLimiter]Limiter failed for key '%s': %v", key, err)
+				cfg.Logger.Errorf("[RateLimiter]Limiter failed for key '%s' (rule '%s'): %v", key, rule, err)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
 
-			w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
-			w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
-			resetTimestamp := time.Now().Add(result.ResetAfter).Unix()
-			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetTimestamp, 10))
+			writeHeaders(w, result, rule)
 
 			if !result.Allowed {
 				cfg.Logger.Debugf(
-					"[RateLimiter] Request denied for key '%s'. Remaining: %d, Limit: %d",
-					key, result.Remaining, result.Limit,
+					"[RateLimiter] Request denied for key '%s' (rule '%s'). Remaining: %d, Limit: %d",
+					key, rule, result.Remaining, result.Limit,
 				)
 				cfg.ErrorHandler(w, r, ratelimiter.ErrorExceeded, result)
 				return
 			}
 
-			cfg.Logger.Debugf(
-				"[RateLimiter] Request allowed for key '%s'. Remaining: %d, Limit: %d",
-				key, result.Remaining, result.Limit,
-			)
-			next.ServeHTTP(w, r)
+			if cfg.DebugEnabled() {
+				cfg.Logger.Debugf(
+					"[RateLimiter] Request allowed for key '%s'. Remaining: %d, Limit: %d",
+					key, result.Remaining, result.Limit,
+				)
+			}
+			ctx := httpheaders.NewContext(r.Context(), result)
+			if !cfg.SettlementsEnabled() {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+			ctx, settlement := ratelimiter.NewSettlement(ctx, cost)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			if err := settlement.Settle(context.WithoutCancel(ctx), active, keys); err != nil {
+				cfg.Logger.Errorf("[RateLimiter] Failed to settle cost for key '%s' (rule '%s'): %v", key, rule, err)
+			}
 		})
 	}
 }
+
+// headerWriter returns the configured HeaderWriter or, by default, one
+// writing the legacy headers and, with WithScopeHeader, the scope.
+func headerWriter(cfg *ratelimiter.Config) ratelimiter.HeaderWriter {
+	if cfg.HeaderWriter != nil {
+		return cfg.HeaderWriter
+	}
+	var headerOpts []httpheaders.Option
+	if cfg.ScopeHeader {
+		headerOpts = append(headerOpts, httpheaders.WithScope())
+	}
+	return httpheaders.Writer(headerOpts...)
+}
+
+// stateHeaderKey is httpheaders.StateHeader in canonical form, as the server
+// stores it, so that removing it does not canonicalize it on every request.
+var stateHeaderKey = http.CanonicalHeaderKey(httpheaders.StateHeader)
+
+// dropClientState removes the httpheaders.StateHeader sent by the client, so
+// that handlers and httpheaders.Transport only see state vouched for by the
+// middleware.
+func dropClientState(r *http.Request) {
+	delete(r.Header, stateHeaderKey)
+}
@@ -0,0 +1,121 @@
+package nethttp
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// counterStore is a minimal fixed window store whose windows never expire.
+type counterStore struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func newCounterStore() *counterStore {
+	return &counterStore{counts: make(map[string]int64)}
+}
+
+func (s *counterStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[key]++
+	return s.counts[key], window, nil
+}
+
+func (s *counterStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, error) {
+	return true, float64(burst - 1), nil
+}
+
+// headerRecorder is an http.ResponseWriter whose header map can be reused.
+type headerRecorder struct {
+	header http.Header
+	status int
+}
+
+func (w *headerRecorder) Header() http.Header         { return w.header }
+func (w *headerRecorder) Write(b []byte) (int, error) { return len(b), nil }
+func (w *headerRecorder) WriteHeader(status int)      { w.status = status }
+
+// allowedPathAllocs is the allocation budget of a request admitted by
+// Middleware with the default configuration: the key slice, the context
+// carrying the decision, the request copy, and the formatted
+// X-RateLimit-Remaining value.
+const allowedPathAllocs = 6
+
+func TestMiddlewareAllowedPathAllocs(t *testing.T) {
+	limiter := ratelimiter.MustNewFixedWindow(newCounterStore(), 1<<40, time.Hour)
+	handler := Middleware(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := &headerRecorder{header: make(http.Header)}
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	handler.ServeHTTP(w, r)
+	if w.status != 0 {
+		t.Fatalf("status = %d, want the request admitted", w.status)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		clear(w.header)
+		handler.ServeHTTP(w, r)
+	})
+	if allocs > allowedPathAllocs {
+		t.Errorf("allowed request allocates %.1f times, want at most %d", allocs, allowedPathAllocs)
+	}
+}
+
+func TestMiddlewareSettlements(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []ratelimiter.Option
+		wantErr   error
+		wantCount int64
+	}{
+		{name: "disabled", wantErr: ratelimiter.ErrorNoSettlement, wantCount: 1},
+		{name: "enabled", opts: []ratelimiter.Option{ratelimiter.WithSettlements()}, wantCount: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newCounterStore()
+			limiter := ratelimiter.MustNewFixedWindow(store, 10, time.Hour)
+			var chargeErr error
+			handler := Middleware(limiter, tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				chargeErr = ratelimiter.Charge(r.Context(), 1)
+			}))
+
+			r, _ := http.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			handler.ServeHTTP(&headerRecorder{header: make(http.Header)}, r)
+
+			if chargeErr != tt.wantErr {
+				t.Errorf("Charge error = %v, want %v", chargeErr, tt.wantErr)
+			}
+			var count int64
+			for _, n := range store.counts {
+				count += n
+			}
+			if count != tt.wantCount {
+				t.Errorf("units charged = %d, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func BenchmarkMiddlewareAllowed(b *testing.B) {
+	limiter := ratelimiter.MustNewFixedWindow(newCounterStore(), 1<<40, time.Hour)
+	handler := Middleware(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	w := &headerRecorder{header: make(http.Header)}
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+
+	b.ReportAllocs()
+	for range b.N {
+		clear(w.header)
+		handler.ServeHTTP(w, r)
+	}
+}
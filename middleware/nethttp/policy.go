@@ -0,0 +1,76 @@
+package nethttp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jassus213/go-rate-limiter/httpheaders"
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// PolicyMiddleware returns a middleware enforcing both the rate limit and the
+// concurrency cap of policy in one pass.
+//
+// Headers describe the limit closest to denying the request, and the
+// concurrency lease, if any, is kept alive while the handler runs and released
+// when it returns. Denied requests are passed to the configured ErrorHandler.
+// As with Middleware, allowed requests carry the decision in their context and
+// the incoming httpheaders.StateHeader is removed.
+//
+// Example:
+//
+//	reports := ratelimiter.Policy{
+//	    Rate:        ratelimiter.MustNewTokenBucket(store, 1, 10),
+//	    Concurrency: ratelimiter.MustNewConcurrency(store, 2, time.Minute),
+//	}
+//	mux.Handle("/reports", nethttp.PolicyMiddleware(reports)(reportsHandler))
+func PolicyMiddleware(policy ratelimiter.Policy, options ...ratelimiter.Option) func(http.Handler) http.Handler {
+	cfg := ratelimiter.NewConfig(options...)
+	writeHeaders := headerWriter(cfg)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			dropClientState(r)
+
+			if cfg.Skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key, err := cfg.KeyFuncCtx(r.Context(), r)
+			if err != nil {
+				cfg.Logger.Errorf("[RateLimiter] Failed to extract key: %v", err)
+				cfg.KeyErrorHandler(w, r, err)
+				return
+			}
+
+			cost, err := cfg.Cost(r)
+			if err != nil {
+				cfg.Logger.Errorf("[RateLimiter] Failed to compute request cost: %v", err)
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+
+			lease, result, err := policy.Acquire(r.Context(), key, cost)
+			if err != nil {
+				cfg.Logger.Errorf("[RateLimiter] Policy failed for key '%s': %v", key, err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			writeHeaders(w, result, "")
+
+			if !result.Allowed {
+				cfg.Logger.Debugf("[RateLimiter] Request denied for key '%s' by %s limit", key, result.Algorithm)
+				cfg.ErrorHandler(w, r, ratelimiter.ErrorExceeded, result)
+				return
+			}
+			if lease != nil {
+				defer lease.Release(context.Background())
+				go lease.KeepAlive(r.Context())
+			}
+
+			next.ServeHTTP(w, r.WithContext(httpheaders.NewContext(r.Context(), result)))
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package nethttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/jassus213/go-rate-limiter/httpheaders"
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// stream is the event limiting state of one long-lived connection.
+type stream struct {
+	events ratelimiter.Limiter
+	key    string
+}
+
+// streamKey is the context key under which StreamMiddleware stores a stream.
+type streamKey struct{}
+
+// StreamMiddleware returns a middleware for server-sent events and other
+// long-lived HTTP connections, which limits how often clients may open
+// connections separately from how many events each connection may carry.
+//
+// Opening a connection is checked once against connects, or the limiter of the
+// rule matched with WithRules, so that a connection held open for hours costs
+// one request of window quota, not one per window it spans. Events are limited
+// per connection with events, which handlers consult through WaitEvent or
+// AllowEvent; their keys have the form "<key>|stream:<id>", where id is unique
+// to the connection. A nil events leaves events unlimited. Headers and the
+// request context are handled as by Middleware.
+//
+// To also cap how many connections a client holds open at once, chain
+// ConcurrencyMiddleware. Endpoints behind StreamMiddleware should not also be
+// covered by Middleware, or opening a connection is charged twice.
+//
+// Example:
+//
+//	connects := ratelimiter.MustNewFixedWindow(store, 10, time.Minute)
+//	events := ratelimiter.MustNewTokenBucket(store, 5, 20)
+//	mux.Handle("/events", nethttp.StreamMiddleware(connects, events)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//	    for update := range updates {
+//	        if err := nethttp.WaitEvent(r.Context()); err != nil {
+//	            return
+//	        }
+//	        fmt.Fprintf(w, "data: %s\n\n", update)
+//	        w.(http.Flusher).Flush()
+//	    }
+//	})))
+func StreamMiddleware(connects, events ratelimiter.Limiter, options ...ratelimiter.Option) func(http.Handler) http.Handler {
+	cfg := ratelimiter.NewConfig(options...)
+	writeHeaders := headerWriter(cfg)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			dropClientState(r)
+
+			if cfg.Skip(r) {
+				cfg.Logger.Debugf("[RateLimiter] Request bypassed rate limiting")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			active, rule := cfg.Resolve(r, connects)
+			if active == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			keys, err := cfg.Keys(r.Context(), r)
+			if err != nil {
+				cfg.Logger.Errorf("[RateLimiter] Failed to extract key: %v", err)
+				cfg.KeyErrorHandler(w, r, err)
+				return
+			}
+
+			key := strings.Join(keys, ", ")
+			result, err := ratelimiter.AllowKeys(r.Context(), active, keys, 1)
+			if err != nil {
+				cfg.Logger.Errorf("[RateLimiter] Limiter failed for stream key '%s' (rule '%s'): %v", key, rule, err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			writeHeaders(w, result, rule)
+
+			if !result.Allowed {
+				cfg.Logger.Debugf("[RateLimiter] Stream connection denied for key '%s' (rule '%s')", key, rule)
+				cfg.ErrorHandler(w, r, ratelimiter.ErrorExceeded, result)
+				return
+			}
+
+			ctx := httpheaders.NewContext(r.Context(), result)
+			if events != nil {
+				s := &stream{events: events, key: key + "|stream:" + connectionID()}
+				ctx = context.WithValue(ctx, streamKey{}, s)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// connectionID returns a random identifier for a connection.
+func connectionID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// AllowEvent reports whether the connection ctx belongs to may send another
+// event now, charging the event if so. Handlers use it to drop or coalesce
+// events over the rate. Outside StreamMiddleware, or without an events
+// limiter, every event is allowed.
+func AllowEvent(ctx context.Context) (bool, error) {
+	s, ok := ctx.Value(streamKey{}).(*stream)
+	if !ok {
+		return true, nil
+	}
+	result, err := s.events.Allow(ctx, s.key)
+	if err != nil {
+		return false, err
+	}
+	return result.Allowed, nil
+}
+
+// WaitEvent blocks until the connection ctx belongs to may send another event,
+// charging the event, or until ctx is done, e.g. because the client
+// disconnected, returning its error. Outside StreamMiddleware, or without an
+// events limiter, it returns immediately.
+func WaitEvent(ctx context.Context) error {
+	s, ok := ctx.Value(streamKey{}).(*stream)
+	if !ok {
+		return nil
+	}
+	return ratelimiter.Wait(ctx, s.events, s.key, 1)
+}
@@ -0,0 +1,76 @@
+package netlimit
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// WithConnectionCap caps the number of connections each remote IP may hold
+// open at the same time.
+//
+// Each accepted connection holds a lease from limiter, which is kept alive
+// while the connection is open and released when it is closed. Connections
+// over the cap are closed immediately, even when WithDelay is given.
+//
+// Example:
+//
+//	conns := ratelimiter.MustNewConcurrency(store, 20, 5*time.Minute)
+//	ln := netlimit.Listener(inner, perIP, netlimit.WithConnectionCap(conns))
+func WithConnectionCap(limiter *ratelimiter.ConcurrencyLimiter) Option {
+	return func(c *config) {
+		c.conns = limiter
+	}
+}
+
+// acquire takes a connection lease for ip. It returns a nil lease, and true,
+// when no connection cap is configured or the limiter fails.
+func (l *limitedListener) acquire(ip string) (*ratelimiter.Lease, bool) {
+	if l.cfg.conns == nil {
+		return nil, true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.cfg.timeout)
+	defer cancel()
+	lease, result, err := l.cfg.conns.Acquire(ctx, ip)
+	if err != nil {
+		l.cfg.logger.Errorf("[RateLimiter] Concurrency limiter failed for key '%s': %v", ip, err)
+		return nil, true
+	}
+	return lease, result.Allowed
+}
+
+// release gives back a lease taken for a connection that was then rejected.
+func (l *limitedListener) release(lease *ratelimiter.Lease) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.cfg.timeout)
+	defer cancel()
+	if err := lease.Release(ctx); err != nil {
+		l.cfg.logger.Errorf("[RateLimiter] Failed to release connection lease: %v", err)
+	}
+}
+
+// leasedConn is a connection that releases its lease when closed.
+type leasedConn struct {
+	net.Conn
+	lease  *ratelimiter.Lease
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+// newLeasedConn wraps conn so that lease is kept alive until conn is closed.
+func newLeasedConn(conn net.Conn, lease *ratelimiter.Lease) net.Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	go lease.KeepAlive(ctx)
+	return &leasedConn{Conn: conn, lease: lease, cancel: cancel}
+}
+
+// Close closes the connection and releases its lease.
+func (c *leasedConn) Close() error {
+	c.once.Do(func() {
+		c.cancel()
+		_ = c.lease.Release(context.Background())
+	})
+	return c.Conn.Close()
+}
@@ -0,0 +1,182 @@
+// Package netlimit applies rate limits from github.com/jassus213/go-rate-limiter
+// at the TCP level.
+//
+// Limiting at the listener rejects excess connections before they reach the
+// HTTP stack or any other protocol handler, which protects against connection
+// floods that are cheap for an attacker but expensive to serve.
+//
+// Example usage:
+//
+//	inner, err := net.Listen("tcp", ":8080")
+//	perIP := ratelimiter.MustNewTokenBucket(store, 5, 20)
+//	global := ratelimiter.MustNewTokenBucket(store, 500, 1000)
+//
+//	ln := netlimit.Listener(inner, perIP, netlimit.WithGlobalLimiter(global))
+//	http.Serve(ln, handler)
+package netlimit
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// GlobalKey is the key under which the global limiter counts connections.
+const GlobalKey = "netlimit:global"
+
+// Option configures a limited listener.
+type Option func(*config)
+
+// config holds the settings collected from Option values.
+type config struct {
+	global  ratelimiter.Limiter
+	conns   *ratelimiter.ConcurrencyLimiter
+	delay   bool
+	timeout time.Duration
+	logger  ratelimiter.Logger
+}
+
+// newConfig applies the given options on top of the defaults.
+func newConfig(opts []Option) config {
+	c := config{timeout: 100 * time.Millisecond, logger: noopLogger{}}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithGlobalLimiter limits the rate of accepted connections across all source
+// IPs in addition to the per-IP limit.
+func WithGlobalLimiter(limiter ratelimiter.Limiter) Option {
+	return func(c *config) {
+		c.global = limiter
+	}
+}
+
+// WithDelay makes the listener wait until excess connections are admitted
+// instead of closing them.
+//
+// While a connection waits, Accept does not return, so no other connection is
+// accepted either; the kernel backlog then absorbs the flood. Use delaying
+// with token bucket limiters only.
+func WithDelay() Option {
+	return func(c *config) {
+		c.delay = true
+	}
+}
+
+// WithTimeout bounds each limiter check of a connection, after which the
+// connection is admitted as if the limiter had failed. Accept checks
+// connections one at a time, so the timeout keeps a slow store, such as an
+// overloaded Redis, from stalling the accept loop. It does not apply to the
+// waits of WithDelay. The default is 100 milliseconds.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.timeout = d
+		}
+	}
+}
+
+// WithLogger sets the Logger used to report rejected connections and limiter errors.
+func WithLogger(l ratelimiter.Logger) Option {
+	return func(c *config) {
+		if l != nil {
+			c.logger = l
+		}
+	}
+}
+
+// limitedListener is a net.Listener that rate-limits accepted connections.
+type limitedListener struct {
+	net.Listener
+	limiter ratelimiter.Limiter
+	cfg     config
+}
+
+// Listener wraps inner so that new connections are rate-limited per source IP
+// by limiter, keyed by the remote IP address.
+//
+// Excess connections are closed immediately after being accepted, unless
+// WithDelay is given. If a limiter fails or exceeds WithTimeout, the
+// connection is admitted. limiter may be nil to enforce only the global limit
+// or the connection cap.
+//
+// The per-IP limits are checked before the global limit, which only counts
+// connections they admit, so that a single flooding IP cannot use up the
+// global limit for everybody.
+//
+// Example:
+//
+//	ln := netlimit.Listener(inner, perIP)
+func Listener(inner net.Listener, limiter ratelimiter.Limiter, opts ...Option) net.Listener {
+	return &limitedListener{Listener: inner, limiter: limiter, cfg: newConfig(opts)}
+}
+
+// Accept waits for and returns the next connection admitted by the limiters.
+func (l *limitedListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		ip := remoteIP(conn)
+		if l.admit(l.limiter, ip) {
+			lease, ok := l.acquire(ip)
+			if ok && l.admit(l.cfg.global, GlobalKey) {
+				if lease == nil {
+					return conn, nil
+				}
+				return newLeasedConn(conn, lease), nil
+			}
+			if lease != nil {
+				l.release(lease)
+			}
+		}
+
+		l.cfg.logger.Debugf("[RateLimiter] Connection from %s rejected", ip)
+		conn.Close()
+	}
+}
+
+// admit reports whether limiter admits a connection for key.
+func (l *limitedListener) admit(limiter ratelimiter.Limiter, key string) bool {
+	if limiter == nil {
+		return true
+	}
+
+	if l.cfg.delay {
+		if err := ratelimiter.Wait(context.Background(), limiter, key, 1); err != nil {
+			l.cfg.logger.Errorf("[RateLimiter] Limiter failed for key '%s': %v", key, err)
+		}
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.cfg.timeout)
+	defer cancel()
+	result, err := limiter.Allow(ctx, key)
+	if err != nil {
+		l.cfg.logger.Errorf("[RateLimiter] Limiter failed for key '%s': %v", key, err)
+		return true
+	}
+	return result.Allowed
+}
+
+// remoteIP returns the IP address of the connection's remote end.
+func remoteIP(conn net.Conn) string {
+	addr := conn.RemoteAddr().String()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// noopLogger is a private default logger that does nothing.
+type noopLogger struct{}
+
+func (noopLogger) Debugf(format string, args ...interface{}) {}
+func (noopLogger) Errorf(format string, args ...interface{}) {}
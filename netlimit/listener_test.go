@@ -0,0 +1,206 @@
+package netlimit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	"github.com/jassus213/go-rate-limiter/store"
+)
+
+// fakeConn is a connection from a fixed remote address.
+type fakeConn struct {
+	net.Conn
+	remote net.Addr
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (c *fakeConn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *fakeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// fakeListener accepts a fixed list of connections, then fails with
+// net.ErrClosed.
+type fakeListener struct {
+	net.Listener
+	conns []*fakeConn
+}
+
+func (l *fakeListener) Accept() (net.Conn, error) {
+	if len(l.conns) == 0 {
+		return nil, net.ErrClosed
+	}
+	conn := l.conns[0]
+	l.conns = l.conns[1:]
+	return conn, nil
+}
+
+// dial returns connections from the given IP addresses.
+func dial(ips ...string) []*fakeConn {
+	conns := make([]*fakeConn, len(ips))
+	for i, ip := range ips {
+		conns[i] = &fakeConn{remote: &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000 + i}}
+	}
+	return conns
+}
+
+// quota admits limit connections per key.
+type quota struct {
+	limit int
+	err   error
+	used  map[string]int
+}
+
+func (q *quota) Allow(ctx context.Context, key string) (ratelimiter.Result, error) {
+	if q.err != nil {
+		return ratelimiter.Result{}, q.err
+	}
+	if q.used == nil {
+		q.used = make(map[string]int)
+	}
+	if q.used[key] >= q.limit {
+		return ratelimiter.Result{Allowed: false}, nil
+	}
+	q.used[key]++
+	return ratelimiter.Result{Allowed: true}, nil
+}
+
+// acceptAll accepts connections from ln until it fails and returns the remote
+// IPs of those admitted.
+func acceptAll(t *testing.T, ln net.Listener) []string {
+	t.Helper()
+	var ips []string
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				t.Fatalf("Accept: %v", err)
+			}
+			return ips
+		}
+		ips = append(ips, remoteIP(conn))
+	}
+}
+
+func TestListener(t *testing.T) {
+	tests := []struct {
+		name     string
+		perIP    ratelimiter.Limiter
+		opts     []Option
+		ips      []string
+		wantIPs  []string
+		wantGone []int
+	}{
+		{
+			name:     "per ip",
+			perIP:    &quota{limit: 2},
+			ips:      []string{"192.0.2.1", "192.0.2.1", "192.0.2.1", "192.0.2.2"},
+			wantIPs:  []string{"192.0.2.1", "192.0.2.1", "192.0.2.2"},
+			wantGone: []int{2},
+		},
+		{
+			name:     "global",
+			opts:     []Option{WithGlobalLimiter(&quota{limit: 2})},
+			ips:      []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"},
+			wantIPs:  []string{"192.0.2.1", "192.0.2.2"},
+			wantGone: []int{2},
+		},
+		{
+			name:     "global only counts admitted connections",
+			perIP:    &quota{limit: 1},
+			opts:     []Option{WithGlobalLimiter(&quota{limit: 2})},
+			ips:      []string{"192.0.2.1", "192.0.2.1", "192.0.2.1", "192.0.2.2"},
+			wantIPs:  []string{"192.0.2.1", "192.0.2.2"},
+			wantGone: []int{1, 2},
+		},
+		{
+			name:    "limiter failure admits",
+			perIP:   &quota{err: errors.New("store unavailable")},
+			ips:     []string{"192.0.2.1", "192.0.2.1"},
+			wantIPs: []string{"192.0.2.1", "192.0.2.1"},
+		},
+		{
+			name:    "ipv6",
+			perIP:   &quota{limit: 1},
+			ips:     []string{"2001:db8::1", "2001:db8::2"},
+			wantIPs: []string{"2001:db8::1", "2001:db8::2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conns := dial(tt.ips...)
+			ln := Listener(&fakeListener{conns: conns}, tt.perIP, tt.opts...)
+
+			got := acceptAll(t, ln)
+			if len(got) != len(tt.wantIPs) {
+				t.Fatalf("admitted %v, want %v", got, tt.wantIPs)
+			}
+			for i := range got {
+				if got[i] != tt.wantIPs[i] {
+					t.Errorf("admitted %v, want %v", got, tt.wantIPs)
+					break
+				}
+			}
+
+			gone := make(map[int]bool)
+			for _, i := range tt.wantGone {
+				gone[i] = true
+			}
+			for i, conn := range conns {
+				if conn.isClosed() != gone[i] {
+					t.Errorf("connection %d closed = %v, want %v", i, conn.isClosed(), gone[i])
+				}
+			}
+		})
+	}
+}
+
+func TestListenerConnectionCap(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory(ctx, time.Minute)
+	defer s.(*store.MemoryStore).Close(ctx)
+	conns := ratelimiter.MustNewConcurrency(s, 1, time.Minute)
+
+	dialed := dial("192.0.2.1", "192.0.2.1", "192.0.2.2")
+	inner := &fakeListener{conns: dialed}
+	ln := Listener(inner, nil, WithConnectionCap(conns))
+
+	first, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remoteIP(second) != "192.0.2.2" || !dialed[1].isClosed() {
+		t.Fatalf("second connection from 192.0.2.1 was admitted over the cap")
+	}
+
+	// Closing the first connection gives its lease back.
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	inner.conns = dial("192.0.2.1")
+	if _, err := ln.Accept(); err != nil {
+		t.Fatalf("connection after the lease was released: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+const (
+	// EventKeysBanned is sent when key patterns are denied with Manager.DenyKeys.
+	EventKeysBanned EventType = "keys_banned"
+	// EventThresholdCrossed is sent when a policy's deny ratio exceeds its threshold.
+	EventThresholdCrossed EventType = "threshold_crossed"
+	// EventThresholdResolved is sent when a policy's deny ratio is back under its threshold.
+	EventThresholdResolved EventType = "threshold_resolved"
+	// EventStoreUnhealthy is sent when the store stops answering pings.
+	EventStoreUnhealthy EventType = "store_unhealthy"
+	// EventStoreRecovered is sent when the store answers pings again.
+	EventStoreRecovered EventType = "store_recovered"
+)
+
+// Event is a notification about the rate limiter.
+type Event struct {
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	Policy  string    `json:"policy,omitempty"`
+	Keys    []string  `json:"keys,omitempty"`
+	Ratio   float64   `json:"ratio,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// String returns a one-line, human-readable description of the event.
+func (e Event) String() string {
+	switch e.Type {
+	case EventKeysBanned:
+		return fmt.Sprintf("Rate limiter: banned keys %s", strings.Join(e.Keys, ", "))
+	case EventThresholdCrossed:
+		return fmt.Sprintf("Rate limiter: policy %q denies %.0f%% of requests", e.Policy, 100*e.Ratio)
+	case EventThresholdResolved:
+		return fmt.Sprintf("Rate limiter: policy %q is back to %.0f%% denied requests", e.Policy, 100*e.Ratio)
+	case EventStoreUnhealthy:
+		return fmt.Sprintf("Rate limiter: store unhealthy: %s", e.Message)
+	case EventStoreRecovered:
+		return "Rate limiter: store recovered"
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("Rate limiter: %s: %s", e.Type, e.Message)
+	}
+	return fmt.Sprintf("Rate limiter: %s", e.Type)
+}
+
+// KeysBanned sends an EventKeysBanned event. Its signature matches
+// Manager.OnDenyKeys.
+//
+// Example:
+//
+//	manager.OnDenyKeys(notifier.KeysBanned)
+func (n *Notifier) KeysBanned(patterns []string) {
+	n.Notify(Event{Type: EventKeysBanned, Keys: patterns})
+}
+
+// DenyRatioAlert returns a ratelimiter.DenyRatioAlert that sends
+// EventThresholdCrossed and EventThresholdResolved events.
+//
+// Example:
+//
+//	manager.AlertOnDenyRatio(notifier.DenyRatioAlert(0.3, 5*time.Minute, 100))
+func (n *Notifier) DenyRatioAlert(threshold float64, window time.Duration, minRequests uint64) ratelimiter.DenyRatioAlert {
+	return ratelimiter.DenyRatioAlert{
+		Threshold:   threshold,
+		Window:      window,
+		MinRequests: minRequests,
+		OnAlert: func(a ratelimiter.Alert) {
+			n.Notify(Event{Type: EventThresholdCrossed, Policy: a.Policy, Ratio: a.Ratio})
+		},
+		OnResolve: func(a ratelimiter.Alert) {
+			n.Notify(Event{Type: EventThresholdResolved, Policy: a.Policy, Ratio: a.Ratio})
+		},
+	}
+}
+
+// WatchStore pings store every interval and sends EventStoreUnhealthy and
+// EventStoreRecovered events when its health changes. It blocks until ctx is
+// canceled and returns immediately if store does not implement
+// ratelimiter.Pinger.
+func (n *Notifier) WatchStore(ctx context.Context, store ratelimiter.Store, interval time.Duration) {
+	pinger, ok := store.(ratelimiter.Pinger)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	healthy := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, interval)
+		err := pinger.Ping(pingCtx)
+		cancel()
+
+		switch {
+		case err != nil && healthy:
+			healthy = false
+			n.Notify(Event{Type: EventStoreUnhealthy, Message: err.Error()})
+		case err == nil && !healthy:
+			healthy = true
+			n.Notify(Event{Type: EventStoreRecovered})
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Package notify posts rate limiter events to a webhook, such as a Slack
+// incoming webhook, so that small teams get visibility into bans, deny-ratio
+// alerts, and store outages without running a metrics stack.
+//
+// Events are queued without blocking the caller, batched, and delivered by a
+// background goroutine with retries.
+//
+// Example usage:
+//
+//	notifier := notify.New(ctx, os.Getenv("SLACK_WEBHOOK_URL"), notify.WithSlack())
+//
+//	manager.OnDenyKeys(notifier.KeysBanned)
+//	manager.AlertOnDenyRatio(notifier.DenyRatioAlert(0.3, 5*time.Minute, 100))
+//	go notifier.WatchStore(ctx, store, 30*time.Second)
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// Option configures a Notifier.
+type Option func(*config)
+
+// config holds the settings collected from Option values.
+type config struct {
+	client        *http.Client
+	slack         bool
+	batchSize     int
+	flushInterval time.Duration
+	retries       int
+	queueSize     int
+	logger        ratelimiter.Logger
+}
+
+// WithSlack formats batches as Slack messages ({"text": ...}) instead of the
+// default {"events": [...]} JSON body.
+func WithSlack() Option {
+	return func(c *config) {
+		c.slack = true
+	}
+}
+
+// WithHTTPClient sets the client used to post events. The default is a client
+// with a ten-second timeout.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		if client != nil {
+			c.client = client
+		}
+	}
+}
+
+// WithBatching sets the maximum number of events per request and how long
+// events are collected before a partial batch is sent. The defaults are 20
+// events and five seconds.
+func WithBatching(size int, interval time.Duration) Option {
+	return func(c *config) {
+		if size > 0 {
+			c.batchSize = size
+		}
+		if interval > 0 {
+			c.flushInterval = interval
+		}
+	}
+}
+
+// WithRetries sets how many times a failed delivery is retried, with
+// exponential backoff starting at one second. The default is 3.
+func WithRetries(n int) Option {
+	return func(c *config) {
+		if n >= 0 {
+			c.retries = n
+		}
+	}
+}
+
+// WithQueueSize sets how many events may wait for delivery. Events notified
+// while the queue is full are dropped. The default is 1000.
+func WithQueueSize(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.queueSize = n
+		}
+	}
+}
+
+// WithLogger sets the Logger used to report dropped events and failed deliveries.
+func WithLogger(l ratelimiter.Logger) Option {
+	return func(c *config) {
+		if l != nil {
+			c.logger = l
+		}
+	}
+}
+
+// Notifier delivers events to a webhook URL.
+type Notifier struct {
+	url    string
+	cfg    config
+	queue  chan Event
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates a Notifier posting to url. Delivery runs in a background
+// goroutine until ctx is canceled or Close is called, at which point queued
+// events are flushed once without retries.
+func New(ctx context.Context, url string, opts ...Option) *Notifier {
+	cfg := config{
+		client:        &http.Client{Timeout: 10 * time.Second},
+		batchSize:     20,
+		flushInterval: 5 * time.Second,
+		retries:       3,
+		queueSize:     1000,
+		logger:        noopLogger{},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	n := &Notifier{url: url, cfg: cfg, queue: make(chan Event, cfg.queueSize), cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(n.done)
+		n.run(ctx)
+	}()
+	return n
+}
+
+// Close stops the Notifier and waits until queued events have been flushed,
+// or ctx expires.
+func (n *Notifier) Close(ctx context.Context) error {
+	n.cancel()
+	select {
+	case <-n.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Notify queues event for delivery without blocking. If Time is zero it is set
+// to the current time.
+func (n *Notifier) Notify(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	select {
+	case n.queue <- event:
+	default:
+		n.cfg.logger.Errorf("[RateLimiter] notify: queue full, dropping %s event", event.Type)
+	}
+}
+
+// run collects queued events into batches and delivers them.
+func (n *Notifier) run(ctx context.Context) {
+	ticker := time.NewTicker(n.cfg.flushInterval)
+	defer ticker.Stop()
+
+	var batch []Event
+	for {
+		select {
+		case <-ctx.Done():
+		drain:
+			for {
+				select {
+				case event := <-n.queue:
+					batch = append(batch, event)
+				default:
+					break drain
+				}
+			}
+			if len(batch) > 0 {
+				// ctx is done, so the final flush gets a fresh one.
+				n.send(context.Background(), batch, 0)
+			}
+			return
+		case event := <-n.queue:
+			batch = append(batch, event)
+			if len(batch) < n.cfg.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		n.send(ctx, batch, n.cfg.retries)
+		batch = nil
+	}
+}
+
+// send posts batch, retrying up to retries times on failure.
+func (n *Notifier) send(ctx context.Context, batch []Event, retries int) {
+	body, err := n.encode(batch)
+	if err != nil {
+		n.cfg.logger.Errorf("[RateLimiter] notify: encoding %d events: %v", len(batch), err)
+		return
+	}
+
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err = n.post(ctx, body)
+		if err == nil {
+			return
+		}
+		if attempt >= retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			n.cfg.logger.Errorf("[RateLimiter] notify: dropping %d events: %v", len(batch), ctx.Err())
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	n.cfg.logger.Errorf("[RateLimiter] notify: dropping %d events: %v", len(batch), err)
+}
+
+// post performs a single delivery attempt.
+func (n *Notifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.cfg.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// encode renders batch as the request body.
+func (n *Notifier) encode(batch []Event) ([]byte, error) {
+	if !n.cfg.slack {
+		return json.Marshal(struct {
+			Events []Event `json:"events"`
+		}{batch})
+	}
+
+	lines := make([]string, len(batch))
+	for i, event := range batch {
+		lines[i] = event.String()
+	}
+	return json.Marshal(struct {
+		Text string `json:"text"`
+	}{strings.Join(lines, "\n")})
+}
+
+// noopLogger is a private default logger that does nothing.
+type noopLogger struct{}
+
+func (noopLogger) Debugf(format string, args ...interface{}) {}
+func (noopLogger) Errorf(format string, args ...interface{}) {}
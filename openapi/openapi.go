@@ -0,0 +1,213 @@
+// Package openapi builds rate-limiting rules from `x-ratelimit` extensions in
+// an OpenAPI document, so that documented and enforced limits cannot drift apart.
+//
+// The extension holds a ratelimiter.Spec and may be placed on an operation or
+// on a path item, where it applies to every method of the path:
+//
+//	paths:
+//	  /login:
+//	    post:
+//	      operationId: login
+//	      x-ratelimit: {algorithm: fixed_window, limit: 5, window: 1m}
+//	  /search:
+//	    x-ratelimit: {algorithm: token_bucket, rate: 10, burst: 50}
+//
+// Documents are read as JSON; convert YAML documents first, e.g. with
+// sigs.k8s.io/yaml.YAMLToJSON.
+//
+// Example usage:
+//
+//	rules, err := openapi.LoadRules("openapi.json", store)
+//	handler := nethttp.Middleware(nil, ratelimiter.WithRules(rules...))(mux)
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// Extension is the name of the OpenAPI extension holding a rate limit.
+const Extension = "x-ratelimit"
+
+// methods lists the operation keys of an OpenAPI path item.
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Option configures Rules.
+type Option func(*config)
+
+// config holds the settings collected from Option values.
+type config struct {
+	basePath string
+}
+
+// WithBasePath prefixes every path in the document, e.g. with the path of
+// the server URL ("/api/v1").
+func WithBasePath(prefix string) Option {
+	return func(c *config) {
+		c.basePath = strings.TrimSuffix(prefix, "/")
+	}
+}
+
+// document is the part of an OpenAPI document read by Rules.
+type document struct {
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+// operation is the part of an OpenAPI operation read by Rules.
+type operation struct {
+	OperationID string            `json:"operationId"`
+	RateLimit   *ratelimiter.Spec `json:"x-ratelimit"`
+}
+
+// LoadRules reads the OpenAPI document at path and builds its rules; see Rules.
+func LoadRules(path string, store ratelimiter.Store, opts ...Option) ([]ratelimiter.Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Rules(data, store, opts...)
+}
+
+// Rules builds one rule per `x-ratelimit` extension in the JSON OpenAPI
+// document data, with limiters created on top of store.
+//
+// Rules are named after the spec name, the operationId, or "METHOD /path", in
+// that order of preference. Each limiter's keys are prefixed with its rule
+// name unless the spec sets a key prefix, so rules sharing a store never share
+// counters.
+//
+// Path templates such as /users/{id} match any value of the parameter. Rules
+// are ordered from most to least specific, and Rule.Priority is set to match,
+// so that /users/me wins over /users/{id} under either rule precedence.
+func Rules(data []byte, store ratelimiter.Store, opts ...Option) ([]ratelimiter.Rule, error) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+
+	var rules []ratelimiter.Rule
+	for path, item := range doc.Paths {
+		template := cfg.basePath + path
+
+		for _, method := range methods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op operation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("openapi: %s %s: %w", strings.ToUpper(method), path, err)
+			}
+			if op.RateLimit == nil {
+				continue
+			}
+
+			name := op.OperationID
+			if name == "" {
+				name = strings.ToUpper(method) + " " + template
+			}
+			rule, err := newRule(*op.RateLimit, name, store, template, strings.ToUpper(method))
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, rule)
+		}
+
+		if raw, ok := item[Extension]; ok {
+			var spec ratelimiter.Spec
+			if err := json.Unmarshal(raw, &spec); err != nil {
+				return nil, fmt.Errorf("openapi: %s: %w", path, err)
+			}
+			rule, err := newRule(spec, template, store, template, "")
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, rule)
+		}
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority > rules[j].Priority
+		}
+		return rules[i].Name < rules[j].Name
+	})
+	return rules, nil
+}
+
+// newRule builds the rule enforcing spec on requests to template with the
+// given method, or with any method if method is empty.
+func newRule(spec ratelimiter.Spec, name string, store ratelimiter.Store, template, method string) (ratelimiter.Rule, error) {
+	if spec.Name == "" {
+		spec.Name = name
+	}
+	if spec.KeyPrefix == "" {
+		spec.KeyPrefix = spec.Name + ":"
+	}
+
+	limiter, err := spec.Build(store)
+	if err != nil {
+		return ratelimiter.Rule{}, fmt.Errorf("openapi: %s: %w", spec.Name, err)
+	}
+
+	segments := strings.Split(strings.Trim(template, "/"), "/")
+	priority := 0
+	for _, segment := range segments {
+		if isParam(segment) {
+			priority += 2
+		} else {
+			priority += 4
+		}
+	}
+	if method != "" {
+		priority++
+	}
+
+	return ratelimiter.Rule{
+		Name:     spec.Name,
+		Match:    matchTemplate(segments, method),
+		Limiter:  limiter,
+		Priority: priority,
+	}, nil
+}
+
+// matchTemplate returns a Matcher selecting requests whose path matches the
+// template segments and, if method is not empty, whose method is method.
+func matchTemplate(segments []string, method string) ratelimiter.Matcher {
+	return func(r *http.Request) bool {
+		if method != "" && r.Method != method {
+			return false
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != len(segments) {
+			return false
+		}
+		for i, segment := range segments {
+			if isParam(segment) {
+				if parts[i] == "" {
+					return false
+				}
+			} else if parts[i] != segment {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// isParam reports whether a path template segment is a parameter such as {id}.
+func isParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
@@ -0,0 +1,78 @@
+// Package presets provides ready-made rate-limiting policies for common scenarios.
+//
+// Each preset bundles a limiter with a sensible algorithm, limit, and key
+// strategy, so new users do not have to pick rate and burst numbers blind.
+// Presets are starting points: tune them once real traffic data is available.
+//
+// Example usage:
+//
+//	login := presets.LoginEndpoint(store)
+//	mux.Handle("/login", nethttp.Middleware(login.Limiter, login.Options...)(loginHandler))
+package presets
+
+import (
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/keyfunc"
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// Preset is a limiter together with the middleware options it is meant to be used with.
+type Preset struct {
+	// Limiter enforces the preset's limit.
+	Limiter ratelimiter.Limiter
+	// Options configure the middleware, e.g. the key function.
+	Options []ratelimiter.Option
+}
+
+// LoginEndpoint protects credential-checking endpoints against brute force.
+//
+// It allows 5 attempts per client IP per minute in an epoch-aligned fixed
+// window, with IPv6 clients aggregated by /64. Bursts are deliberately not
+// allowed.
+func LoginEndpoint(store ratelimiter.Store) Preset {
+	return Preset{
+		Limiter: ratelimiter.MustNewFixedWindow(store, 5, time.Minute,
+			ratelimiter.WithName("login"),
+			ratelimiter.WithKeyPrefix("login:"),
+			ratelimiter.WithAlignedWindows(),
+		),
+		Options: []ratelimiter.Option{
+			ratelimiter.WithKeyFunc(keyfunc.ClientIP()),
+		},
+	}
+}
+
+// PublicAPI limits general API traffic.
+//
+// It allows a sustained 10 requests per second with bursts of up to 20 per
+// API key (the X-API-Key header), falling back to the client IP for
+// unauthenticated requests.
+func PublicAPI(store ratelimiter.Store) Preset {
+	return Preset{
+		Limiter: ratelimiter.MustNewTokenBucket(store, 10, 20,
+			ratelimiter.WithName("public-api"),
+			ratelimiter.WithKeyPrefix("api:"),
+		),
+		Options: []ratelimiter.Option{
+			ratelimiter.WithKeyFunc(keyfunc.Header("X-API-Key", keyfunc.FallbackToIP())),
+		},
+	}
+}
+
+// WebhookReceiver limits inbound webhook deliveries.
+//
+// Webhook senders retry on failure and deliver in bursts after outages, so it
+// allows a sustained 50 requests per second with bursts of up to 200 per
+// sender IP and endpoint path.
+func WebhookReceiver(store ratelimiter.Store) Preset {
+	return Preset{
+		Limiter: ratelimiter.MustNewTokenBucket(store, 50, 200,
+			ratelimiter.WithName("webhook"),
+			ratelimiter.WithKeyPrefix("webhook:"),
+		),
+		Options: []ratelimiter.Option{
+			ratelimiter.WithKeyFunc(keyfunc.Join(keyfunc.ClientIP(), keyfunc.Path())),
+		},
+	}
+}
@@ -0,0 +1,143 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains deny-ratio alerting for Manager policies.
+package ratelimiter
+
+import (
+	"time"
+)
+
+// alertBuckets is the number of buckets the sliding alert window is split into.
+const alertBuckets = 10
+
+// DenyRatioAlert configures alerting on the share of denied requests per policy.
+//
+// A policy alerts when, over the sliding Window, at least MinRequests requests
+// were checked and the ratio of denied requests exceeds Threshold. It resolves
+// once the ratio drops back to Threshold or below. A high deny ratio usually
+// means a misconfigured limit or an attack.
+type DenyRatioAlert struct {
+	// Threshold is the deny ratio, between 0 and 1, above which the alert fires.
+	Threshold float64
+	// Window is the sliding period over which the ratio is computed.
+	Window time.Duration
+	// MinRequests is the number of requests in the window below which the
+	// alert is not evaluated, so that a handful of denials on an idle policy
+	// do not page anyone.
+	MinRequests uint64
+	// OnAlert is called when a policy starts alerting.
+	OnAlert func(Alert)
+	// OnResolve, if set, is called when a policy stops alerting.
+	OnResolve func(Alert)
+}
+
+// Alert describes the state of a policy when a DenyRatioAlert fires or resolves.
+type Alert struct {
+	Policy  string
+	Ratio   float64
+	Allowed uint64
+	Denied  uint64
+	Window  time.Duration
+}
+
+// AlertOnDenyRatio enables deny-ratio alerting for every policy of the Manager,
+// replacing any previous configuration.
+//
+// Callbacks run in their own goroutine so that they never delay requests.
+//
+// Example:
+//
+//	manager.AlertOnDenyRatio(ratelimiter.DenyRatioAlert{
+//	    Threshold:   0.3,
+//	    Window:      5 * time.Minute,
+//	    MinRequests: 100,
+//	    OnAlert: func(a ratelimiter.Alert) {
+//	        log.Printf("policy %s denies %.0f%% of requests", a.Policy, 100*a.Ratio)
+//	    },
+//	})
+func (m *Manager) AlertOnDenyRatio(alert DenyRatioAlert) {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+
+	if alert.Window <= 0 || alert.OnAlert == nil {
+		m.alert = nil
+		return
+	}
+	m.alert = &alert
+	for _, stats := range m.stats {
+		stats.resetAlert()
+	}
+}
+
+// ratioWindow counts decisions over a sliding window split into buckets.
+type ratioWindow struct {
+	bucketSize time.Duration
+	start      [alertBuckets]time.Time
+	allowed    [alertBuckets]uint64
+	denied     [alertBuckets]uint64
+}
+
+// add counts a decision made at now.
+func (w *ratioWindow) add(now time.Time, allowed bool) {
+	slot := now.Truncate(w.bucketSize)
+	i := int(slot.UnixNano()/int64(w.bucketSize)) % alertBuckets
+	if !w.start[i].Equal(slot) {
+		w.start[i], w.allowed[i], w.denied[i] = slot, 0, 0
+	}
+	if allowed {
+		w.allowed[i]++
+	} else {
+		w.denied[i]++
+	}
+}
+
+// totals returns the decisions counted within the window ending at now.
+func (w *ratioWindow) totals(now time.Time) (allowed, denied uint64) {
+	oldest := now.Truncate(w.bucketSize).Add(-w.bucketSize * (alertBuckets - 1))
+	for i := range w.start {
+		if !w.start[i].Before(oldest) {
+			allowed += w.allowed[i]
+			denied += w.denied[i]
+		}
+	}
+	return allowed, denied
+}
+
+// evaluateAlert counts the decision in the alert window and returns the
+// callback to run, if the alert state changed. The caller must hold s.mu.
+func (s *policyStats) evaluateAlert(name string, alert *DenyRatioAlert, allowed bool, now time.Time) func() {
+	if s.window == nil {
+		bucket := alert.Window / alertBuckets
+		if bucket <= 0 {
+			bucket = 1
+		}
+		s.window = &ratioWindow{bucketSize: bucket}
+	}
+	s.window.add(now, allowed)
+
+	a, d := s.window.totals(now)
+	if a+d < alert.MinRequests || a+d == 0 {
+		return nil
+	}
+
+	info := Alert{Policy: name, Ratio: float64(d) / float64(a+d), Allowed: a, Denied: d, Window: alert.Window}
+	switch firing := info.Ratio > alert.Threshold; {
+	case firing && !s.firing:
+		s.firing = true
+		return func() { alert.OnAlert(info) }
+	case !firing && s.firing:
+		s.firing = false
+		if alert.OnResolve != nil {
+			return func() { alert.OnResolve(info) }
+		}
+	}
+	return nil
+}
+
+// resetAlert discards the alert window and state.
+func (s *policyStats) resetAlert() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.window, s.firing = nil, false
+}
@@ -0,0 +1,213 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains the AnomalyLimiter, which flags keys whose request rate
+// departs from their own baseline.
+package ratelimiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// maxAnomalyKeys bounds the number of keys an AnomalyLimiter tracks.
+const maxAnomalyKeys = 100000
+
+// anomalySweepEvery is how many observations pass between sweeps of idle keys.
+const anomalySweepEvery = 4096
+
+// Anomaly describes a key whose request rate deviates from its baseline.
+type Anomaly struct {
+	Key string
+	// Rate is the key's recent request rate, in requests per second.
+	Rate float64
+	// Baseline is the key's long-term request rate, in requests per second.
+	Baseline float64
+	// Factor is Rate divided by Baseline.
+	Factor float64
+}
+
+// AnomalyOption configures an AnomalyLimiter.
+type AnomalyOption func(*AnomalyLimiter)
+
+// WithAnomalyFactor sets how many times its baseline a key's recent rate must
+// reach to be reported. The default is 5.
+func WithAnomalyFactor(factor float64) AnomalyOption {
+	return func(a *AnomalyLimiter) {
+		if factor > 1 {
+			a.factor = factor
+		}
+	}
+}
+
+// WithAnomalyHalfLives sets the half-lives of the exponentially weighted
+// moving averages used for a key's recent rate and for its baseline. The
+// defaults are one minute and one hour. Keys are only judged once they have
+// been tracked for the baseline half-life. The recent rate can exceed the
+// baseline by at most baseline/recent times, so keep the anomaly factor well
+// below that ratio.
+func WithAnomalyHalfLives(recent, baseline time.Duration) AnomalyOption {
+	return func(a *AnomalyLimiter) {
+		if recent > 0 && baseline > recent {
+			a.recentHalfLife, a.baselineHalfLife = recent, baseline
+		}
+	}
+}
+
+// WithAnomalyMinRate sets the recent rate, in requests per second, below
+// which keys are never reported, so that a key going from one request an
+// hour to five is not flagged. The default is 1.
+func WithAnomalyMinRate(perSecond float64) AnomalyOption {
+	return func(a *AnomalyLimiter) {
+		if perSecond >= 0 {
+			a.minRate = perSecond
+		}
+	}
+}
+
+// AnomalyLimiter wraps a limiter and tracks an exponentially weighted moving
+// average of each key's request rate against its own long-term baseline,
+// calling a hook when the recent rate exceeds the baseline by the configured
+// factor. It flags compromised or misbehaving API keys before they reach
+// their hard limit. Decisions are those of the wrapped limiter.
+//
+// The hook fires once per episode: a key is reported again only after its
+// rate has fallen back under the threshold. Rates are tracked per instance,
+// for up to 100000 keys.
+//
+// Example usage:
+//
+//	limiter := ratelimiter.NewAnomaly(inner, func(ctx context.Context, a ratelimiter.Anomaly) {
+//	    log.Printf("key %s at %.1f req/s, %.0fx its baseline", a.Key, a.Rate, a.Factor)
+//	}, ratelimiter.WithAnomalyFactor(10))
+type AnomalyLimiter struct {
+	inner  Limiter
+	onHook func(ctx context.Context, a Anomaly)
+
+	factor           float64
+	recentHalfLife   time.Duration
+	baselineHalfLife time.Duration
+	minRate          float64
+
+	mu           sync.Mutex
+	keys         map[string]*keyRate
+	observations int
+}
+
+// keyRate holds the moving averages of one key.
+type keyRate struct {
+	recent    float64
+	baseline  float64
+	first     time.Time
+	last      time.Time
+	anomalous bool
+}
+
+// NewAnomaly creates an AnomalyLimiter wrapping inner and calling hook, in its
+// own goroutine, whenever a key becomes anomalous.
+func NewAnomaly(inner Limiter, hook func(ctx context.Context, a Anomaly), opts ...AnomalyOption) *AnomalyLimiter {
+	a := &AnomalyLimiter{
+		inner:            inner,
+		onHook:           hook,
+		factor:           5,
+		recentHalfLife:   time.Minute,
+		baselineHalfLife: time.Hour,
+		minRate:          1,
+		keys:             make(map[string]*keyRate),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// decay returns the factor an average with the given half-life decays by
+// over elapsed.
+func decay(elapsed, halfLife time.Duration) float64 {
+	return math.Exp(-math.Ln2 * elapsed.Seconds() / halfLife.Seconds())
+}
+
+// observe records n requests for key and returns the anomaly to report, if
+// the key just became anomalous.
+func (a *AnomalyLimiter) observe(key string, n int64, now time.Time) (Anomaly, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.observations++; a.observations%anomalySweepEvery == 0 {
+		a.sweep(now)
+	}
+
+	k := a.keys[key]
+	if k == nil {
+		if len(a.keys) >= maxAnomalyKeys {
+			return Anomaly{}, false
+		}
+		k = &keyRate{first: now, last: now}
+		a.keys[key] = k
+	}
+
+	elapsed := now.Sub(k.last)
+	k.recent = k.recent*decay(elapsed, a.recentHalfLife) + float64(n)*math.Ln2/a.recentHalfLife.Seconds()
+	k.baseline = k.baseline*decay(elapsed, a.baselineHalfLife) + float64(n)*math.Ln2/a.baselineHalfLife.Seconds()
+	k.last = now
+
+	if now.Sub(k.first) < a.baselineHalfLife || k.baseline <= 0 {
+		return Anomaly{}, false
+	}
+	anomalous := k.recent >= a.minRate && k.recent > a.factor*k.baseline
+	if anomalous == k.anomalous {
+		return Anomaly{}, false
+	}
+	k.anomalous = anomalous
+	if !anomalous {
+		return Anomaly{}, false
+	}
+	return Anomaly{Key: key, Rate: k.recent, Baseline: k.baseline, Factor: k.recent / k.baseline}, true
+}
+
+// sweep forgets keys idle for long enough that their baseline has decayed to
+// almost nothing. The caller must hold a.mu.
+func (a *AnomalyLimiter) sweep(now time.Time) {
+	for key, k := range a.keys {
+		if now.Sub(k.last) > 8*a.baselineHalfLife {
+			delete(a.keys, key)
+		}
+	}
+}
+
+// Rates returns the recent and baseline request rates of key, in requests per
+// second, or zeros if the key is not tracked.
+func (a *AnomalyLimiter) Rates(key string) (recent, baseline float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := a.keys[key]
+	if k == nil {
+		return 0, 0
+	}
+	elapsed := time.Since(k.last)
+	return k.recent * decay(elapsed, a.recentHalfLife), k.baseline * decay(elapsed, a.baselineHalfLife)
+}
+
+// Allow records the request and checks it against the wrapped limiter.
+func (a *AnomalyLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return a.AllowN(ctx, key, 1)
+}
+
+// AllowN records a request costing n units and checks it against the wrapped
+// limiter.
+func (a *AnomalyLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	if anomaly, ok := a.observe(key, n, time.Now()); ok && a.onHook != nil {
+		go a.onHook(context.WithoutCancel(ctx), anomaly)
+	}
+	return AllowN(ctx, a.inner, key, n)
+}
+
+// Refund gives back n units to the wrapped limiter.
+func (a *AnomalyLimiter) Refund(ctx context.Context, key string, n int64) error {
+	if refunder, ok := a.inner.(Refunder); ok {
+		return refunder.Refund(ctx, key, n)
+	}
+	return ErrorRefundUnsupported
+}
@@ -0,0 +1,65 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnomalyEpisodes(t *testing.T) {
+	a := NewAnomaly(allowOnly{}, nil,
+		WithAnomalyHalfLives(time.Second, time.Minute),
+		WithAnomalyFactor(3),
+		WithAnomalyMinRate(0),
+	)
+	now := time.Unix(1_700_000_000, 0)
+
+	// run sends perSecond requests per second for d and returns the anomalies
+	// reported meanwhile.
+	run := func(perSecond int, d time.Duration) []Anomaly {
+		var reported []Anomaly
+		step := time.Second / time.Duration(perSecond)
+		for end := now.Add(d); now.Before(end); now = now.Add(step) {
+			if anomaly, ok := a.observe("key", 1, now); ok {
+				reported = append(reported, anomaly)
+			}
+		}
+		return reported
+	}
+
+	phases := []struct {
+		name      string
+		perSecond int
+		duration  time.Duration
+		want      int
+	}{
+		{name: "baseline", perSecond: 1, duration: 3 * time.Minute, want: 0},
+		{name: "first burst", perSecond: 30, duration: 5 * time.Second, want: 1},
+		{name: "burst continues", perSecond: 30, duration: 5 * time.Second, want: 0},
+		{name: "calm", perSecond: 1, duration: 5 * time.Minute, want: 0},
+		{name: "second burst", perSecond: 30, duration: 5 * time.Second, want: 1},
+	}
+	for _, phase := range phases {
+		reported := run(phase.perSecond, phase.duration)
+		if len(reported) != phase.want {
+			t.Fatalf("%s: %d anomalies reported, want %d", phase.name, len(reported), phase.want)
+		}
+		for _, anomaly := range reported {
+			if anomaly.Key != "key" || anomaly.Factor <= 3 {
+				t.Errorf("%s: anomaly = %+v, want key %q with factor above 3", phase.name, anomaly, "key")
+			}
+		}
+	}
+}
+
+func TestAnomalyNeedsBaseline(t *testing.T) {
+	a := NewAnomaly(allowOnly{}, nil, WithAnomalyHalfLives(time.Second, time.Minute), WithAnomalyMinRate(0))
+	now := time.Unix(1_700_000_000, 0)
+
+	// A key bursting from its first request has no baseline to deviate from.
+	for range 300 {
+		if _, ok := a.observe("new", 1, now); ok {
+			t.Fatal("anomaly reported before the baseline half-life elapsed")
+		}
+		now = now.Add(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,119 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains the fluent Builder API.
+package ratelimiter
+
+import (
+	"fmt"
+	"time"
+)
+
+// Builder assembles a limiter and its middleware options in one chain.
+//
+// Create one with New, pick an algorithm and a store, then call Build.
+//
+// Example:
+//
+//	limiter, opts, err := ratelimiter.New().
+//	    TokenBucket(5, 20).
+//	    Store(redisStore).
+//	    Name("search").
+//	    KeyBy(keyfunc.ClientIP()).
+//	    Build()
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	handler := nethttp.Middleware(limiter, opts...)(mux)
+type Builder struct {
+	newLimiter  func(store Store, opts ...LimiterOption) (Limiter, error)
+	store       Store
+	limiterOpts []LimiterOption
+	options     []Option
+}
+
+// New starts a new Builder.
+func New() *Builder {
+	return &Builder{}
+}
+
+// TokenBucket selects the token bucket algorithm with the given refill rate
+// (tokens per second) and burst capacity.
+func (b *Builder) TokenBucket(rate float64, burst int64) *Builder {
+	b.newLimiter = func(store Store, opts ...LimiterOption) (Limiter, error) {
+		return NewTokenBucket(store, rate, burst, opts...)
+	}
+	return b
+}
+
+// FixedWindow selects the fixed window algorithm allowing limit requests per window.
+func (b *Builder) FixedWindow(limit int64, window time.Duration) *Builder {
+	b.newLimiter = func(store Store, opts ...LimiterOption) (Limiter, error) {
+		return NewFixedWindow(store, limit, window, opts...)
+	}
+	return b
+}
+
+// Store sets the store backing the limiter.
+func (b *Builder) Store(s Store) *Builder {
+	b.store = s
+	return b
+}
+
+// Name sets the policy name reported in Result.Policy (see WithName).
+func (b *Builder) Name(name string) *Builder {
+	return b.With(WithName(name))
+}
+
+// KeyPrefix namespaces the limiter's keys in the store (see WithKeyPrefix).
+func (b *Builder) KeyPrefix(prefix string) *Builder {
+	return b.With(WithKeyPrefix(prefix))
+}
+
+// With adds limiter options.
+func (b *Builder) With(opts ...LimiterOption) *Builder {
+	b.limiterOpts = append(b.limiterOpts, opts...)
+	return b
+}
+
+// KeyBy sets the middleware key function (see WithKeyFunc).
+func (b *Builder) KeyBy(f KeyFunc) *Builder {
+	return b.Options(WithKeyFunc(f))
+}
+
+// KeyByCtx sets a context-aware middleware key function (see WithKeyFuncCtx).
+func (b *Builder) KeyByCtx(f KeyFuncCtx) *Builder {
+	return b.Options(WithKeyFuncCtx(f))
+}
+
+// Logger sets the middleware logger (see WithLogger).
+func (b *Builder) Logger(l Logger) *Builder {
+	return b.Options(WithLogger(l))
+}
+
+// OnLimit sets the middleware error handler invoked on denial (see WithErrorHandler).
+func (b *Builder) OnLimit(f ErrorHandler) *Builder {
+	return b.Options(WithErrorHandler(f))
+}
+
+// Options adds middleware options.
+func (b *Builder) Options(opts ...Option) *Builder {
+	b.options = append(b.options, opts...)
+	return b
+}
+
+// Build creates the limiter and returns it together with the middleware
+// options collected by the chain.
+//
+// It returns an error wrapping ErrorInvalidConfig if no algorithm was selected
+// or the limiter parameters are invalid.
+func (b *Builder) Build() (Limiter, []Option, error) {
+	if b.newLimiter == nil {
+		return nil, nil, fmt.Errorf("%w: no algorithm selected", ErrorInvalidConfig)
+	}
+
+	limiter, err := b.newLimiter(b.store, b.limiterOpts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return limiter, b.options, nil
+}
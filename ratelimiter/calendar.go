@@ -0,0 +1,76 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains the calendars used to restrict schedule windows to
+// particular dates.
+package ratelimiter
+
+import (
+	"fmt"
+	"time"
+)
+
+// Calendar is a set of days, such as weekends or the public holidays of a
+// region, used to restrict a ScheduleWindow to those days.
+type Calendar interface {
+	// Contains reports whether the day of t, in t's location, is in the calendar.
+	Contains(t time.Time) bool
+}
+
+// CalendarFunc adapts a function to a Calendar, e.g. to plug in a holiday
+// library.
+//
+// Example:
+//
+//	holidays := ratelimiter.CalendarFunc(func(t time.Time) bool {
+//	    return germanHolidays.IsHoliday(t)
+//	})
+type CalendarFunc func(t time.Time) bool
+
+// Contains calls f(t).
+func (f CalendarFunc) Contains(t time.Time) bool {
+	return f(t)
+}
+
+// Weekends returns a Calendar containing Saturdays and Sundays.
+func Weekends() Calendar {
+	return CalendarFunc(func(t time.Time) bool {
+		day := t.Weekday()
+		return day == time.Saturday || day == time.Sunday
+	})
+}
+
+// dateKey identifies a calendar day.
+type dateKey struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+// Dates returns a Calendar containing the given dates in "2006-01-02" format,
+// e.g. the days of a promotion.
+func Dates(dates ...string) (Calendar, error) {
+	set := make(map[dateKey]bool, len(dates))
+	for _, date := range dates {
+		t, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid calendar date %q", ErrorInvalidConfig, date)
+		}
+		set[dateKey{t.Year(), t.Month(), t.Day()}] = true
+	}
+
+	return CalendarFunc(func(t time.Time) bool {
+		return set[dateKey{t.Year(), t.Month(), t.Day()}]
+	}), nil
+}
+
+// AnyOf returns a Calendar containing the days contained in any of calendars.
+func AnyOf(calendars ...Calendar) Calendar {
+	return CalendarFunc(func(t time.Time) bool {
+		for _, c := range calendars {
+			if c.Contains(t) {
+				return true
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,90 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains Combine, which enforces several limiters as one.
+package ratelimiter
+
+import (
+	"context"
+)
+
+// Combine returns a Limiter that admits a request only if every given limiter
+// admits it, e.g. a per-second and a per-day limit.
+//
+// Limiters are evaluated in order in a single pass. On the first denial
+// evaluation stops and the quota already consumed from earlier limiters is
+// refunded (for limiters implementing Refunder), so a denied request does not
+// count against the limits that admitted it. Refunds are best effort.
+//
+// The returned Result is the most restrictive one, so middleware emits
+// coherent headers: the denying limiter's result, or, when all admit the
+// request, the result with the fewest remaining requests.
+//
+// Example:
+//
+//	limiter := ratelimiter.Combine(perSecond, perDay)
+//	handler := nethttp.Middleware(limiter)(mux)
+func Combine(limiters ...Limiter) Limiter {
+	return &combinedLimiter{limiters: limiters}
+}
+
+// combinedLimiter enforces all of its limiters.
+type combinedLimiter struct {
+	limiters []Limiter
+}
+
+// Allow checks key against every limiter and returns the most restrictive result.
+func (c *combinedLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return c.AllowN(ctx, key, 1)
+}
+
+// AllowN charges n units to every limiter and returns the most restrictive result.
+func (c *combinedLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	var strictest Result
+	for i, limiter := range c.limiters {
+		result, err := AllowN(ctx, limiter, key, n)
+		if err != nil {
+			c.refund(ctx, key, i, n)
+			return Result{Allowed: false}, err
+		}
+
+		if !result.Allowed {
+			c.refund(ctx, key, i, n)
+			return result, nil
+		}
+
+		if i == 0 || moreRestrictive(result, strictest) {
+			strictest = result
+		}
+	}
+	return strictest, nil
+}
+
+// Refund gives n units of quota back to every limiter that supports it.
+func (c *combinedLimiter) Refund(ctx context.Context, key string, n int64) error {
+	var firstErr error
+	for _, limiter := range c.limiters {
+		if refunder, ok := limiter.(Refunder); ok {
+			if err := refunder.Refund(ctx, key, n); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// refund gives back cost units of quota to the first count limiters.
+func (c *combinedLimiter) refund(ctx context.Context, key string, count int, cost int64) {
+	for _, limiter := range c.limiters[:count] {
+		if refunder, ok := limiter.(Refunder); ok {
+			_ = refunder.Refund(ctx, key, cost)
+		}
+	}
+}
+
+// moreRestrictive reports whether a leaves the client less headroom than b.
+func moreRestrictive(a, b Result) bool {
+	if a.Remaining != b.Remaining {
+		return a.Remaining < b.Remaining
+	}
+	return a.ResetAfter > b.ResetAfter
+}
@@ -0,0 +1,168 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains the concurrency limiter, which caps the number of
+// simultaneously open streams or connections per key.
+package ratelimiter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// ConcurrencyLimiter limits how many leases, such as open gRPC streams or
+// WebSocket connections, a key may hold at the same time.
+//
+// It is distinct from message-rate limiting: a client holding the maximum
+// number of streams is denied new ones, however slowly it sends. Leases expire
+// after the configured TTL unless refreshed, so a crashed holder cannot leak
+// capacity forever.
+//
+// Example usage:
+//
+//	limiter, err := ratelimiter.NewConcurrency(store, 5, time.Minute)
+//	lease, result, err := limiter.Acquire(ctx, "user:123")
+//	if err != nil || !result.Allowed {
+//	    // reject stream
+//	}
+//	defer lease.Release(context.Background())
+//	go lease.KeepAlive(ctx)
+type ConcurrencyLimiter struct {
+	store ConcurrencyStore
+	limit int64
+	ttl   time.Duration
+	opts  limiterOptions
+}
+
+// NewConcurrency creates a new ConcurrencyLimiter instance.
+//
+// Parameters:
+//   - store: a ratelimiter.Store implementation that also implements ConcurrencyStore
+//   - limit: maximum number of leases held at the same time per key
+//   - ttl: time after which an unrefreshed lease expires
+//   - opts: optional LimiterOption values, e.g. WithName
+//
+// Returns an error wrapping ErrorInvalidConfig if store is nil or does not
+// implement ConcurrencyStore, limit is less than 1, or ttl is not positive.
+func NewConcurrency(store Store, limit int64, ttl time.Duration, opts ...LimiterOption) (*ConcurrencyLimiter, error) {
+	if store == nil {
+		return nil, fmt.Errorf("%w: store must not be nil", ErrorInvalidConfig)
+	}
+	leases, ok := StoreAs[ConcurrencyStore](store)
+	if !ok {
+		return nil, fmt.Errorf("%w: store does not support concurrency limiting", ErrorInvalidConfig)
+	}
+	if limit < 1 {
+		return nil, fmt.Errorf("%w: limit must be at least 1, got %d", ErrorInvalidConfig, limit)
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("%w: ttl must be positive, got %s", ErrorInvalidConfig, ttl)
+	}
+
+	return &ConcurrencyLimiter{
+		store: leases,
+		limit: limit,
+		ttl:   ttl,
+		opts:  newLimiterOptions(opts),
+	}, nil
+}
+
+// MustNewConcurrency is like NewConcurrency but panics if the configuration is invalid.
+func MustNewConcurrency(store Store, limit int64, ttl time.Duration, opts ...LimiterOption) *ConcurrencyLimiter {
+	limiter, err := NewConcurrency(store, limit, ttl, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return limiter
+}
+
+// Acquire tries to take a lease for key.
+//
+// The returned Lease is nil unless Result.Allowed is true. Result.Remaining is
+// the number of leases still available to key. When denied, ResetAfter and
+// RetryAt report the TTL, by which the oldest lease has expired unless its
+// holder refreshes it.
+//
+// Example:
+//
+//	lease, result, err := limiter.Acquire(ctx, "user:123")
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, key string) (*Lease, Result, error) {
+	id, err := newLeaseID()
+	if err != nil {
+		return nil, Result{Allowed: false}, err
+	}
+
+	storeKey := l.opts.keyPrefix + key
+	allowed, held, err := l.store.Acquire(ctx, storeKey, id, l.limit, l.ttl)
+	if err != nil {
+		return nil, Result{Allowed: false}, err
+	}
+
+	result := Result{
+		Allowed:         allowed,
+		Limit:           l.limit,
+		Remaining:       max(0, l.limit-held),
+		RemainingTokens: float64(max(0, l.limit-held)),
+		Policy:          l.opts.name,
+		Algorithm:       AlgorithmConcurrency,
+	}
+	if !allowed {
+		result.ResetAfter = l.ttl
+		result.RetryAt = l.opts.clock.Now().Add(l.ttl)
+		return nil, result, nil
+	}
+
+	return &Lease{limiter: l, key: storeKey, id: id, done: make(chan struct{})}, result, nil
+}
+
+// Lease is a slot held in a ConcurrencyLimiter.
+type Lease struct {
+	limiter *ConcurrencyLimiter
+	key     string
+	id      string
+	done    chan struct{}
+}
+
+// Refresh extends the lease by the limiter's TTL.
+func (l *Lease) Refresh(ctx context.Context) error {
+	_, _, err := l.limiter.store.Acquire(ctx, l.key, l.id, l.limiter.limit, l.limiter.ttl)
+	return err
+}
+
+// KeepAlive refreshes the lease every half TTL until ctx is done or the lease
+// is released. It blocks, so run it in its own goroutine.
+//
+// Tie ctx to the lifetime of the stream or connection: if the holder goes away
+// without calling Release, refreshing stops and the lease expires.
+func (l *Lease) KeepAlive(ctx context.Context) {
+	ticker := time.NewTicker(l.limiter.ttl / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = l.Refresh(ctx)
+		case <-l.done:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Release returns the lease to the limiter. It must be called at most once.
+func (l *Lease) Release(ctx context.Context) error {
+	close(l.done)
+	return l.limiter.store.Release(ctx, l.key, l.id)
+}
+
+// newLeaseID returns a random identifier for a lease.
+func newLeaseID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
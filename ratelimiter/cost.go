@@ -0,0 +1,152 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains request costs, which let middleware charge more than one
+// unit of quota per request.
+package ratelimiter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultPathCost is the key of the cost charged by PathCosts for paths
+// matching no pattern.
+const DefaultPathCost = "default"
+
+// CostFunc returns the number of quota units a request consumes.
+type CostFunc func(r *http.Request) (int64, error)
+
+// WithCostFunc returns an Option that charges every request the cost returned
+// by f instead of a single unit.
+//
+// Costs other than 1 require a limiter implementing CostLimiter, such as the
+// built-in limiters backed by a store implementing CostStore.
+//
+// Example:
+//
+//	limiter, err := ratelimiter.NewTokenBucket(store, 1<<20, 8<<20) // 1 MiB/s, 8 MiB burst
+//	handler := nethttp.Middleware(limiter, ratelimiter.WithCostFunc(ratelimiter.BodyBytes()))(upload)
+func WithCostFunc(f CostFunc) Option {
+	return func(c *Config) {
+		c.cost = f
+	}
+}
+
+// BodyBytes returns a CostFunc that charges the size of the request body in
+// bytes, so that upload endpoints are limited by bandwidth rather than by
+// request count.
+//
+// The size is taken from Content-Length when known. Otherwise, e.g. for chunked
+// uploads, the body is read through a counting reader into memory and replaced
+// with an equivalent reader; wrap such bodies with http.MaxBytesReader to bound
+// memory use. Empty bodies cost one unit.
+func BodyBytes() CostFunc {
+	return func(r *http.Request) (int64, error) {
+		if r.ContentLength >= 0 || r.Body == nil || r.Body == http.NoBody {
+			return max(r.ContentLength, 1), nil
+		}
+
+		var buf bytes.Buffer
+		n, err := io.Copy(&buf, r.Body)
+		if err != nil {
+			return 0, err
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(&buf)
+		return max(n, 1), nil
+	}
+}
+
+// PathCosts returns a CostFunc charging requests by URL path, so that
+// expensive endpoints consume more quota without custom code.
+//
+// Each key of costs is a path pattern in path.Match syntax. A pattern matches
+// the paths it matches with path.Match and every path below them: "/export"
+// covers "/export/123", and "/users/*/avatar" covers "/users/42/avatar". When
+// several patterns match, the longest wins. Paths matching no pattern cost
+// the value under DefaultPathCost, or one unit if it is absent.
+//
+// It returns an error wrapping ErrorInvalidConfig if a pattern is malformed
+// or a cost is less than 1.
+//
+// Example:
+//
+//	cost, err := ratelimiter.PathCosts(map[string]int64{
+//	    "/search": 5,
+//	    "/export": 20,
+//	    "default": 1,
+//	})
+//	handler := nethttp.Middleware(limiter, ratelimiter.WithCostFunc(cost))(mux)
+func PathCosts(costs map[string]int64) (CostFunc, error) {
+	type pathCost struct {
+		pattern string
+		cost    int64
+	}
+
+	fallback := int64(1)
+	patterns := make([]pathCost, 0, len(costs))
+	for pattern, cost := range costs {
+		if cost < 1 {
+			return nil, fmt.Errorf("%w: cost of %q must be at least 1, got %d", ErrorInvalidConfig, pattern, cost)
+		}
+		if pattern == DefaultPathCost {
+			fallback = cost
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%w: malformed path pattern %q: %v", ErrorInvalidConfig, pattern, err)
+		}
+		patterns = append(patterns, pathCost{pattern: strings.TrimSuffix(pattern, "/"), cost: cost})
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i].pattern) != len(patterns[j].pattern) {
+			return len(patterns[i].pattern) > len(patterns[j].pattern)
+		}
+		return patterns[i].pattern < patterns[j].pattern
+	})
+
+	return func(r *http.Request) (int64, error) {
+		for _, p := range patterns {
+			if matchPathOrBelow(p.pattern, r.URL.Path) {
+				return p.cost, nil
+			}
+		}
+		return fallback, nil
+	}, nil
+}
+
+// matchPathOrBelow reports whether p, or one of its parent paths, matches
+// pattern.
+func matchPathOrBelow(pattern, p string) bool {
+	for {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+		i := strings.LastIndexByte(p, '/')
+		if i <= 0 {
+			return pattern == "" && strings.HasPrefix(p, "/")
+		}
+		p = p[:i]
+	}
+}
+
+// Cost returns the number of quota units r consumes.
+//
+// It is 1 unless a cost function is configured with WithCostFunc. Costs the
+// function returns below 1 are raised to 1, so that a request never gives
+// quota back.
+func (c *Config) Cost(r *http.Request) (int64, error) {
+	if c.cost == nil {
+		return 1, nil
+	}
+	cost, err := c.cost(r)
+	if err != nil {
+		return 0, err
+	}
+	return max(cost, 1), nil
+}
@@ -0,0 +1,174 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains A/B experiments comparing several limiter configurations
+// on disjoint cohorts of keys.
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// Variant is one arm of an Experiment.
+type Variant struct {
+	// Name labels the variant in decisions and statistics, e.g. "control".
+	Name string
+	// Weight is the relative share of keys assigned to the variant.
+	Weight uint32
+	// Limiter enforces the limits of the variant.
+	Limiter Limiter
+}
+
+// ExperimentDecision is a decision made by an Experiment, labeled with the
+// experiment and the variant of the key.
+type ExperimentDecision struct {
+	Experiment string
+	Variant    string
+	Key        string
+	Result     Result
+}
+
+// ExperimentHook is called with every decision made by an Experiment, e.g. to
+// export it to the analytics pipeline that measures conversion.
+type ExperimentHook func(ctx context.Context, decision ExperimentDecision)
+
+// VariantStats counts the decisions made for one variant of an Experiment.
+type VariantStats struct {
+	Variant string `json:"variant"`
+	Allowed uint64 `json:"allowed"`
+	Denied  uint64 `json:"denied"`
+}
+
+// Experiment splits keys between variants by a stable hash salted with the
+// experiment name, so that a key always sees the same limits, and
+// assignments of different experiments are independent.
+//
+// Every decision is passed to the hook with the experiment and variant
+// labels. Handlers can label their own events, such as purchases, with
+// Assign, so that the impact of each variant's limits on conversion can be
+// measured without a separate assignment system.
+//
+// Example usage:
+//
+//	experiment, err := ratelimiter.NewExperiment("search-limits", []ratelimiter.Variant{
+//	    {Name: "control", Weight: 90, Limiter: ratelimiter.MustNewFixedWindow(store, 100, time.Minute)},
+//	    {Name: "strict", Weight: 10, Limiter: ratelimiter.MustNewFixedWindow(store, 50, time.Minute, ratelimiter.WithKeyPrefix("strict:"))},
+//	}, func(ctx context.Context, d ratelimiter.ExperimentDecision) {
+//	    analytics.Track(d.Key, "rate_limit_decision", d.Experiment, d.Variant, d.Result.Allowed)
+//	})
+//	handler := nethttp.Middleware(experiment)(mux)
+type Experiment struct {
+	name     string
+	variants []Variant
+	total    uint32
+	hook     ExperimentHook
+	allowed  []atomic.Uint64
+	denied   []atomic.Uint64
+}
+
+// NewExperiment creates an Experiment named name over variants. hook may be nil.
+//
+// Variants must have distinct names, a limiter, and together a positive
+// weight. Reordering variants or changing weights reassigns keys.
+func NewExperiment(name string, variants []Variant, hook ExperimentHook) (*Experiment, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: experiment name must not be empty", ErrorInvalidConfig)
+	}
+
+	var total uint32
+	seen := make(map[string]bool, len(variants))
+	for _, v := range variants {
+		if v.Limiter == nil {
+			return nil, fmt.Errorf("%w: variant %q has no limiter", ErrorInvalidConfig, v.Name)
+		}
+		if seen[v.Name] {
+			return nil, fmt.Errorf("%w: duplicate variant %q", ErrorInvalidConfig, v.Name)
+		}
+		seen[v.Name] = true
+		total += v.Weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%w: variant weights must sum to a positive value", ErrorInvalidConfig)
+	}
+
+	return &Experiment{
+		name:     name,
+		variants: variants,
+		total:    total,
+		hook:     hook,
+		allowed:  make([]atomic.Uint64, len(variants)),
+		denied:   make([]atomic.Uint64, len(variants)),
+	}, nil
+}
+
+// Name returns the name of the experiment.
+func (e *Experiment) Name() string {
+	return e.name
+}
+
+// variant returns the index of the variant of key.
+func (e *Experiment) variant(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(e.name))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	point := h.Sum32() % e.total
+
+	for i, v := range e.variants {
+		if point < v.Weight {
+			return i
+		}
+		point -= v.Weight
+	}
+	return len(e.variants) - 1
+}
+
+// Assign returns the name of the variant key is assigned to.
+func (e *Experiment) Assign(key string) string {
+	return e.variants[e.variant(key)].Name
+}
+
+// Allow delegates to the limiter of the variant of key.
+func (e *Experiment) Allow(ctx context.Context, key string) (Result, error) {
+	return e.AllowN(ctx, key, 1)
+}
+
+// AllowN charges n units to the limiter of the variant of key and reports the
+// decision to the hook.
+func (e *Experiment) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	i := e.variant(key)
+	result, err := AllowN(ctx, e.variants[i].Limiter, key, n)
+	if err != nil {
+		return result, err
+	}
+
+	if result.Allowed {
+		e.allowed[i].Add(1)
+	} else {
+		e.denied[i].Add(1)
+	}
+	if e.hook != nil {
+		e.hook(ctx, ExperimentDecision{Experiment: e.name, Variant: e.variants[i].Name, Key: key, Result: result})
+	}
+	return result, nil
+}
+
+// Refund gives n units back to the limiter of the variant of key.
+func (e *Experiment) Refund(ctx context.Context, key string, n int64) error {
+	refunder, ok := e.variants[e.variant(key)].Limiter.(Refunder)
+	if !ok {
+		return ErrorRefundUnsupported
+	}
+	return refunder.Refund(ctx, key, n)
+}
+
+// Stats returns the decisions recorded so far per variant, in variant order.
+func (e *Experiment) Stats() []VariantStats {
+	stats := make([]VariantStats, len(e.variants))
+	for i, v := range e.variants {
+		stats[i] = VariantStats{Variant: v.Name, Allowed: e.allowed[i].Load(), Denied: e.denied[i].Load()}
+	}
+	return stats
+}
@@ -6,7 +6,9 @@ package ratelimiter
 
 import (
 	"context"
+	"fmt"
 	"math"
+	"strconv"
 	"time"
 )
 
@@ -19,7 +21,10 @@ import (
 // Example usage:
 //
 //	store := store.NewMemory(ctx, time.Minute)
-//	limiter := ratelimiter.NewFixedWindow(store, 100, time.Minute)
+//	limiter, err := ratelimiter.NewFixedWindow(store, 100, time.Minute)
+//	if err != nil {
+//	    // handle invalid configuration
+//	}
 //	result, err := limiter.Allow(ctx, "user:123")
 //	if result.Allowed {
 //	    // process request
@@ -30,6 +35,31 @@ type FixedWindowLimiter struct {
 	store  Store
 	limit  int64
 	window time.Duration
+	opts   limiterOptions
+}
+
+// WithAlignedWindows makes FixedWindowLimiter align windows to epoch boundaries.
+//
+// Each key is stored as `key:{windowIndex}`, where windowIndex is the number of
+// whole windows elapsed since the Unix epoch, and the stored counter expires
+// exactly at the end of its window. All instances sharing a store therefore
+// agree on window edges, and the advertised reset time matches the backend's
+// expiration.
+//
+// Since the store key changes every window, reset keys through the limiter
+// rather than the store: FixedWindowLimiter implements Resetter, and can be
+// passed to store.Broadcaster.Reset or WithTopUp with an empty prefix.
+//
+// It cannot be combined with a store aligning expiry itself, such as
+// store.WithAlignedExpiry: NewFixedWindow rejects such an AlignedExpiryStore.
+//
+// Example:
+//
+//	limiter, err := ratelimiter.NewFixedWindow(store, 100, time.Minute, ratelimiter.WithAlignedWindows())
+func WithAlignedWindows() LimiterOption {
+	return func(o *limiterOptions) {
+		o.alignWindows = true
+	}
 }
 
 // NewFixedWindow creates a new FixedWindowLimiter instance.
@@ -38,14 +68,62 @@ type FixedWindowLimiter struct {
 //   - store: a ratelimiter.Store implementation to persist request counts
 //   - limit: maximum number of requests allowed per window
 //   - window: duration of each fixed window
+//   - opts: optional LimiterOption values, e.g. WithAlignedWindows
 //
-// Returns a Limiter interface that can be used with any middleware or custom logic.
-func NewFixedWindow(store Store, limit int64, window time.Duration) Limiter {
+// Returns a Limiter interface that can be used with any middleware or custom logic,
+// or an error wrapping ErrorInvalidConfig if store is nil, limit is less than 1,
+// window is not positive, or WithAlignedWindows is used with a store aligning
+// expiry itself.
+func NewFixedWindow(store Store, limit int64, window time.Duration, opts ...LimiterOption) (Limiter, error) {
+	if store == nil {
+		return nil, fmt.Errorf("%w: store must not be nil", ErrorInvalidConfig)
+	}
+	if limit < 1 {
+		return nil, fmt.Errorf("%w: limit must be at least 1, got %d", ErrorInvalidConfig, limit)
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("%w: window must be positive, got %s", ErrorInvalidConfig, window)
+	}
+
+	options := newLimiterOptions(opts)
+	if options.alignWindows && alignsExpiry(store) {
+		return nil, fmt.Errorf("%w: WithAlignedWindows cannot be used with a store aligning expiry itself", ErrorInvalidConfig)
+	}
+
 	return &FixedWindowLimiter{
 		store:  store,
 		limit:  limit,
 		window: window,
+		opts:   options,
+	}, nil
+}
+
+// alignsExpiry reports whether store, or any store it wraps, is an
+// AlignedExpiryStore aligning expiry.
+func alignsExpiry(store Store) bool {
+	if s, ok := store.(AlignedExpiryStore); ok && s.AlignedExpiry() {
+		return true
+	}
+	if wrapper, ok := store.(StoreWrapper); ok {
+		for _, inner := range wrapper.Unwrap() {
+			if alignsExpiry(inner) {
+				return true
+			}
+		}
 	}
+	return false
+}
+
+// MustNewFixedWindow is like NewFixedWindow but panics if the configuration is invalid.
+//
+// It is intended for limits known at compile time, e.g. in package-level
+// variables or presets.
+func MustNewFixedWindow(store Store, limit int64, window time.Duration, opts ...LimiterOption) Limiter {
+	limiter, err := NewFixedWindow(store, limit, window, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return limiter
 }
 
 // Allow checks whether a request with the given key is allowed under the fixed window.
@@ -55,7 +133,9 @@ func NewFixedWindow(store Store, limit int64, window time.Duration) Limiter {
 //   - Allowed: true if the request is within the limit
 //   - Limit: maximum number of requests in the window
 //   - Remaining: requests left in the current window
-//   - ResetAfter: duration until the window resets
+//   - ResetAfter: duration until the window resets, as reported by the store
+//   - RetryAt, Policy, Algorithm: absolute reset time, limiter name, and algorithm
+//   - WindowStart, WindowEnd: bounds of the window the request was counted in
 //
 // Example:
 //
@@ -66,24 +146,171 @@ func NewFixedWindow(store Store, limit int64, window time.Duration) Limiter {
 //	    // reject request
 //	}
 func (l *FixedWindowLimiter) Allow(ctx context.Context, key string) (Result, error) {
-	currentCount, err := l.store.Increment(ctx, key, l.window)
+	storeKey, ttl := l.storeKey(key, l.opts.clock.Now())
+
+	currentCount, resetAfter, err := l.store.Increment(ctx, storeKey, ttl)
+	if err != nil {
+		return Result{Allowed: false}, err
+	}
+
+	return l.result(currentCount, l.resetAfter(resetAfter, ttl)), nil
+}
+
+// AllowN checks whether a request costing n units is allowed and adds n to the
+// counter of the current window.
+//
+// It returns ErrorCostUnsupported if the store does not implement CostStore,
+// and an error wrapping ErrorInvalidConfig if n is less than 1.
+//
+// Example:
+//
+//	result, err := limiter.(ratelimiter.CostLimiter).AllowN(ctx, "user:123", 5)
+func (l *FixedWindowLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	if err := checkCost(n); err != nil {
+		return Result{Allowed: false}, err
+	}
+	costs, ok := StoreAs[CostStore](l.store)
+	if !ok {
+		return Result{Allowed: false}, ErrorCostUnsupported
+	}
+
+	storeKey, ttl := l.storeKey(key, l.opts.clock.Now())
+	currentCount, resetAfter, err := costs.IncrementBy(ctx, storeKey, n, ttl)
 	if err != nil {
 		return Result{Allowed: false}, err
 	}
+	return l.result(currentCount, l.resetAfter(resetAfter, ttl)), nil
+}
+
+// AllowMulti checks several keys against the fixed window in one call.
+//
+// When the store implements BatchStore, all counters are incremented in a single
+// round trip; otherwise the keys are checked one by one. Results are returned in
+// the same order as keys.
+//
+// Example:
+//
+//	results, err := limiter.(ratelimiter.BatchLimiter).AllowMulti(ctx, []string{"user:42", "org:7"})
+func (l *FixedWindowLimiter) AllowMulti(ctx context.Context, keys []string) ([]Result, error) {
+	now := l.opts.clock.Now()
+	storeKeys := make([]string, len(keys))
+	var ttl time.Duration
+	for i, key := range keys {
+		storeKeys[i], ttl = l.storeKey(key, now)
+	}
+
+	results := make([]Result, len(keys))
 
-	allowed := currentCount <= l.limit
-	remaining := int64(math.Max(0, float64(l.limit-currentCount)))
+	if batch, ok := StoreAs[BatchStore](l.store); ok {
+		counters, err := batch.IncrementMulti(ctx, storeKeys, ttl)
+		if err != nil {
+			return nil, err
+		}
+		for i, c := range counters {
+			results[i] = l.result(c.Count, l.resetAfter(c.TTL, ttl))
+		}
+		return results, nil
+	}
+
+	for i, storeKey := range storeKeys {
+		count, resetAfter, err := l.store.Increment(ctx, storeKey, ttl)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = l.result(count, l.resetAfter(resetAfter, ttl))
+	}
+	return results, nil
+}
+
+// Refund lowers the counter of the current window for key by n.
+//
+// It returns ErrorRefundUnsupported if the store does not implement RefundStore.
+func (l *FixedWindowLimiter) Refund(ctx context.Context, key string, n int64) error {
+	refunds, ok := StoreAs[RefundStore](l.store)
+	if !ok {
+		return ErrorRefundUnsupported
+	}
+
+	storeKey, _ := l.storeKey(key, l.opts.clock.Now())
+	return refunds.Decrement(ctx, storeKey, n)
+}
+
+// Reset removes the counter of the current window for key, so that its next
+// request starts a fresh window.
+//
+// It returns ErrorResetUnsupported if the store does not implement Resetter.
+func (l *FixedWindowLimiter) Reset(ctx context.Context, key string) error {
+	resetter, ok := StoreAs[Resetter](l.store)
+	if !ok {
+		return ErrorResetUnsupported
+	}
+
+	storeKey, _ := l.storeKey(key, l.opts.clock.Now())
+	return resetter.Reset(ctx, storeKey)
+}
+
+// Spec returns the configuration of the limiter.
+func (l *FixedWindowLimiter) Spec() Spec {
+	return Spec{
+		Name:         l.opts.name,
+		Algorithm:    AlgorithmFixedWindow,
+		Limit:        l.limit,
+		Window:       Duration(l.window),
+		KeyPrefix:    l.opts.keyPrefix,
+		AlignWindows: l.opts.alignWindows,
+	}
+}
+
+// storeKey returns the key under which the counter for key is stored and the
+// expiration to apply to it.
+func (l *FixedWindowLimiter) storeKey(key string, now time.Time) (string, time.Duration) {
+	key = l.opts.keyPrefix + key
+	if l.opts.alignWindows {
+		return l.alignedKey(key, now)
+	}
+	return key, l.window
+}
+
+// resetAfter returns the time until the counter of a request resets, given
+// the TTL reported by the store and the expiration passed to it. Aligned
+// windows reset when the next window's key takes over, even if the store keeps
+// the old key longer, e.g. with store.WithGraceTTL.
+func (l *FixedWindowLimiter) resetAfter(stored, ttl time.Duration) time.Duration {
+	if l.opts.alignWindows {
+		return min(stored, ttl)
+	}
+	return stored
+}
+
+// result builds a Result from the counter value and TTL reported by the store.
+func (l *FixedWindowLimiter) result(count int64, resetAfter time.Duration) Result {
+	remaining := int64(math.Max(0, float64(l.limit-count)))
+	windowEnd := l.opts.clock.Now().Add(resetAfter)
+
+	return Result{
+		Allowed:         count <= l.limit,
+		Limit:           l.limit,
+		Remaining:       remaining,
+		ResetAfter:      resetAfter,
+		RemainingTokens: float64(remaining),
+		RetryAt:         windowEnd,
+		Policy:          l.opts.name,
+		Algorithm:       AlgorithmFixedWindow,
+		WindowStart:     windowEnd.Add(-l.window),
+		WindowEnd:       windowEnd,
+	}
+}
 
-	now := time.Now()
-	endOfWindow := now.Truncate(l.window).Add(l.window)
-	resetAfter := time.Until(endOfWindow)
+// alignedKey returns the window-indexed store key for key at the given time
+// and the duration left until that window ends.
+func (l *FixedWindowLimiter) alignedKey(key string, now time.Time) (string, time.Duration) {
+	index := now.UnixNano() / int64(l.window)
+	windowEnd := time.Unix(0, (index+1)*int64(l.window))
 
-	result := Result{
-		Allowed:    allowed,
-		Limit:      l.limit,
-		Remaining:  remaining,
-		ResetAfter: resetAfter,
+	ttl := windowEnd.Sub(now)
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
 	}
 
-	return result, nil
+	return key + ":" + strconv.FormatInt(index, 10), ttl
 }
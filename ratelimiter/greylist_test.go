@@ -0,0 +1,60 @@
+package ratelimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGreylistWait(t *testing.T) {
+	const delay, ttl = 30 * time.Second, time.Hour
+
+	tests := []struct {
+		name string
+		// elapsed is the time since the key's first request.
+		elapsed     time.Duration
+		wantAllowed bool
+		wantWait    time.Duration
+	}{
+		{name: "first request", wantWait: delay},
+		{name: "early retry", elapsed: 10 * time.Second, wantWait: 20 * time.Second},
+		{name: "just before delay", elapsed: delay - time.Second, wantWait: time.Second},
+		{name: "at delay", elapsed: delay, wantAllowed: true},
+		{name: "later", elapsed: 30 * time.Minute, wantAllowed: true},
+		{name: "after ttl", elapsed: ttl, wantWait: delay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newFakeStore()
+			limiter, err := NewGreylist(store, allowOnly{}, delay, ttl)
+			if err != nil {
+				t.Fatalf("NewGreylist: %v", err)
+			}
+
+			if tt.elapsed > 0 {
+				if _, err := limiter.Allow(ctx, "key"); err != nil {
+					t.Fatal(err)
+				}
+				store.advance(tt.elapsed)
+			}
+			result, err := limiter.Allow(ctx, "key")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v", result.Allowed, tt.wantAllowed)
+			}
+			if !tt.wantAllowed {
+				if result.ResetAfter != tt.wantWait {
+					t.Errorf("ResetAfter = %s, want %s", result.ResetAfter, tt.wantWait)
+				}
+				if result.Policy != GreylistPolicy {
+					t.Errorf("policy = %q, want %q", result.Policy, GreylistPolicy)
+				}
+			}
+		})
+	}
+}
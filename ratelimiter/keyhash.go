@@ -0,0 +1,96 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains key hashing, used to keep raw client identifiers out of
+// stores and logs.
+package ratelimiter
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"net/http"
+)
+
+// HashAlgorithm selects the function used by WithKeyHashing.
+type HashAlgorithm int
+
+const (
+	// HashSHA256 hashes keys with HMAC-SHA256 keyed by the salt. Keys are
+	// anonymized and become 64 hex characters long.
+	HashSHA256 HashAlgorithm = iota
+	// HashFNV hashes keys with 64-bit FNV-1a over the salt and the key. It is
+	// faster but not cryptographically secure; keys become 16 hex characters long.
+	HashFNV
+)
+
+// HashKey returns the hex-encoded hash of key using the given algorithm and salt.
+//
+// It is exported so that code calling a Limiter directly can anonymize keys the
+// same way as the middleware.
+//
+// Example:
+//
+//	result, err := limiter.Allow(ctx, ratelimiter.HashKey(ratelimiter.HashSHA256, salt, email))
+func HashKey(alg HashAlgorithm, salt, key string) string {
+	switch alg {
+	case HashFNV:
+		h := fnv.New64a()
+		h.Write([]byte(salt))
+		h.Write([]byte(key))
+		return hex.EncodeToString(h.Sum(nil))
+	default:
+		mac := hmac.New(sha256.New, []byte(salt))
+		mac.Write([]byte(key))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+}
+
+// WithKeyHashing returns an Option that hashes every key produced by the KeyFunc.
+//
+// Raw identifiers such as IP addresses or emails are then never written to the
+// store or to logs, and arbitrarily long keys (full URLs, tokens) are bounded
+// in size. The hashing applies regardless of the order in which WithKeyFunc
+// and WithKeyHashing are passed.
+//
+// Example:
+//
+//	cfg := NewConfig(WithKeyHashing(HashSHA256, os.Getenv("RATELIMIT_SALT")))
+func WithKeyHashing(alg HashAlgorithm, salt string) Option {
+	return func(c *Config) {
+		c.keyHashing = &keyHashing{alg: alg, salt: salt}
+	}
+}
+
+// keyHashing holds the settings applied by WithKeyHashing.
+type keyHashing struct {
+	alg  HashAlgorithm
+	salt string
+}
+
+// wrap returns a KeyFuncCtx that hashes the keys returned by f.
+func (h *keyHashing) wrap(f KeyFuncCtx) KeyFuncCtx {
+	return func(ctx context.Context, r *http.Request) (string, error) {
+		key, err := f(ctx, r)
+		if err != nil {
+			return "", err
+		}
+		return HashKey(h.alg, h.salt, key), nil
+	}
+}
+
+// wrapMulti returns a MultiKeyFunc that hashes the keys returned by f.
+func (h *keyHashing) wrapMulti(f MultiKeyFunc) MultiKeyFunc {
+	return func(ctx context.Context, r *http.Request) ([]string, error) {
+		keys, err := f(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		hashed := make([]string, len(keys))
+		for i, key := range keys {
+			hashed[i] = HashKey(h.alg, h.salt, key)
+		}
+		return hashed, nil
+	}
+}
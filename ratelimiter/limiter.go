@@ -11,6 +11,8 @@ package ratelimiter
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 )
 
@@ -27,8 +29,40 @@ type Result struct {
 	Remaining int64
 	// ResetAfter is the duration after which the rate limit will be reset.
 	ResetAfter time.Duration
+	// RemainingTokens is the unrounded number of requests left. For the token
+	// bucket it includes the partially refilled token that Remaining floors away;
+	// for window-based algorithms it equals Remaining.
+	RemainingTokens float64
+	// RefillInterval is the time it takes to refill a single token. It is zero
+	// for algorithms that do not refill continuously.
+	RefillInterval time.Duration
+	// RetryAt is the absolute time corresponding to ResetAfter, i.e. the moment
+	// a denied client may retry.
+	RetryAt time.Time
+	// Policy is the name of the limiter that produced the result, as set with
+	// WithName. It is empty for unnamed limiters.
+	Policy string
+	// Algorithm identifies the rate-limiting algorithm, e.g. AlgorithmTokenBucket.
+	Algorithm string
+	// WindowStart and WindowEnd bound the window the request was counted in,
+	// for window-based algorithms such as AlgorithmFixedWindow. They are zero
+	// for algorithms without windows.
+	WindowStart time.Time
+	WindowEnd   time.Time
 }
 
+// Algorithm names reported in Result.Algorithm by the built-in limiters.
+const (
+	AlgorithmFixedWindow = "fixed_window"
+	AlgorithmTokenBucket = "token_bucket"
+	AlgorithmConcurrency = "concurrency"
+	AlgorithmSlidingLog  = "sliding_log"
+)
+
+// AlgorithmState is the KeyState.Algorithm of opaque state written through
+// StateStore, e.g. by UsageTracker or custom algorithms.
+const AlgorithmState = "state"
+
 // Limiter defines the interface for rate-limiting algorithms.
 //
 // Middleware and users interact with Limiter to enforce limits on requests.
@@ -46,12 +80,186 @@ type Limiter interface {
 	Allow(ctx context.Context, key string) (Result, error)
 }
 
+// BatchLimiter is implemented by limiters that can check several keys at once.
+//
+// Callers enforcing several limits per request (e.g. user, organization, and
+// global keys) can use it to pay a single store round trip instead of one per key.
+// Use the package-level AllowMulti to fall back to sequential checks for
+// limiters that do not implement it.
+type BatchLimiter interface {
+	Limiter
+
+	// AllowMulti checks every key and returns one Result per key, in order.
+	AllowMulti(ctx context.Context, keys []string) ([]Result, error)
+}
+
+// AllowMulti checks every key against limiter and returns one Result per key.
+//
+// It uses BatchLimiter.AllowMulti when the limiter supports it and otherwise
+// calls Allow for each key in turn.
+//
+// Example:
+//
+//	results, err := ratelimiter.AllowMulti(ctx, limiter, []string{"user:42", "org:7", "global"})
+func AllowMulti(ctx context.Context, limiter Limiter, keys []string) ([]Result, error) {
+	if batch, ok := limiter.(BatchLimiter); ok {
+		return batch.AllowMulti(ctx, keys)
+	}
+
+	results := make([]Result, len(keys))
+	for i, key := range keys {
+		result, err := limiter.Allow(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// CostLimiter is implemented by limiters that can charge a request more than
+// one unit of quota, e.g. bytes for bandwidth limiting.
+type CostLimiter interface {
+	Limiter
+
+	// AllowN checks whether a request costing n units is allowed for key and
+	// consumes n units if so.
+	AllowN(ctx context.Context, key string, n int64) (Result, error)
+}
+
+// AllowN checks a request costing n units against limiter.
+//
+// A cost of 1 is checked with Allow. Other costs require the limiter to
+// implement CostLimiter; otherwise AllowN returns ErrorCostUnsupported. Costs
+// less than 1 return an error wrapping ErrorInvalidConfig.
+//
+// Example:
+//
+//	result, err := ratelimiter.AllowN(ctx, limiter, "user:42", max(r.ContentLength, 1))
+func AllowN(ctx context.Context, limiter Limiter, key string, n int64) (Result, error) {
+	if err := checkCost(n); err != nil {
+		return Result{Allowed: false}, err
+	}
+	if n == 1 {
+		return limiter.Allow(ctx, key)
+	}
+	if cost, ok := limiter.(CostLimiter); ok {
+		return cost.AllowN(ctx, key, n)
+	}
+	return Result{Allowed: false}, ErrorCostUnsupported
+}
+
+// checkCost returns an error wrapping ErrorInvalidConfig if n is less than 1,
+// which would otherwise give quota back instead of consuming it.
+func checkCost(n int64) error {
+	if n < 1 {
+		return fmt.Errorf("%w: cost must be at least 1, got %d", ErrorInvalidConfig, n)
+	}
+	return nil
+}
+
+// Refunder is implemented by limiters that can give back quota consumed by a
+// previous Allow call.
+//
+// It is used to undo partial consumption, e.g. when one of several combined
+// limiters denies a request after the others already admitted it.
+type Refunder interface {
+	// Refund gives back n units of quota previously consumed for key.
+	Refund(ctx context.Context, key string, n int64) error
+}
+
+// LimiterOption configures optional behavior of the built-in limiters.
+//
+// Options are passed as trailing arguments to limiter constructors such as
+// NewFixedWindow. Options that do not apply to a given algorithm are ignored.
+type LimiterOption func(*limiterOptions)
+
+// limiterOptions holds the settings collected from LimiterOption values.
+type limiterOptions struct {
+	name         string
+	keyPrefix    string
+	alignWindows bool
+	clock        Clock
+	// initialTokens is the fill of new token buckets when hasInitialTokens is set.
+	initialTokens    int64
+	hasInitialTokens bool
+	idleTTL          time.Duration
+}
+
+// newLimiterOptions applies the given options on top of the defaults.
+func newLimiterOptions(opts []LimiterOption) limiterOptions {
+	o := limiterOptions{clock: systemClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithName sets the policy name reported in Result.Policy.
+//
+// Naming limiters lets error handlers, logs, and metrics tell which policy
+// produced a decision.
+//
+// Example:
+//
+//	limiter, err := ratelimiter.NewTokenBucket(store, 1.0, 5, ratelimiter.WithName("search"))
+func WithName(name string) LimiterOption {
+	return func(o *limiterOptions) {
+		o.name = name
+	}
+}
+
+// Clock provides the current time to limiters.
+//
+// The default uses time.Now. Supply a custom Clock with WithClock to control
+// time in tests or simulations.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default Clock backed by time.Now.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// WithClock sets the Clock a limiter uses for window alignment and for
+// computing Result.RetryAt.
+//
+// Stores keep their own notion of time; the clock does not affect expirations
+// or refills computed by the backend.
+//
+// Example:
+//
+//	limiter, err := ratelimiter.NewFixedWindow(store, 100, time.Minute, ratelimiter.WithClock(fakeClock))
+func WithClock(c Clock) LimiterOption {
+	return func(o *limiterOptions) {
+		if c != nil {
+			o.clock = c
+		}
+	}
+}
+
+// WithKeyPrefix prepends prefix to every key before it reaches the store.
+//
+// Use it to namespace limiters that share a store: without a prefix, two
+// limiters receiving the same raw key (e.g. the client IP) silently share state.
+//
+// Example:
+//
+//	search, err := ratelimiter.NewTokenBucket(store, 10, 50, ratelimiter.WithKeyPrefix("tenantA:search:"))
+func WithKeyPrefix(prefix string) LimiterOption {
+	return func(o *limiterOptions) {
+		o.keyPrefix = prefix
+	}
+}
+
 // Store defines the interface for storing rate-limiting data.
 //
 // This abstraction allows interchangeable backends such as in-memory stores
 // or Redis for distributed rate limiting.
 type Store interface {
-	// Increment atomically increments the counter for a given key and returns the new value.
+	// Increment atomically increments the counter for a given key and returns the new value
+	// together with the time left until the counter expires.
 	//
 	// If the key does not exist, it should be created with a value of 1 and an expiration equal to the window.
 	//
@@ -62,8 +270,9 @@ type Store interface {
 	//
 	// Returns:
 	//   - new counter value
+	//   - remaining time to live of the counter, as tracked by the backend
 	//   - error if the operation fails
-	Increment(ctx context.Context, key string, window time.Duration) (int64, error)
+	Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error)
 
 	// TakeToken atomically refills and consumes tokens for token-based algorithms like Token Bucket.
 	//
@@ -79,3 +288,281 @@ type Store interface {
 	//   - error if the operation fails
 	TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, error)
 }
+
+// Resetter is implemented by stores that can discard the state of a single key.
+//
+// It is used by operators to reset a client's quota, and by invalidation
+// subscribers to drop locally cached state when another instance announces
+// that a key was reset or its limits changed.
+type Resetter interface {
+	// Reset removes all rate-limiting state for the given key.
+	//
+	// Resetting a key that does not exist is not an error.
+	Reset(ctx context.Context, key string) error
+}
+
+// Counter is the state of a fixed window counter returned by BatchStore.IncrementMulti.
+type Counter struct {
+	// Count is the counter value after the increment.
+	Count int64
+	// TTL is the time left until the counter expires.
+	TTL time.Duration
+}
+
+// TokenState is the outcome of a token take returned by BatchStore.TakeTokenMulti.
+type TokenState struct {
+	// Allowed is true if a token was successfully taken.
+	Allowed bool
+	// Remaining is the number of tokens left in the bucket.
+	Remaining float64
+}
+
+// BatchStore is implemented by stores that can update several keys in one
+// atomic operation, e.g. a single Redis round trip.
+//
+// Limiters use it from AllowMulti when available.
+type BatchStore interface {
+	// IncrementMulti performs Increment for every key and returns the counters in order.
+	IncrementMulti(ctx context.Context, keys []string, window time.Duration) ([]Counter, error)
+
+	// TakeTokenMulti performs TakeToken for every key and returns the outcomes in order.
+	TakeTokenMulti(ctx context.Context, keys []string, rate float64, burst int64) ([]TokenState, error)
+}
+
+// RefundStore is implemented by stores that can give back consumed quota.
+//
+// The built-in limiters implement Refunder on top of it.
+type RefundStore interface {
+	// Decrement lowers the fixed window counter for key by n, never below zero.
+	// Missing or expired counters are left untouched.
+	Decrement(ctx context.Context, key string, n int64) error
+
+	// ReturnTokens adds n tokens back to the bucket for key, capped at burst.
+	// Missing buckets are left untouched, since new buckets start full.
+	ReturnTokens(ctx context.Context, key string, n float64, burst int64) error
+}
+
+// CostStore is implemented by stores that can consume several units at once.
+//
+// The built-in limiters implement CostLimiter on top of it.
+type CostStore interface {
+	// IncrementBy atomically increases the fixed window counter for key by n,
+	// returning the new count and the time left until the window expires.
+	IncrementBy(ctx context.Context, key string, n int64, window time.Duration) (int64, time.Duration, error)
+
+	// TakeTokens atomically takes n tokens from the bucket for key if it holds
+	// at least n, returning whether they were taken and the tokens remaining.
+	TakeTokens(ctx context.Context, key string, n int64, rate float64, burst int64) (bool, float64, error)
+}
+
+// Bucket describes a token bucket for BucketStore.
+type Bucket struct {
+	// Rate is the number of tokens added per second.
+	Rate float64
+	// Burst is the maximum number of tokens.
+	Burst int64
+	// Initial is the number of tokens a new bucket starts with, usually Burst.
+	Initial int64
+	// IdleTTL is how long the bucket is kept after its last use, after which
+	// the key is treated as new. Zero uses the store's default.
+	IdleTTL time.Duration
+}
+
+// BucketStore is implemented by stores that can take tokens from buckets
+// configured beyond rate and burst.
+//
+// TokenBucketLimiter uses it when configured with WithInitialTokens or
+// WithIdleTTL.
+type BucketStore interface {
+	// TakeTokensWith is like CostStore.TakeTokens for the given bucket.
+	TakeTokensWith(ctx context.Context, key string, n int64, bucket Bucket) (bool, float64, error)
+}
+
+// AlignedExpiryStore is implemented by stores that can expire fixed window
+// counters at the end of epoch-aligned windows themselves.
+//
+// NewFixedWindow rejects WithAlignedWindows for such stores, including when
+// they are wrapped, since both would align the same window.
+type AlignedExpiryStore interface {
+	// AlignedExpiry reports whether the store aligns the expiry of counters.
+	AlignedExpiry() bool
+}
+
+// ConcurrencyStore is implemented by stores that can track concurrent leases.
+//
+// Leases are identified by key and a unique id and expire after ttl unless
+// acquired again, so that leases leaked by crashed holders are reclaimed.
+type ConcurrencyStore interface {
+	// Acquire registers lease id under key if fewer than limit unexpired leases
+	// are held, or refreshes its expiration if id is already held. It returns
+	// whether the lease is held and the number of leases held under key.
+	Acquire(ctx context.Context, key, id string, limit int64, ttl time.Duration) (bool, int64, error)
+
+	// Release removes lease id from key.
+	Release(ctx context.Context, key, id string) error
+}
+
+// SlidingLogStore is implemented by stores that can keep a log of request
+// timestamps per key, for the sliding window log algorithm.
+type SlidingLogStore interface {
+	// LogRequests drops the entries of key older than window, then records n
+	// entries at the current time if the log then holds at most limit. It
+	// returns whether they were recorded, the number of entries in the log,
+	// and the time until the log has room for another n entries.
+	LogRequests(ctx context.Context, key string, n, limit int64, window time.Duration) (bool, int64, time.Duration, error)
+}
+
+// UpdateFunc computes the new state of a key from its current state, which is
+// nil if the key holds none, and returns the Result of the operation.
+//
+// Returning a nil state deletes the key; returning an error leaves it
+// unchanged. Stores may call the function several times for one update, e.g.
+// after a concurrent write, so it must not have side effects or retain state.
+type UpdateFunc func(state []byte) ([]byte, Result, error)
+
+// StateStore is implemented by stores that can atomically read, modify, and
+// write opaque per-key state.
+//
+// It is the extension point for algorithms other than the built-in ones: a
+// custom Limiter encodes its state as bytes and implements the algorithm in an
+// UpdateFunc, and then runs unchanged on every backend implementing StateStore.
+//
+// Example:
+//
+//	// A limiter allowing one request per key for good.
+//	result, err := store.(ratelimiter.StateStore).Update(ctx, key, 0, func(state []byte) ([]byte, ratelimiter.Result, error) {
+//	    if state != nil {
+//	        return state, ratelimiter.Result{Allowed: false}, nil
+//	    }
+//	    return []byte{1}, ratelimiter.Result{Allowed: true, Limit: 1}, nil
+//	})
+type StateStore interface {
+	// Update applies fn to the state of key atomically with respect to other
+	// updates of key and returns the Result produced by fn. The new state
+	// expires after ttl, or never if ttl is zero.
+	Update(ctx context.Context, key string, ttl time.Duration, fn UpdateFunc) (Result, error)
+}
+
+// Closer is implemented by stores and components that run background work,
+// such as cleanup loops or asynchronous synchronization.
+type Closer interface {
+	// Close flushes pending state, stops the background goroutines, and waits
+	// for them to return. If ctx expires first, Close returns ctx.Err() and the
+	// goroutines finish on their own. Closing more than once is not an error.
+	Close(ctx context.Context) error
+}
+
+// Shutdown closes every component implementing Closer, e.g. the store and
+// limiters of a service on SIGTERM, and returns the joined errors. Components
+// not implementing Closer are skipped.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	err := ratelimiter.Shutdown(ctx, store, exporter)
+func Shutdown(ctx context.Context, components ...any) error {
+	var errs []error
+	for _, c := range components {
+		if closer, ok := c.(Closer); ok {
+			if err := closer.Close(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Pinger is implemented by stores that can check the health of their backend.
+type Pinger interface {
+	// Ping returns an error if the backend cannot be reached.
+	Ping(ctx context.Context) error
+}
+
+// KeyState is a snapshot of the state held by a store for one key.
+type KeyState struct {
+	Key string `json:"key"`
+	// Algorithm is the kind of state held: AlgorithmFixedWindow for counters,
+	// AlgorithmTokenBucket for buckets, AlgorithmSlidingLog for request logs,
+	// AlgorithmConcurrency for leases, or AlgorithmState for state written
+	// through StateStore.
+	Algorithm string `json:"algorithm"`
+	// Count is the fixed window counter, the number of entries in the request
+	// log, or the number of leases held.
+	Count int64 `json:"count,omitempty"`
+	// Tokens is the number of tokens in the bucket when last updated.
+	Tokens float64 `json:"tokens,omitempty"`
+	// State is the opaque state written through StateStore.
+	State []byte `json:"state,omitempty"`
+	// TTL is the time left until the state expires, or zero if unknown.
+	TTL Duration `json:"ttl"`
+}
+
+// Inspector is implemented by stores that can list the state they hold, for
+// debugging and capacity analysis.
+type Inspector interface {
+	// Inspect returns the state of up to limit keys matching pattern, in
+	// path.Match syntax (e.g. "login:*"). A limit of 0 or less means no limit.
+	Inspect(ctx context.Context, pattern string, limit int) ([]KeyState, error)
+}
+
+// Restorer is implemented by stores that can write state read from another
+// store with Inspector, e.g. to migrate state between stores.
+type Restorer interface {
+	// Restore writes states, replacing the state held for their keys. Token
+	// buckets hold their tokens as of the time of the call, and request logs
+	// hold their entries as if made at that time, which errs on the side of
+	// limiting. Concurrency leases belong to in-flight requests and are
+	// skipped, as are states without a known algorithm.
+	Restore(ctx context.Context, states []KeyState) error
+}
+
+// StoreWrapper is implemented by stores that wrap other stores, e.g. to
+// instrument or replicate them. Wrappers implement the optional store
+// interfaces of every store they may wrap, so their capabilities are those
+// they share with the stores returned by Unwrap; StoreAs checks both.
+type StoreWrapper interface {
+	// Unwrap returns the wrapped stores.
+	Unwrap() []Store
+}
+
+// StoreAs returns store as the optional interface T, such as CostStore, and
+// whether it supports it. Unlike a type assertion, it also requires the
+// stores wrapped by a StoreWrapper to implement T, so that a wrapper is not
+// mistaken for a store with capabilities it would only report as unsupported.
+//
+// Example:
+//
+//	if costs, ok := ratelimiter.StoreAs[ratelimiter.CostStore](store); ok {
+//	    count, ttl, err = costs.IncrementBy(ctx, key, n, window)
+//	}
+func StoreAs[T any](store Store) (T, bool) {
+	t, ok := store.(T)
+	if !ok {
+		return t, false
+	}
+	if wrapper, ok := store.(StoreWrapper); ok {
+		for _, inner := range wrapper.Unwrap() {
+			if _, ok := StoreAs[T](inner); !ok {
+				var zero T
+				return zero, false
+			}
+		}
+	}
+	return t, true
+}
+
+// Inspect returns the state of up to limit keys matching pattern in store.
+//
+// It returns ErrorInspectUnsupported if the store does not implement Inspector.
+//
+// Example:
+//
+//	states, err := ratelimiter.Inspect(ctx, store, "login:*", 100)
+func Inspect(ctx context.Context, store Store, pattern string, limit int) ([]KeyState, error) {
+	inspector, ok := StoreAs[Inspector](store)
+	if !ok {
+		return nil, ErrorInspectUnsupported
+	}
+	return inspector.Inspect(ctx, pattern, limit)
+}
@@ -0,0 +1,148 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// fakeStore is an in-memory Store and ConcurrencyStore whose time only moves
+// when advanced, so that tests are deterministic.
+type fakeStore struct {
+	mu       sync.Mutex
+	now      time.Time
+	counters map[string]fakeCounter
+	leases   map[string]map[string]time.Time
+}
+
+// fakeCounter is a fixed window counter held by a fakeStore.
+type fakeCounter struct {
+	count     int64
+	expiresAt time.Time
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		now:      time.Unix(1_700_000_000, 0),
+		counters: make(map[string]fakeCounter),
+		leases:   make(map[string]map[string]time.Time),
+	}
+}
+
+// advance moves the time of the store forward by d.
+func (s *fakeStore) advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+}
+
+func (s *fakeStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.counters[key]
+	if !s.now.Before(c.expiresAt) {
+		c = fakeCounter{expiresAt: s.now.Add(window)}
+	}
+	c.count++
+	s.counters[key] = c
+	return c.count, c.expiresAt.Sub(s.now), nil
+}
+
+func (s *fakeStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, error) {
+	return true, float64(burst - 1), nil
+}
+
+func (s *fakeStore) TakeTokensWith(ctx context.Context, key string, n int64, bucket Bucket) (bool, float64, error) {
+	return n <= bucket.Initial, float64(bucket.Initial - n), nil
+}
+
+func (s *fakeStore) Acquire(ctx context.Context, key, id string, limit int64, ttl time.Duration) (bool, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	held := s.leases[key]
+	if held == nil {
+		held = make(map[string]time.Time)
+		s.leases[key] = held
+	}
+	for leaseID, expiresAt := range held {
+		if !s.now.Before(expiresAt) {
+			delete(held, leaseID)
+		}
+	}
+	if _, ok := held[id]; !ok && int64(len(held)) >= limit {
+		return false, int64(len(held)), nil
+	}
+	held[id] = s.now.Add(ttl)
+	return true, int64(len(held)), nil
+}
+
+func (s *fakeStore) Release(ctx context.Context, key, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.leases[key], id)
+	return nil
+}
+
+// held returns the number of leases held under key.
+func (s *fakeStore) held(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.leases[key])
+}
+
+// quotaLimiter gives every key limit units, which are only restored by
+// refunds. It implements CostLimiter and Refunder.
+type quotaLimiter struct {
+	limit      int64
+	resetAfter time.Duration
+
+	mu   sync.Mutex
+	used map[string]int64
+}
+
+func newQuotaLimiter(limit int64) *quotaLimiter {
+	return &quotaLimiter{limit: limit, resetAfter: time.Minute, used: make(map[string]int64)}
+}
+
+func (l *quotaLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *quotaLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	allowed := l.used[key]+n <= l.limit
+	if allowed {
+		l.used[key] += n
+	}
+	return Result{
+		Allowed:    allowed,
+		Limit:      l.limit,
+		Remaining:  l.limit - l.used[key],
+		ResetAfter: l.resetAfter,
+	}, nil
+}
+
+func (l *quotaLimiter) Refund(ctx context.Context, key string, n int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.used[key] = max(l.used[key]-n, 0)
+	return nil
+}
+
+// usage returns the units consumed by key.
+func (l *quotaLimiter) usage(key string) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.used[key]
+}
+
+// allowOnly is a Limiter without refunds admitting every request.
+type allowOnly struct{}
+
+func (allowOnly) Allow(ctx context.Context, key string) (Result, error) {
+	return Result{Allowed: true, Limit: 1, Remaining: 1}, nil
+}
@@ -0,0 +1,392 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains the Manager, a registry of named limiters.
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"sync"
+)
+
+// ErrorLimiterNotFound is returned by Manager when no limiter is registered
+// under the requested name and no template can create one.
+var ErrorLimiterNotFound = errors.New("rate limiter not found")
+
+// Mode is the operational mode of a Manager.
+type Mode string
+
+const (
+	// ModeNormal enforces the configured limiters. It is the default.
+	ModeNormal Mode = "normal"
+	// ModeAllowAll admits every request without consulting the limiters or
+	// their stores, e.g. to recover from an incident caused by the limits
+	// themselves or by an unavailable store.
+	ModeAllowAll Mode = "allow_all"
+)
+
+// Factory creates a limiter for the given policy name.
+//
+// Factories act as templates: the Manager calls them lazily the first time a
+// name is looked up and caches the resulting limiter.
+type Factory func(name string) (Limiter, error)
+
+// Manager holds named limiters such as "login", "search", or "default".
+//
+// Limiters can be registered directly, or created lazily from templates on
+// first use. Registered limiters can be replaced at runtime; middleware wired
+// with Manager.Limiter picks up the replacement on the next request.
+//
+// Example usage:
+//
+//	manager := ratelimiter.NewManager()
+//	manager.Register("login", ratelimiter.MustNewFixedWindow(store, 5, time.Minute))
+//	manager.RegisterTemplate("search", func(name string) (ratelimiter.Limiter, error) {
+//	    return ratelimiter.NewTokenBucket(store, 10, 50, ratelimiter.WithName(name))
+//	})
+//
+//	mux.Handle("/login", nethttp.Middleware(manager.Limiter("login"))(loginHandler))
+type Manager struct {
+	mu        sync.RWMutex
+	limiters  map[string]Limiter
+	templates map[string]Factory
+	fallback  Factory
+	mode      Mode
+	denied    []string
+	onDeny    func(patterns []string)
+
+	statsMu sync.Mutex
+	stats   map[string]*policyStats
+	alert   *DenyRatioAlert
+}
+
+// NewManager creates an empty Manager.
+func NewManager() *Manager {
+	return &Manager{
+		limiters:  make(map[string]Limiter),
+		templates: make(map[string]Factory),
+		mode:      ModeNormal,
+		stats:     make(map[string]*policyStats),
+	}
+}
+
+// Register stores limiter under name, replacing any limiter previously
+// registered or lazily created under that name.
+func (m *Manager) Register(name string, limiter Limiter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.limiters[name] = limiter
+}
+
+// RegisterTemplate sets the factory used to create the limiter for name on
+// first lookup. A limiter already created for name is discarded so that the
+// new template takes effect on the next lookup.
+func (m *Manager) RegisterTemplate(name string, factory Factory) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.templates[name] = factory
+	delete(m.limiters, name)
+}
+
+// SetDefaultTemplate sets the factory used for names that have neither a
+// registered limiter nor a template of their own.
+func (m *Manager) SetDefaultTemplate(factory Factory) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.fallback = factory
+}
+
+// Remove deletes the limiter registered under name. Its template, if any, is
+// kept, so the limiter is recreated on the next lookup.
+func (m *Manager) Remove(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.limiters, name)
+}
+
+// Get returns the limiter registered under name, creating it from a template
+// if necessary.
+//
+// It returns ErrorLimiterNotFound if the name is unknown and no default
+// template is set.
+func (m *Manager) Get(name string) (Limiter, error) {
+	m.mu.RLock()
+	limiter, ok := m.limiters[name]
+	m.mu.RUnlock()
+	if ok {
+		return limiter, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if limiter, ok := m.limiters[name]; ok {
+		return limiter, nil
+	}
+
+	factory, ok := m.templates[name]
+	if !ok {
+		factory = m.fallback
+	}
+	if factory == nil {
+		return nil, ErrorLimiterNotFound
+	}
+
+	limiter, err := factory(name)
+	if err != nil {
+		return nil, err
+	}
+	m.limiters[name] = limiter
+	return limiter, nil
+}
+
+// Apply builds a limiter for every spec on top of store and registers it under
+// the spec's name, replacing existing limiters.
+//
+// Specs are validated first: if any of them is invalid, Apply returns the
+// error and registers nothing, so a bad configuration never half-applies.
+//
+// Example:
+//
+//	err := manager.Apply(store,
+//	    ratelimiter.Spec{Name: "login", Algorithm: ratelimiter.AlgorithmFixedWindow, Limit: 5, Window: ratelimiter.Duration(time.Minute)},
+//	    ratelimiter.Spec{Name: "search", Algorithm: ratelimiter.AlgorithmTokenBucket, Rate: 10, Burst: 50},
+//	)
+func (m *Manager) Apply(store Store, specs ...Spec) error {
+	limiters := make([]Limiter, len(specs))
+	for i, spec := range specs {
+		limiter, err := spec.Build(store)
+		if err != nil {
+			return err
+		}
+		limiters[i] = limiter
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, spec := range specs {
+		m.limiters[spec.Name] = limiters[i]
+	}
+	return nil
+}
+
+// Names returns the sorted names of all limiters currently held by the Manager.
+func (m *Manager) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.limiters))
+	for name := range m.limiters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetMode switches the operational mode of all limiters returned by Limiter.
+//
+// The switch applies to the next request and can be toggled at runtime, e.g.
+// from the admin handler. Unknown modes are treated as ModeNormal.
+//
+// Example:
+//
+//	manager.SetMode(ratelimiter.ModeAllowAll) // incident recovery
+//	defer manager.SetMode(ratelimiter.ModeNormal)
+func (m *Manager) SetMode(mode Mode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mode = mode
+}
+
+// Mode returns the current operational mode.
+func (m *Manager) Mode() Mode {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.mode
+}
+
+// DenyKeys rejects every request whose key matches one of the given patterns,
+// e.g. during an active attack. Patterns use path.Match syntax, such as
+// "203.0.113.*" or "tenant:acme:*".
+//
+// Denials take precedence over ModeAllowAll and apply until removed with
+// AllowKeys. Malformed patterns never match.
+//
+// Example:
+//
+//	manager.DenyKeys("198.51.100.*")
+func (m *Manager) DenyKeys(patterns ...string) {
+	m.mu.Lock()
+	var added []string
+	for _, pattern := range patterns {
+		if !containsString(m.denied, pattern) {
+			m.denied = append(m.denied, pattern)
+			added = append(added, pattern)
+		}
+	}
+	hook := m.onDeny
+	m.mu.Unlock()
+
+	if hook != nil && len(added) > 0 {
+		hook(added)
+	}
+}
+
+// OnDenyKeys sets a function called with the patterns newly added by DenyKeys,
+// e.g. to notify operators that keys were banned.
+func (m *Manager) OnDenyKeys(fn func(patterns []string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.onDeny = fn
+}
+
+// AllowKeys removes patterns previously added with DenyKeys.
+func (m *Manager) AllowKeys(patterns ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.denied[:0]
+	for _, pattern := range m.denied {
+		if !containsString(patterns, pattern) {
+			kept = append(kept, pattern)
+		}
+	}
+	m.denied = kept
+}
+
+// DeniedKeys returns the sorted patterns currently denied.
+func (m *Manager) DeniedKeys() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	patterns := make([]string, len(m.denied))
+	copy(patterns, m.denied)
+	sort.Strings(patterns)
+	return patterns
+}
+
+// override reports whether the operational switches decide the request for key
+// without consulting a limiter, and whether the request is then allowed.
+func (m *Manager) override(key string) (decided bool, allowed bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, pattern := range m.denied {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true, false
+		}
+	}
+	if m.mode == ModeAllowAll {
+		return true, true
+	}
+	return false, false
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Limiter returns a Limiter that resolves name through the Manager on every call.
+//
+// It is meant to be passed to middleware: runtime replacements made with
+// Register or RegisterTemplate, as well as SetMode and DenyKeys, apply without
+// rebuilding the handler chain. Decisions made through it are counted in Stats.
+func (m *Manager) Limiter(name string) Limiter {
+	return &managedLimiter{manager: m, name: name}
+}
+
+// managedLimiter is a Limiter that looks up its implementation by name on each call.
+type managedLimiter struct {
+	manager *Manager
+	name    string
+}
+
+// Allow applies the Manager's operational switches, then resolves the named
+// limiter and delegates to it.
+func (l *managedLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN applies the Manager's operational switches, then resolves the named
+// limiter and charges n units to it.
+func (l *managedLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	if decided, allowed := l.manager.override(key); decided {
+		l.manager.record(l.name, key, allowed)
+		return Result{Allowed: allowed, Policy: l.name}, nil
+	}
+
+	limiter, err := l.manager.Get(l.name)
+	if err != nil {
+		return Result{Allowed: false}, err
+	}
+
+	result, err := AllowN(ctx, limiter, key, n)
+	if err != nil {
+		return result, err
+	}
+	l.manager.record(l.name, key, result.Allowed)
+	return result, nil
+}
+
+// Refund resolves the named limiter and gives n units back to it.
+func (l *managedLimiter) Refund(ctx context.Context, key string, n int64) error {
+	limiter, err := l.manager.Get(l.name)
+	if err != nil {
+		return err
+	}
+	refunder, ok := limiter.(Refunder)
+	if !ok {
+		return ErrorRefundUnsupported
+	}
+	return refunder.Refund(ctx, key, n)
+}
+
+// AllowMulti applies the Manager's operational switches, then resolves the
+// named limiter and delegates to it.
+//
+// If a switch decides any of the keys, the keys are checked one by one.
+func (l *managedLimiter) AllowMulti(ctx context.Context, keys []string) ([]Result, error) {
+	for _, key := range keys {
+		if decided, _ := l.manager.override(key); decided {
+			results := make([]Result, len(keys))
+			for i, key := range keys {
+				result, err := l.Allow(ctx, key)
+				if err != nil {
+					return nil, err
+				}
+				results[i] = result
+			}
+			return results, nil
+		}
+	}
+
+	limiter, err := l.manager.Get(l.name)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := AllowMulti(ctx, limiter, keys)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range results {
+		l.manager.record(l.name, keys[i], result.Allowed)
+	}
+	return results, nil
+}
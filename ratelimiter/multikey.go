@@ -0,0 +1,124 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains multi-key checks, which enforce several keys, such as the
+// client IP and the user, in one middleware.
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrorNoKeys is returned when a MultiKeyFunc returns no keys for a request.
+var ErrorNoKeys = errors.New("rate limit key function returned no keys")
+
+// MultiKeyFunc extracts several identifiers from a request, each of which is
+// rate limited. A request is admitted only if every key is within its limit.
+//
+// Example:
+//
+//	func ipAndUser(ctx context.Context, r *http.Request) ([]string, error) {
+//	    ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+//	    return []string{"ip:" + ip, "user:" + r.Header.Get("X-User-ID")}, nil
+//	}
+type MultiKeyFunc func(ctx context.Context, r *http.Request) ([]string, error)
+
+// WithMultiKeyFunc returns an Option that keys every request by several keys
+// instead of one, replacing the KeyFunc for middleware.
+//
+// The keys are checked against the same limiter, in a single store round trip
+// where supported, and the most restrictive result is reported. Prefix the keys
+// (e.g. "ip:", "user:") so that they cannot collide.
+//
+// Example:
+//
+//	cfg := NewConfig(WithMultiKeyFunc(ipAndUser))
+func WithMultiKeyFunc(f MultiKeyFunc) Option {
+	return func(c *Config) {
+		c.multiKeyFunc = f
+	}
+}
+
+// Keys returns the keys r is rate limited by: those returned by the
+// MultiKeyFunc if one is configured, otherwise the single key returned by
+// KeyFuncCtx.
+func (c *Config) Keys(ctx context.Context, r *http.Request) ([]string, error) {
+	if c.multiKeyFunc == nil {
+		key, err := c.KeyFuncCtx(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		return []string{key}, nil
+	}
+
+	keys, err := c.multiKeyFunc(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrorNoKeys
+	}
+	return keys, nil
+}
+
+// AllowKeys charges n units to every key and returns the most restrictive result.
+//
+// Keys are checked with AllowMulti when n is 1, so limiters backed by a
+// BatchStore use a single round trip. If any key is denied, the units charged
+// to the admitted keys are refunded (for limiters implementing Refunder) and
+// the first denied result is returned.
+//
+// Example:
+//
+//	result, err := ratelimiter.AllowKeys(ctx, limiter, []string{"ip:203.0.113.7", "user:42"}, 1)
+func AllowKeys(ctx context.Context, limiter Limiter, keys []string, n int64) (Result, error) {
+	if len(keys) == 1 {
+		return AllowN(ctx, limiter, keys[0], n)
+	}
+
+	var results []Result
+	if n == 1 {
+		var err error
+		if results, err = AllowMulti(ctx, limiter, keys); err != nil {
+			return Result{Allowed: false}, err
+		}
+	} else {
+		results = make([]Result, len(keys))
+		for i, key := range keys {
+			result, err := AllowN(ctx, limiter, key, n)
+			if err != nil {
+				refundKeys(ctx, limiter, keys[:i], results[:i], n)
+				return Result{Allowed: false}, err
+			}
+			results[i] = result
+		}
+	}
+
+	strictest := results[0]
+	for _, result := range results[1:] {
+		switch {
+		case !strictest.Allowed:
+			// Keep the first denial.
+		case !result.Allowed, moreRestrictive(result, strictest):
+			strictest = result
+		}
+	}
+	if !strictest.Allowed {
+		refundKeys(ctx, limiter, keys, results, n)
+	}
+	return strictest, nil
+}
+
+// refundKeys gives n units back to every key whose result was allowed.
+func refundKeys(ctx context.Context, limiter Limiter, keys []string, results []Result, n int64) {
+	refunder, ok := limiter.(Refunder)
+	if !ok {
+		return
+	}
+	for i, key := range keys {
+		if results[i].Allowed {
+			_ = refunder.Refund(ctx, key, n)
+		}
+	}
+}
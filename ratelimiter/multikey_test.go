@@ -0,0 +1,111 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		// used is the quota already consumed per key, out of 5.
+		used          map[string]int64
+		keys          []string
+		n             int64
+		wantAllowed   bool
+		wantRemaining int64
+		// wantUsed is the quota consumed per key afterwards.
+		wantUsed map[string]int64
+	}{
+		{
+			name:          "single key",
+			keys:          []string{"ip"},
+			n:             2,
+			wantAllowed:   true,
+			wantRemaining: 3,
+			wantUsed:      map[string]int64{"ip": 2},
+		},
+		{
+			name:          "strictest of allowed keys",
+			used:          map[string]int64{"user": 3},
+			keys:          []string{"ip", "user"},
+			n:             1,
+			wantAllowed:   true,
+			wantRemaining: 1,
+			wantUsed:      map[string]int64{"ip": 1, "user": 4},
+		},
+		{
+			name:          "denial refunds admitted keys",
+			used:          map[string]int64{"user": 5},
+			keys:          []string{"ip", "user", "org"},
+			n:             1,
+			wantAllowed:   false,
+			wantRemaining: 0,
+			wantUsed:      map[string]int64{"ip": 0, "user": 5, "org": 0},
+		},
+		{
+			name:          "cost denial refunds admitted keys",
+			used:          map[string]int64{"org": 3},
+			keys:          []string{"ip", "user", "org"},
+			n:             3,
+			wantAllowed:   false,
+			wantRemaining: 2,
+			wantUsed:      map[string]int64{"ip": 0, "user": 0, "org": 3},
+		},
+		{
+			name:          "cost within every limit",
+			used:          map[string]int64{"user": 1},
+			keys:          []string{"ip", "user"},
+			n:             4,
+			wantAllowed:   true,
+			wantRemaining: 0,
+			wantUsed:      map[string]int64{"ip": 4, "user": 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			limiter := newQuotaLimiter(5)
+			for key, used := range tt.used {
+				limiter.used[key] = used
+			}
+
+			result, err := AllowKeys(ctx, limiter, tt.keys, tt.n)
+			if err != nil {
+				t.Fatalf("AllowKeys: %v", err)
+			}
+			if result.Allowed != tt.wantAllowed || result.Remaining != tt.wantRemaining {
+				t.Errorf("result = allowed %v, remaining %d, want %v, %d",
+					result.Allowed, result.Remaining, tt.wantAllowed, tt.wantRemaining)
+			}
+			for key, want := range tt.wantUsed {
+				if got := limiter.usage(key); got != want {
+					t.Errorf("%s: used = %d, want %d", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigKeys(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	cfg := NewConfig(WithMultiKeyFunc(func(ctx context.Context, r *http.Request) ([]string, error) {
+		return nil, nil
+	}))
+	if _, err := cfg.Keys(r.Context(), r); !errors.Is(err, ErrorNoKeys) {
+		t.Errorf("Keys error = %v, want %v", err, ErrorNoKeys)
+	}
+
+	cfg = NewConfig(WithMultiKeyFunc(func(ctx context.Context, r *http.Request) ([]string, error) {
+		return []string{"ip:1", "user:2"}, nil
+	}))
+	keys, err := cfg.Keys(r.Context(), r)
+	if err != nil || len(keys) != 2 {
+		t.Errorf("Keys = %v, %v, want two keys", keys, err)
+	}
+}
@@ -9,8 +9,8 @@
 package ratelimiter
 
 import (
+	"context"
 	"errors"
-	"math"
 	"net/http"
 	"strconv"
 )
@@ -36,6 +36,32 @@ type Logger interface {
 // this specific condition.
 var ErrorExceeded = errors.New("rate limit exceeded")
 
+// ErrorRefundUnsupported is returned by Refund when the limiter's store cannot
+// give back consumed quota.
+var ErrorRefundUnsupported = errors.New("refund not supported by store")
+
+// ErrorResetUnsupported is returned by Reset when the limiter's store cannot
+// discard the state of a key.
+var ErrorResetUnsupported = errors.New("reset not supported by store")
+
+// ErrorCostUnsupported is returned by AllowN when the limiter or its store
+// cannot charge more than one unit per request.
+var ErrorCostUnsupported = errors.New("request cost not supported by limiter")
+
+// ErrorUpdateConflict is returned by StateStore.Update when the state of the key
+// kept changing concurrently and the update could not be applied.
+var ErrorUpdateConflict = errors.New("state update conflicted with concurrent updates")
+
+// ErrorInspectUnsupported is returned by Inspect when the store cannot list
+// the state it holds.
+var ErrorInspectUnsupported = errors.New("inspection not supported by store")
+
+// ErrorInvalidConfig is returned by limiter constructors when given invalid
+// parameters, such as a non-positive rate or a nil store.
+//
+// The returned errors wrap it with a description of the offending parameter.
+var ErrorInvalidConfig = errors.New("invalid rate limiter configuration")
+
 // KeyFunc defines a function type that extracts a unique identifier
 // from an HTTP request.
 //
@@ -44,6 +70,38 @@ var ErrorExceeded = errors.New("rate limit exceeded")
 // Example: use the client's IP address or an API key header.
 type KeyFunc func(r *http.Request) (string, error)
 
+// KeyFuncCtx is the context-aware variant of KeyFunc.
+//
+// ctx is the request context. It carries values injected by earlier
+// middleware, such as the authenticated user, and is canceled when the client
+// goes away, so key functions performing lookups can stop early.
+//
+// Example:
+//
+//	func userKey(ctx context.Context, r *http.Request) (string, error) {
+//	    user, ok := auth.UserFromContext(ctx)
+//	    if !ok {
+//	        return "", errors.New("unauthenticated")
+//	    }
+//	    return "user:" + user.ID, nil
+//	}
+type KeyFuncCtx func(ctx context.Context, r *http.Request) (string, error)
+
+// AdaptKeyFunc converts a KeyFunc into a KeyFuncCtx that ignores ctx.
+func AdaptKeyFunc(f KeyFunc) KeyFuncCtx {
+	return func(ctx context.Context, r *http.Request) (string, error) {
+		return f(r)
+	}
+}
+
+// AdaptKeyFuncCtx converts a KeyFuncCtx into a KeyFunc that passes the
+// request context as ctx.
+func AdaptKeyFuncCtx(f KeyFuncCtx) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		return f(r.Context(), r)
+	}
+}
+
 // ErrorHandler defines a function type that handles a client request
 // after a rate limit is exceeded.
 //
@@ -57,13 +115,51 @@ type KeyFunc func(r *http.Request) (string, error)
 //	}
 type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error, result Result)
 
+// KeyErrorHandler defines a function type that handles a request whose rate
+// limit key cannot be extracted, e.g. because it lacks an API key.
+//
+// The default responds with a plain-text 500 Internal Server Error.
+//
+// Example:
+//
+//	func missingKey(w http.ResponseWriter, r *http.Request, err error) {
+//	    w.Header().Set("Content-Type", "application/json")
+//	    w.WriteHeader(http.StatusUnauthorized)
+//	    w.Write([]byte(`{"error":"missing_api_key"}`))
+//	}
+type KeyErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// HeaderWriter defines a function type that writes rate-limit headers for a
+// checked request. rule is the name of the matched rule, or empty.
+//
+// Middleware uses the legacy X-RateLimit-* headers when no HeaderWriter is
+// configured; see the httpheaders package for other formats.
+type HeaderWriter func(w http.ResponseWriter, result Result, rule string)
+
 // Config holds all configurable options for the rate limiter middleware.
 //
 // Users typically create a Config via NewConfig and provide functional options.
+//
+// KeyFunc and KeyFuncCtx are equivalent after NewConfig returns; middleware
+// calls KeyFuncCtx.
 type Config struct {
-	KeyFunc      KeyFunc
-	ErrorHandler ErrorHandler
-	Logger       Logger
+	KeyFunc         KeyFunc
+	KeyFuncCtx      KeyFuncCtx
+	ErrorHandler    ErrorHandler
+	KeyErrorHandler KeyErrorHandler
+	Logger          Logger
+	Rules           []Rule
+	HeaderWriter    HeaderWriter
+	ScopeHeader     bool
+
+	keyHashing     *keyHashing
+	bypassSecrets  [][]byte
+	rulePrecedence RulePrecedence
+	memoMatches    bool
+	enabled        EnabledFunc
+	cost           CostFunc
+	multiKeyFunc   MultiKeyFunc
+	settlements    bool
 }
 
 // Option defines a functional option type for configuring the rate limiter.
@@ -84,19 +180,29 @@ func NewConfig(opts ...Option) *Config {
 			return r.RemoteAddr, nil
 		},
 		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error, result Result) {
-			retryAfter := int(math.Ceil(result.ResetAfter.Seconds()))
-			if retryAfter <= 0 {
-				retryAfter = 1
-			}
-			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(result)))
 			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
 		},
+		KeyErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		},
 		Logger: &noopLogger{},
 	}
 
 	for _, opt := range opts {
 		opt(cfg)
 	}
+
+	if cfg.KeyFuncCtx == nil {
+		cfg.KeyFuncCtx = AdaptKeyFunc(cfg.KeyFunc)
+	}
+	if cfg.keyHashing != nil {
+		cfg.KeyFuncCtx = cfg.keyHashing.wrap(cfg.KeyFuncCtx)
+		if cfg.multiKeyFunc != nil {
+			cfg.multiKeyFunc = cfg.keyHashing.wrapMulti(cfg.multiKeyFunc)
+		}
+	}
+	cfg.KeyFunc = AdaptKeyFuncCtx(cfg.KeyFuncCtx)
 	return cfg
 }
 
@@ -109,6 +215,22 @@ func WithKeyFunc(f KeyFunc) Option {
 	return func(c *Config) {
 		if f != nil {
 			c.KeyFunc = f
+			c.KeyFuncCtx = nil
+		}
+	}
+}
+
+// WithKeyFuncCtx returns an Option to set a context-aware KeyFuncCtx.
+//
+// Like WithKeyFunc, it replaces any key function set by earlier options.
+//
+// Example:
+//
+//	cfg := NewConfig(WithKeyFuncCtx(userKey))
+func WithKeyFuncCtx(f KeyFuncCtx) Option {
+	return func(c *Config) {
+		if f != nil {
+			c.KeyFuncCtx = f
 		}
 	}
 }
@@ -126,6 +248,36 @@ func WithErrorHandler(f ErrorHandler) Option {
 	}
 }
 
+// WithScopeHeader returns an Option that makes middleware report the matched
+// rule or limiter name in the X-RateLimit-Scope response header.
+//
+// It is ignored when a custom HeaderWriter is set; use httpheaders.WithScope
+// there instead.
+//
+// Example:
+//
+//	cfg := NewConfig(WithScopeHeader())
+func WithScopeHeader() Option {
+	return func(c *Config) {
+		c.ScopeHeader = true
+	}
+}
+
+// WithKeyErrorHandler returns an Option to set a custom KeyErrorHandler.
+//
+// Example:
+//
+//	cfg := NewConfig(WithKeyErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+//	    http.Error(w, "API key required", http.StatusUnauthorized)
+//	}))
+func WithKeyErrorHandler(f KeyErrorHandler) Option {
+	return func(c *Config) {
+		if f != nil {
+			c.KeyErrorHandler = f
+		}
+	}
+}
+
 // WithLogger returns an Option to set a custom Logger.
 //
 // Example:
@@ -139,6 +291,47 @@ func WithLogger(l Logger) Option {
 	}
 }
 
+// EnabledFunc reports whether rate limiting applies to a request.
+type EnabledFunc func(ctx context.Context, r *http.Request) bool
+
+// WithEnabledFunc returns an Option that evaluates f for every request and
+// passes the request through unlimited when f returns false.
+//
+// It is meant for feature flag systems such as LaunchDarkly or Unleash, so
+// that rate limiting can be rolled out or rolled back without code changes.
+// f is called on the hot path and should not block.
+//
+// Example:
+//
+//	cfg := NewConfig(WithEnabledFunc(func(ctx context.Context, r *http.Request) bool {
+//	    return flags.BoolVariation("rate-limiting", userFromContext(ctx), true)
+//	}))
+func WithEnabledFunc(f EnabledFunc) Option {
+	return func(c *Config) {
+		c.enabled = f
+	}
+}
+
+// WithHeaderWriter returns an Option to set a custom HeaderWriter.
+//
+// Example:
+//
+//	cfg := NewConfig(WithHeaderWriter(httpheaders.Writer(httpheaders.WithFormat(httpheaders.Draft))))
+func WithHeaderWriter(f HeaderWriter) Option {
+	return func(c *Config) {
+		if f != nil {
+			c.HeaderWriter = f
+		}
+	}
+}
+
+// DebugEnabled reports whether a Logger is configured, so that middleware can
+// skip formatting debug messages on the hot path when they would be discarded.
+func (c *Config) DebugEnabled() bool {
+	_, noop := c.Logger.(*noopLogger)
+	return !noop
+}
+
 // noopLogger is a private default logger that does nothing.
 type noopLogger struct{}
 
@@ -0,0 +1,72 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains Policy, which bundles a rate limit with a concurrency cap.
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+)
+
+// Policy bundles a rate limit and a concurrency cap for an endpoint.
+//
+// Protecting a slow endpoint usually takes both: the rate limit bounds how
+// often a key may call it, and the concurrency cap bounds how many of its
+// calls may run at the same time. Either may be nil, but not both.
+//
+// Example usage:
+//
+//	reports := ratelimiter.Policy{
+//	    Rate:        ratelimiter.MustNewTokenBucket(store, 1, 10),
+//	    Concurrency: ratelimiter.MustNewConcurrency(store, 2, time.Minute),
+//	}
+//	mux.Handle("/reports", nethttp.PolicyMiddleware(reports)(reportsHandler))
+type Policy struct {
+	Rate        Limiter
+	Concurrency *ConcurrencyLimiter
+}
+
+// Acquire checks a request costing n units for key against both limits in a
+// single pass.
+//
+// The concurrency lease is taken first, so a request denied for concurrency
+// consumes no rate quota; if the rate limit then denies the request, the lease
+// is released right away. The returned Lease is nil unless the request is
+// allowed and a concurrency cap is set; the caller must release it when the
+// request completes.
+//
+// The returned Result is the denying limit's result or, when both admit the
+// request, the more restrictive of the two, so that headers stay coherent.
+//
+// It returns an error wrapping ErrorInvalidConfig if neither limit is set.
+func (p Policy) Acquire(ctx context.Context, key string, n int64) (*Lease, Result, error) {
+	if p.Rate == nil && p.Concurrency == nil {
+		return nil, Result{Allowed: false}, fmt.Errorf("%w: policy has neither a rate limit nor a concurrency cap", ErrorInvalidConfig)
+	}
+
+	var lease *Lease
+	var concurrency Result
+	if p.Concurrency != nil {
+		var err error
+		lease, concurrency, err = p.Concurrency.Acquire(ctx, key)
+		if err != nil || !concurrency.Allowed {
+			return nil, concurrency, err
+		}
+		if p.Rate == nil {
+			return lease, concurrency, nil
+		}
+	}
+
+	rate, err := AllowN(ctx, p.Rate, key, n)
+	if err != nil || !rate.Allowed {
+		if lease != nil {
+			_ = lease.Release(context.WithoutCancel(ctx))
+		}
+		return nil, rate, err
+	}
+
+	if lease != nil && moreRestrictive(concurrency, rate) {
+		return lease, concurrency, nil
+	}
+	return lease, rate, nil
+}
@@ -0,0 +1,121 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPolicyAcquire(t *testing.T) {
+	tests := []struct {
+		name string
+		// rateLimit and concurrencyLimit are the limits to set, or 0 for none.
+		rateLimit        int64
+		concurrencyLimit int64
+		// rateUsed and leasesHeld are the quota and leases already taken.
+		rateUsed      int64
+		leasesHeld    int
+		wantAllowed   bool
+		wantLease     bool
+		wantAlgorithm string
+		wantRateUsed  int64
+		wantHeld      int
+	}{
+		{
+			name:         "rate only",
+			rateLimit:    5,
+			wantAllowed:  true,
+			wantRateUsed: 1,
+		},
+		{
+			name:             "concurrency only",
+			concurrencyLimit: 2,
+			wantAllowed:      true,
+			wantLease:        true,
+			wantAlgorithm:    AlgorithmConcurrency,
+			wantHeld:         1,
+		},
+		{
+			name:             "both admit, rate stricter",
+			rateLimit:        5,
+			concurrencyLimit: 10,
+			rateUsed:         3,
+			wantAllowed:      true,
+			wantLease:        true,
+			wantRateUsed:     4,
+			wantHeld:         1,
+		},
+		{
+			name:             "both admit, concurrency stricter",
+			rateLimit:        100,
+			concurrencyLimit: 2,
+			wantAllowed:      true,
+			wantLease:        true,
+			wantAlgorithm:    AlgorithmConcurrency,
+			wantRateUsed:     1,
+			wantHeld:         1,
+		},
+		{
+			name:             "concurrency denial consumes no rate quota",
+			rateLimit:        5,
+			concurrencyLimit: 1,
+			leasesHeld:       1,
+			wantAlgorithm:    AlgorithmConcurrency,
+			wantHeld:         1,
+		},
+		{
+			name:             "rate denial releases the lease",
+			rateLimit:        5,
+			concurrencyLimit: 2,
+			rateUsed:         5,
+			wantRateUsed:     5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newFakeStore()
+			var policy Policy
+			rate := newQuotaLimiter(tt.rateLimit)
+			rate.used["key"] = tt.rateUsed
+			if tt.rateLimit > 0 {
+				policy.Rate = rate
+			}
+			if tt.concurrencyLimit > 0 {
+				policy.Concurrency = MustNewConcurrency(store, tt.concurrencyLimit, time.Minute)
+				for i := range tt.leasesHeld {
+					_, _, _ = store.Acquire(ctx, "key", "held-"+string(rune('a'+i)), tt.concurrencyLimit, time.Minute)
+				}
+			}
+
+			lease, result, err := policy.Acquire(ctx, "key", 1)
+			if err != nil {
+				t.Fatalf("Acquire: %v", err)
+			}
+			if result.Allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v", result.Allowed, tt.wantAllowed)
+			}
+			if (lease != nil) != tt.wantLease {
+				t.Errorf("lease = %v, want lease %v", lease, tt.wantLease)
+			}
+			if result.Algorithm != tt.wantAlgorithm {
+				t.Errorf("algorithm = %q, want %q", result.Algorithm, tt.wantAlgorithm)
+			}
+			if got := rate.usage("key"); got != tt.wantRateUsed {
+				t.Errorf("rate used = %d, want %d", got, tt.wantRateUsed)
+			}
+			if got := store.held("key"); got != tt.wantHeld {
+				t.Errorf("leases held = %d, want %d", got, tt.wantHeld)
+			}
+		})
+	}
+}
+
+func TestPolicyAcquireWithoutLimits(t *testing.T) {
+	_, _, err := Policy{}.Acquire(context.Background(), "key", 1)
+	if !errors.Is(err, ErrorInvalidConfig) {
+		t.Errorf("Acquire error = %v, want %v", err, ErrorInvalidConfig)
+	}
+}
@@ -0,0 +1,98 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains the algorithm registry used by Spec.Build.
+package ratelimiter
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AlgorithmFactory creates a limiter from spec on top of store.
+//
+// opts already name the limiter after spec.Name and apply spec.KeyPrefix;
+// factories should pass them on to the limiters they build.
+type AlgorithmFactory func(spec Spec, store Store, opts ...LimiterOption) (Limiter, error)
+
+var (
+	algorithmsMu sync.RWMutex
+	algorithms   = map[string]AlgorithmFactory{
+		AlgorithmFixedWindow: func(s Spec, store Store, opts ...LimiterOption) (Limiter, error) {
+			if s.AlignWindows {
+				opts = append([]LimiterOption{WithAlignedWindows()}, opts...)
+			}
+			return NewFixedWindow(store, s.Limit, time.Duration(s.Window), opts...)
+		},
+		AlgorithmTokenBucket: func(s Spec, store Store, opts ...LimiterOption) (Limiter, error) {
+			if s.IdleTTL != 0 {
+				opts = append([]LimiterOption{WithIdleTTL(time.Duration(s.IdleTTL))}, opts...)
+			}
+			if s.InitialTokens != nil {
+				opts = append([]LimiterOption{WithInitialTokens(*s.InitialTokens)}, opts...)
+			}
+			return NewTokenBucket(store, s.Rate, s.Burst, opts...)
+		},
+		AlgorithmSlidingLog: func(s Spec, store Store, opts ...LimiterOption) (Limiter, error) {
+			return NewSlidingLog(store, s.Limit, time.Duration(s.Window), opts...)
+		},
+	}
+)
+
+// RegisterAlgorithm makes an algorithm available to Spec.Build, and thus to
+// every declarative source of policies (configuration files, the policy
+// catalog, remote watchers), under the given name.
+//
+// It is meant to be called from an init function, so that in-house algorithms
+// can be plugged in without modifying this module. It panics if name is empty,
+// factory is nil, or name is already registered, including the built-in
+// "fixed_window" and "token_bucket".
+//
+// Example:
+//
+//	func init() {
+//	    ratelimiter.RegisterAlgorithm("gcra", func(s ratelimiter.Spec, store ratelimiter.Store, opts ...ratelimiter.LimiterOption) (ratelimiter.Limiter, error) {
+//	        return gcra.New(store, s.Rate, s.Burst, opts...)
+//	    })
+//	}
+func RegisterAlgorithm(name string, factory AlgorithmFactory) {
+	algorithmsMu.Lock()
+	defer algorithmsMu.Unlock()
+
+	if name == "" {
+		panic("ratelimiter: RegisterAlgorithm called with an empty name")
+	}
+	if factory == nil {
+		panic("ratelimiter: RegisterAlgorithm factory is nil for " + name)
+	}
+	if _, dup := algorithms[name]; dup {
+		panic("ratelimiter: RegisterAlgorithm called twice for " + name)
+	}
+	algorithms[name] = factory
+}
+
+// Algorithms returns the sorted names of all registered algorithms.
+func Algorithms() []string {
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
+
+	names := make([]string, 0, len(algorithms))
+	for name := range algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupAlgorithm returns the factory registered under name.
+func lookupAlgorithm(name string) (AlgorithmFactory, error) {
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
+
+	factory, ok := algorithms[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown algorithm %q", ErrorInvalidConfig, name)
+	}
+	return factory, nil
+}
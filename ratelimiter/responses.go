@@ -0,0 +1,120 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains built-in error handlers rendering structured denial responses.
+package ratelimiter
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// jsonError is the body rendered by JSONErrorHandler.
+type jsonError struct {
+	Error      string `json:"error"`
+	Message    string `json:"message"`
+	RetryAfter int    `json:"retry_after"`
+	Limit      int64  `json:"limit"`
+	Remaining  int64  `json:"remaining"`
+	Policy     string `json:"policy,omitempty"`
+}
+
+// JSONErrorHandler is an ErrorHandler that responds with status 429, a
+// Retry-After header, and a JSON body such as:
+//
+//	{"error":"rate_limited","message":"rate limit exceeded","retry_after":12,"limit":100,"remaining":0}
+//
+// retry_after is in seconds. The policy member is included for named limiters.
+func JSONErrorHandler(w http.ResponseWriter, r *http.Request, err error, result Result) {
+	retryAfter := retryAfterSeconds(result)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	_ = json.NewEncoder(w).Encode(jsonError{
+		Error:      "rate_limited",
+		Message:    err.Error(),
+		RetryAfter: retryAfter,
+		Limit:      result.Limit,
+		Remaining:  result.Remaining,
+		Policy:     result.Policy,
+	})
+}
+
+// WithJSONError returns an Option that renders denials with JSONErrorHandler.
+//
+// Example:
+//
+//	cfg := NewConfig(WithJSONError())
+func WithJSONError() Option {
+	return WithErrorHandler(JSONErrorHandler)
+}
+
+// problem is the RFC 7807 body rendered by ProblemErrorHandler.
+type problem struct {
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Status     int    `json:"status"`
+	Detail     string `json:"detail"`
+	Instance   string `json:"instance,omitempty"`
+	RetryAfter int    `json:"retry-after"`
+	Limit      int64  `json:"limit"`
+	Remaining  int64  `json:"remaining"`
+	Policy     string `json:"policy,omitempty"`
+}
+
+// ProblemErrorHandler returns an ErrorHandler that responds with status 429, a
+// Retry-After header, and an RFC 7807 application/problem+json body such as:
+//
+//	{"type":"about:blank","title":"Too Many Requests","status":429,
+//	 "detail":"rate limit exceeded, retry in 12 seconds","instance":"/search",
+//	 "retry-after":12,"limit":100,"remaining":0}
+//
+// typeURI identifies the problem type, e.g. a documentation page about rate
+// limits; an empty typeURI uses "about:blank". retry-after, limit, remaining,
+// and policy are extension members.
+func ProblemErrorHandler(typeURI string) ErrorHandler {
+	if typeURI == "" {
+		typeURI = "about:blank"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, err error, result Result) {
+		retryAfter := retryAfterSeconds(result)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusTooManyRequests)
+
+		_ = json.NewEncoder(w).Encode(problem{
+			Type:       typeURI,
+			Title:      http.StatusText(http.StatusTooManyRequests),
+			Status:     http.StatusTooManyRequests,
+			Detail:     err.Error() + ", retry in " + strconv.Itoa(retryAfter) + " seconds",
+			Instance:   r.URL.Path,
+			RetryAfter: retryAfter,
+			Limit:      result.Limit,
+			Remaining:  result.Remaining,
+			Policy:     result.Policy,
+		})
+	}
+}
+
+// WithProblemJSON returns an Option that renders denials as RFC 7807 problem
+// details with ProblemErrorHandler.
+//
+// Example:
+//
+//	cfg := NewConfig(WithProblemJSON("https://api.example.com/problems/rate-limited"))
+func WithProblemJSON(typeURI string) Option {
+	return WithErrorHandler(ProblemErrorHandler(typeURI))
+}
+
+// retryAfterSeconds returns the whole number of seconds, at least 1, a denied
+// client should wait before retrying.
+func retryAfterSeconds(result Result) int {
+	retryAfter := int(math.Ceil(result.ResetAfter.Seconds()))
+	if retryAfter <= 0 {
+		retryAfter = 1
+	}
+	return retryAfter
+}
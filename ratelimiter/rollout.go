@@ -0,0 +1,152 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains the Rollout, which canaries a new policy on a
+// percentage of keys.
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// rolloutBuckets is the number of buckets keys are hashed into, giving the
+// rollout percentage a resolution of 0.01%.
+const rolloutBuckets = 10000
+
+// CohortStats counts the decisions made for one cohort of a Rollout.
+type CohortStats struct {
+	Allowed uint64 `json:"allowed"`
+	Denied  uint64 `json:"denied"`
+}
+
+// RolloutStats summarizes the decisions of a Rollout by cohort.
+type RolloutStats struct {
+	// Percent is the current share of keys on the canary policy.
+	Percent float64     `json:"percent"`
+	Stable  CohortStats `json:"stable"`
+	Canary  CohortStats `json:"canary"`
+}
+
+// cohortCounters accumulates CohortStats.
+type cohortCounters struct {
+	allowed atomic.Uint64
+	denied  atomic.Uint64
+}
+
+func (c *cohortCounters) record(allowed bool) {
+	if allowed {
+		c.allowed.Add(1)
+	} else {
+		c.denied.Add(1)
+	}
+}
+
+func (c *cohortCounters) stats() CohortStats {
+	return CohortStats{Allowed: c.allowed.Load(), Denied: c.denied.Load()}
+}
+
+// Rollout applies a canary policy to a percentage of keys and the stable
+// policy to the rest, so that limit changes can be rolled out gradually.
+//
+// Keys are assigned by a stable hash: a key stays in the same cohort across
+// requests and instances, and raising the percentage only moves keys from
+// the stable cohort to the canary one.
+//
+// Example usage:
+//
+//	rollout, err := ratelimiter.NewRollout(
+//	    ratelimiter.MustNewFixedWindow(store, 100, time.Minute),
+//	    ratelimiter.MustNewFixedWindow(store, 60, time.Minute, ratelimiter.WithKeyPrefix("v2:")),
+//	    5,
+//	)
+//	handler := nethttp.Middleware(rollout)(mux)
+//
+//	// Once the canary's deny rate looks right:
+//	err = rollout.SetPercent(25)
+type Rollout struct {
+	stable Limiter
+	canary Limiter
+	// threshold is the number of buckets, out of rolloutBuckets, on the canary.
+	threshold atomic.Uint64
+
+	stableStats cohortCounters
+	canaryStats cohortCounters
+}
+
+// NewRollout creates a Rollout applying canary to percent percent of keys and
+// stable to the others.
+func NewRollout(stable, canary Limiter, percent float64) (*Rollout, error) {
+	if stable == nil || canary == nil {
+		return nil, fmt.Errorf("%w: limiters must not be nil", ErrorInvalidConfig)
+	}
+	r := &Rollout{stable: stable, canary: canary}
+	if err := r.SetPercent(percent); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetPercent changes the share of keys on the canary policy, between 0 and 100.
+func (r *Rollout) SetPercent(percent float64) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("%w: percent must be between 0 and 100, got %g", ErrorInvalidConfig, percent)
+	}
+	r.threshold.Store(uint64(percent * rolloutBuckets / 100))
+	return nil
+}
+
+// Percent returns the current share of keys on the canary policy.
+func (r *Rollout) Percent() float64 {
+	return float64(r.threshold.Load()) * 100 / rolloutBuckets
+}
+
+// IsCanary reports whether key is in the canary cohort.
+func (r *Rollout) IsCanary(key string) bool {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return uint64(h.Sum32()%rolloutBuckets) < r.threshold.Load()
+}
+
+// Allow delegates to the limiter of the cohort of key.
+func (r *Rollout) Allow(ctx context.Context, key string) (Result, error) {
+	return r.AllowN(ctx, key, 1)
+}
+
+// AllowN charges n units to the limiter of the cohort of key.
+func (r *Rollout) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	limiter, stats := r.stable, &r.stableStats
+	if r.IsCanary(key) {
+		limiter, stats = r.canary, &r.canaryStats
+	}
+
+	result, err := AllowN(ctx, limiter, key, n)
+	if err != nil {
+		return result, err
+	}
+	stats.record(result.Allowed)
+	return result, nil
+}
+
+// Refund gives n units back to the limiter of the cohort of key.
+func (r *Rollout) Refund(ctx context.Context, key string, n int64) error {
+	limiter := r.stable
+	if r.IsCanary(key) {
+		limiter = r.canary
+	}
+	refunder, ok := limiter.(Refunder)
+	if !ok {
+		return ErrorRefundUnsupported
+	}
+	return refunder.Refund(ctx, key, n)
+}
+
+// Stats returns the decisions recorded so far by cohort.
+func (r *Rollout) Stats() RolloutStats {
+	return RolloutStats{
+		Percent: r.Percent(),
+		Stable:  r.stableStats.stats(),
+		Canary:  r.canaryStats.stats(),
+	}
+}
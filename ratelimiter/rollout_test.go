@@ -0,0 +1,86 @@
+package ratelimiter
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+func TestRolloutCohorts(t *testing.T) {
+	const keys = 10000
+
+	tests := []struct {
+		name    string
+		percent float64
+		// raised is a higher percentage the rollout moves to.
+		raised float64
+	}{
+		{name: "none", percent: 0, raised: 5},
+		{name: "canary", percent: 5, raised: 25},
+		{name: "half", percent: 50, raised: 50},
+		{name: "almost all", percent: 90, raised: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, err := NewRollout(allowOnly{}, allowOnly{}, tt.percent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, _ := NewRollout(allowOnly{}, allowOnly{}, tt.percent)
+			raised, _ := NewRollout(allowOnly{}, allowOnly{}, tt.raised)
+
+			canaries := 0
+			for i := range keys {
+				key := "user:" + strconv.Itoa(i)
+				canary := first.IsCanary(key)
+				if canary != second.IsCanary(key) || canary != first.IsCanary(key) {
+					t.Fatalf("%s changed cohort between calls or instances", key)
+				}
+				if canary && !raised.IsCanary(key) {
+					t.Fatalf("%s left the canary cohort when the percentage was raised", key)
+				}
+				if canary {
+					canaries++
+				}
+			}
+
+			share := float64(canaries) * 100 / keys
+			if share < tt.percent-2 || share > tt.percent+2 {
+				t.Errorf("canary share = %.1f%%, want about %g%%", share, tt.percent)
+			}
+		})
+	}
+}
+
+func TestRolloutRouting(t *testing.T) {
+	ctx := context.Background()
+	stable, canary := newQuotaLimiter(10), newQuotaLimiter(10)
+	rollout, err := NewRollout(stable, canary, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 100 {
+		key := "user:" + strconv.Itoa(i)
+		if _, err := rollout.AllowN(ctx, key, 2); err != nil {
+			t.Fatal(err)
+		}
+		if err := rollout.Refund(ctx, key, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		want, other := stable, canary
+		if rollout.IsCanary(key) {
+			want, other = canary, stable
+		}
+		if want.usage(key) != 1 || other.usage(key) != 0 {
+			t.Fatalf("%s: used %d by its cohort and %d by the other, want 1 and 0",
+				key, want.usage(key), other.usage(key))
+		}
+	}
+
+	if _, err := NewRollout(stable, canary, 101); err == nil {
+		t.Error("NewRollout accepted a percentage above 100")
+	}
+}
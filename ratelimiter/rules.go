@@ -0,0 +1,175 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains request rules, which route requests to different limiters
+// within a single middleware.
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// RuleHeader is the response header in which middleware reports the name of the
+// rule that matched a request.
+const RuleHeader = "X-RateLimit-Rule"
+
+// Matcher reports whether a request is covered by a Rule.
+type Matcher func(r *http.Request) bool
+
+// Rule routes matching requests to a dedicated limiter.
+//
+// Rules allow one middleware stack to apply different algorithms to different
+// endpoints, e.g. a strict fixed window for login and a bursty token bucket for
+// search.
+//
+// Example:
+//
+//	cfg := ratelimiter.NewConfig(ratelimiter.WithRules(
+//	    ratelimiter.Rule{Name: "login", Match: ratelimiter.MatchPathPrefix("/login"), Limiter: loginLimiter},
+//	    ratelimiter.Rule{Name: "search", Match: ratelimiter.MatchPathPrefix("/search"), Limiter: searchLimiter},
+//	))
+type Rule struct {
+	// Name identifies the rule in logs and in the X-RateLimit-Rule header.
+	Name string
+	// Match selects the requests the rule applies to.
+	Match Matcher
+	// Limiter enforces the rule.
+	Limiter Limiter
+	// Priority orders overlapping rules under MostSpecific precedence; higher
+	// values win. It is ignored under FirstMatch precedence.
+	Priority int
+}
+
+// RulePrecedence selects how a rule is chosen when several rules match a request.
+type RulePrecedence int
+
+const (
+	// FirstMatch selects the first matching rule in registration order. It is
+	// the default.
+	FirstMatch RulePrecedence = iota
+	// MostSpecific selects the matching rule with the highest Priority, e.g. the
+	// length of its path prefix. Ties are resolved in registration order.
+	MostSpecific
+)
+
+// MatchPathPrefix returns a Matcher selecting requests whose URL path starts with prefix.
+func MatchPathPrefix(prefix string) Matcher {
+	return func(r *http.Request) bool {
+		return strings.HasPrefix(r.URL.Path, prefix)
+	}
+}
+
+// MatchMethod returns a Matcher selecting requests with one of the given HTTP methods.
+func MatchMethod(methods ...string) Matcher {
+	return func(r *http.Request) bool {
+		for _, m := range methods {
+			if r.Method == m {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// WithRules returns an Option that adds request rules to the middleware.
+//
+// By default rules are evaluated in order and the first matching rule wins; see
+// WithRulePrecedence. Requests that match no rule are checked against the
+// limiter passed to the middleware.
+//
+// Example:
+//
+//	cfg := NewConfig(WithRules(loginRule, searchRule))
+func WithRules(rules ...Rule) Option {
+	return func(c *Config) {
+		for _, rule := range rules {
+			if rule.Match != nil && rule.Limiter != nil {
+				c.Rules = append(c.Rules, rule)
+			}
+		}
+	}
+}
+
+// WithRulePrecedence returns an Option that sets how overlapping rules are resolved.
+//
+// Example:
+//
+//	cfg := NewConfig(
+//	    WithRules(
+//	        Rule{Name: "api", Match: MatchPathPrefix("/api"), Limiter: apiLimiter, Priority: 4},
+//	        Rule{Name: "api-upload", Match: MatchPathPrefix("/api/upload"), Limiter: uploadLimiter, Priority: 11},
+//	    ),
+//	    WithRulePrecedence(MostSpecific),
+//	)
+func WithRulePrecedence(p RulePrecedence) Option {
+	return func(c *Config) {
+		c.rulePrecedence = p
+	}
+}
+
+// matchMemoKey is the context key under which WhichRule passes a matchMemo to
+// matchers.
+type matchMemoKey struct{}
+
+// matchMemo holds results computed by matchers for one request, so that
+// several rules built on the same expensive computation, such as a Classifier,
+// compute it once.
+type matchMemo map[any]any
+
+// WhichRule returns the rule that applies to r, and false if no rule matches.
+//
+// It applies the same precedence as the middleware and has no side effects, so
+// it can be used to answer "why was this request limited?", e.g. from a debug
+// endpoint.
+//
+// Example:
+//
+//	if rule, ok := cfg.WhichRule(r); ok {
+//	    log.Printf("request matched rule %q", rule.Name)
+//	}
+func (c *Config) WhichRule(r *http.Request) (Rule, bool) {
+	if c.memoMatches && len(c.Rules) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), matchMemoKey{}, matchMemo{}))
+	}
+
+	var (
+		matched Rule
+		found   bool
+	)
+	for _, rule := range c.Rules {
+		if !rule.Match(r) {
+			continue
+		}
+		if c.rulePrecedence == FirstMatch {
+			return rule, true
+		}
+		if !found || rule.Priority > matched.Priority {
+			matched, found = rule, true
+		}
+	}
+	return matched, found
+}
+
+// Resolve returns the limiter that applies to r and the name of the matched rule.
+//
+// If no rule matches, fallback is returned with an empty rule name. Middleware
+// should let the request through when the returned limiter is nil.
+func (c *Config) Resolve(r *http.Request, fallback Limiter) (Limiter, string) {
+	if len(c.Rules) == 0 {
+		return fallback, ""
+	}
+
+	rule, ok := c.WhichRule(r)
+	if !ok {
+		if c.DebugEnabled() {
+			c.Logger.Debugf("[RateLimiter] No rule matched %s %s, using default limiter", r.Method, r.URL.Path)
+		}
+		return fallback, ""
+	}
+
+	if c.DebugEnabled() {
+		c.Logger.Debugf("[RateLimiter] Rule '%s' matched %s %s", rule.Name, r.Method, r.URL.Path)
+	}
+	return rule.Limiter, rule.Name
+}
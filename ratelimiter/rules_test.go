@@ -0,0 +1,89 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWhichRule(t *testing.T) {
+	api := Rule{Name: "api", Match: MatchPathPrefix("/api"), Limiter: allowOnly{}, Priority: 4}
+	upload := Rule{Name: "upload", Match: MatchPathPrefix("/api/upload"), Limiter: allowOnly{}, Priority: 11}
+	uploadTie := Rule{Name: "upload-tie", Match: MatchPathPrefix("/api/upload"), Limiter: allowOnly{}, Priority: 11}
+	post := Rule{Name: "post", Match: MatchMethod(http.MethodPost), Limiter: allowOnly{}}
+
+	tests := []struct {
+		name       string
+		rules      []Rule
+		precedence RulePrecedence
+		method     string
+		path       string
+		want       string
+	}{
+		{name: "first match", rules: []Rule{api, upload}, method: http.MethodGet, path: "/api/upload/1", want: "api"},
+		{name: "first match order", rules: []Rule{upload, api}, method: http.MethodGet, path: "/api/upload/1", want: "upload"},
+		{name: "most specific", rules: []Rule{api, upload}, precedence: MostSpecific, method: http.MethodGet, path: "/api/upload/1", want: "upload"},
+		{name: "most specific falls back", rules: []Rule{api, upload}, precedence: MostSpecific, method: http.MethodGet, path: "/api/users", want: "api"},
+		{name: "most specific tie", rules: []Rule{upload, uploadTie}, precedence: MostSpecific, method: http.MethodGet, path: "/api/upload", want: "upload"},
+		{name: "method", rules: []Rule{post, api}, method: http.MethodPost, path: "/api", want: "post"},
+		{name: "no match", rules: []Rule{api, upload}, method: http.MethodGet, path: "/health", want: ""},
+		{name: "no rules", method: http.MethodGet, path: "/api", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig(WithRules(tt.rules...), WithRulePrecedence(tt.precedence))
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+
+			rule, ok := cfg.WhichRule(r)
+			if ok != (tt.want != "") || rule.Name != tt.want {
+				t.Errorf("WhichRule = %q, %v, want %q", rule.Name, ok, tt.want)
+			}
+
+			fallback := newQuotaLimiter(1)
+			limiter, name := cfg.Resolve(r, fallback)
+			if name != tt.want {
+				t.Errorf("Resolve rule = %q, want %q", name, tt.want)
+			}
+			if tt.want == "" && limiter != Limiter(fallback) {
+				t.Errorf("Resolve did not return the fallback limiter")
+			}
+		})
+	}
+}
+
+func TestWithRulesSkipsIncompleteRules(t *testing.T) {
+	cfg := NewConfig(WithRules(
+		Rule{Name: "no-match", Limiter: allowOnly{}},
+		Rule{Name: "no-limiter", Match: MatchPathPrefix("/")},
+		Rule{Name: "ok", Match: MatchPathPrefix("/"), Limiter: allowOnly{}},
+	))
+	if len(cfg.Rules) != 1 || cfg.Rules[0].Name != "ok" {
+		t.Errorf("rules = %v, want only %q", cfg.Rules, "ok")
+	}
+}
+
+func TestClassLimitsClassifyOnce(t *testing.T) {
+	calls := 0
+	classifier := func(r *http.Request) Class {
+		calls++
+		return ClassBrowser
+	}
+	cfg := NewConfig(
+		WithClassLimits(classifier, map[Class]Limiter{
+			ClassVerifiedBot: allowOnly{},
+			ClassUnknownBot:  allowOnly{},
+			ClassBrowser:     allowOnly{},
+			ClassOther:       allowOnly{},
+		}),
+		WithRulePrecedence(MostSpecific),
+	)
+
+	_, rule := cfg.Resolve(httptest.NewRequest(http.MethodGet, "/", nil), nil)
+	if rule != "class:browser" {
+		t.Errorf("rule = %q, want %q", rule, "class:browser")
+	}
+	if calls != 1 {
+		t.Errorf("classifier called %d times, want 1", calls)
+	}
+}
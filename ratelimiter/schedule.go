@@ -0,0 +1,192 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains time-of-day schedules, which switch between limiters
+// depending on when a request is made.
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ScheduleWindow applies a limiter during a daily time range.
+type ScheduleWindow struct {
+	// Start and End are times of day in "15:04" format. The window includes
+	// Start and excludes End; an End at or before Start spans midnight.
+	Start string
+	End   string
+	// Days restricts the window to the given weekdays, those on which it
+	// starts for windows spanning midnight. Empty means every day.
+	Days []time.Weekday
+	// Calendar, if set, further restricts the window to the days it contains,
+	// e.g. Weekends() or regional holidays.
+	Calendar Calendar
+	// Limiter enforces the limits while the window is active.
+	Limiter Limiter
+}
+
+// ScheduleOption configures a limiter created by NewSchedule.
+type ScheduleOption func(*scheduleLimiter)
+
+// WithScheduleLocation sets the time zone in which windows are evaluated. The
+// default is time.Local.
+func WithScheduleLocation(loc *time.Location) ScheduleOption {
+	return func(s *scheduleLimiter) {
+		if loc != nil {
+			s.loc = loc
+		}
+	}
+}
+
+// WithScheduleClock sets the Clock used to determine the active window.
+func WithScheduleClock(c Clock) ScheduleOption {
+	return func(s *scheduleLimiter) {
+		if c != nil {
+			s.clock = c
+		}
+	}
+}
+
+// scheduleRange is a parsed ScheduleWindow.
+type scheduleRange struct {
+	start, end int // minutes since midnight
+	days       [7]bool
+	calendar   Calendar
+	limiter    Limiter
+}
+
+// NewSchedule returns a Limiter delegating each request to the limiter of the
+// first window active at the time of the request, or to fallback if none is.
+//
+// Limiters that share store keys carry their usage across window boundaries;
+// give them distinct WithKeyPrefix values to start each window afresh.
+//
+// Windows restricted by a Calendar apply on specific dates only; a window
+// from "00:00" to "00:00" covers whole days, e.g. to relax the limits of a
+// promotional endpoint during a sale.
+//
+// Example:
+//
+//	limiter, err := ratelimiter.NewSchedule(
+//	    ratelimiter.MustNewFixedWindow(store, 200, time.Minute),
+//	    []ratelimiter.ScheduleWindow{{
+//	        Start:   "09:00",
+//	        End:     "18:00",
+//	        Days:    []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+//	        Limiter: ratelimiter.MustNewFixedWindow(store, 1000, time.Minute),
+//	    }},
+//	    ratelimiter.WithScheduleLocation(berlin),
+//	)
+func NewSchedule(fallback Limiter, windows []ScheduleWindow, opts ...ScheduleOption) (Limiter, error) {
+	if fallback == nil {
+		return nil, fmt.Errorf("%w: fallback limiter must not be nil", ErrorInvalidConfig)
+	}
+
+	s := &scheduleLimiter{fallback: fallback, loc: time.Local, clock: systemClock{}}
+	for _, w := range windows {
+		r, err := parseScheduleWindow(w)
+		if err != nil {
+			return nil, err
+		}
+		s.ranges = append(s.ranges, r)
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// parseScheduleWindow validates w and converts it to a scheduleRange.
+func parseScheduleWindow(w ScheduleWindow) (scheduleRange, error) {
+	if w.Limiter == nil {
+		return scheduleRange{}, fmt.Errorf("%w: schedule window %s-%s has no limiter", ErrorInvalidConfig, w.Start, w.End)
+	}
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return scheduleRange{}, fmt.Errorf("%w: invalid schedule start %q", ErrorInvalidConfig, w.Start)
+	}
+	end, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return scheduleRange{}, fmt.Errorf("%w: invalid schedule end %q", ErrorInvalidConfig, w.End)
+	}
+
+	r := scheduleRange{
+		start:    start.Hour()*60 + start.Minute(),
+		end:      end.Hour()*60 + end.Minute(),
+		calendar: w.Calendar,
+		limiter:  w.Limiter,
+	}
+	for _, day := range w.Days {
+		r.days[day] = true
+	}
+	if len(w.Days) == 0 {
+		r.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	return r, nil
+}
+
+// active reports whether r applies at t.
+func (r scheduleRange) active(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+
+	var startDay time.Time
+	switch {
+	case r.start < r.end:
+		if minute < r.start || minute >= r.end {
+			return false
+		}
+		startDay = t
+	case minute >= r.start:
+		// The window spans midnight: it is active from start on the day it
+		// starts and until end on the following one.
+		startDay = t
+	case minute < r.end:
+		startDay = t.AddDate(0, 0, -1)
+	default:
+		return false
+	}
+
+	if !r.days[startDay.Weekday()] {
+		return false
+	}
+	return r.calendar == nil || r.calendar.Contains(startDay)
+}
+
+// scheduleLimiter is the Limiter returned by NewSchedule.
+type scheduleLimiter struct {
+	ranges   []scheduleRange
+	fallback Limiter
+	loc      *time.Location
+	clock    Clock
+}
+
+// current returns the limiter that applies now.
+func (s *scheduleLimiter) current() Limiter {
+	now := s.clock.Now().In(s.loc)
+	for _, r := range s.ranges {
+		if r.active(now) {
+			return r.limiter
+		}
+	}
+	return s.fallback
+}
+
+// Allow delegates to the limiter of the active window.
+func (s *scheduleLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return s.AllowN(ctx, key, 1)
+}
+
+// AllowN charges n units to the limiter of the active window.
+func (s *scheduleLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	return AllowN(ctx, s.current(), key, n)
+}
+
+// Refund gives n units back to the limiter of the active window.
+func (s *scheduleLimiter) Refund(ctx context.Context, key string, n int64) error {
+	refunder, ok := s.current().(Refunder)
+	if !ok {
+		return ErrorRefundUnsupported
+	}
+	return refunder.Refund(ctx, key, n)
+}
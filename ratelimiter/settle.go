@@ -0,0 +1,152 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains settlements, which let handlers adjust the cost charged
+// for a request once they know what it actually consumed.
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrorNoSettlement is returned by Charge and Refund when the context does not
+// belong to a request admitted by middleware configured with WithSettlements.
+var ErrorNoSettlement = errors.New("no rate limit settlement in context")
+
+// ErrorSettled is returned by Charge and Refund once the middleware has
+// settled the request, i.e. after the handler returned.
+var ErrorSettled = errors.New("rate limit settlement already settled")
+
+// Settlement records the adjustments a handler makes to the provisional cost
+// charged for its request. The bundled net/http and gin middleware configured
+// with WithSettlements create one for every admitted request and settle it
+// against the limiter when the handler returns.
+type Settlement struct {
+	mu      sync.Mutex
+	cost    int64
+	delta   int64
+	settled bool
+}
+
+// WithSettlements returns an Option that lets handlers adjust the cost of
+// their request with Charge and Refund. Without it, middleware skips the
+// bookkeeping and both return ErrorNoSettlement.
+//
+// Example:
+//
+//	handler := nethttp.Middleware(limiter, ratelimiter.WithSettlements())(search)
+func WithSettlements() Option {
+	return func(c *Config) {
+		c.settlements = true
+	}
+}
+
+// SettlementsEnabled reports whether WithSettlements was given, so that
+// middleware only creates a Settlement when handlers may use it.
+func (c *Config) SettlementsEnabled() bool {
+	return c.settlements
+}
+
+// settlementKey is the context key under which NewSettlement stores a
+// Settlement.
+type settlementKey struct{}
+
+// NewSettlement returns a copy of ctx carrying a Settlement for a request
+// provisionally charged cost units. It is meant for middleware; handlers use
+// Charge and Refund.
+func NewSettlement(ctx context.Context, cost int64) (context.Context, *Settlement) {
+	s := &Settlement{cost: cost}
+	return context.WithValue(ctx, settlementKey{}, s), s
+}
+
+// Charge adds n units to the cost of the request ctx belongs to, e.g. one unit
+// per row returned or per kilobyte streamed. The units are charged when the
+// handler returns.
+//
+// Example:
+//
+//	rows, err := db.Query(ctx, query)
+//	// ...
+//	_ = ratelimiter.Charge(ctx, int64(len(rows))/100)
+func Charge(ctx context.Context, n int64) error {
+	return adjust(ctx, n)
+}
+
+// Refund removes n units from the cost of the request ctx belongs to, e.g.
+// when a request served from a cache was provisionally charged as expensive.
+// At most the units charged for the request are given back, and only by
+// limiters implementing Refunder.
+//
+// Example:
+//
+//	if cached {
+//	    _ = ratelimiter.Refund(ctx, 4)
+//	}
+func Refund(ctx context.Context, n int64) error {
+	return adjust(ctx, -n)
+}
+
+// adjust adds delta to the settlement in ctx.
+func adjust(ctx context.Context, delta int64) error {
+	s, ok := ctx.Value(settlementKey{}).(*Settlement)
+	if !ok {
+		return ErrorNoSettlement
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.settled {
+		return ErrorSettled
+	}
+	s.delta += delta
+	return nil
+}
+
+// Settle charges or refunds the net adjustment to every key, using limiter,
+// and closes the settlement to further adjustments. It does nothing if there
+// is no adjustment.
+//
+// Extra units are charged even if the keys have run out of quota, as far as
+// the algorithm allows: fixed windows count them in full, while token buckets
+// are drained of the tokens they have left. Refunds are capped at the units
+// charged for the request and return ErrorRefundUnsupported if limiter does not
+// implement Refunder.
+func (s *Settlement) Settle(ctx context.Context, limiter Limiter, keys []string) error {
+	s.mu.Lock()
+	delta := s.delta
+	s.settled = true
+	s.mu.Unlock()
+
+	switch {
+	case delta > 0:
+		var firstErr error
+		for _, key := range keys {
+			result, err := AllowN(ctx, limiter, key, delta)
+			if err == nil && !result.Allowed && result.Remaining > 0 {
+				_, err = AllowN(ctx, limiter, key, min(result.Remaining, delta))
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	case delta < 0:
+		refunder, ok := limiter.(Refunder)
+		if !ok {
+			return ErrorRefundUnsupported
+		}
+		n := min(-delta, s.cost)
+		if n <= 0 {
+			return nil
+		}
+		var firstErr error
+		for _, key := range keys {
+			if err := refunder.Refund(ctx, key, n); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+	return nil
+}
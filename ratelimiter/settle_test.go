@@ -0,0 +1,78 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSettlement(t *testing.T) {
+	tests := []struct {
+		name string
+		// cost is the provisional cost charged when the request was admitted.
+		cost int64
+		// adjust lists the handler's adjustments: positive charges, negative refunds.
+		adjust   []int64
+		wantUsed int64
+	}{
+		{name: "no adjustment", cost: 2, wantUsed: 2},
+		{name: "charge", cost: 2, adjust: []int64{3}, wantUsed: 5},
+		{name: "charges add up", cost: 1, adjust: []int64{2, 2}, wantUsed: 5},
+		{name: "charge drains remaining quota", cost: 8, adjust: []int64{5}, wantUsed: 10},
+		{name: "refund", cost: 4, adjust: []int64{-3}, wantUsed: 1},
+		{name: "refund capped at cost", cost: 4, adjust: []int64{-10}, wantUsed: 0},
+		{name: "charge and refund net out", cost: 2, adjust: []int64{3, -3}, wantUsed: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newQuotaLimiter(10)
+			keys := []string{"ip", "user"}
+			if _, err := AllowKeys(context.Background(), limiter, keys, tt.cost); err != nil {
+				t.Fatalf("AllowKeys: %v", err)
+			}
+
+			ctx, settlement := NewSettlement(context.Background(), tt.cost)
+			for _, n := range tt.adjust {
+				var err error
+				if n > 0 {
+					err = Charge(ctx, n)
+				} else {
+					err = Refund(ctx, -n)
+				}
+				if err != nil {
+					t.Fatalf("adjust %d: %v", n, err)
+				}
+			}
+			if err := settlement.Settle(ctx, limiter, keys); err != nil {
+				t.Fatalf("Settle: %v", err)
+			}
+
+			for _, key := range keys {
+				if got := limiter.usage(key); got != tt.wantUsed {
+					t.Errorf("%s: used = %d, want %d", key, got, tt.wantUsed)
+				}
+			}
+		})
+	}
+}
+
+func TestSettlementErrors(t *testing.T) {
+	if err := Charge(context.Background(), 1); !errors.Is(err, ErrorNoSettlement) {
+		t.Errorf("Charge without settlement = %v, want %v", err, ErrorNoSettlement)
+	}
+
+	ctx, settlement := NewSettlement(context.Background(), 1)
+	if err := settlement.Settle(ctx, allowOnly{}, []string{"key"}); err != nil {
+		t.Fatalf("Settle: %v", err)
+	}
+	if err := Refund(ctx, 1); !errors.Is(err, ErrorSettled) {
+		t.Errorf("Refund after Settle = %v, want %v", err, ErrorSettled)
+	}
+
+	ctx, settlement = NewSettlement(context.Background(), 1)
+	_ = Refund(ctx, 1)
+	if err := settlement.Settle(ctx, allowOnly{}, []string{"key"}); !errors.Is(err, ErrorRefundUnsupported) {
+		t.Errorf("Settle refund without Refunder = %v, want %v", err, ErrorRefundUnsupported)
+	}
+}
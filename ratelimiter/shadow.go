@@ -0,0 +1,142 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains the ShadowLimiter, which compares a candidate limiter
+// configuration against the one being enforced.
+package ratelimiter
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ShadowStats counts how the decisions of a ShadowLimiter's candidate compare
+// to those of its primary.
+type ShadowStats struct {
+	// BothAllowed and BothDenied count the decisions on which they agreed.
+	BothAllowed uint64 `json:"both_allowed"`
+	BothDenied  uint64 `json:"both_denied"`
+	// WouldDeny counts requests the primary allowed but the candidate would
+	// have denied, i.e. the candidate is stricter.
+	WouldDeny uint64 `json:"would_deny"`
+	// WouldAllow counts requests the primary denied but the candidate would
+	// have allowed, i.e. the candidate is looser.
+	WouldAllow uint64 `json:"would_allow"`
+	// CandidateErrors counts requests for which the candidate returned an error.
+	CandidateErrors uint64 `json:"candidate_errors"`
+}
+
+// DivergenceFunc is called with the key and both results when the candidate
+// of a ShadowLimiter decides differently from the primary.
+type DivergenceFunc func(ctx context.Context, key string, primary, candidate Result)
+
+// ShadowOption configures a ShadowLimiter.
+type ShadowOption func(*ShadowLimiter)
+
+// WithDivergenceFunc sets a function called on every divergent decision, e.g.
+// to log the affected keys.
+func WithDivergenceFunc(f DivergenceFunc) ShadowOption {
+	return func(s *ShadowLimiter) {
+		s.onDivergence = f
+	}
+}
+
+// ShadowLimiter enforces a primary limiter while also evaluating a candidate
+// limiter on every request, recording where their decisions diverge. It is
+// used to validate a new algorithm or tighter limits before cutover.
+//
+// The candidate's decisions are never enforced, but it does consume its own
+// quota, so it must not share store keys with the primary: give it a distinct
+// WithKeyPrefix.
+//
+// Example usage:
+//
+//	shadow := ratelimiter.NewShadow(
+//	    ratelimiter.MustNewFixedWindow(store, 100, time.Minute),
+//	    ratelimiter.MustNewTokenBucket(store, 1, 50, ratelimiter.WithKeyPrefix("candidate:")),
+//	)
+//	handler := nethttp.Middleware(shadow)(mux)
+//
+//	// Later:
+//	stats := shadow.Stats()
+//	log.Printf("candidate would deny %d requests the current limits allow", stats.WouldDeny)
+type ShadowLimiter struct {
+	primary      Limiter
+	candidate    Limiter
+	onDivergence DivergenceFunc
+
+	bothAllowed     atomic.Uint64
+	bothDenied      atomic.Uint64
+	wouldDeny       atomic.Uint64
+	wouldAllow      atomic.Uint64
+	candidateErrors atomic.Uint64
+}
+
+// NewShadow creates a ShadowLimiter enforcing primary and shadowing candidate.
+func NewShadow(primary, candidate Limiter, opts ...ShadowOption) *ShadowLimiter {
+	s := &ShadowLimiter{primary: primary, candidate: candidate}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Allow returns the decision of the primary and records the candidate's.
+func (s *ShadowLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return s.AllowN(ctx, key, 1)
+}
+
+// AllowN charges n units to both limiters and returns the decision of the
+// primary. Errors of the candidate are counted but never returned.
+func (s *ShadowLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	result, err := AllowN(ctx, s.primary, key, n)
+	if err != nil {
+		return result, err
+	}
+
+	shadow, err := AllowN(ctx, s.candidate, key, n)
+	if err != nil {
+		s.candidateErrors.Add(1)
+		return result, nil
+	}
+
+	switch {
+	case result.Allowed && shadow.Allowed:
+		s.bothAllowed.Add(1)
+		return result, nil
+	case !result.Allowed && !shadow.Allowed:
+		s.bothDenied.Add(1)
+		return result, nil
+	case result.Allowed:
+		s.wouldDeny.Add(1)
+	default:
+		s.wouldAllow.Add(1)
+	}
+	if s.onDivergence != nil {
+		s.onDivergence(ctx, key, result, shadow)
+	}
+	return result, nil
+}
+
+// Refund gives n units back to the primary, and to the candidate if it
+// supports refunds.
+func (s *ShadowLimiter) Refund(ctx context.Context, key string, n int64) error {
+	refunder, ok := s.primary.(Refunder)
+	if !ok {
+		return ErrorRefundUnsupported
+	}
+	if candidate, ok := s.candidate.(Refunder); ok {
+		_ = candidate.Refund(ctx, key, n)
+	}
+	return refunder.Refund(ctx, key, n)
+}
+
+// Stats returns the divergence counts recorded so far.
+func (s *ShadowLimiter) Stats() ShadowStats {
+	return ShadowStats{
+		BothAllowed:     s.bothAllowed.Load(),
+		BothDenied:      s.bothDenied.Load(),
+		WouldDeny:       s.wouldDeny.Load(),
+		WouldAllow:      s.wouldAllow.Load(),
+		CandidateErrors: s.candidateErrors.Load(),
+	}
+}
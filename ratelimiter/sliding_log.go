@@ -0,0 +1,130 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains the sliding window log limiter, which counts requests
+// over the exact window preceding each request.
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SlidingLogLimiter implements the "sliding window log" rate-limiting
+// algorithm.
+//
+// It records the time of every allowed request and allows a request if fewer
+// than limit requests were recorded during the preceding window. Unlike the
+// fixed window, a client cannot send twice the limit around a window
+// boundary; the price is one log entry per request instead of one counter
+// per key, so it suits low limits such as login attempts.
+//
+// Example usage:
+//
+//	limiter, err := ratelimiter.NewSlidingLog(store.NewRedis(client), 5, 15*time.Minute)
+//	result, err := limiter.Allow(ctx, "login:alice")
+type SlidingLogLimiter struct {
+	store  SlidingLogStore
+	limit  int64
+	window time.Duration
+	opts   limiterOptions
+}
+
+// NewSlidingLog creates a new SlidingLogLimiter instance.
+//
+// Parameters:
+//   - store: a ratelimiter.Store implementation that also implements SlidingLogStore
+//   - limit: maximum number of requests allowed within any window
+//   - window: length of the sliding window
+//   - opts: optional LimiterOption values, e.g. WithName
+//
+// Returns an error wrapping ErrorInvalidConfig if store is nil or does not
+// implement SlidingLogStore, limit is less than 1, or window is not positive.
+func NewSlidingLog(store Store, limit int64, window time.Duration, opts ...LimiterOption) (Limiter, error) {
+	if store == nil {
+		return nil, fmt.Errorf("%w: store must not be nil", ErrorInvalidConfig)
+	}
+	logs, ok := StoreAs[SlidingLogStore](store)
+	if !ok {
+		return nil, fmt.Errorf("%w: store does not support sliding window logs", ErrorInvalidConfig)
+	}
+	if limit < 1 {
+		return nil, fmt.Errorf("%w: limit must be at least 1, got %d", ErrorInvalidConfig, limit)
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("%w: window must be positive, got %s", ErrorInvalidConfig, window)
+	}
+
+	return &SlidingLogLimiter{
+		store:  logs,
+		limit:  limit,
+		window: window,
+		opts:   newLimiterOptions(opts),
+	}, nil
+}
+
+// MustNewSlidingLog is like NewSlidingLog but panics if the configuration is invalid.
+func MustNewSlidingLog(store Store, limit int64, window time.Duration, opts ...LimiterOption) Limiter {
+	limiter, err := NewSlidingLog(store, limit, window, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return limiter
+}
+
+// Allow checks whether a request with the given key is allowed and records it
+// if so.
+//
+// ResetAfter is the time until the log has room for another request, i.e.
+// until the oldest recorded request leaves the window once the limit is
+// reached.
+//
+// Example:
+//
+//	result, err := limiter.Allow(ctx, "login:alice")
+func (l *SlidingLogLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN checks whether a request costing n units is allowed and records n
+// entries if so. Requests costing more than limit are never allowed, and
+// costs less than 1 return an error wrapping ErrorInvalidConfig.
+//
+// Example:
+//
+//	result, err := limiter.(ratelimiter.CostLimiter).AllowN(ctx, "export:alice", 3)
+func (l *SlidingLogLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	if err := checkCost(n); err != nil {
+		return Result{Allowed: false}, err
+	}
+	allowed, count, resetAfter, err := l.store.LogRequests(ctx, l.opts.keyPrefix+key, n, l.limit, l.window)
+	if err != nil {
+		return Result{Allowed: false}, err
+	}
+
+	remaining := max(l.limit-count, 0)
+	now := l.opts.clock.Now()
+	return Result{
+		Allowed:         allowed,
+		Limit:           l.limit,
+		Remaining:       remaining,
+		ResetAfter:      resetAfter,
+		RemainingTokens: float64(remaining),
+		RetryAt:         now.Add(resetAfter),
+		Policy:          l.opts.name,
+		Algorithm:       AlgorithmSlidingLog,
+		WindowStart:     now.Add(-l.window),
+		WindowEnd:       now,
+	}, nil
+}
+
+// Spec returns the configuration of the limiter.
+func (l *SlidingLogLimiter) Spec() Spec {
+	return Spec{
+		Name:      l.opts.name,
+		Algorithm: AlgorithmSlidingLog,
+		Limit:     l.limit,
+		Window:    Duration(l.window),
+		KeyPrefix: l.opts.keyPrefix,
+	}
+}
@@ -0,0 +1,98 @@
+package ratelimiter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSpecRoundTrip(t *testing.T) {
+	store := newFakeStore()
+
+	tests := []struct {
+		name    string
+		limiter func() (Limiter, error)
+	}{
+		{
+			name: "fixed window",
+			limiter: func() (Limiter, error) {
+				return NewFixedWindow(store, 5, time.Minute, WithName("login"), WithKeyPrefix("auth:"))
+			},
+		},
+		{
+			name: "aligned fixed window",
+			limiter: func() (Limiter, error) {
+				return NewFixedWindow(store, 5, time.Minute, WithName("login"), WithAlignedWindows())
+			},
+		},
+		{
+			name: "token bucket",
+			limiter: func() (Limiter, error) {
+				return NewTokenBucket(store, 10, 50, WithName("search"))
+			},
+		},
+		{
+			name: "token bucket starting empty",
+			limiter: func() (Limiter, error) {
+				return NewTokenBucket(store, 10, 50, WithName("search"), WithInitialTokens(0))
+			},
+		},
+		{
+			name: "token bucket with idle ttl",
+			limiter: func() (Limiter, error) {
+				return NewTokenBucket(store, 10, 50, WithName("search"), WithInitialTokens(5), WithIdleTTL(5*time.Minute))
+			},
+		},
+	}
+
+	type specLimiter interface{ Spec() Spec }
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, err := tt.limiter()
+			if err != nil {
+				t.Fatal(err)
+			}
+			spec := limiter.(specLimiter).Spec()
+
+			data, err := json.Marshal(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded Spec
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			rebuilt, err := decoded.Build(store)
+			if err != nil {
+				t.Fatalf("Build(%s): %v", data, err)
+			}
+
+			if got := rebuilt.(specLimiter).Spec(); !reflect.DeepEqual(got, spec) {
+				t.Errorf("rebuilt spec = %+v, want %+v (from %s)", got, spec, data)
+			}
+		})
+	}
+}
+
+func TestSpecInitialTokensRequireBucketStore(t *testing.T) {
+	initial := int64(0)
+	spec := Spec{Name: "search", Algorithm: AlgorithmTokenBucket, Rate: 1, Burst: 5, InitialTokens: &initial}
+	if _, err := spec.Build(quotaStore{}); !errors.Is(err, ErrorInvalidConfig) {
+		t.Errorf("Build error = %v, want %v", err, ErrorInvalidConfig)
+	}
+}
+
+// quotaStore is a Store without optional capabilities.
+type quotaStore struct{}
+
+func (quotaStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	return 1, window, nil
+}
+
+func (quotaStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, error) {
+	return true, float64(burst - 1), nil
+}
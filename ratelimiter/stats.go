@@ -0,0 +1,166 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains the decision statistics collected by Manager.
+package ratelimiter
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// topKeysCapacity is the number of keys tracked per policy for PolicyStats.TopKeys.
+const topKeysCapacity = 64
+
+// KeyCount is the number of denied requests recorded for a key.
+type KeyCount struct {
+	Key    string `json:"key"`
+	Denied uint64 `json:"denied"`
+}
+
+// PolicyStats summarizes the decisions made by a named limiter of a Manager.
+type PolicyStats struct {
+	Name    string `json:"name"`
+	Allowed uint64 `json:"allowed"`
+	Denied  uint64 `json:"denied"`
+	// TopKeys lists the most denied keys, most denied first. Counts are
+	// approximate once more keys than can be tracked have been denied.
+	TopKeys []KeyCount `json:"top_keys"`
+	// Spec describes the limiter's configuration, if it can describe itself.
+	Spec *Spec `json:"spec,omitempty"`
+}
+
+// Describer is implemented by limiters that can report their configuration.
+type Describer interface {
+	// Spec returns the configuration of the limiter.
+	Spec() Spec
+}
+
+// policyStats accumulates decisions for one policy.
+type policyStats struct {
+	mu      sync.Mutex
+	allowed uint64
+	denied  uint64
+	keys    map[string]uint64
+
+	window *ratioWindow
+	firing bool
+}
+
+// record counts a decision for key and returns the alert callback to run, if any.
+//
+// Denied keys are tracked with the Space-Saving algorithm: when the table is
+// full, the least denied key is replaced and the new key inherits its count,
+// which bounds memory while keeping heavy hitters.
+func (s *policyStats) record(name, key string, allowed bool, alert *DenyRatioAlert) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var notify func()
+	if alert != nil {
+		notify = s.evaluateAlert(name, alert, allowed, time.Now())
+	}
+
+	if allowed {
+		s.allowed++
+		return notify
+	}
+	s.denied++
+
+	if _, ok := s.keys[key]; ok || len(s.keys) < topKeysCapacity {
+		s.keys[key]++
+		return notify
+	}
+
+	minKey, minCount := "", uint64(0)
+	for k, c := range s.keys {
+		if minKey == "" || c < minCount {
+			minKey, minCount = k, c
+		}
+	}
+	delete(s.keys, minKey)
+	s.keys[key] = minCount + 1
+	return notify
+}
+
+// snapshot returns the accumulated statistics for the policy name.
+func (s *policyStats) snapshot(name string) PolicyStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	top := make([]KeyCount, 0, len(s.keys))
+	for k, c := range s.keys {
+		top = append(top, KeyCount{Key: k, Denied: c})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Denied != top[j].Denied {
+			return top[i].Denied > top[j].Denied
+		}
+		return top[i].Key < top[j].Key
+	})
+	if len(top) > 10 {
+		top = top[:10]
+	}
+
+	return PolicyStats{Name: name, Allowed: s.allowed, Denied: s.denied, TopKeys: top}
+}
+
+// record counts a decision made by the named limiter for key.
+func (m *Manager) record(name, key string, allowed bool) {
+	m.statsMu.Lock()
+	stats, ok := m.stats[name]
+	if !ok {
+		stats = &policyStats{keys: make(map[string]uint64)}
+		m.stats[name] = stats
+	}
+	alert := m.alert
+	m.statsMu.Unlock()
+
+	if notify := stats.record(name, key, allowed, alert); notify != nil {
+		go notify()
+	}
+}
+
+// Stats returns decision statistics for every policy that has made decisions
+// through a Limiter returned by Limiter, sorted by name.
+//
+// Counts are cumulative since the Manager was created; compare successive
+// snapshots to derive rates.
+func (m *Manager) Stats() []PolicyStats {
+	m.statsMu.Lock()
+	names := make([]string, 0, len(m.stats))
+	for name := range m.stats {
+		names = append(names, name)
+	}
+	m.statsMu.Unlock()
+	sort.Strings(names)
+
+	result := make([]PolicyStats, 0, len(names))
+	for _, name := range names {
+		m.statsMu.Lock()
+		stats := m.stats[name]
+		m.statsMu.Unlock()
+
+		snapshot := stats.snapshot(name)
+		if limiter, err := m.lookup(name); err == nil {
+			if d, ok := limiter.(Describer); ok {
+				spec := d.Spec()
+				snapshot.Spec = &spec
+			}
+		}
+		result = append(result, snapshot)
+	}
+	return result
+}
+
+// lookup returns the limiter currently held under name without creating it.
+func (m *Manager) lookup(name string) (Limiter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	limiter, ok := m.limiters[name]
+	if !ok {
+		return nil, ErrorLimiterNotFound
+	}
+	return limiter, nil
+}
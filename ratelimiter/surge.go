@@ -0,0 +1,326 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains the SurgeLimiter, which switches to emergency limits
+// while traffic surges.
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// SurgeStats describes the traffic observed by a SurgeLimiter.
+type SurgeStats struct {
+	// Active reports whether the emergency limits are in force.
+	Active bool `json:"active"`
+	// Since is when the current mode was entered, or the zero time if the
+	// limiter has never switched.
+	Since time.Time `json:"since"`
+	// RequestRate is the number of requests per second over the window.
+	RequestRate float64 `json:"request_rate"`
+	// DenyRatio is the share of requests denied by the normal limits over the
+	// window. Requests checked against the emergency limits are not counted.
+	DenyRatio float64 `json:"deny_ratio"`
+}
+
+// SurgeOption configures a SurgeLimiter.
+type SurgeOption func(*SurgeLimiter)
+
+// WithSurgeRequestRate makes the limiter switch to the emergency limits when
+// more than perSecond requests per second pass through it over the window.
+func WithSurgeRequestRate(perSecond float64) SurgeOption {
+	return func(s *SurgeLimiter) {
+		s.maxRate = perSecond
+	}
+}
+
+// WithSurgeDenyRatio makes the limiter switch to the emergency limits when,
+// over the window, at least minRequests requests were checked against the
+// normal limits and more than ratio of them (between 0 and 1) were denied.
+func WithSurgeDenyRatio(ratio float64, minRequests uint64) SurgeOption {
+	return func(s *SurgeLimiter) {
+		s.maxDenyRatio = ratio
+		s.minRequests = minRequests
+	}
+}
+
+// WithSurgeWindow sets the sliding window over which the request rate and
+// deny ratio are measured. The default is one minute.
+func WithSurgeWindow(d time.Duration) SurgeOption {
+	return func(s *SurgeLimiter) {
+		if d > 0 {
+			s.window.bucketSize = max(d/alertBuckets, 1)
+			s.normalWindow.bucketSize = s.window.bucketSize
+			s.windowSize = d
+		}
+	}
+}
+
+// WithSurgeCooldown sets how long traffic must stay below the thresholds
+// before the normal limits are restored. The default is five minutes.
+func WithSurgeCooldown(d time.Duration) SurgeOption {
+	return func(s *SurgeLimiter) {
+		if d >= 0 {
+			s.cooldown = d
+		}
+	}
+}
+
+// WithSurgeAggregation rewrites keys while the emergency limits are in force,
+// so that clients are limited in broader groups, e.g. with AggregateIP to
+// limit networks instead of addresses.
+func WithSurgeAggregation(aggregate func(key string) string) SurgeOption {
+	return func(s *SurgeLimiter) {
+		s.aggregate = aggregate
+	}
+}
+
+// WithSurgeHook calls fn whenever the limiter switches mode. It runs in its
+// own goroutine so that it never delays requests.
+func WithSurgeHook(fn func(SurgeStats)) SurgeOption {
+	return func(s *SurgeLimiter) {
+		s.onChange = fn
+	}
+}
+
+// AggregateIP returns a key rewrite for WithSurgeAggregation mapping IP
+// address keys to their network, keeping v4Bits bits of IPv4 addresses and
+// v6Bits bits of IPv6 addresses. Keys that are not IP addresses are returned
+// unchanged.
+//
+// Example:
+//
+//	// Limit /24 IPv4 and /48 IPv6 networks during a surge.
+//	ratelimiter.WithSurgeAggregation(ratelimiter.AggregateIP(24, 48))
+func AggregateIP(v4Bits, v6Bits int) func(key string) string {
+	return func(key string) string {
+		addr, err := netip.ParseAddr(key)
+		if err != nil {
+			return key
+		}
+		bits := v6Bits
+		if addr.Is4() || addr.Is4In6() {
+			addr, bits = addr.Unmap(), v4Bits
+		}
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			return key
+		}
+		return prefix.String()
+	}
+}
+
+// SurgeLimiter enforces normal limits until the traffic it observes exceeds a
+// request rate or deny ratio, then enforces stricter emergency limits, with
+// optionally broader keys, until traffic has stayed below the thresholds for
+// the cooldown period.
+//
+// Traffic is measured per instance, over every request passing through the
+// limiter. The deny ratio only counts requests checked against the normal
+// limits, so that denials by the emergency limits do not prolong the surge.
+// The emergency limiter must not share store keys with the normal one: give it
+// a distinct WithKeyPrefix.
+//
+// Example usage:
+//
+//	surge, err := ratelimiter.NewSurge(
+//	    ratelimiter.MustNewTokenBucket(store, 10, 50),
+//	    ratelimiter.MustNewTokenBucket(store, 1, 5, ratelimiter.WithKeyPrefix("surge:")),
+//	    ratelimiter.WithSurgeRequestRate(5000),
+//	    ratelimiter.WithSurgeDenyRatio(0.5, 1000),
+//	    ratelimiter.WithSurgeAggregation(ratelimiter.AggregateIP(24, 48)),
+//	)
+type SurgeLimiter struct {
+	normal    Limiter
+	emergency Limiter
+
+	maxRate      float64
+	maxDenyRatio float64
+	minRequests  uint64
+	windowSize   time.Duration
+	cooldown     time.Duration
+	aggregate    func(key string) string
+	onChange     func(SurgeStats)
+
+	mu           sync.Mutex
+	window       ratioWindow
+	normalWindow ratioWindow
+	active       bool
+	since        time.Time
+	lastSurge    time.Time
+	charges      map[string]surgeCharge
+	observations uint64
+}
+
+// maxSurgeCharges bounds the number of keys a SurgeLimiter remembers the
+// limiter of, so that a surge of distinct keys cannot exhaust memory.
+const maxSurgeCharges = 100_000
+
+// surgeChargeSweepEvery is how many decisions pass between sweeps of the
+// remembered charges.
+const surgeChargeSweepEvery = 1024
+
+// surgeCharge records which limiter last charged a key, so that refunds go
+// back to it even if the mode switched in between.
+type surgeCharge struct {
+	emergency bool
+	key       string
+	at        time.Time
+}
+
+// NewSurge creates a SurgeLimiter switching from normal to emergency.
+//
+// It returns an error wrapping ErrorInvalidConfig if either limiter is nil or
+// neither WithSurgeRequestRate nor WithSurgeDenyRatio is given.
+func NewSurge(normal, emergency Limiter, opts ...SurgeOption) (*SurgeLimiter, error) {
+	if normal == nil || emergency == nil {
+		return nil, fmt.Errorf("%w: surge limiters must not be nil", ErrorInvalidConfig)
+	}
+
+	s := &SurgeLimiter{
+		normal:       normal,
+		emergency:    emergency,
+		windowSize:   time.Minute,
+		window:       ratioWindow{bucketSize: time.Minute / alertBuckets},
+		normalWindow: ratioWindow{bucketSize: time.Minute / alertBuckets},
+		cooldown:     5 * time.Minute,
+		charges:      make(map[string]surgeCharge),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.maxRate <= 0 && s.maxDenyRatio <= 0 {
+		return nil, fmt.Errorf("%w: surge needs a request rate or deny ratio threshold", ErrorInvalidConfig)
+	}
+	return s, nil
+}
+
+// Active reports whether the emergency limits are in force.
+func (s *SurgeLimiter) Active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
+
+// Stats returns the current mode and the traffic observed over the window.
+func (s *SurgeLimiter) Stats() SurgeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats(time.Now())
+}
+
+// stats computes the SurgeStats at now. The caller must hold s.mu.
+func (s *SurgeLimiter) stats(now time.Time) SurgeStats {
+	allowed, denied := s.window.totals(now)
+	stats := SurgeStats{
+		Active:      s.active,
+		Since:       s.since,
+		RequestRate: float64(allowed+denied) / s.windowSize.Seconds(),
+	}
+	if allowed, denied := s.normalWindow.totals(now); allowed+denied > 0 {
+		stats.DenyRatio = float64(denied) / float64(allowed+denied)
+	}
+	return stats
+}
+
+// route returns the limiter and key to use for key in the current mode, and
+// whether that is the emergency mode.
+func (s *SurgeLimiter) route(key string) (Limiter, string, bool) {
+	if !s.Active() {
+		return s.normal, key, false
+	}
+	if s.aggregate != nil {
+		key = s.aggregate(key)
+	}
+	return s.emergency, key, true
+}
+
+// observe counts a decision made for key, charged to routed in the given mode,
+// and switches mode if the thresholds say so.
+func (s *SurgeLimiter) observe(key, routed string, emergency, allowed bool) {
+	now := time.Now()
+	s.mu.Lock()
+	if s.observations++; s.observations%surgeChargeSweepEvery == 0 {
+		s.sweep(now)
+	}
+	if _, ok := s.charges[key]; allowed && (ok || len(s.charges) < maxSurgeCharges) {
+		s.charges[key] = surgeCharge{emergency: emergency, key: routed, at: now}
+	}
+
+	s.window.add(now, allowed)
+	if !emergency {
+		s.normalWindow.add(now, allowed)
+	}
+	a, d := s.normalWindow.totals(now)
+	stats := s.stats(now)
+
+	surging := (s.maxRate > 0 && stats.RequestRate > s.maxRate) ||
+		(s.maxDenyRatio > 0 && a+d >= s.minRequests && stats.DenyRatio > s.maxDenyRatio)
+
+	changed := false
+	switch {
+	case surging:
+		s.lastSurge = now
+		if !s.active {
+			s.active, s.since, changed = true, now, true
+		}
+	case s.active && now.Sub(s.lastSurge) >= s.cooldown:
+		s.active, s.since, changed = false, now, true
+	}
+	stats.Active, stats.Since = s.active, s.since
+	s.mu.Unlock()
+
+	if changed && s.onChange != nil {
+		go s.onChange(stats)
+	}
+}
+
+// sweep forgets charges older than the window. The caller must hold s.mu.
+func (s *SurgeLimiter) sweep(now time.Time) {
+	for key, charge := range s.charges {
+		if now.Sub(charge.at) > s.windowSize {
+			delete(s.charges, key)
+		}
+	}
+}
+
+// Allow checks the request against the limits of the current mode.
+func (s *SurgeLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return s.AllowN(ctx, key, 1)
+}
+
+// AllowN checks a request costing n units against the limits of the current
+// mode.
+func (s *SurgeLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	limiter, routed, emergency := s.route(key)
+	result, err := AllowN(ctx, limiter, routed, n)
+	if err != nil {
+		return result, err
+	}
+	s.observe(key, routed, emergency, result.Allowed)
+	return result, nil
+}
+
+// Refund gives back n units to the limiter that last charged key within the
+// window, or to the limiter of the current mode if there is none, so that a
+// mode switch between AllowN and Refund does not credit the wrong limiter.
+func (s *SurgeLimiter) Refund(ctx context.Context, key string, n int64) error {
+	s.mu.Lock()
+	charge, ok := s.charges[key]
+	s.mu.Unlock()
+
+	limiter, routed := s.normal, charge.key
+	switch {
+	case !ok || time.Since(charge.at) > s.windowSize:
+		limiter, routed, _ = s.route(key)
+	case charge.emergency:
+		limiter = s.emergency
+	}
+	if refunder, ok := limiter.(Refunder); ok {
+		return refunder.Refund(ctx, routed, n)
+	}
+	return ErrorRefundUnsupported
+}
@@ -0,0 +1,193 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains the TieredLimiter, which applies the limits of the plan
+// each key is subscribed to.
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxCachedTiers bounds the number of tier lookups a TieredLimiter caches.
+// When it is reached the cache is cleared.
+const maxCachedTiers = 100000
+
+// TierFunc returns the tier of key, e.g. the plan a customer is subscribed to.
+type TierFunc func(ctx context.Context, key string) (string, error)
+
+// TieredOption configures a TieredLimiter.
+type TieredOption func(*TieredLimiter)
+
+// WithTierCacheTTL sets how long tier lookups are cached. The default is one minute.
+func WithTierCacheTTL(ttl time.Duration) TieredOption {
+	return func(t *TieredLimiter) {
+		if ttl > 0 {
+			t.ttl = ttl
+		}
+	}
+}
+
+// WithDefaultTier sets the tier used for keys whose tier is unknown to the
+// limiter. Without it such keys are rejected with an error wrapping
+// ErrorLimiterNotFound.
+func WithDefaultTier(tier string) TieredOption {
+	return func(t *TieredLimiter) {
+		t.fallback = tier
+	}
+}
+
+// WithTopUp lets Invalidate reset the state of a key in store, so that after
+// an upgrade the key starts with the full burst of its new tier. prefix must
+// match the key prefix of the tier limiters.
+func WithTopUp(store Resetter, prefix string) TieredOption {
+	return func(t *TieredLimiter) {
+		t.topUp = store
+		t.topUpPrefix = prefix
+	}
+}
+
+// cachedTier is a tier lookup cached by a TieredLimiter.
+type cachedTier struct {
+	tier      string
+	expiresAt time.Time
+}
+
+// TieredLimiter delegates each key to the limiter of its tier, looked up with
+// a TierFunc and cached.
+//
+// When a customer changes plan, call Invalidate so that the new limits apply
+// on the next request instead of when the cached lookup expires. Use
+// store.TierPropagator to propagate invalidations to every instance.
+//
+// Example usage:
+//
+//	tiered, err := ratelimiter.NewTiered(lookupPlan, map[string]ratelimiter.Limiter{
+//	    "free": ratelimiter.MustNewTokenBucket(store, 1, 10),
+//	    "pro":  ratelimiter.MustNewTokenBucket(store, 20, 200),
+//	}, ratelimiter.WithDefaultTier("free"), ratelimiter.WithTopUp(store.(ratelimiter.Resetter), ""))
+//
+//	// After the customer upgrades:
+//	err = tiered.Invalidate(ctx, "tenant:acme")
+type TieredLimiter struct {
+	lookup      TierFunc
+	tiers       map[string]Limiter
+	ttl         time.Duration
+	fallback    string
+	topUp       Resetter
+	topUpPrefix string
+
+	mu    sync.Mutex
+	cache map[string]cachedTier
+}
+
+// NewTiered creates a TieredLimiter resolving tiers with lookup and applying
+// the limiter registered for each tier name in tiers.
+func NewTiered(lookup TierFunc, tiers map[string]Limiter, opts ...TieredOption) (*TieredLimiter, error) {
+	if lookup == nil {
+		return nil, fmt.Errorf("%w: tier lookup must not be nil", ErrorInvalidConfig)
+	}
+	if len(tiers) == 0 {
+		return nil, fmt.Errorf("%w: at least one tier is required", ErrorInvalidConfig)
+	}
+
+	t := &TieredLimiter{
+		lookup: lookup,
+		tiers:  tiers,
+		ttl:    time.Minute,
+		cache:  make(map[string]cachedTier),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if _, ok := tiers[t.fallback]; t.fallback != "" && !ok {
+		return nil, fmt.Errorf("%w: default tier %q has no limiter", ErrorInvalidConfig, t.fallback)
+	}
+	return t, nil
+}
+
+// Tier returns the tier of key, from the cache if it is fresh.
+func (t *TieredLimiter) Tier(ctx context.Context, key string) (string, error) {
+	now := time.Now()
+	t.mu.Lock()
+	cached, ok := t.cache[key]
+	t.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.tier, nil
+	}
+
+	tier, err := t.lookup(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	t.mu.Lock()
+	if len(t.cache) >= maxCachedTiers {
+		t.cache = make(map[string]cachedTier)
+	}
+	t.cache[key] = cachedTier{tier: tier, expiresAt: now.Add(t.ttl)}
+	t.mu.Unlock()
+	return tier, nil
+}
+
+// limiter returns the limiter of the tier of key.
+func (t *TieredLimiter) limiter(ctx context.Context, key string) (Limiter, error) {
+	tier, err := t.Tier(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if limiter, ok := t.tiers[tier]; ok {
+		return limiter, nil
+	}
+	if limiter, ok := t.tiers[t.fallback]; ok {
+		return limiter, nil
+	}
+	return nil, fmt.Errorf("%w: no limiter for tier %q", ErrorLimiterNotFound, tier)
+}
+
+// Allow delegates to the limiter of the tier of key.
+func (t *TieredLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return t.AllowN(ctx, key, 1)
+}
+
+// AllowN charges n units to the limiter of the tier of key.
+func (t *TieredLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	limiter, err := t.limiter(ctx, key)
+	if err != nil {
+		return Result{Allowed: false}, err
+	}
+	return AllowN(ctx, limiter, key, n)
+}
+
+// Refund gives n units back to the limiter of the tier of key.
+func (t *TieredLimiter) Refund(ctx context.Context, key string, n int64) error {
+	limiter, err := t.limiter(ctx, key)
+	if err != nil {
+		return err
+	}
+	refunder, ok := limiter.(Refunder)
+	if !ok {
+		return ErrorRefundUnsupported
+	}
+	return refunder.Refund(ctx, key, n)
+}
+
+// Forget drops the cached tier of key on this instance only.
+func (t *TieredLimiter) Forget(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.cache, key)
+}
+
+// Invalidate drops the cached tier of key and, if WithTopUp is set, resets
+// its state so that it starts with the full burst of its new tier.
+func (t *TieredLimiter) Invalidate(ctx context.Context, key string) error {
+	t.Forget(key)
+	if t.topUp == nil {
+		return nil
+	}
+	return t.topUp.Reset(ctx, t.topUpPrefix+key)
+}
@@ -6,6 +6,7 @@ package ratelimiter
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"time"
 )
@@ -18,7 +19,10 @@ import (
 // Example usage:
 //
 //	store := store.NewMemory(ctx, time.Minute)
-//	limiter := ratelimiter.NewTokenBucket(store, 1.0, 5) // 1 token/sec, burst of 5
+//	limiter, err := ratelimiter.NewTokenBucket(store, 1.0, 5) // 1 token/sec, burst of 5
+//	if err != nil {
+//	    // handle invalid configuration
+//	}
 //	result, err := limiter.Allow(ctx, "user:123")
 //	if result.Allowed {
 //	    // process request
@@ -29,6 +33,45 @@ type TokenBucketLimiter struct {
 	store Store
 	rate  float64 // Tokens generated per second
 	burst int64   // Maximum number of tokens in the bucket
+	opts  limiterOptions
+}
+
+// WithInitialTokens makes TokenBucketLimiter start the bucket of a newly seen
+// key with n tokens instead of a full burst, so that new clients earn tokens
+// gradually. Use 0 to start empty, e.g. for signup or password reset
+// endpoints. Keys whose state the store has expired count as newly seen.
+//
+// It requires a store implementing BucketStore; NewTokenBucket returns an
+// error wrapping ErrorInvalidConfig otherwise, or if n is negative or greater
+// than burst.
+//
+// Example:
+//
+//	limiter, err := ratelimiter.NewTokenBucket(store, 1.0/60, 3, ratelimiter.WithInitialTokens(0))
+func WithInitialTokens(n int64) LimiterOption {
+	return func(o *limiterOptions) {
+		o.initialTokens = n
+		o.hasInitialTokens = true
+	}
+}
+
+// WithIdleTTL sets how long TokenBucketLimiter keeps the bucket of a key after
+// its last request. A dormant client's bucket is dropped after d and the
+// client starts over with a full bucket (or WithInitialTokens), which bounds
+// store memory independently of rate and burst. By default the store decides:
+// RedisStore keeps buckets for twice the time to refill a full burst, at least
+// 10 seconds, and MemoryStore for ten cleanup intervals.
+//
+// It requires a store implementing BucketStore; NewTokenBucket returns an
+// error wrapping ErrorInvalidConfig otherwise, or if d is negative.
+//
+// Example:
+//
+//	limiter, err := ratelimiter.NewTokenBucket(store, 100, 1000, ratelimiter.WithIdleTTL(5*time.Minute))
+func WithIdleTTL(d time.Duration) LimiterOption {
+	return func(o *limiterOptions) {
+		o.idleTTL = d
+	}
 }
 
 // NewTokenBucket creates a new TokenBucketLimiter instance.
@@ -37,19 +80,58 @@ type TokenBucketLimiter struct {
 //   - store: a ratelimiter.Store implementation for persisting token state
 //   - rate: number of tokens added to the bucket per second
 //   - burst: maximum number of tokens in the bucket (burst capacity)
+//   - opts: optional LimiterOption values, e.g. WithName, WithInitialTokens, or WithIdleTTL
 //
-// Returns a Limiter interface that can be used with any middleware or custom logic.
+// Returns a Limiter interface that can be used with any middleware or custom logic,
+// or an error wrapping ErrorInvalidConfig if store is nil, rate is not a positive
+// finite number, burst is less than 1, or WithInitialTokens cannot be honored.
 //
 // Example:
 //
 //	store := store.NewMemory(ctx, time.Minute)
-//	limiter := ratelimiter.NewTokenBucket(store, 1.0, 5)
-func NewTokenBucket(store Store, rate float64, burst int64) Limiter {
+//	limiter, err := ratelimiter.NewTokenBucket(store, 1.0, 5)
+func NewTokenBucket(store Store, rate float64, burst int64, opts ...LimiterOption) (Limiter, error) {
+	if store == nil {
+		return nil, fmt.Errorf("%w: store must not be nil", ErrorInvalidConfig)
+	}
+	if !(rate > 0) || math.IsInf(rate, 1) {
+		return nil, fmt.Errorf("%w: rate must be a positive finite number, got %v", ErrorInvalidConfig, rate)
+	}
+	if burst < 1 {
+		return nil, fmt.Errorf("%w: burst must be at least 1, got %d", ErrorInvalidConfig, burst)
+	}
+
+	o := newLimiterOptions(opts)
+	if o.hasInitialTokens && (o.initialTokens < 0 || o.initialTokens > burst) {
+		return nil, fmt.Errorf("%w: initial tokens must be between 0 and burst (%d), got %d", ErrorInvalidConfig, burst, o.initialTokens)
+	}
+	if o.idleTTL < 0 {
+		return nil, fmt.Errorf("%w: idle TTL must not be negative, got %v", ErrorInvalidConfig, o.idleTTL)
+	}
+	if o.hasInitialTokens || o.idleTTL > 0 {
+		if _, ok := StoreAs[BucketStore](store); !ok {
+			return nil, fmt.Errorf("%w: store does not support initial tokens or idle TTL", ErrorInvalidConfig)
+		}
+	}
+
 	return &TokenBucketLimiter{
 		store: store,
 		rate:  rate,
 		burst: burst,
+		opts:  o,
+	}, nil
+}
+
+// MustNewTokenBucket is like NewTokenBucket but panics if the configuration is invalid.
+//
+// It is intended for limits known at compile time, e.g. in package-level
+// variables or presets.
+func MustNewTokenBucket(store Store, rate float64, burst int64, opts ...LimiterOption) Limiter {
+	limiter, err := NewTokenBucket(store, rate, burst, opts...)
+	if err != nil {
+		panic(err)
 	}
+	return limiter
 }
 
 // Allow checks whether a request is allowed under the token bucket algorithm.
@@ -60,6 +142,9 @@ func NewTokenBucket(store Store, rate float64, burst int64) Limiter {
 //   - Limit: maximum number of tokens (burst)
 //   - Remaining: number of tokens remaining in the bucket
 //   - ResetAfter: estimated duration until the next token is available if request is denied
+//   - RemainingTokens: exact (fractional) number of tokens remaining in the bucket
+//   - RefillInterval: time it takes to refill a single token
+//   - RetryAt, Policy, Algorithm: absolute retry time, limiter name, and algorithm
 //
 // Example:
 //
@@ -70,30 +155,167 @@ func NewTokenBucket(store Store, rate float64, burst int64) Limiter {
 //	    // reject request
 //	}
 func (l *TokenBucketLimiter) Allow(ctx context.Context, key string) (Result, error) {
-	allowed, remaining, err := l.store.TakeToken(ctx, key, l.rate, l.burst)
+	allowed, remaining, err := l.takeToken(ctx, l.opts.keyPrefix+key)
+	if err != nil {
+		return Result{Allowed: false}, err
+	}
+
+	return l.result(allowed, remaining, 1), nil
+}
+
+// AllowN checks whether a request costing n tokens is allowed and takes them
+// from the bucket if so. Requests costing more than burst are never allowed:
+// they are denied without taking tokens, and ResetAfter is the time until the
+// bucket is full, after which waiting longer does not help.
+//
+// It returns ErrorCostUnsupported if the store does not implement CostStore,
+// and an error wrapping ErrorInvalidConfig if n is less than 1.
+//
+// Example:
+//
+//	result, err := limiter.(ratelimiter.CostLimiter).AllowN(ctx, "user:123", max(r.ContentLength, 1))
+func (l *TokenBucketLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	if err := checkCost(n); err != nil {
+		return Result{Allowed: false}, err
+	}
+	if buckets, ok := l.bucketStore(); ok {
+		allowed, remaining, err := buckets.TakeTokensWith(ctx, l.opts.keyPrefix+key, n, l.bucket())
+		if err != nil {
+			return Result{Allowed: false}, err
+		}
+		return l.result(allowed, remaining, float64(n)), nil
+	}
+
+	costs, ok := StoreAs[CostStore](l.store)
+	if !ok {
+		return Result{Allowed: false}, ErrorCostUnsupported
+	}
+
+	allowed, remaining, err := costs.TakeTokens(ctx, l.opts.keyPrefix+key, n, l.rate, l.burst)
 	if err != nil {
 		return Result{Allowed: false}, err
 	}
+	return l.result(allowed, remaining, float64(n)), nil
+}
+
+// AllowMulti takes one token from the bucket of each key in one call.
+//
+// When the store implements BatchStore, all buckets are updated in a single
+// round trip; otherwise, or with WithInitialTokens or WithIdleTTL, the keys
+// are checked one by one. Results are returned in
+// the same order as keys.
+//
+// Example:
+//
+//	results, err := limiter.(ratelimiter.BatchLimiter).AllowMulti(ctx, []string{"user:42", "org:7"})
+func (l *TokenBucketLimiter) AllowMulti(ctx context.Context, keys []string) ([]Result, error) {
+	storeKeys := make([]string, len(keys))
+	for i, key := range keys {
+		storeKeys[i] = l.opts.keyPrefix + key
+	}
+
+	results := make([]Result, len(keys))
+
+	_, configured := l.bucketStore()
+	if batch, ok := StoreAs[BatchStore](l.store); ok && !configured {
+		tokens, err := batch.TakeTokenMulti(ctx, storeKeys, l.rate, l.burst)
+		if err != nil {
+			return nil, err
+		}
+		for i, t := range tokens {
+			results[i] = l.result(t.Allowed, t.Remaining, 1)
+		}
+		return results, nil
+	}
+
+	for i, storeKey := range storeKeys {
+		allowed, remaining, err := l.takeToken(ctx, storeKey)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = l.result(allowed, remaining, 1)
+	}
+	return results, nil
+}
 
+// takeToken takes one token from the bucket stored under storeKey.
+func (l *TokenBucketLimiter) takeToken(ctx context.Context, storeKey string) (bool, float64, error) {
+	if buckets, ok := l.bucketStore(); ok {
+		return buckets.TakeTokensWith(ctx, storeKey, 1, l.bucket())
+	}
+	return l.store.TakeToken(ctx, storeKey, l.rate, l.burst)
+}
+
+// bucketStore returns the store as a BucketStore if WithInitialTokens or
+// WithIdleTTL is in effect.
+func (l *TokenBucketLimiter) bucketStore() (BucketStore, bool) {
+	if !l.opts.hasInitialTokens && l.opts.idleTTL == 0 {
+		return nil, false
+	}
+	buckets, ok := StoreAs[BucketStore](l.store)
+	return buckets, ok
+}
+
+// bucket returns the bucket configuration passed to BucketStore.
+func (l *TokenBucketLimiter) bucket() Bucket {
+	initial := l.burst
+	if l.opts.hasInitialTokens {
+		initial = l.opts.initialTokens
+	}
+	return Bucket{Rate: l.rate, Burst: l.burst, Initial: initial, IdleTTL: l.opts.idleTTL}
+}
+
+// Refund returns n tokens to the bucket for key.
+//
+// It returns ErrorRefundUnsupported if the store does not implement RefundStore.
+func (l *TokenBucketLimiter) Refund(ctx context.Context, key string, n int64) error {
+	refunds, ok := StoreAs[RefundStore](l.store)
+	if !ok {
+		return ErrorRefundUnsupported
+	}
+	return refunds.ReturnTokens(ctx, l.opts.keyPrefix+key, float64(n), l.burst)
+}
+
+// Spec returns the configuration of the limiter.
+func (l *TokenBucketLimiter) Spec() Spec {
+	spec := Spec{
+		Name:      l.opts.name,
+		Algorithm: AlgorithmTokenBucket,
+		Rate:      l.rate,
+		Burst:     l.burst,
+		KeyPrefix: l.opts.keyPrefix,
+		IdleTTL:   Duration(l.opts.idleTTL),
+	}
+	if l.opts.hasInitialTokens {
+		initial := l.opts.initialTokens
+		spec.InitialTokens = &initial
+	}
+	return spec
+}
+
+// result builds a Result from the bucket state reported by the store for a
+// request costing cost tokens.
+func (l *TokenBucketLimiter) result(allowed bool, remaining, cost float64) Result {
 	remainingInt := int64(math.Floor(remaining))
 	if remainingInt < 0 {
 		remainingInt = 0
 	}
 
 	var resetAfter time.Duration
-	if allowed {
-		resetAfter = 0
-	} else {
-		secondsToWait := (1.0 - remaining) / l.rate
-		resetAfter = time.Duration(secondsToWait * float64(time.Second))
+	if !allowed {
+		secondsToWait := (min(cost, float64(l.burst)) - remaining) / l.rate
+		resetAfter = time.Duration(max(secondsToWait, 0) * float64(time.Second))
 	}
 
-	result := Result{
-		Allowed:    allowed,
-		Limit:      l.burst,
-		Remaining:  remainingInt,
-		ResetAfter: resetAfter,
+	return Result{
+		Allowed:         allowed,
+		Limit:           l.burst,
+		Remaining:       remainingInt,
+		ResetAfter:      resetAfter,
+		RemainingTokens: math.Max(0, remaining),
+		RefillInterval:  time.Duration(float64(time.Second) / l.rate),
+		RetryAt:         l.opts.clock.Now().Add(resetAfter),
+		Policy:          l.opts.name,
+		Algorithm:       AlgorithmTokenBucket,
 	}
-
-	return result, nil
 }
@@ -0,0 +1,193 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains usage reporting, which accumulates per-key consumption
+// over calendar periods.
+package ratelimiter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Period is a calendar period over which usage is accumulated. Periods are
+// aligned to UTC.
+type Period string
+
+const (
+	// PeriodHour accumulates usage per UTC hour.
+	PeriodHour Period = "hour"
+	// PeriodDay accumulates usage per UTC day.
+	PeriodDay Period = "day"
+)
+
+// bounds returns the start and end of the period containing t.
+func (p Period) bounds(t time.Time) (time.Time, time.Time, error) {
+	t = t.UTC()
+	switch p {
+	case PeriodHour:
+		start := t.Truncate(time.Hour)
+		return start, start.Add(time.Hour), nil
+	case PeriodDay:
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1), nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: unknown usage period %q", ErrorInvalidConfig, p)
+	}
+}
+
+// Usage is the consumption of one key over one period.
+type Usage struct {
+	Key    string    `json:"key"`
+	Period Period    `json:"period"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	// Allowed and Denied count the units admitted and rejected in the period.
+	Allowed int64 `json:"allowed"`
+	Denied  int64 `json:"denied"`
+	// Limit is the limit reported by the most recent decision, for showing
+	// consumption against quota. It is zero if the limiter does not report one.
+	Limit int64 `json:"limit,omitempty"`
+}
+
+// usageState is the state kept in the store for one key and period.
+type usageState struct {
+	Allowed int64 `json:"a"`
+	Denied  int64 `json:"d"`
+	Limit   int64 `json:"l,omitempty"`
+}
+
+// UsageTracker accumulates allowed and denied counts per key for each of its
+// periods, in a store implementing StateStore, so usage is shared across
+// instances using the same store.
+//
+// Example usage:
+//
+//	usage, err := ratelimiter.NewUsageTracker(store)
+//	limiter := usage.Limiter(ratelimiter.MustNewFixedWindow(store, 10000, 24*time.Hour))
+//
+//	// Later, e.g. on the customer's billing page:
+//	today, err := usage.Usage(ctx, "tenant:acme", ratelimiter.PeriodDay)
+type UsageTracker struct {
+	store   StateStore
+	periods []Period
+}
+
+// NewUsageTracker creates a UsageTracker keeping usage in store for the given
+// periods, or for PeriodHour and PeriodDay if none are given.
+//
+// It returns an error wrapping ErrorInvalidConfig if store does not implement
+// StateStore or a period is unknown.
+func NewUsageTracker(store Store, periods ...Period) (*UsageTracker, error) {
+	stateStore, ok := StoreAs[StateStore](store)
+	if !ok {
+		return nil, fmt.Errorf("%w: usage tracking requires a store implementing StateStore", ErrorInvalidConfig)
+	}
+	if len(periods) == 0 {
+		periods = []Period{PeriodHour, PeriodDay}
+	}
+	for _, period := range periods {
+		if _, _, err := period.bounds(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	return &UsageTracker{store: stateStore, periods: periods}, nil
+}
+
+// usageKey returns the store key holding the usage of key in the period starting at start.
+func usageKey(key string, period Period, start time.Time) string {
+	return "usage:" + string(period) + ":" + start.Format("2006010215") + ":" + key
+}
+
+// Record adds a decision about n units for key to every period.
+func (t *UsageTracker) Record(ctx context.Context, key string, n int64, result Result) error {
+	now := time.Now()
+	for _, period := range t.periods {
+		start, end, _ := period.bounds(now)
+		_, err := t.store.Update(ctx, usageKey(key, period, start), end.Sub(now), func(data []byte) ([]byte, Result, error) {
+			var state usageState
+			if data != nil {
+				if err := json.Unmarshal(data, &state); err != nil {
+					return nil, Result{}, err
+				}
+			}
+			if result.Allowed {
+				state.Allowed += n
+			} else {
+				state.Denied += n
+			}
+			if result.Limit > 0 {
+				state.Limit = result.Limit
+			}
+
+			next, err := json.Marshal(state)
+			return next, Result{Allowed: true}, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Usage returns the usage of key in the current period. Periods the tracker
+// does not accumulate report no usage.
+func (t *UsageTracker) Usage(ctx context.Context, key string, period Period) (Usage, error) {
+	start, end, err := period.bounds(time.Now())
+	if err != nil {
+		return Usage{}, err
+	}
+	usage := Usage{Key: key, Period: period, Start: start, End: end}
+
+	var state usageState
+	_, err = t.store.Update(ctx, usageKey(key, period, start), time.Until(end), func(data []byte) ([]byte, Result, error) {
+		if data == nil {
+			return nil, Result{}, nil
+		}
+		return data, Result{}, json.Unmarshal(data, &state)
+	})
+	if err != nil {
+		return Usage{}, err
+	}
+
+	usage.Allowed, usage.Denied, usage.Limit = state.Allowed, state.Denied, state.Limit
+	return usage, nil
+}
+
+// Limiter returns a Limiter that delegates to inner and records every
+// decision. Failing to record usage does not affect the decision.
+func (t *UsageTracker) Limiter(inner Limiter) Limiter {
+	return &usageLimiter{tracker: t, inner: inner}
+}
+
+// usageLimiter is the Limiter returned by UsageTracker.Limiter.
+type usageLimiter struct {
+	tracker *UsageTracker
+	inner   Limiter
+}
+
+// Allow delegates to the inner limiter and records the decision.
+func (l *usageLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN delegates n units to the inner limiter and records the decision.
+func (l *usageLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	result, err := AllowN(ctx, l.inner, key, n)
+	if err != nil {
+		return result, err
+	}
+	_ = l.tracker.Record(ctx, key, n, result)
+	return result, nil
+}
+
+// Refund gives n units back to the inner limiter. Recorded usage is kept.
+func (l *usageLimiter) Refund(ctx context.Context, key string, n int64) error {
+	refunder, ok := l.inner.(Refunder)
+	if !ok {
+		return ErrorRefundUnsupported
+	}
+	return refunder.Refund(ctx, key, n)
+}
@@ -0,0 +1,187 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains request classification by user agent, used to apply
+// different limits to bots, browsers, and mobile apps.
+package ratelimiter
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Class is a category of client assigned by a Classifier.
+type Class string
+
+// Client classes assigned by UserAgentClassifier.
+const (
+	ClassVerifiedBot Class = "verified_bot"
+	ClassUnknownBot  Class = "unknown_bot"
+	ClassBrowser     Class = "browser"
+	ClassMobileApp   Class = "mobile_app"
+	ClassOther       Class = "other"
+)
+
+// Classifier assigns a request to a client class.
+type Classifier func(r *http.Request) Class
+
+// ClassifierOption configures UserAgentClassifier.
+type ClassifierOption func(*uaClassifier)
+
+// uaClassifier holds the settings applied by ClassifierOption values.
+type uaClassifier struct {
+	verifyBot func(r *http.Request) bool
+	appAgents []string
+}
+
+// WithBotVerifier sets the function that confirms a self-declared search bot
+// is genuine, typically by reverse and forward DNS lookup of the client IP.
+//
+// Without a verifier, search bot user agents are classified as ClassUnknownBot
+// because they are trivially spoofed.
+func WithBotVerifier(verify func(r *http.Request) bool) ClassifierOption {
+	return func(c *uaClassifier) {
+		c.verifyBot = verify
+	}
+}
+
+// WithMobileAppAgents adds user-agent substrings identifying the organization's
+// own mobile apps, e.g. "MyApp/".
+func WithMobileAppAgents(substrings ...string) ClassifierOption {
+	return func(c *uaClassifier) {
+		for _, s := range substrings {
+			c.appAgents = append(c.appAgents, strings.ToLower(s))
+		}
+	}
+}
+
+// searchBotAgents identify well-known search engine crawlers.
+var searchBotAgents = []string{
+	"googlebot", "bingbot", "duckduckbot", "yandexbot", "baiduspider", "applebot", "slurp",
+}
+
+// botAgents identify automated clients.
+var botAgents = []string{
+	"bot", "crawler", "spider", "scraper", "curl/", "wget/", "python-requests", "go-http-client",
+	"java/", "okhttp-bot", "headlesschrome", "phantomjs",
+}
+
+// mobileAppAgents identify native HTTP stacks used by mobile apps.
+var mobileAppAgents = []string{
+	"okhttp/", "cfnetwork/", "dalvik/", "alamofire/",
+}
+
+// UserAgentClassifier returns a Classifier based on the User-Agent header.
+//
+// Requests are classified, in order, as verified search bots, other bots
+// (including unverified search bots and requests without a user agent),
+// mobile apps, browsers, or other clients.
+//
+// Example:
+//
+//	classifier := ratelimiter.UserAgentClassifier(ratelimiter.WithMobileAppAgents("ShopApp/"))
+func UserAgentClassifier(opts ...ClassifierOption) Classifier {
+	c := &uaClassifier{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return func(r *http.Request) Class {
+		ua := strings.ToLower(r.UserAgent())
+		if ua == "" {
+			return ClassUnknownBot
+		}
+
+		if containsAny(ua, searchBotAgents) {
+			if c.verifyBot != nil && c.verifyBot(r) {
+				return ClassVerifiedBot
+			}
+			return ClassUnknownBot
+		}
+		if containsAny(ua, c.appAgents) || containsAny(ua, mobileAppAgents) {
+			return ClassMobileApp
+		}
+		if containsAny(ua, botAgents) {
+			return ClassUnknownBot
+		}
+		if strings.HasPrefix(ua, "mozilla/") {
+			return ClassBrowser
+		}
+		return ClassOther
+	}
+}
+
+// WithClassLimits returns an Option that applies a dedicated limiter to each
+// client class.
+//
+// It is built on rules: each class becomes a rule named "class:<class>", added
+// after any rules configured so far. Since a request belongs to exactly one
+// class, the order of class rules does not matter. Classes without a limiter
+// fall through to later rules and finally to the middleware's limiter. The
+// classifier runs at most once per request, however many class rules are
+// evaluated.
+//
+// Example:
+//
+//	cfg := NewConfig(WithClassLimits(UserAgentClassifier(), map[Class]Limiter{
+//	    ClassVerifiedBot: generous,
+//	    ClassUnknownBot:  strict,
+//	}))
+func WithClassLimits(classifier Classifier, limits map[Class]Limiter) Option {
+	return func(c *Config) {
+		c.memoMatches = true
+		memo := &classMemo{classify: classifier}
+
+		classes := make([]string, 0, len(limits))
+		for class := range limits {
+			classes = append(classes, string(class))
+		}
+		sort.Strings(classes)
+
+		for _, class := range classes {
+			WithRules(Rule{
+				Name:    "class:" + class,
+				Match:   memo.match(Class(class)),
+				Limiter: limits[Class(class)],
+			})(c)
+		}
+	}
+}
+
+// classMemo shares the class of a request between the rules of one
+// WithClassLimits option.
+type classMemo struct {
+	classify Classifier
+}
+
+// class returns the class of r, computed once per request when r carries the
+// matchMemo of WhichRule.
+func (m *classMemo) class(r *http.Request) Class {
+	memo, ok := r.Context().Value(matchMemoKey{}).(matchMemo)
+	if !ok {
+		return m.classify(r)
+	}
+	if class, ok := memo[m]; ok {
+		return class.(Class)
+	}
+	class := m.classify(r)
+	memo[m] = class
+	return class
+}
+
+// match returns a Matcher selecting requests classified as class.
+func (m *classMemo) match(class Class) Matcher {
+	return func(r *http.Request) bool {
+		return m.class(r) == class
+	}
+}
+
+// containsAny reports whether s contains any of the given substrings.
+func containsAny(s string, substrings []string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
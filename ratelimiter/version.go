@@ -0,0 +1,148 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains API version selection, which applies different policies
+// to different versions of an API within a single middleware.
+package ratelimiter
+
+import (
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// APIVersionHeader is the request header read by VersionFromHeader when given
+// an empty name.
+const APIVersionHeader = "X-API-Version"
+
+// VersionFunc returns the API version a request targets, normalized by
+// NormalizeVersion, or "" if it does not name one.
+type VersionFunc func(r *http.Request) string
+
+// NormalizeVersion returns version in the form used to select policies:
+// lower case, with a "v" prefix if it starts with a digit, so that "2", "V2"
+// and "v2" are the same version.
+func NormalizeVersion(version string) string {
+	version = strings.ToLower(strings.TrimSpace(version))
+	if version != "" && version[0] >= '0' && version[0] <= '9' {
+		version = "v" + version
+	}
+	return version
+}
+
+// isVersion reports whether s has the form "v" followed by a digit, as in
+// "v1" or "v2.1".
+func isVersion(s string) bool {
+	return len(s) >= 2 && s[0] == 'v' && s[1] >= '0' && s[1] <= '9'
+}
+
+// VersionFromPath returns a VersionFunc reading the version from the first
+// segment of the URL path, as in "/v1/users". Paths whose first segment is not
+// a version have none.
+func VersionFromPath() VersionFunc {
+	return func(r *http.Request) string {
+		segment, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if segment = strings.ToLower(segment); isVersion(segment) {
+			return segment
+		}
+		return ""
+	}
+}
+
+// VersionFromHeader returns a VersionFunc reading the version from the named
+// header, or APIVersionHeader if name is empty.
+func VersionFromHeader(name string) VersionFunc {
+	if name == "" {
+		name = APIVersionHeader
+	}
+	return func(r *http.Request) string {
+		return NormalizeVersion(r.Header.Get(name))
+	}
+}
+
+// VersionFromAccept returns a VersionFunc reading the version from the Accept
+// header, either from a version parameter ("application/json; version=2") or
+// from a vendor media type ("application/vnd.example.v2+json").
+func VersionFromAccept() VersionFunc {
+	return func(r *http.Request) string {
+		for _, accept := range r.Header.Values("Accept") {
+			for _, part := range strings.Split(accept, ",") {
+				mediaType, params, err := mime.ParseMediaType(part)
+				if err != nil {
+					continue
+				}
+				if v := params["version"]; v != "" {
+					return NormalizeVersion(v)
+				}
+				subtype, _, _ := strings.Cut(mediaType[strings.IndexByte(mediaType, '/')+1:], "+")
+				for _, label := range strings.Split(subtype, ".")[1:] {
+					if isVersion(label) {
+						return label
+					}
+				}
+			}
+		}
+		return ""
+	}
+}
+
+// FirstVersion returns a VersionFunc returning the first version found by
+// funcs, e.g. to accept both a path prefix and a header.
+func FirstVersion(funcs ...VersionFunc) VersionFunc {
+	return func(r *http.Request) string {
+		for _, f := range funcs {
+			if version := f(r); version != "" {
+				return version
+			}
+		}
+		return ""
+	}
+}
+
+// MatchVersion returns a Matcher selecting requests for which version returns
+// v, after normalization.
+func MatchVersion(version VersionFunc, v string) Matcher {
+	v = NormalizeVersion(v)
+	return func(r *http.Request) bool {
+		return version(r) == v
+	}
+}
+
+// WithVersionPolicies returns an Option that checks requests against the
+// limiter registered for their API version, as found by version, so that
+// generous limits can be kept for an old version while a new one is held to
+// stricter ones, with one middleware.
+//
+// Each version becomes a rule named after it (e.g. "v1"), reported in the
+// X-RateLimit-Rule header and combined with any other rules as described by
+// WithRules. Requests without a version, or with a version missing from
+// limiters, are checked against the limiter passed to the middleware. Give
+// each limiter a distinct WithKeyPrefix unless versions are meant to share
+// quota.
+//
+// Example:
+//
+//	handler := nethttp.Middleware(v2Limiter, ratelimiter.WithVersionPolicies(
+//	    ratelimiter.FirstVersion(ratelimiter.VersionFromPath(), ratelimiter.VersionFromHeader("")),
+//	    map[string]ratelimiter.Limiter{
+//	        "v1": ratelimiter.MustNewTokenBucket(store, 50, 500, ratelimiter.WithKeyPrefix("v1:")),
+//	        "v2": v2Limiter,
+//	    },
+//	))(mux)
+func WithVersionPolicies(version VersionFunc, limiters map[string]Limiter) Option {
+	versions := make([]string, 0, len(limiters))
+	for v := range limiters {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+
+	rules := make([]Rule, 0, len(versions))
+	for _, v := range versions {
+		rules = append(rules, Rule{
+			Name:    NormalizeVersion(v),
+			Match:   MatchVersion(version, v),
+			Limiter: limiters[v],
+		})
+	}
+	return WithRules(rules...)
+}
@@ -0,0 +1,53 @@
+// Package ratelimiter provides flexible rate-limiting algorithms and interfaces.
+//
+// This file contains Wait, which blocks until a limiter admits a request.
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Wait blocks until limiter admits a request costing n units for key, or until
+// ctx is done.
+//
+// Wait retries after the ResetAfter reported by each denial, so it is meant
+// for token buckets, where denied requests consume nothing. With a fixed
+// window, every retry is counted against the window.
+//
+// It returns an error wrapping ErrorExceeded if n exceeds the limiter's limit,
+// since such a request can never be admitted.
+//
+// Example:
+//
+//	if err := ratelimiter.Wait(ctx, limiter, "download:42", int64(len(chunk))); err != nil {
+//	    return err
+//	}
+func Wait(ctx context.Context, limiter Limiter, key string, n int64) error {
+	for {
+		result, err := AllowN(ctx, limiter, key, n)
+		if err != nil {
+			return err
+		}
+		if result.Allowed {
+			return nil
+		}
+		if result.Limit > 0 && n > result.Limit {
+			return fmt.Errorf("%w: cost %d exceeds limit %d", ErrorExceeded, n, result.Limit)
+		}
+
+		delay := result.ResetAfter
+		if delay < time.Millisecond {
+			delay = time.Millisecond
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
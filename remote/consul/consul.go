@@ -0,0 +1,140 @@
+// Package consul hot-swaps limiters from policies stored in the Consul KV store.
+//
+// Policies are JSON-encoded ratelimiter.Spec values stored under a common key
+// prefix, one key per policy. The policy name is the key with the prefix
+// removed. Watch applies every stored policy to a ratelimiter.Manager and then
+// follows changes using blocking queries, so limits can be tuned at runtime
+// without a redeploy.
+//
+// Example usage:
+//
+//	client, err := api.NewClient(api.DefaultConfig())
+//	manager := ratelimiter.NewManager()
+//	go consul.Watch(ctx, client, "ratelimit/policies/", manager, store)
+//
+//	// consul kv put ratelimit/policies/search '{"algorithm":"token_bucket","rate":20,"burst":100}'
+//	mux.Handle("/search", nethttp.Middleware(manager.Limiter("search"))(search))
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// Option configures Watch.
+type Option func(*config)
+
+// config holds the settings collected from Option values.
+type config struct {
+	logger     ratelimiter.Logger
+	retryDelay time.Duration
+}
+
+// WithLogger sets the Logger used to report invalid policies and Consul errors.
+func WithLogger(l ratelimiter.Logger) Option {
+	return func(c *config) {
+		if l != nil {
+			c.logger = l
+		}
+	}
+}
+
+// WithRetryDelay sets how long Watch waits after a failed query. The default
+// is five seconds.
+func WithRetryDelay(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.retryDelay = d
+		}
+	}
+}
+
+// Watch applies the policies stored under prefix to manager, building their
+// limiters on top of store, and keeps them up to date.
+//
+// Changed keys register a rebuilt limiter; deleted keys remove it. Invalid
+// policies are logged and skipped, leaving the previous limiter in place.
+// Failed queries are logged and retried.
+//
+// It blocks until ctx is canceled.
+func Watch(ctx context.Context, client *api.Client, prefix string, manager *ratelimiter.Manager, store ratelimiter.Store, opts ...Option) error {
+	cfg := config{logger: noopLogger{}, retryDelay: 5 * time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	kv := client.KV()
+	applied := make(map[string]uint64)
+	var index uint64
+
+	for {
+		pairs, meta, err := kv.List(prefix, (&api.QueryOptions{WaitIndex: index}).WithContext(ctx))
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			cfg.logger.Errorf("[RateLimiter] Failed to list policies from Consul: %v", err)
+			select {
+			case <-time.After(cfg.retryDelay):
+				continue
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		// Consul indexes may go backwards, e.g. after a snapshot restore.
+		if meta.LastIndex < index {
+			index = 0
+			continue
+		}
+		index = meta.LastIndex
+
+		seen := make(map[string]bool, len(pairs))
+		for _, pair := range pairs {
+			name := strings.TrimPrefix(pair.Key, prefix)
+			if name == "" {
+				continue
+			}
+			seen[name] = true
+			if applied[name] == pair.ModifyIndex {
+				continue
+			}
+			applied[name] = pair.ModifyIndex
+			cfg.apply(manager, store, name, pair.Value)
+		}
+
+		for name := range applied {
+			if !seen[name] {
+				delete(applied, name)
+				manager.Remove(name)
+			}
+		}
+	}
+}
+
+// apply decodes the policy stored for name and registers it on manager.
+func (c config) apply(manager *ratelimiter.Manager, store ratelimiter.Store, name string, value []byte) {
+	var spec ratelimiter.Spec
+	if err := json.Unmarshal(value, &spec); err != nil {
+		c.logger.Errorf("[RateLimiter] Invalid policy '%s' in Consul: %v", name, err)
+		return
+	}
+	spec.Name = name
+
+	if err := manager.Apply(store, spec); err != nil {
+		c.logger.Errorf("[RateLimiter] Invalid policy '%s' in Consul: %v", name, err)
+		return
+	}
+	c.logger.Debugf("[RateLimiter] Applied policy '%s' from Consul", name)
+}
+
+// noopLogger is a private default logger that does nothing.
+type noopLogger struct{}
+
+func (noopLogger) Debugf(format string, args ...interface{}) {}
+func (noopLogger) Errorf(format string, args ...interface{}) {}
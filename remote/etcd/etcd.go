@@ -0,0 +1,111 @@
+// Package etcd hot-swaps limiters from policies stored in etcd.
+//
+// Policies are JSON-encoded ratelimiter.Spec values stored under a common key
+// prefix, one key per policy. The policy name is the key with the prefix
+// removed. Watch applies every stored policy to a ratelimiter.Manager and then
+// follows changes, so limits can be tuned at runtime without a redeploy.
+//
+// Example usage:
+//
+//	client, err := clientv3.New(clientv3.Config{Endpoints: []string{"localhost:2379"}})
+//	manager := ratelimiter.NewManager()
+//	go etcd.Watch(ctx, client, "/ratelimit/policies/", manager, store)
+//
+//	// etcdctl put /ratelimit/policies/search '{"algorithm":"token_bucket","rate":20,"burst":100}'
+//	mux.Handle("/search", nethttp.Middleware(manager.Limiter("search"))(search))
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Option configures Watch.
+type Option func(*config)
+
+// config holds the settings collected from Option values.
+type config struct {
+	logger ratelimiter.Logger
+}
+
+// WithLogger sets the Logger used to report invalid policies.
+func WithLogger(l ratelimiter.Logger) Option {
+	return func(c *config) {
+		if l != nil {
+			c.logger = l
+		}
+	}
+}
+
+// Watch applies the policies stored under prefix to manager, building their
+// limiters on top of store, and keeps them up to date.
+//
+// Put events register a rebuilt limiter; delete events remove it. Invalid
+// policies are logged and skipped, leaving the previous limiter in place.
+//
+// It blocks until ctx is canceled, returning nil in that case, or until etcd
+// fails, returning the error.
+func Watch(ctx context.Context, client *clientv3.Client, prefix string, manager *ratelimiter.Manager, store ratelimiter.Store, opts ...Option) error {
+	cfg := config{logger: noopLogger{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	resp, err := client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	for _, kv := range resp.Kvs {
+		cfg.apply(manager, store, strings.TrimPrefix(string(kv.Key), prefix), kv.Value)
+	}
+
+	events := client.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1))
+	for wresp := range events {
+		if err := wresp.Err(); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		for _, ev := range wresp.Events {
+			name := strings.TrimPrefix(string(ev.Kv.Key), prefix)
+			switch ev.Type {
+			case clientv3.EventTypePut:
+				cfg.apply(manager, store, name, ev.Kv.Value)
+			case clientv3.EventTypeDelete:
+				manager.Remove(name)
+			}
+		}
+	}
+	return nil
+}
+
+// apply decodes the policy stored for name and registers it on manager.
+func (c config) apply(manager *ratelimiter.Manager, store ratelimiter.Store, name string, value []byte) {
+	var spec ratelimiter.Spec
+	if err := json.Unmarshal(value, &spec); err != nil {
+		c.logger.Errorf("[RateLimiter] Invalid policy '%s' in etcd: %v", name, err)
+		return
+	}
+	spec.Name = name
+
+	if err := manager.Apply(store, spec); err != nil {
+		c.logger.Errorf("[RateLimiter] Invalid policy '%s' in etcd: %v", name, err)
+		return
+	}
+	c.logger.Debugf("[RateLimiter] Applied policy '%s' from etcd", name)
+}
+
+// noopLogger is a private default logger that does nothing.
+type noopLogger struct{}
+
+func (noopLogger) Debugf(format string, args ...interface{}) {}
+func (noopLogger) Errorf(format string, args ...interface{}) {}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+// background runs the goroutines of a store and stops them on Close.
+type background struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startBackground runs every fn in its own goroutine with a context derived
+// from ctx, which is canceled by stop.
+func startBackground(ctx context.Context, fns ...func(ctx context.Context)) *background {
+	ctx, cancel := context.WithCancel(ctx)
+	b := &background{cancel: cancel, done: make(chan struct{})}
+
+	var wg sync.WaitGroup
+	for _, fn := range fns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(ctx)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(b.done)
+	}()
+	return b
+}
+
+// stop cancels the goroutines and waits for them to return or for ctx to expire.
+func (b *background) stop(ctx context.Context) error {
+	b.cancel()
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,100 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains the Redis Pub/Sub broadcaster used to propagate key
+// invalidations between application instances.
+package store
+
+import (
+	"context"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultInvalidationChannel is the Pub/Sub channel used when NewBroadcaster
+// is given an empty channel name.
+const DefaultInvalidationChannel = "ratelimiter:invalidate"
+
+// Broadcaster publishes and receives key invalidations over Redis Pub/Sub.
+//
+// When an operator resets a key or changes its limits on one instance, the
+// invalidation is broadcast so that every other instance holding local state
+// (for example a MemoryStore used as a cache in front of Redis) can drop it
+// immediately instead of waiting for it to expire.
+//
+// Example usage:
+//
+//	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	broadcaster := store.NewBroadcaster(client, "")
+//
+//	local := store.NewMemory(ctx, time.Minute)
+//	go broadcaster.Subscribe(ctx, func(key string) {
+//	    _ = local.(ratelimiter.Resetter).Reset(ctx, key)
+//	})
+//
+//	// On any instance:
+//	err := broadcaster.Reset(ctx, redisStore.(ratelimiter.Resetter), "user:123")
+type Broadcaster struct {
+	client  *redis.Client
+	channel string
+}
+
+// NewBroadcaster creates a new Broadcaster publishing on the given channel.
+//
+// If channel is empty, DefaultInvalidationChannel is used.
+func NewBroadcaster(client *redis.Client, channel string) *Broadcaster {
+	if channel == "" {
+		channel = DefaultInvalidationChannel
+	}
+	return &Broadcaster{
+		client:  client,
+		channel: channel,
+	}
+}
+
+// Publish announces to all subscribers that the state of key is no longer valid.
+func (b *Broadcaster) Publish(ctx context.Context, key string) error {
+	return b.client.Publish(ctx, b.channel, key).Err()
+}
+
+// Reset removes the state of key from the given store and broadcasts the
+// invalidation to all subscribers.
+//
+// The invalidation is published even when the store reset fails, so that
+// local caches never outlive the authoritative state.
+func (b *Broadcaster) Reset(ctx context.Context, s ratelimiter.Resetter, key string) error {
+	resetErr := s.Reset(ctx, key)
+	if err := b.Publish(ctx, key); err != nil {
+		return err
+	}
+	return resetErr
+}
+
+// Subscribe listens for invalidations and calls fn with each invalidated key.
+//
+// It blocks until ctx is canceled, returning nil in that case, or until the
+// subscription cannot be established, returning the Redis error.
+func (b *Broadcaster) Subscribe(ctx context.Context, fn func(key string)) error {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			fn(msg.Payload)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
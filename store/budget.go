@@ -0,0 +1,413 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains KeyBudgetStore, which caps the number of keys each
+// tenant may hold in a shared store.
+package store
+
+import (
+	"container/heap"
+	"container/list"
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// TenantFunc returns the tenant owning a store key, or "" for keys not
+// subject to a budget.
+type TenantFunc func(key string) string
+
+// TenantPrefix returns a TenantFunc using the part of the key before the
+// first sep as the tenant. Keys without sep have no tenant.
+//
+// Example:
+//
+//	// "acme:user:42" belongs to tenant "acme".
+//	tenant := store.TenantPrefix(":")
+func TenantPrefix(sep string) TenantFunc {
+	return func(key string) string {
+		tenant, _, found := strings.Cut(key, sep)
+		if !found {
+			return ""
+		}
+		return tenant
+	}
+}
+
+// BudgetPolicy selects what KeyBudgetStore does with a new key of a tenant
+// that has used up its budget.
+type BudgetPolicy int
+
+const (
+	// BudgetDeny rejects requests for the new key as over the limit, while
+	// the tenant's existing keys keep working.
+	BudgetDeny BudgetPolicy = iota
+	// BudgetShare counts requests for the new key against a single overflow
+	// key shared by all of the tenant's keys beyond the budget, so they stay
+	// limited collectively.
+	BudgetShare
+	// BudgetEvict resets the tenant's least recently used key to make room
+	// for the new one.
+	BudgetEvict
+)
+
+// KeyBudgetOption configures a KeyBudgetStore.
+type KeyBudgetOption func(*KeyBudgetStore)
+
+// WithBudgetPolicy sets what happens to new keys of a tenant over budget. The
+// default is BudgetDeny.
+func WithBudgetPolicy(p BudgetPolicy) KeyBudgetOption {
+	return func(s *KeyBudgetStore) {
+		s.policy = p
+	}
+}
+
+// WithBudgetHook calls fn whenever a tenant's new key is handled by the
+// budget policy, e.g. to log or count offending tenants. fn must not block.
+func WithBudgetHook(fn func(tenant, key string)) KeyBudgetOption {
+	return func(s *KeyBudgetStore) {
+		s.onExceeded = fn
+	}
+}
+
+// KeyBudgetStore wraps a store shared by several tenants and caps the number
+// of distinct live keys each tenant may create in it, so that one tenant
+// generating random keys cannot exhaust the memory of a backend serving
+// everyone.
+//
+// Keys are tracked per instance until the state they create expires: the
+// window for fixed windows, or twice the time to refill the bucket for token
+// buckets. With several instances sharing the backend, a tenant may
+// therefore hold up to the budget times the number of instances.
+//
+// It implements ratelimiter.CostStore and ratelimiter.RefundStore on behalf of
+// the wrapped store, which must implement them too for ratelimiter.StoreAs to
+// report them.
+//
+// Example usage:
+//
+//	shared := store.NewKeyBudget(store.NewRedis(client), store.TenantPrefix(":"), 10000,
+//	    store.WithBudgetPolicy(store.BudgetShare),
+//	)
+type KeyBudgetStore struct {
+	store      ratelimiter.Store
+	tenant     TenantFunc
+	budget     int
+	policy     BudgetPolicy
+	onExceeded func(tenant, key string)
+
+	mu      sync.Mutex
+	tenants map[string]*tenantKeys
+	admits  int
+}
+
+// budgetSweepEvery is how many admitted requests pass between sweeps dropping
+// the tenants whose keys have all expired.
+const budgetSweepEvery = 1024
+
+// tenantKeys holds the live keys of one tenant, least recently used first,
+// and ordered by expiry, since a recently used key may expire before a key
+// used earlier with a longer lifetime.
+type tenantKeys struct {
+	order  *list.List
+	keys   map[string]*list.Element
+	expiry expiryHeap
+}
+
+// budgetEntry is a key tracked by a tenantKeys.
+type budgetEntry struct {
+	key       string
+	expiresAt time.Time
+	// index is the position of the entry in the expiry heap.
+	index int
+}
+
+// expiryHeap is a heap.Interface of budget entries, earliest expiry first.
+type expiryHeap []*budgetEntry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *expiryHeap) Push(x any) {
+	e := x.(*budgetEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+// NewKeyBudget returns a KeyBudgetStore allowing each tenant, as determined by
+// tenant, at most budget live keys in s.
+func NewKeyBudget(s ratelimiter.Store, tenant TenantFunc, budget int, opts ...KeyBudgetOption) ratelimiter.Store {
+	b := &KeyBudgetStore{
+		store:   s,
+		tenant:  tenant,
+		budget:  max(budget, 1),
+		tenants: make(map[string]*tenantKeys),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Unwrap returns the wrapped store.
+func (s *KeyBudgetStore) Unwrap() []ratelimiter.Store {
+	return []ratelimiter.Store{s.store}
+}
+
+// overflowKey returns the key shared by the keys of tenant beyond its budget
+// under BudgetShare.
+func overflowKey(tenant string) string {
+	return tenant + ":__overflow__"
+}
+
+// admit records a use of key, which keeps state for lifetime, and returns the
+// key to use in the store, or false if the request must be denied.
+func (s *KeyBudgetStore) admit(ctx context.Context, key string, lifetime time.Duration) (string, bool) {
+	tenant := s.tenant(key)
+	if tenant == "" {
+		return key, true
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	if s.admits++; s.admits%budgetSweepEvery == 0 {
+		s.sweep(now)
+	}
+	t := s.tenants[tenant]
+	if t == nil {
+		t = &tenantKeys{order: list.New(), keys: make(map[string]*list.Element)}
+		s.tenants[tenant] = t
+	}
+	t.expire(now)
+
+	if el, ok := t.keys[key]; ok {
+		e := el.Value.(*budgetEntry)
+		e.expiresAt = maxTime(e.expiresAt, now.Add(lifetime))
+		heap.Fix(&t.expiry, e.index)
+		t.order.MoveToBack(el)
+		s.mu.Unlock()
+		return key, true
+	}
+
+	var evicted string
+	if t.order.Len() >= s.budget {
+		if s.onExceeded != nil {
+			s.onExceeded(tenant, key)
+		}
+		switch s.policy {
+		case BudgetShare:
+			s.mu.Unlock()
+			return overflowKey(tenant), true
+		case BudgetEvict:
+			front := t.order.Front()
+			e := front.Value.(*budgetEntry)
+			evicted = e.key
+			t.order.Remove(front)
+			heap.Remove(&t.expiry, e.index)
+			delete(t.keys, evicted)
+		default:
+			s.mu.Unlock()
+			return key, false
+		}
+	}
+	e := &budgetEntry{key: key, expiresAt: now.Add(lifetime)}
+	t.keys[key] = t.order.PushBack(e)
+	heap.Push(&t.expiry, e)
+	s.mu.Unlock()
+
+	if evicted != "" {
+		if resetter, ok := s.store.(ratelimiter.Resetter); ok {
+			_ = resetter.Reset(ctx, evicted)
+		}
+	}
+	return key, true
+}
+
+// sweep drops the tenants whose keys have all expired. The caller must hold
+// s.mu.
+func (s *KeyBudgetStore) sweep(now time.Time) {
+	for tenant, t := range s.tenants {
+		t.expire(now)
+		if t.order.Len() == 0 {
+			delete(s.tenants, tenant)
+		}
+	}
+}
+
+// expire drops the keys whose state has expired.
+func (t *tenantKeys) expire(now time.Time) {
+	for len(t.expiry) > 0 && !now.Before(t.expiry[0].expiresAt) {
+		e := heap.Pop(&t.expiry).(*budgetEntry)
+		t.order.Remove(t.keys[e.key])
+		delete(t.keys, e.key)
+	}
+}
+
+// resolve returns the key under which the state of key is stored, without
+// recording a use.
+func (s *KeyBudgetStore) resolve(key string) string {
+	tenant := s.tenant(key)
+	if tenant == "" || s.policy != BudgetShare {
+		return key
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t := s.tenants[tenant]; t != nil {
+		if _, ok := t.keys[key]; ok {
+			return key
+		}
+	}
+	return overflowKey(tenant)
+}
+
+// bucketLifetime returns how long the store keeps a token bucket, matching
+// the expiration RedisStore gives it.
+func bucketLifetime(rate float64, burst int64) time.Duration {
+	seconds := math.Max(math.Ceil(float64(burst)/rate*2), 10)
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// maxTime returns the later of a and b.
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// Increment increments the counter for key, or handles it according to the
+// budget policy if key is a new key of a tenant over budget.
+func (s *KeyBudgetStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	storeKey, ok := s.admit(ctx, key, window)
+	if !ok {
+		return math.MaxInt64, window, nil
+	}
+	return s.store.Increment(ctx, storeKey, window)
+}
+
+// TakeToken takes a token from the bucket for key, or handles it according to
+// the budget policy if key is a new key of a tenant over budget.
+func (s *KeyBudgetStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, error) {
+	storeKey, ok := s.admit(ctx, key, bucketLifetime(rate, burst))
+	if !ok {
+		return false, 0, nil
+	}
+	return s.store.TakeToken(ctx, storeKey, rate, burst)
+}
+
+// IncrementBy is like Increment for n units. It returns
+// ratelimiter.ErrorCostUnsupported if the wrapped store does not implement
+// ratelimiter.CostStore.
+func (s *KeyBudgetStore) IncrementBy(ctx context.Context, key string, n int64, window time.Duration) (int64, time.Duration, error) {
+	costs, ok := ratelimiter.StoreAs[ratelimiter.CostStore](s.store)
+	if !ok {
+		return 0, 0, ratelimiter.ErrorCostUnsupported
+	}
+	storeKey, ok := s.admit(ctx, key, window)
+	if !ok {
+		return math.MaxInt64, window, nil
+	}
+	return costs.IncrementBy(ctx, storeKey, n, window)
+}
+
+// TakeTokens is like TakeToken for n tokens. It returns
+// ratelimiter.ErrorCostUnsupported if the wrapped store does not implement
+// ratelimiter.CostStore.
+func (s *KeyBudgetStore) TakeTokens(ctx context.Context, key string, n int64, rate float64, burst int64) (bool, float64, error) {
+	costs, ok := ratelimiter.StoreAs[ratelimiter.CostStore](s.store)
+	if !ok {
+		return false, 0, ratelimiter.ErrorCostUnsupported
+	}
+	storeKey, ok := s.admit(ctx, key, bucketLifetime(rate, burst))
+	if !ok {
+		return false, 0, nil
+	}
+	return costs.TakeTokens(ctx, storeKey, n, rate, burst)
+}
+
+// Decrement lowers the counter holding the usage of key. It returns
+// ratelimiter.ErrorRefundUnsupported if the wrapped store does not implement
+// ratelimiter.RefundStore.
+func (s *KeyBudgetStore) Decrement(ctx context.Context, key string, n int64) error {
+	refunds, ok := ratelimiter.StoreAs[ratelimiter.RefundStore](s.store)
+	if !ok {
+		return ratelimiter.ErrorRefundUnsupported
+	}
+	return refunds.Decrement(ctx, s.resolve(key), n)
+}
+
+// ReturnTokens adds tokens back to the bucket holding the usage of key. It
+// returns ratelimiter.ErrorRefundUnsupported if the wrapped store does not
+// implement ratelimiter.RefundStore.
+func (s *KeyBudgetStore) ReturnTokens(ctx context.Context, key string, n float64, burst int64) error {
+	refunds, ok := ratelimiter.StoreAs[ratelimiter.RefundStore](s.store)
+	if !ok {
+		return ratelimiter.ErrorRefundUnsupported
+	}
+	return refunds.ReturnTokens(ctx, s.resolve(key), n, burst)
+}
+
+// Reset removes the state for key from the wrapped store, if it supports it,
+// and releases the key's place in its tenant's budget.
+func (s *KeyBudgetStore) Reset(ctx context.Context, key string) error {
+	if tenant := s.tenant(key); tenant != "" {
+		s.mu.Lock()
+		if t := s.tenants[tenant]; t != nil {
+			if el, ok := t.keys[key]; ok {
+				t.order.Remove(el)
+				heap.Remove(&t.expiry, el.Value.(*budgetEntry).index)
+				delete(t.keys, key)
+			}
+			if t.order.Len() == 0 {
+				delete(s.tenants, tenant)
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	if resetter, ok := s.store.(ratelimiter.Resetter); ok {
+		return resetter.Reset(ctx, key)
+	}
+	return nil
+}
+
+// Ping checks the wrapped store when it supports it.
+func (s *KeyBudgetStore) Ping(ctx context.Context) error {
+	if pinger, ok := s.store.(ratelimiter.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// Keys returns the number of live keys tracked for tenant.
+//
+// Example:
+//
+//	n := shared.(*store.KeyBudgetStore).Keys("acme")
+func (s *KeyBudgetStore) Keys(tenant string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.tenants[tenant]
+	if t == nil {
+		return 0
+	}
+	t.expire(time.Now())
+	return t.order.Len()
+}
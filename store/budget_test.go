@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTenantPrefix(t *testing.T) {
+	tenant := TenantPrefix(":")
+	tests := map[string]string{
+		"acme:user:42": "acme",
+		"acme:":        "acme",
+		"global":       "",
+	}
+	for key, want := range tests {
+		if got := tenant(key); got != want {
+			t.Errorf("tenant(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestKeyBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy BudgetPolicy
+		// wantCount is the count returned for a new key over budget.
+		wantCount int64
+		// wantKeys are the keys of the tenant present in the store afterwards.
+		wantKeys []string
+		// wantGone are the keys removed from the store.
+		wantGone []string
+	}{
+		{
+			name:      "deny",
+			policy:    BudgetDeny,
+			wantCount: math.MaxInt64,
+			wantKeys:  []string{"acme:a", "acme:b"},
+			wantGone:  []string{"acme:c", overflowKey("acme")},
+		},
+		{
+			name:      "share",
+			policy:    BudgetShare,
+			wantCount: 1,
+			wantKeys:  []string{"acme:a", "acme:b", overflowKey("acme")},
+			wantGone:  []string{"acme:c"},
+		},
+		{
+			name:      "evict",
+			policy:    BudgetEvict,
+			wantCount: 1,
+			wantKeys:  []string{"acme:b", "acme:c"},
+			wantGone:  []string{"acme:a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			inner := newTestMemory(t, memoryStripes, time.Minute)
+			var exceeded []string
+			s := NewKeyBudget(inner, TenantPrefix(":"), 2,
+				WithBudgetPolicy(tt.policy),
+				WithBudgetHook(func(tenant, key string) { exceeded = append(exceeded, tenant+" "+key) }),
+			).(*KeyBudgetStore)
+
+			for _, key := range []string{"acme:a", "acme:b", "acme:b", "other:a", "global"} {
+				if _, _, err := s.Increment(ctx, key, time.Hour); err != nil {
+					t.Fatal(err)
+				}
+			}
+			count, _, err := s.Increment(ctx, "acme:c", time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.wantCount {
+				t.Errorf("count of the new key = %d, want %d", count, tt.wantCount)
+			}
+			if len(exceeded) != 1 || exceeded[0] != "acme acme:c" {
+				t.Errorf("hook calls = %q, want one for acme:c", exceeded)
+			}
+			if got := s.Keys("acme"); got != 2 {
+				t.Errorf("acme keys = %d, want 2", got)
+			}
+			if got := s.Keys("other"); got != 1 {
+				t.Errorf("other keys = %d, want 1", got)
+			}
+
+			states, err := inner.Inspect(ctx, "*", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored := make(map[string]bool)
+			for _, state := range states {
+				stored[state.Key] = true
+			}
+			for _, key := range tt.wantKeys {
+				if !stored[key] {
+					t.Errorf("%s missing from the store", key)
+				}
+			}
+			for _, key := range tt.wantGone {
+				if stored[key] {
+					t.Errorf("%s present in the store", key)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyBudgetReset(t *testing.T) {
+	ctx := context.Background()
+	s := NewKeyBudget(newTestMemory(t, memoryStripes, time.Minute), TenantPrefix(":"), 1).(*KeyBudgetStore)
+
+	if _, _, err := s.Increment(ctx, "acme:a", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if count, _, _ := s.Increment(ctx, "acme:b", time.Hour); count != math.MaxInt64 {
+		t.Fatalf("second key admitted over a budget of one")
+	}
+	if err := s.Reset(ctx, "acme:a"); err != nil {
+		t.Fatal(err)
+	}
+	if count, _, _ := s.Increment(ctx, "acme:b", time.Hour); count != 1 {
+		t.Errorf("count after freeing the budget = %d, want 1", count)
+	}
+}
+
+func TestKeyBudgetExpiry(t *testing.T) {
+	ctx := context.Background()
+	s := NewKeyBudget(newTestMemory(t, memoryStripes, time.Minute), TenantPrefix(":"), 1).(*KeyBudgetStore)
+
+	if _, _, err := s.Increment(ctx, "acme:a", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if got := s.Keys("acme"); got != 0 {
+		t.Errorf("acme keys after expiry = %d, want 0", got)
+	}
+	if count, _, _ := s.Increment(ctx, "acme:b", time.Hour); count != 1 {
+		t.Errorf("count after the first key expired = %d, want 1", count)
+	}
+}
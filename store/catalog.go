@@ -0,0 +1,243 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains the Redis-backed policy catalog, which lets limits be
+// changed fleet-wide from one place.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultCatalogKey is the Redis hash holding policies when NewPolicyCatalog
+// is given no WithCatalogKey option.
+const DefaultCatalogKey = "ratelimiter:policies"
+
+// DefaultCatalogChannel is the Pub/Sub channel on which policy changes are announced.
+const DefaultCatalogChannel = "ratelimiter:policies:changed"
+
+// CatalogOption configures a PolicyCatalog.
+type CatalogOption func(*PolicyCatalog)
+
+// WithCatalogKey sets the Redis hash in which policies are stored.
+func WithCatalogKey(key string) CatalogOption {
+	return func(c *PolicyCatalog) {
+		if key != "" {
+			c.key = key
+		}
+	}
+}
+
+// WithCatalogChannel sets the Pub/Sub channel on which policy changes are announced.
+func WithCatalogChannel(channel string) CatalogOption {
+	return func(c *PolicyCatalog) {
+		if channel != "" {
+			c.broadcaster = NewBroadcaster(c.client, channel)
+		}
+	}
+}
+
+// WithCatalogTTL sets how long policies are cached locally. Changes missed by
+// the Pub/Sub subscription are picked up after at most this long. The default
+// is one minute.
+func WithCatalogTTL(ttl time.Duration) CatalogOption {
+	return func(c *PolicyCatalog) {
+		if ttl > 0 {
+			c.ttl = ttl
+		}
+	}
+}
+
+// cachedSpec is a policy cached locally by a PolicyCatalog.
+type cachedSpec struct {
+	spec      ratelimiter.Spec
+	expiresAt time.Time
+}
+
+// PolicyCatalog stores named policies (ratelimiter.Spec values) in a Redis
+// hash, so that limits can be changed for every service from one place.
+//
+// Policies are cached locally with a TTL. Changes made through Put and Delete
+// are announced over Pub/Sub, and instances running Sync drop the affected
+// limiters immediately.
+//
+// Example usage:
+//
+//	catalog := store.NewPolicyCatalog(client)
+//	manager := ratelimiter.NewManager()
+//	manager.SetDefaultTemplate(catalog.Factory(redisStore))
+//	go catalog.Sync(ctx, manager)
+//
+//	mux.Handle("/search", nethttp.Middleware(manager.Limiter("search"))(search))
+//
+//	// From an ops tool, on any instance:
+//	err := catalog.Put(ctx, ratelimiter.Spec{Name: "search", Algorithm: ratelimiter.AlgorithmTokenBucket, Rate: 20, Burst: 100})
+type PolicyCatalog struct {
+	client      *redis.Client
+	key         string
+	ttl         time.Duration
+	broadcaster *Broadcaster
+
+	mu    sync.Mutex
+	cache map[string]cachedSpec
+}
+
+// NewPolicyCatalog creates a PolicyCatalog backed by client.
+func NewPolicyCatalog(client *redis.Client, opts ...CatalogOption) *PolicyCatalog {
+	c := &PolicyCatalog{
+		client:      client,
+		key:         DefaultCatalogKey,
+		ttl:         time.Minute,
+		broadcaster: NewBroadcaster(client, DefaultCatalogChannel),
+		cache:       make(map[string]cachedSpec),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Put stores spec under its name, replacing any previous policy, and announces
+// the change.
+func (c *PolicyCatalog) Put(ctx context.Context, spec ratelimiter.Spec) error {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	if err := c.client.HSet(ctx, c.key, spec.Name, data).Err(); err != nil {
+		return err
+	}
+	c.invalidate(spec.Name)
+	return c.broadcaster.Publish(ctx, spec.Name)
+}
+
+// Delete removes the policy stored under name and announces the change.
+func (c *PolicyCatalog) Delete(ctx context.Context, name string) error {
+	if err := c.client.HDel(ctx, c.key, name).Err(); err != nil {
+		return err
+	}
+	c.invalidate(name)
+	return c.broadcaster.Publish(ctx, name)
+}
+
+// Get returns the policy stored under name, from the local cache if it is
+// fresh. It returns ratelimiter.ErrorLimiterNotFound if no such policy exists.
+func (c *PolicyCatalog) Get(ctx context.Context, name string) (ratelimiter.Spec, error) {
+	c.mu.Lock()
+	cached, ok := c.cache[name]
+	c.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.spec, nil
+	}
+
+	data, err := c.client.HGet(ctx, c.key, name).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return ratelimiter.Spec{}, ratelimiter.ErrorLimiterNotFound
+	}
+	if err != nil {
+		return ratelimiter.Spec{}, err
+	}
+
+	var spec ratelimiter.Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return ratelimiter.Spec{}, err
+	}
+	spec.Name = name
+
+	c.mu.Lock()
+	c.cache[name] = cachedSpec{spec: spec, expiresAt: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return spec, nil
+}
+
+// List returns all stored policies.
+func (c *PolicyCatalog) List(ctx context.Context) ([]ratelimiter.Spec, error) {
+	entries, err := c.client.HGetAll(ctx, c.key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	specs := make([]ratelimiter.Spec, 0, len(entries))
+	for name, data := range entries {
+		var spec ratelimiter.Spec
+		if err := json.Unmarshal([]byte(data), &spec); err != nil {
+			return nil, err
+		}
+		spec.Name = name
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// Factory returns a ratelimiter.Factory building limiters on top of s from the
+// policies in the catalog. Use it as a Manager template.
+func (c *PolicyCatalog) Factory(s ratelimiter.Store) ratelimiter.Factory {
+	return func(name string) (ratelimiter.Limiter, error) {
+		spec, err := c.Get(context.Background(), name)
+		if err != nil {
+			return nil, err
+		}
+		return spec.Build(s)
+	}
+}
+
+// Sync keeps manager in line with the catalog: whenever a policy changes, the
+// limiter built from it is removed so that the next lookup rebuilds it through
+// the catalog's Factory. Every TTL, limiters built from expired cache entries
+// are also removed, so that changes missed by the subscription are picked up.
+// Limiters registered on manager by other means are left alone.
+//
+// It blocks until ctx is canceled, returning nil in that case, or until the
+// subscription cannot be established, returning the Redis error.
+func (c *PolicyCatalog) Sync(ctx context.Context, manager *ratelimiter.Manager) error {
+	go func() {
+		ticker := time.NewTicker(c.ttl)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				for _, name := range c.expired() {
+					manager.Remove(name)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return c.broadcaster.Subscribe(ctx, func(name string) {
+		c.invalidate(name)
+		manager.Remove(name)
+	})
+}
+
+// expired drops the locally cached policies whose TTL has passed and returns their names.
+func (c *PolicyCatalog) expired() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	var names []string
+	for name, cached := range c.cache {
+		if now.After(cached.expiresAt) {
+			delete(c.cache, name)
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// invalidate drops the locally cached policy for name.
+func (c *PolicyCatalog) invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.cache, name)
+}
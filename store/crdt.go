@@ -0,0 +1,321 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains CRDTStore, an eventually consistent store replicating
+// counters between instances without central infrastructure.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// crdtFullStateTicks is how many replication intervals pass between
+// broadcasts of the full local state, which repair payloads lost in transit.
+const crdtFullStateTicks = 10
+
+// Transport replicates CRDTStore state between instances, e.g. over HTTP
+// (see HTTPTransport) or a gossip library such as hashicorp/memberlist.
+//
+// Delivery may be lossy, duplicated, and out of order: states are merged
+// idempotently.
+type Transport interface {
+	// Broadcast sends payload to the other instances.
+	Broadcast(ctx context.Context, payload []byte) error
+
+	// Subscribe calls fn with every payload received from other instances. It
+	// blocks until ctx is canceled.
+	Subscribe(ctx context.Context, fn func(payload []byte)) error
+}
+
+// CRDTOption configures a CRDTStore.
+type CRDTOption func(*CRDTStore)
+
+// WithReplicationInterval sets how often local changes are broadcast. The
+// default is 200 milliseconds.
+func WithReplicationInterval(d time.Duration) CRDTOption {
+	return func(s *CRDTStore) {
+		if d > 0 {
+			s.interval = d
+		}
+	}
+}
+
+// CRDTStore is a ratelimiter.Store built on PN-Counter CRDTs replicated
+// between instances, for teams that can tolerate approximate limits and want
+// no shared Redis.
+//
+// Each instance counts its own requests and periodically broadcasts its
+// counts; an instance's view of a key is the sum over all instances it has
+// heard from. The error is bounded by what the other instances admit during
+// one replication interval plus transport delay.
+//
+// Fixed windows are aligned to multiples of the window size so that all
+// instances agree on them. Token buckets are approximated by a sliding window
+// admitting burst requests per burst/rate seconds.
+//
+// Example usage:
+//
+//	transport := store.NewHTTPTransport(
+//	    []string{"http://10.0.0.2:8081/crdt", "http://10.0.0.3:8081/crdt"},
+//	    store.WithTransportSecret(secret),
+//	)
+//	go http.ListenAndServe(":8081", transport)
+//
+//	s := store.NewCRDT(ctx, "10.0.0.1", transport)
+//	limiter := ratelimiter.MustNewFixedWindow(s, 1000, time.Minute)
+type CRDTStore struct {
+	node      string
+	transport Transport
+	interval  time.Duration
+
+	background *background
+
+	mu       sync.Mutex
+	counters map[string]*pnCounter
+	windows  map[string]time.Duration // window size last used for each key
+}
+
+// pnCounter is a positive-negative counter for one key and window.
+type pnCounter struct {
+	expiresAt time.Time
+	inc       map[string]int64
+	dec       map[string]int64
+	dirty     bool
+}
+
+// value returns the merged count.
+func (c *pnCounter) value() int64 {
+	var v int64
+	for _, n := range c.inc {
+		v += n
+	}
+	for _, n := range c.dec {
+		v -= n
+	}
+	return max(v, 0)
+}
+
+// crdtState is the replication payload: the counts of one node.
+type crdtState struct {
+	Node     string         `json:"node"`
+	Counters []crdtCountRef `json:"counters"`
+}
+
+// crdtCountRef is the count of one node for one counter.
+type crdtCountRef struct {
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Inc       int64     `json:"inc"`
+	Dec       int64     `json:"dec,omitempty"`
+}
+
+// NewCRDT creates a CRDTStore identified by node, which must be unique among
+// the instances, and starts replicating through transport until ctx is
+// canceled or Close is called.
+func NewCRDT(ctx context.Context, node string, transport Transport, opts ...CRDTOption) ratelimiter.Store {
+	s := &CRDTStore{
+		node:      node,
+		transport: transport,
+		interval:  200 * time.Millisecond,
+		counters:  make(map[string]*pnCounter),
+		windows:   make(map[string]time.Duration),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.background = startBackground(ctx, s.runReplication, func(ctx context.Context) {
+		_ = transport.Subscribe(ctx, s.merge)
+	})
+	return s
+}
+
+// Close stops the replication and broadcasts the full local state once more,
+// so that the other instances account for the requests admitted here since
+// the last broadcast.
+func (s *CRDTStore) Close(ctx context.Context) error {
+	if err := s.background.stop(ctx); err != nil {
+		return err
+	}
+	if payload := s.collect(time.Now(), true); payload != nil {
+		return s.transport.Broadcast(ctx, payload)
+	}
+	return nil
+}
+
+// counterID returns the identifier of the counter for key in the window of
+// the given size containing now, and the end of that window.
+func counterID(key string, window time.Duration, now time.Time) (string, time.Time) {
+	index := now.UnixNano() / int64(window)
+	return key + "#" + strconv.FormatInt(index, 10), time.Unix(0, (index+1)*int64(window))
+}
+
+// counter returns the counter id, creating it with the given expiration. The
+// caller must hold s.mu.
+func (s *CRDTStore) counter(id string, expiresAt time.Time) *pnCounter {
+	c, ok := s.counters[id]
+	if !ok {
+		c = &pnCounter{expiresAt: expiresAt, inc: make(map[string]int64), dec: make(map[string]int64)}
+		s.counters[id] = c
+	}
+	return c
+}
+
+// Increment counts a request for key in the current aligned window and returns
+// the count across all instances heard from.
+func (s *CRDTStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.windows[key] = window
+
+	id, end := counterID(key, window, now)
+	c := s.counter(id, end)
+	c.inc[s.node]++
+	c.dirty = true
+	return c.value(), end.Sub(now), nil
+}
+
+// TakeToken admits a request for key if fewer than burst requests were made
+// across all instances in the sliding window of burst/rate seconds.
+func (s *CRDTStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	window := time.Duration(float64(burst) / rate * float64(time.Second))
+	s.windows[key] = window
+
+	id, end := counterID(key, window, now)
+	prevID, _ := counterID(key, window, now.Add(-window))
+
+	// Keep the counter for one more window so it can serve as previous.
+	current := s.counter(id, end.Add(window))
+	var previous int64
+	if c, ok := s.counters[prevID]; ok {
+		previous = c.value()
+	}
+
+	elapsed := float64(window-end.Sub(now)) / float64(window)
+	used := float64(previous)*(1-elapsed) + float64(current.value())
+	if used+1 > float64(burst) {
+		return false, max(float64(burst)-used, 0), nil
+	}
+
+	current.inc[s.node]++
+	current.dirty = true
+	return true, float64(burst) - used - 1, nil
+}
+
+// Decrement lowers the count of the current window for key by n.
+func (s *CRDTStore) Decrement(ctx context.Context, key string, n int64) error {
+	s.refund(key, n)
+	return nil
+}
+
+// ReturnTokens gives n requests back to the current window of the bucket for key.
+func (s *CRDTStore) ReturnTokens(ctx context.Context, key string, n float64, burst int64) error {
+	s.refund(key, int64(n))
+	return nil
+}
+
+// refund records n decrements on the current counter of key, if any.
+func (s *CRDTStore) refund(key string, n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	window, ok := s.windows[key]
+	if !ok {
+		return
+	}
+	id, _ := counterID(key, window, time.Now())
+	if c, ok := s.counters[id]; ok {
+		c.dec[s.node] += n
+		c.dirty = true
+	}
+}
+
+// runReplication broadcasts local changes every interval and drops expired
+// counters.
+func (s *CRDTStore) runReplication(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for tick := 1; ; tick++ {
+		select {
+		case <-ticker.C:
+			if payload := s.collect(time.Now(), tick%crdtFullStateTicks == 0); payload != nil {
+				_ = s.transport.Broadcast(ctx, payload)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// collect drops expired counters and encodes the local counts of the changed
+// ones, or of all of them if full is set. It returns nil if there is nothing
+// to send.
+func (s *CRDTStore) collect(now time.Time, full bool) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := crdtState{Node: s.node}
+	for id, c := range s.counters {
+		if now.After(c.expiresAt) {
+			delete(s.counters, id)
+			continue
+		}
+		if c.dirty || (full && c.inc[s.node] > 0) {
+			c.dirty = false
+			state.Counters = append(state.Counters, crdtCountRef{
+				ID:        id,
+				ExpiresAt: c.expiresAt,
+				Inc:       c.inc[s.node],
+				Dec:       c.dec[s.node],
+			})
+		}
+	}
+	for key, window := range s.windows {
+		if id, _ := counterID(key, window, now); s.counters[id] == nil {
+			delete(s.windows, key)
+		}
+	}
+	if len(state.Counters) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return nil
+	}
+	return payload
+}
+
+// merge applies a state received from another instance. Counts only grow, so
+// merging keeps the maximum seen for each node.
+func (s *CRDTStore) merge(payload []byte) {
+	var state crdtState
+	if err := json.Unmarshal(payload, &state); err != nil || state.Node == s.node {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, ref := range state.Counters {
+		if now.After(ref.ExpiresAt) {
+			continue
+		}
+		c := s.counter(ref.ID, ref.ExpiresAt)
+		c.inc[state.Node] = max(c.inc[state.Node], ref.Inc)
+		c.dec[state.Node] = max(c.dec[state.Node], ref.Dec)
+	}
+}
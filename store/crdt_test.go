@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// newTestCRDT returns a CRDTStore for node without replication goroutines, so
+// that tests deliver payloads themselves.
+func newTestCRDT(node string) *CRDTStore {
+	return &CRDTStore{
+		node:     node,
+		counters: make(map[string]*pnCounter),
+		windows:  make(map[string]time.Duration),
+	}
+}
+
+// crdtPayload encodes the counts of node for the counter id.
+func crdtPayload(t *testing.T, node, id string, expiresAt time.Time, inc, dec int64) []byte {
+	t.Helper()
+	payload, err := json.Marshal(crdtState{
+		Node:     node,
+		Counters: []crdtCountRef{{ID: id, ExpiresAt: expiresAt, Inc: inc, Dec: dec}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func TestCRDTMergeMonotonic(t *testing.T) {
+	type delivery struct {
+		node     string
+		inc, dec int64
+		expired  bool
+	}
+
+	tests := []struct {
+		name       string
+		deliveries []delivery
+		want       int64
+	}{
+		{name: "single node", deliveries: []delivery{{node: "b", inc: 3}}, want: 3},
+		{name: "nodes add up", deliveries: []delivery{{node: "b", inc: 2}, {node: "c", inc: 4}}, want: 6},
+		{name: "duplicates", deliveries: []delivery{{node: "b", inc: 3}, {node: "b", inc: 3}, {node: "b", inc: 3}}, want: 3},
+		{name: "stale state", deliveries: []delivery{{node: "b", inc: 5}, {node: "b", inc: 2}}, want: 5},
+		{name: "out of order", deliveries: []delivery{{node: "b", inc: 2}, {node: "c", inc: 1}, {node: "b", inc: 7}, {node: "b", inc: 4}}, want: 8},
+		{name: "decrements", deliveries: []delivery{{node: "b", inc: 5, dec: 2}}, want: 3},
+		{name: "stale decrements", deliveries: []delivery{{node: "b", inc: 5, dec: 2}, {node: "b", inc: 5}}, want: 3},
+		{name: "own state ignored", deliveries: []delivery{{node: "a", inc: 10}, {node: "b", inc: 1}}, want: 1},
+		{name: "expired state ignored", deliveries: []delivery{{node: "b", inc: 10, expired: true}}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestCRDT("a")
+			id, _ := counterID("key", time.Hour, time.Now())
+			live, expired := time.Now().Add(time.Hour), time.Now().Add(-time.Second)
+
+			seen := make(map[string][2]int64)
+			for _, d := range tt.deliveries {
+				expiresAt := live
+				if d.expired {
+					expiresAt = expired
+				}
+				s.merge(crdtPayload(t, d.node, id, expiresAt, d.inc, d.dec))
+
+				c := s.counters[id]
+				if c == nil {
+					continue
+				}
+				for node, prev := range seen {
+					if c.inc[node] < prev[0] || c.dec[node] < prev[1] {
+						t.Fatalf("counts of %s went down from %v to [%d %d]", node, prev, c.inc[node], c.dec[node])
+					}
+				}
+				seen[d.node] = [2]int64{c.inc[d.node], c.dec[d.node]}
+			}
+
+			var got int64
+			if c := s.counters[id]; c != nil {
+				got = c.value()
+			}
+			if got != tt.want {
+				t.Errorf("value = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCRDTConvergence(t *testing.T) {
+	ctx := context.Background()
+	nodes := []*CRDTStore{newTestCRDT("a"), newTestCRDT("b"), newTestCRDT("c")}
+
+	var payloads [][]byte
+	for i, s := range nodes {
+		for range i + 1 {
+			if _, _, err := s.Increment(ctx, "key", time.Hour); err != nil {
+				t.Fatal(err)
+			}
+		}
+		payloads = append(payloads, s.collect(time.Now(), true))
+	}
+	if err := nodes[2].Decrement(ctx, "key", 1); err != nil {
+		t.Fatal(err)
+	}
+	payloads = append(payloads, nodes[2].collect(time.Now(), false))
+
+	// Every node receives the payloads in another order, with duplicates.
+	orders := [][]int{{0, 1, 2, 3}, {3, 2, 1, 0, 3}, {1, 3, 0, 2, 1}}
+	for i, s := range nodes {
+		for _, p := range orders[i] {
+			s.merge(payloads[p])
+		}
+	}
+
+	id, _ := counterID("key", time.Hour, time.Now())
+	for _, s := range nodes {
+		if got := s.counters[id].value(); got != 5 {
+			t.Errorf("node %s: value = %d, want 5", s.node, got)
+		}
+	}
+}
+
+func TestCRDTCollect(t *testing.T) {
+	ctx := context.Background()
+	s := newTestCRDT("a")
+
+	if payload := s.collect(time.Now(), true); payload != nil {
+		t.Errorf("collect without counters = %s, want nil", payload)
+	}
+	if _, _, err := s.Increment(ctx, "key", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if payload := s.collect(time.Now(), false); payload == nil {
+		t.Error("changed counter not collected")
+	}
+	if payload := s.collect(time.Now(), false); payload != nil {
+		t.Errorf("unchanged counter collected: %s", payload)
+	}
+	if payload := s.collect(time.Now(), true); payload == nil {
+		t.Error("full state not collected")
+	}
+	if payload := s.collect(time.Now().Add(2*time.Hour), true); payload != nil || len(s.counters) != 0 {
+		t.Errorf("expired counter collected or kept: %s", payload)
+	}
+}
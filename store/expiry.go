@@ -0,0 +1,84 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains the options controlling how RedisStore expires fixed
+// window counters.
+package store
+
+import "time"
+
+// WithGraceTTL keeps fixed window counters for grace after their window ends.
+//
+// It is meant for limiters using ratelimiter.WithAlignedWindows, whose
+// counters are stored under a new key per window: instances whose clocks lag
+// behind can still find the counter of the window they believe is current,
+// and tools can inspect the previous window. Those limiters advertise the end
+// of the window as the reset, since the next window starts a new key. The TTL
+// reported to limiters is the key's actual remaining lifetime, so on keys
+// reused across windows the advertised reset includes the margin.
+//
+// Example:
+//
+//	store := store.NewRedis(client, store.WithGraceTTL(5*time.Second))
+func WithGraceTTL(grace time.Duration) RedisOption {
+	return func(s *RedisStore) {
+		if grace > 0 {
+			s.graceTTL = grace
+		}
+	}
+}
+
+// WithMinTTL sets a lower bound on the expiration of fixed window counters,
+// including the grace of WithGraceTTL. Aligned windows are close to their end
+// when a key is first incremented near a boundary, and a millisecond TTL would
+// expire it before a request's follow-up reads (e.g. a Refund) arrive. As with
+// WithGraceTTL, the TTL reported to limiters is the key's actual lifetime.
+//
+// Example:
+//
+//	store := store.NewRedis(client, store.WithGraceTTL(time.Second), store.WithMinTTL(2*time.Second))
+func WithMinTTL(d time.Duration) RedisOption {
+	return func(s *RedisStore) {
+		if d > 0 {
+			s.minTTL = d
+		}
+	}
+}
+
+// WithAlignedExpiry makes fixed window counters expire with EXPIREAT at the end
+// of the epoch-aligned window containing their first increment, as seen by the
+// Redis clock, and report the exact time left until then. Windows then start
+// and end at the same instant for every key and every instance, and the key's
+// expiry matches the advertised reset exactly, regardless of clock skew
+// between application servers.
+//
+// The counter is reused by the next window, so WithGraceTTL and WithMinTTL do
+// not apply. It cannot be combined with ratelimiter.WithAlignedWindows, which
+// aligns windows on the application clock instead and passes the time left in
+// the window rather than its length: ratelimiter.NewFixedWindow rejects the
+// combination with ErrorInvalidConfig.
+//
+// Example:
+//
+//	store := store.NewRedis(client, store.WithAlignedExpiry())
+//	limiter, err := ratelimiter.NewFixedWindow(store, 100, time.Minute)
+func WithAlignedExpiry() RedisOption {
+	return func(s *RedisStore) {
+		s.alignedExpiry = true
+	}
+}
+
+// AlignedExpiry reports whether the store was created with WithAlignedExpiry.
+// It implements ratelimiter.AlignedExpiryStore.
+func (s *RedisStore) AlignedExpiry() bool {
+	return s.alignedExpiry
+}
+
+// expiryArgs returns the script arguments describing the expiration of fixed
+// window counters for window.
+func (s *RedisStore) expiryArgs(window time.Duration) []interface{} {
+	aligned := 0
+	if s.alignedExpiry {
+		aligned = 1
+	}
+	return []interface{}{window.Milliseconds(), s.graceTTL.Milliseconds(), s.minTTL.Milliseconds(), aligned}
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis returns a RedisStore backed by an in-process Redis server.
+func newTestRedis(t *testing.T, opts ...RedisOption) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return NewRedis(client, opts...).(*RedisStore), server
+}
+
+func TestRedisIncrementReportsKeyExpiry(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []RedisOption
+		window time.Duration
+		// elapsed is how long to wait between the first and second increment.
+		elapsed time.Duration
+	}{
+		{name: "default", window: 10 * time.Second, elapsed: 4 * time.Second},
+		{name: "grace", opts: []RedisOption{WithGraceTTL(2 * time.Second)}, window: 10 * time.Second, elapsed: 11 * time.Second},
+		{name: "min ttl", opts: []RedisOption{WithMinTTL(20 * time.Second)}, window: 10 * time.Second, elapsed: 15 * time.Second},
+		{name: "grace and min ttl", opts: []RedisOption{WithGraceTTL(time.Second), WithMinTTL(5 * time.Second)}, window: time.Second, elapsed: 3 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, server := newTestRedis(t, tt.opts...)
+
+			for i, wait := range []time.Duration{0, tt.elapsed} {
+				server.FastForward(wait)
+				count, reset, err := s.Increment(ctx, "key", tt.window)
+				if err != nil {
+					t.Fatalf("Increment: %v", err)
+				}
+				if count != int64(i+1) {
+					t.Fatalf("increment %d: count = %d, want %d", i+1, count, i+1)
+				}
+				if expiry := server.TTL("key"); reset != expiry {
+					t.Errorf("increment %d: reset = %s, key expires in %s", i+1, reset, expiry)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisIncrementAlignedExpiry(t *testing.T) {
+	ctx := context.Background()
+	s, server := newTestRedis(t, WithAlignedExpiry())
+	server.SetTime(time.Unix(1_700_000_003, 0))
+
+	_, reset, err := s.Increment(ctx, "key", 10*time.Second)
+	if err != nil {
+		t.Fatalf("Increment: %v", err)
+	}
+	if want := 7 * time.Second; reset != want {
+		t.Errorf("reset = %s, want %s", reset, want)
+	}
+	if expiry := server.TTL("key"); reset != expiry {
+		t.Errorf("reset = %s, key expires in %s", reset, expiry)
+	}
+}
+
+func TestAlignedExpiryRejectsAlignedWindows(t *testing.T) {
+	aligned, _ := newTestRedis(t, WithAlignedExpiry())
+	plain, _ := newTestRedis(t)
+
+	tests := []struct {
+		name    string
+		store   ratelimiter.Store
+		wantErr error
+	}{
+		{name: "aligned expiry", store: aligned, wantErr: ratelimiter.ErrorInvalidConfig},
+		{name: "wrapped aligned expiry", store: Instrument(aligned, nil, nil), wantErr: ratelimiter.ErrorInvalidConfig},
+		{name: "plain expiry", store: plain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ratelimiter.NewFixedWindow(tt.store, 10, time.Minute, ratelimiter.WithAlignedWindows())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewFixedWindow error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains Export and Import, which write limiter state to and read
+// it from a portable JSON or CSV format.
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// ExportVersion is the version of the export format written by Export.
+const ExportVersion = 1
+
+// Format selects the encoding used by Export and Import.
+type Format string
+
+const (
+	// FormatJSON encodes an export as a JSON document:
+	//
+	//	{
+	//	  "version": 1,
+	//	  "exported_at": "2025-01-02T15:04:05Z",
+	//	  "states": [
+	//	    {"key": "api:alice", "algorithm": "fixed_window", "count": 42, "reset": "2025-01-02T15:05:00Z"},
+	//	    {"key": "api:bob", "algorithm": "token_bucket", "tokens": 7.5, "reset": "2025-01-02T16:04:05Z"}
+	//	  ]
+	//	}
+	//
+	// Import also accepts a bare JSON array of ratelimiter.KeyState values, as
+	// written by earlier versions of ratelimit-migrate.
+	FormatJSON Format = "json"
+
+	// FormatCSV encodes an export as CSV with the header row
+	// "key,algorithm,count,tokens,reset", one state per row.
+	FormatCSV Format = "csv"
+)
+
+// ExportedState is one record of an export.
+type ExportedState struct {
+	Key string `json:"key"`
+	// Algorithm is ratelimiter.AlgorithmFixedWindow,
+	// ratelimiter.AlgorithmTokenBucket or ratelimiter.AlgorithmSlidingLog.
+	Algorithm string `json:"algorithm"`
+	// Count is the fixed window counter or the number of entries in the
+	// request log.
+	Count int64 `json:"count,omitempty"`
+	// Tokens is the number of tokens in the bucket.
+	Tokens float64 `json:"tokens,omitempty"`
+	// Reset is when the state expires, or the zero time if the source store
+	// did not report it. Import gives such state restoreDefaultTTL.
+	Reset time.Time `json:"reset,omitzero"`
+}
+
+// export is the document written for FormatJSON.
+type export struct {
+	Version    int             `json:"version"`
+	ExportedAt time.Time       `json:"exported_at"`
+	States     []ExportedState `json:"states"`
+}
+
+// csvHeader is the header row written for FormatCSV.
+var csvHeader = []string{"key", "algorithm", "count", "tokens", "reset"}
+
+// Export writes the fixed window, token bucket and sliding window log state of
+// s to w in format, e.g. for backups, audits, or seeding quotas in another
+// environment. s must implement ratelimiter.Inspector. WithMigratePattern and
+// WithPrefixRemap select and rename the exported keys. It returns the number
+// of keys written.
+//
+// Example:
+//
+//	f, _ := os.Create("quotas.csv")
+//	defer f.Close()
+//	n, err := store.Export(ctx, redisStore, f, store.FormatCSV, store.WithMigratePattern("api:*"))
+func Export(ctx context.Context, s ratelimiter.Store, w io.Writer, format Format, opts ...MigrateOption) (int, error) {
+	m := newMigration(opts)
+	if format != FormatJSON && format != FormatCSV {
+		return 0, fmt.Errorf("%w: unknown export format %q", ratelimiter.ErrorInvalidConfig, format)
+	}
+	states, err := ratelimiter.Inspect(ctx, s, m.pattern, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	records := make([]ExportedState, 0, len(states))
+	for _, state := range states {
+		switch state.Algorithm {
+		case ratelimiter.AlgorithmFixedWindow, ratelimiter.AlgorithmTokenBucket, ratelimiter.AlgorithmSlidingLog:
+		default:
+			continue
+		}
+		record := ExportedState{
+			Key:       m.remap(state.Key),
+			Algorithm: state.Algorithm,
+			Count:     state.Count,
+			Tokens:    state.Tokens,
+		}
+		if state.TTL > 0 {
+			record.Reset = now.Add(time.Duration(state.TTL)).UTC()
+		}
+		records = append(records, record)
+	}
+
+	if format == FormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return len(records), enc.Encode(export{Version: ExportVersion, ExportedAt: now.UTC(), States: records})
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return 0, err
+	}
+	for _, record := range records {
+		var reset string
+		if !record.Reset.IsZero() {
+			reset = record.Reset.Format(time.RFC3339Nano)
+		}
+		row := []string{
+			record.Key,
+			record.Algorithm,
+			strconv.FormatInt(record.Count, 10),
+			strconv.FormatFloat(record.Tokens, 'f', -1, 64),
+			reset,
+		}
+		if err := cw.Write(row); err != nil {
+			return 0, err
+		}
+	}
+	cw.Flush()
+	return len(records), cw.Error()
+}
+
+// Import reads state written by Export from r and writes it into s, which must
+// implement ratelimiter.Restorer. State whose reset time has passed is
+// skipped. WithMigratePattern, WithPrefixRemap, and WithMigrateBatchSize apply
+// as for Migrate. It returns the number of keys written.
+//
+// Example:
+//
+//	f, _ := os.Open("quotas.json")
+//	defer f.Close()
+//	n, err := store.Import(ctx, f, stagingStore, store.FormatJSON, store.WithPrefixRemap("prod:", "staging:"))
+func Import(ctx context.Context, r io.Reader, s ratelimiter.Store, format Format, opts ...MigrateOption) (int, error) {
+	m := newMigration(opts)
+	restorer, ok := ratelimiter.StoreAs[ratelimiter.Restorer](s)
+	if !ok {
+		return 0, fmt.Errorf("%w: destination store does not implement Restorer", ratelimiter.ErrorInvalidConfig)
+	}
+
+	var records []ExportedState
+	var err error
+	switch format {
+	case FormatJSON:
+		records, err = decodeJSON(r)
+	case FormatCSV:
+		records, err = decodeCSV(r)
+	default:
+		return 0, fmt.Errorf("%w: unknown export format %q", ratelimiter.ErrorInvalidConfig, format)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	states := make([]ratelimiter.KeyState, 0, len(records))
+	for _, record := range records {
+		if matched, _ := path.Match(m.pattern, record.Key); !matched {
+			continue
+		}
+		state := ratelimiter.KeyState{
+			Key:       m.remap(record.Key),
+			Algorithm: record.Algorithm,
+			Count:     record.Count,
+			Tokens:    record.Tokens,
+		}
+		if !record.Reset.IsZero() {
+			ttl := record.Reset.Sub(now)
+			if ttl <= 0 {
+				continue
+			}
+			state.TTL = ratelimiter.Duration(ttl)
+		}
+		states = append(states, state)
+	}
+	return m.restore(ctx, restorer, states)
+}
+
+// decodeJSON reads a FormatJSON export, or a bare array of KeyState values.
+func decodeJSON(r io.Reader) ([]ExportedState, error) {
+	br := bufio.NewReader(r)
+	first, err := peekNonSpace(br)
+	if err != nil {
+		return nil, err
+	}
+
+	if first == '[' {
+		var states []ratelimiter.KeyState
+		if err := json.NewDecoder(br).Decode(&states); err != nil {
+			return nil, fmt.Errorf("decoding export: %w", err)
+		}
+		now := time.Now()
+		records := make([]ExportedState, 0, len(states))
+		for _, state := range states {
+			record := ExportedState{Key: state.Key, Algorithm: state.Algorithm, Count: state.Count, Tokens: state.Tokens}
+			if state.TTL > 0 {
+				record.Reset = now.Add(time.Duration(state.TTL))
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+
+	var doc export
+	if err := json.NewDecoder(br).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding export: %w", err)
+	}
+	if doc.Version > ExportVersion {
+		return nil, fmt.Errorf("%w: export version %d is newer than %d", ratelimiter.ErrorInvalidConfig, doc.Version, ExportVersion)
+	}
+	return doc.States, nil
+}
+
+// peekNonSpace returns the first byte of br that is not JSON whitespace,
+// without consuming it.
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, fmt.Errorf("decoding export: %w", err)
+		}
+		if !bytes.ContainsAny(b, " \t\r\n") {
+			return b[0], nil
+		}
+		_, _ = br.ReadByte()
+	}
+}
+
+// decodeCSV reads a FormatCSV export.
+func decodeCSV(r io.Reader) ([]ExportedState, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("decoding export: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	records := make([]ExportedState, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		record := ExportedState{Key: row[0], Algorithm: row[1]}
+		if row[2] != "" {
+			if record.Count, err = strconv.ParseInt(row[2], 10, 64); err != nil {
+				return nil, fmt.Errorf("decoding export: row %d: invalid count: %w", i+2, err)
+			}
+		}
+		if row[3] != "" {
+			if record.Tokens, err = strconv.ParseFloat(row[3], 64); err != nil {
+				return nil, fmt.Errorf("decoding export: row %d: invalid tokens: %w", i+2, err)
+			}
+		}
+		if row[4] != "" {
+			if record.Reset, err = time.Parse(time.RFC3339Nano, row[4]); err != nil {
+				return nil, fmt.Errorf("decoding export: row %d: invalid reset: %w", i+2, err)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
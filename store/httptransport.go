@@ -0,0 +1,178 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains HTTPTransport, which replicates CRDTStore state between
+// instances over HTTP.
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxTransportPayload bounds the size of a payload accepted by HTTPTransport.
+const maxTransportPayload = 4 << 20
+
+// TransportSignatureHeader is the request header carrying the HMAC-SHA256 of
+// payloads sent by an HTTPTransport configured with WithTransportSecret, in
+// the form "sha256=<hex>".
+const TransportSignatureHeader = "X-RateLimit-Signature"
+
+// HTTPTransportOption configures an HTTPTransport.
+type HTTPTransportOption func(*HTTPTransport)
+
+// WithTransportSecret signs every payload sent with secret and rejects
+// payloads received without a valid signature, so that only instances
+// sharing secret can change the counters. All peers must use the same secret.
+func WithTransportSecret(secret []byte) HTTPTransportOption {
+	return func(t *HTTPTransport) {
+		t.secret = secret
+	}
+}
+
+// WithTransportClient sets the HTTP client payloads are sent with, e.g. one
+// presenting a client certificate to peers requiring mutual TLS. The default
+// client times out after two seconds.
+func WithTransportClient(client *http.Client) HTTPTransportOption {
+	return func(t *HTTPTransport) {
+		if client != nil {
+			t.client = client
+		}
+	}
+}
+
+// HTTPTransport is a Transport sending payloads to a static list of peers over
+// HTTP. It is also the http.Handler receiving payloads from them, to be
+// mounted at the URL the peers are configured with.
+//
+// Payloads set the counters of every instance, so whoever can reach the
+// handler can deny any key fleet-wide. Configure WithTransportSecret, or serve
+// the handler only to peers authenticated by mutual TLS, e.g. with an
+// http.Server requiring client certificates and WithTransportClient.
+//
+// Example:
+//
+//	secret := []byte(os.Getenv("CRDT_SECRET"))
+//	transport := store.NewHTTPTransport([]string{"http://10.0.0.2:8081/crdt"}, store.WithTransportSecret(secret))
+//	mux.Handle("/crdt", transport)
+type HTTPTransport struct {
+	peers    []string
+	client   *http.Client
+	secret   []byte
+	received chan []byte
+}
+
+// NewHTTPTransport creates an HTTPTransport broadcasting to the given peer URLs.
+func NewHTTPTransport(peers []string, opts ...HTTPTransportOption) *HTTPTransport {
+	t := &HTTPTransport{
+		peers:    peers,
+		client:   &http.Client{Timeout: 2 * time.Second},
+		received: make(chan []byte, 64),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// sign returns the signature of payload for TransportSignatureHeader.
+func (t *HTTPTransport) sign(payload []byte) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify reports whether signature is the valid signature of payload, or no
+// secret is configured.
+func (t *HTTPTransport) verify(payload []byte, signature string) bool {
+	if t.secret == nil {
+		return true
+	}
+	got, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	sum, err := hex.DecodeString(got)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write(payload)
+	return hmac.Equal(sum, mac.Sum(nil))
+}
+
+// Broadcast POSTs payload to every peer and returns the joined errors of the
+// peers that could not be reached or rejected the payload, e.g. for a secret
+// that does not match theirs.
+func (t *HTTPTransport) Broadcast(ctx context.Context, payload []byte) error {
+	var errs []error
+	for _, peer := range t.peers {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, peer, bytes.NewReader(payload))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if t.secret != nil {
+			req.Header.Set(TransportSignatureHeader, t.sign(payload))
+		}
+
+		resp, err := t.client.Do(req)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= http.StatusMultipleChoices {
+			errs = append(errs, fmt.Errorf("peer %s: %s", peer, resp.Status))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Subscribe calls fn with every payload received by ServeHTTP until ctx is
+// canceled.
+func (t *HTTPTransport) Subscribe(ctx context.Context, fn func(payload []byte)) error {
+	for {
+		select {
+		case payload := <-t.received:
+			fn(payload)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// ServeHTTP accepts a payload POSTed by a peer. Payloads are dropped when
+// the subscriber falls behind; the peer's periodic full state repairs them.
+// With WithTransportSecret, payloads without a valid signature are rejected
+// with 401 Unauthorized.
+func (t *HTTPTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxTransportPayload))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !t.verify(payload, r.Header.Get(TransportSignatureHeader)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	select {
+	case t.received <- payload:
+	default:
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
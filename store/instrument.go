@@ -0,0 +1,378 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains InstrumentedStore, which measures the operations of any
+// store.
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// ErrorOperationUnsupported is returned by InstrumentedStore for operations
+// the wrapped store does not implement and for which ratelimiter defines no
+// more specific error.
+var ErrorOperationUnsupported = errors.New("operation not supported by wrapped store")
+
+// Recorder receives a measurement for every operation of an InstrumentedStore.
+// Call counts and error rates follow from counting the calls and the non-nil
+// errors per operation. Implementations must be safe for concurrent use and
+// must not block.
+type Recorder interface {
+	// RecordOperation records one call of op, such as "increment" or
+	// "take_token", that took latency and returned err.
+	RecordOperation(op string, latency time.Duration, err error)
+}
+
+// RecorderFunc adapts a function to the Recorder interface.
+//
+// Example:
+//
+//	recorder := store.RecorderFunc(func(op string, latency time.Duration, err error) {
+//	    storeLatency.WithLabelValues(op, strconv.FormatBool(err == nil)).Observe(latency.Seconds())
+//	})
+type RecorderFunc func(op string, latency time.Duration, err error)
+
+// RecordOperation calls f(op, latency, err).
+func (f RecorderFunc) RecordOperation(op string, latency time.Duration, err error) {
+	f(op, latency, err)
+}
+
+// OperationStats summarizes the calls of one store operation.
+type OperationStats struct {
+	Calls        uint64        `json:"calls"`
+	Errors       uint64        `json:"errors"`
+	TotalLatency time.Duration `json:"total_latency"`
+	MaxLatency   time.Duration `json:"max_latency"`
+}
+
+// StatsRecorder is a Recorder keeping running totals per operation in memory,
+// for services without a metrics system or for debug endpoints.
+type StatsRecorder struct {
+	mu  sync.Mutex
+	ops map[string]*OperationStats
+}
+
+// NewStatsRecorder creates an empty StatsRecorder.
+func NewStatsRecorder() *StatsRecorder {
+	return &StatsRecorder{ops: make(map[string]*OperationStats)}
+}
+
+// RecordOperation adds one call of op to its totals.
+func (r *StatsRecorder) RecordOperation(op string, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.ops[op]
+	if stats == nil {
+		stats = &OperationStats{}
+		r.ops[op] = stats
+	}
+	stats.Calls++
+	if err != nil {
+		stats.Errors++
+	}
+	stats.TotalLatency += latency
+	stats.MaxLatency = max(stats.MaxLatency, latency)
+}
+
+// Stats returns a copy of the totals, keyed by operation.
+func (r *StatsRecorder) Stats() map[string]OperationStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := make(map[string]OperationStats, len(r.ops))
+	for op, s := range r.ops {
+		stats[op] = *s
+	}
+	return stats
+}
+
+// InstrumentedStore wraps a store and reports the latency and outcome of each
+// of its operations to a Recorder, and failures to a Logger, so that every
+// backend gets the same observability.
+//
+// It implements every optional store interface of package ratelimiter and
+// ratelimiter.StoreWrapper, so that ratelimiter.StoreAs, which the limiters
+// use to detect capabilities, reports only those of the wrapped store.
+// Operations the wrapped store lacks fail with ratelimiter.ErrorCostUnsupported,
+// ratelimiter.ErrorRefundUnsupported, ratelimiter.ErrorInspectUnsupported or
+// ErrorOperationUnsupported, except batch operations, which fall back to one
+// call per key.
+//
+// Example usage:
+//
+//	stats := store.NewStatsRecorder()
+//	s := store.Instrument(store.NewRedis(client), stats, logger)
+type InstrumentedStore struct {
+	store    ratelimiter.Store
+	recorder Recorder
+	logger   ratelimiter.Logger
+}
+
+// Instrument wraps inner, reporting its operations to recorder and logging
+// failed operations with logger. Either may be nil.
+func Instrument(inner ratelimiter.Store, recorder Recorder, logger ratelimiter.Logger) ratelimiter.Store {
+	return &InstrumentedStore{store: inner, recorder: recorder, logger: logger}
+}
+
+// Unwrap returns the wrapped store.
+func (s *InstrumentedStore) Unwrap() []ratelimiter.Store {
+	return []ratelimiter.Store{s.store}
+}
+
+// observe reports an operation started at start that returned err.
+func (s *InstrumentedStore) observe(op string, start time.Time, err error) {
+	if s.recorder != nil {
+		s.recorder.RecordOperation(op, time.Since(start), err)
+	}
+	if err != nil && s.logger != nil {
+		s.logger.Errorf("[RateLimiter] Store operation %s failed: %v", op, err)
+	}
+}
+
+// Increment increments the counter for key in the wrapped store.
+func (s *InstrumentedStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	start := time.Now()
+	count, ttl, err := s.store.Increment(ctx, key, window)
+	s.observe("increment", start, err)
+	return count, ttl, err
+}
+
+// TakeToken takes a token from the bucket for key in the wrapped store.
+func (s *InstrumentedStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, error) {
+	start := time.Now()
+	allowed, tokens, err := s.store.TakeToken(ctx, key, rate, burst)
+	s.observe("take_token", start, err)
+	return allowed, tokens, err
+}
+
+// IncrementBy increments the counter for key by n in the wrapped store.
+func (s *InstrumentedStore) IncrementBy(ctx context.Context, key string, n int64, window time.Duration) (int64, time.Duration, error) {
+	start := time.Now()
+	var (
+		count int64
+		ttl   time.Duration
+		err   = ratelimiter.ErrorCostUnsupported
+	)
+	if costs, ok := ratelimiter.StoreAs[ratelimiter.CostStore](s.store); ok {
+		count, ttl, err = costs.IncrementBy(ctx, key, n, window)
+	}
+	s.observe("increment_by", start, err)
+	return count, ttl, err
+}
+
+// TakeTokens takes n tokens from the bucket for key in the wrapped store.
+func (s *InstrumentedStore) TakeTokens(ctx context.Context, key string, n int64, rate float64, burst int64) (bool, float64, error) {
+	start := time.Now()
+	var (
+		allowed bool
+		tokens  float64
+		err     = ratelimiter.ErrorCostUnsupported
+	)
+	if costs, ok := ratelimiter.StoreAs[ratelimiter.CostStore](s.store); ok {
+		allowed, tokens, err = costs.TakeTokens(ctx, key, n, rate, burst)
+	}
+	s.observe("take_tokens", start, err)
+	return allowed, tokens, err
+}
+
+// TakeTokensWith takes n tokens from the bucket for key, configured by
+// bucket, in the wrapped store.
+func (s *InstrumentedStore) TakeTokensWith(ctx context.Context, key string, n int64, bucket ratelimiter.Bucket) (bool, float64, error) {
+	start := time.Now()
+	var (
+		allowed bool
+		tokens  float64
+		err     = ErrorOperationUnsupported
+	)
+	if buckets, ok := ratelimiter.StoreAs[ratelimiter.BucketStore](s.store); ok {
+		allowed, tokens, err = buckets.TakeTokensWith(ctx, key, n, bucket)
+	}
+	s.observe("take_tokens_with", start, err)
+	return allowed, tokens, err
+}
+
+// LogRequests records n requests for key in the sliding window log of the
+// wrapped store.
+func (s *InstrumentedStore) LogRequests(ctx context.Context, key string, n, limit int64, window time.Duration) (bool, int64, time.Duration, error) {
+	start := time.Now()
+	var (
+		allowed    bool
+		count      int64
+		resetAfter time.Duration
+		err        = ErrorOperationUnsupported
+	)
+	if logs, ok := ratelimiter.StoreAs[ratelimiter.SlidingLogStore](s.store); ok {
+		allowed, count, resetAfter, err = logs.LogRequests(ctx, key, n, limit, window)
+	}
+	s.observe("log_requests", start, err)
+	return allowed, count, resetAfter, err
+}
+
+// IncrementMulti increments the counters for keys in the wrapped store, in
+// one call if it implements ratelimiter.BatchStore.
+func (s *InstrumentedStore) IncrementMulti(ctx context.Context, keys []string, window time.Duration) ([]ratelimiter.Counter, error) {
+	batch, ok := ratelimiter.StoreAs[ratelimiter.BatchStore](s.store)
+	if !ok {
+		counters := make([]ratelimiter.Counter, len(keys))
+		for i, key := range keys {
+			count, ttl, err := s.Increment(ctx, key, window)
+			if err != nil {
+				return nil, err
+			}
+			counters[i] = ratelimiter.Counter{Count: count, TTL: ttl}
+		}
+		return counters, nil
+	}
+
+	start := time.Now()
+	counters, err := batch.IncrementMulti(ctx, keys, window)
+	s.observe("increment_multi", start, err)
+	return counters, err
+}
+
+// TakeTokenMulti takes a token from the buckets for keys in the wrapped
+// store, in one call if it implements ratelimiter.BatchStore.
+func (s *InstrumentedStore) TakeTokenMulti(ctx context.Context, keys []string, rate float64, burst int64) ([]ratelimiter.TokenState, error) {
+	batch, ok := ratelimiter.StoreAs[ratelimiter.BatchStore](s.store)
+	if !ok {
+		states := make([]ratelimiter.TokenState, len(keys))
+		for i, key := range keys {
+			allowed, tokens, err := s.TakeToken(ctx, key, rate, burst)
+			if err != nil {
+				return nil, err
+			}
+			states[i] = ratelimiter.TokenState{Allowed: allowed, Remaining: tokens}
+		}
+		return states, nil
+	}
+
+	start := time.Now()
+	states, err := batch.TakeTokenMulti(ctx, keys, rate, burst)
+	s.observe("take_token_multi", start, err)
+	return states, err
+}
+
+// Decrement lowers the counter for key in the wrapped store.
+func (s *InstrumentedStore) Decrement(ctx context.Context, key string, n int64) error {
+	start := time.Now()
+	err := ratelimiter.ErrorRefundUnsupported
+	if refunds, ok := ratelimiter.StoreAs[ratelimiter.RefundStore](s.store); ok {
+		err = refunds.Decrement(ctx, key, n)
+	}
+	s.observe("decrement", start, err)
+	return err
+}
+
+// ReturnTokens adds tokens back to the bucket for key in the wrapped store.
+func (s *InstrumentedStore) ReturnTokens(ctx context.Context, key string, n float64, burst int64) error {
+	start := time.Now()
+	err := ratelimiter.ErrorRefundUnsupported
+	if refunds, ok := ratelimiter.StoreAs[ratelimiter.RefundStore](s.store); ok {
+		err = refunds.ReturnTokens(ctx, key, n, burst)
+	}
+	s.observe("return_tokens", start, err)
+	return err
+}
+
+// Acquire takes a concurrency lease for key in the wrapped store.
+func (s *InstrumentedStore) Acquire(ctx context.Context, key, id string, limit int64, ttl time.Duration) (bool, int64, error) {
+	start := time.Now()
+	var (
+		acquired bool
+		held     int64
+		err      = ErrorOperationUnsupported
+	)
+	if leases, ok := ratelimiter.StoreAs[ratelimiter.ConcurrencyStore](s.store); ok {
+		acquired, held, err = leases.Acquire(ctx, key, id, limit, ttl)
+	}
+	s.observe("acquire", start, err)
+	return acquired, held, err
+}
+
+// Release gives back a concurrency lease for key in the wrapped store.
+func (s *InstrumentedStore) Release(ctx context.Context, key, id string) error {
+	start := time.Now()
+	err := ErrorOperationUnsupported
+	if leases, ok := ratelimiter.StoreAs[ratelimiter.ConcurrencyStore](s.store); ok {
+		err = leases.Release(ctx, key, id)
+	}
+	s.observe("release", start, err)
+	return err
+}
+
+// Update applies fn to the state of key in the wrapped store.
+func (s *InstrumentedStore) Update(ctx context.Context, key string, ttl time.Duration, fn ratelimiter.UpdateFunc) (ratelimiter.Result, error) {
+	start := time.Now()
+	var (
+		result ratelimiter.Result
+		err    = ErrorOperationUnsupported
+	)
+	if states, ok := ratelimiter.StoreAs[ratelimiter.StateStore](s.store); ok {
+		result, err = states.Update(ctx, key, ttl, fn)
+	}
+	s.observe("update", start, err)
+	return result, err
+}
+
+// Reset removes the state for key from the wrapped store, if it supports it.
+func (s *InstrumentedStore) Reset(ctx context.Context, key string) error {
+	resetter, ok := s.store.(ratelimiter.Resetter)
+	if !ok {
+		return nil
+	}
+	start := time.Now()
+	err := resetter.Reset(ctx, key)
+	s.observe("reset", start, err)
+	return err
+}
+
+// Ping checks the wrapped store when it supports it.
+func (s *InstrumentedStore) Ping(ctx context.Context) error {
+	pinger, ok := s.store.(ratelimiter.Pinger)
+	if !ok {
+		return nil
+	}
+	start := time.Now()
+	err := pinger.Ping(ctx)
+	s.observe("ping", start, err)
+	return err
+}
+
+// Inspect lists the state held by the wrapped store.
+func (s *InstrumentedStore) Inspect(ctx context.Context, pattern string, limit int) ([]ratelimiter.KeyState, error) {
+	start := time.Now()
+	var (
+		states []ratelimiter.KeyState
+		err    = ratelimiter.ErrorInspectUnsupported
+	)
+	if inspector, ok := ratelimiter.StoreAs[ratelimiter.Inspector](s.store); ok {
+		states, err = inspector.Inspect(ctx, pattern, limit)
+	}
+	s.observe("inspect", start, err)
+	return states, err
+}
+
+// Restore writes states to the wrapped store.
+func (s *InstrumentedStore) Restore(ctx context.Context, states []ratelimiter.KeyState) error {
+	start := time.Now()
+	err := ErrorOperationUnsupported
+	if restorer, ok := ratelimiter.StoreAs[ratelimiter.Restorer](s.store); ok {
+		err = restorer.Restore(ctx, states)
+	}
+	s.observe("restore", start, err)
+	return err
+}
+
+// Close closes the wrapped store when it supports it.
+func (s *InstrumentedStore) Close(ctx context.Context) error {
+	if closer, ok := s.store.(ratelimiter.Closer); ok {
+		return closer.Close(ctx)
+	}
+	return nil
+}
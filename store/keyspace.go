@@ -0,0 +1,119 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains the KeyspaceWatcher, which invalidates local copies of
+// limiter state when Redis reports that a key changed.
+package store
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyspaceOption configures a KeyspaceWatcher.
+type KeyspaceOption func(*KeyspaceWatcher)
+
+// WithKeyspaceEvents restricts the watcher to the given keyspace events, such
+// as "del", "expired" and "evicted" to react to resets and expirations but not
+// to ordinary increments. By default every event is reported.
+func WithKeyspaceEvents(events ...string) KeyspaceOption {
+	return func(w *KeyspaceWatcher) {
+		w.events = make(map[string]bool, len(events))
+		for _, event := range events {
+			w.events[event] = true
+		}
+	}
+}
+
+// KeyspaceWatcher subscribes to the Redis keyspace notifications of the keys
+// starting with a prefix, so that local copies of limiter state, such as a
+// MemoryStore caching the state of a RedisStore, are invalidated as soon as
+// another instance resets or modifies a key, without that instance having to
+// publish anything as with a Broadcaster.
+//
+// Keyspace notifications are disabled by default; the server must be
+// configured with notify-keyspace-events including "K" and the classes of the
+// events to watch, e.g. "Kg$hzxe" for generic, string, hash, sorted set,
+// expired and evicted events. Notifications are also sent for the writes of
+// the watching instance itself, and are delivered at most once: a local cache
+// relying on them should still expire its entries.
+//
+// Example usage:
+//
+//	local := store.NewMemory(ctx, time.Minute)
+//	watcher := store.NewKeyspaceWatcher(client, "login:", store.WithKeyspaceEvents("del", "expired"))
+//	go watcher.Subscribe(ctx, func(key string) {
+//	    _ = local.(ratelimiter.Resetter).Reset(ctx, key)
+//	})
+type KeyspaceWatcher struct {
+	client *redis.Client
+	prefix string
+	events map[string]bool
+}
+
+// NewKeyspaceWatcher creates a KeyspaceWatcher for the keys starting with
+// prefix in the database client is connected to. An empty prefix watches
+// every key.
+func NewKeyspaceWatcher(client *redis.Client, prefix string, opts ...KeyspaceOption) *KeyspaceWatcher {
+	w := &KeyspaceWatcher{client: client, prefix: prefix}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// channelPrefix returns the prefix of the keyspace channels of the watched
+// database.
+func (w *KeyspaceWatcher) channelPrefix() string {
+	return "__keyspace@" + strconv.Itoa(w.client.Options().DB) + "__:"
+}
+
+// Subscribe listens for keyspace notifications and calls fn with the key of
+// each watched event.
+//
+// It blocks until ctx is canceled, returning nil in that case, or until the
+// subscription cannot be established, returning the Redis error.
+func (w *KeyspaceWatcher) Subscribe(ctx context.Context, fn func(key string)) error {
+	channelPrefix := w.channelPrefix()
+	pubsub := w.client.PSubscribe(ctx, channelPrefix+escapeGlob(w.prefix)+"*")
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			if w.events != nil && !w.events[msg.Payload] {
+				continue
+			}
+			fn(strings.TrimPrefix(msg.Channel, channelPrefix))
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// escapeGlob escapes the characters Redis treats as special in PSUBSCRIBE
+// patterns, so that s matches only itself.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -11,11 +11,13 @@
 //
 //	ctx := context.Background()
 //	store := store.NewMemory(ctx, time.Minute) // cleanup interval = 1 minute
-//	limiter := ratelimiter.NewFixedWindow(store, 100, time.Minute)
+//	limiter, err := ratelimiter.NewFixedWindow(store, 100, time.Minute)
 package store
 
 import (
 	"context"
+	"path"
+	"sort"
 	"sync"
 	"time"
 
@@ -28,10 +30,39 @@ type fixedWindowEntry struct {
 	expiresAt time.Time
 }
 
+// stateEntry stores the opaque state of a key written through Update.
+type stateEntry struct {
+	data      []byte
+	expiresAt time.Time // zero if the state never expires
+}
+
 // tokenBucketEntry stores the state of a token bucket key.
 type tokenBucketEntry struct {
 	tokens      float64
 	lastUpdated time.Time
+	// idleTTL overrides the cleanup staleness threshold when positive.
+	idleTTL time.Duration
+}
+
+// memoryStripes is the number of lock stripes of a MemoryStore. It must be a
+// power of two.
+const memoryStripes = 64
+
+// memoryStripe holds the entries of the keys hashing to it, guarded by its own mutex.
+type memoryStripe struct {
+	mu                 sync.Mutex
+	fixedWindowEntries map[string]fixedWindowEntry
+	tokenBucketEntries map[string]tokenBucketEntry
+	leaseEntries       map[string]map[string]time.Time
+	stateEntries       map[string]stateEntry
+	logEntries         map[string]*logEntry
+}
+
+// logEntry is the sliding window log of a key: the times of its recorded
+// requests in ascending order.
+type logEntry struct {
+	times     []time.Time
+	expiresAt time.Time
 }
 
 // MemoryStore is an in-memory implementation of ratelimiter.Store.
@@ -39,62 +70,208 @@ type tokenBucketEntry struct {
 // It supports both fixed window and token bucket algorithms, and optionally
 // runs a background cleanup goroutine to remove stale entries.
 //
+// Keys are spread over independently locked stripes, so operations on
+// unrelated keys rarely contend and a slow operation on one key never blocks
+// keys of other stripes.
+//
 // Note: MemoryStore is suitable for single-instance applications.
 type MemoryStore struct {
-	mu                 sync.Mutex
-	fixedWindowEntries map[string]fixedWindowEntry
-	tokenBucketEntries map[string]tokenBucketEntry
+	stripes    [memoryStripes]memoryStripe
+	stripeMask uint32
+	background *background
+
+	staleThreshold time.Duration
+	onCleanup      func(CleanupStats)
+}
+
+// CleanupStats describes one cycle of the MemoryStore background cleanup.
+type CleanupStats struct {
+	// Scanned is the number of entries examined: fixed window counters, token
+	// buckets, stored states, sliding window logs, and concurrency leases.
+	Scanned int
+	// Removed is the number of entries removed as expired or stale.
+	Removed int
+	// Duration is how long the cycle took, including waiting for stripe locks.
+	Duration time.Duration
+}
+
+// MemoryOption configures a MemoryStore.
+type MemoryOption func(*MemoryStore)
+
+// WithStaleThreshold sets how long a token bucket may go without updates
+// before the cleanup removes it. The default is ten cleanup intervals.
+// Buckets of limiters using ratelimiter.WithIdleTTL use their idle TTL
+// instead.
+//
+// Example:
+//
+//	store := store.NewMemory(ctx, time.Minute, store.WithStaleThreshold(5*time.Minute))
+func WithStaleThreshold(d time.Duration) MemoryOption {
+	return func(s *MemoryStore) {
+		if d > 0 {
+			s.staleThreshold = d
+		}
+	}
+}
+
+// WithCleanupHook calls fn with the statistics of every cleanup cycle, e.g. to
+// export them as metrics and tune the cleanup interval. fn runs on the
+// cleanup goroutine and should return quickly.
+//
+// Example:
+//
+//	store := store.NewMemory(ctx, time.Minute, store.WithCleanupHook(func(stats store.CleanupStats) {
+//	    cleanupDuration.Observe(stats.Duration.Seconds())
+//	    cleanupRemoved.Add(float64(stats.Removed))
+//	}))
+func WithCleanupHook(fn func(CleanupStats)) MemoryOption {
+	return func(s *MemoryStore) {
+		s.onCleanup = fn
+	}
 }
 
 // NewMemory creates a new MemoryStore instance.
 //
 // ctx: a parent context used to manage the lifecycle of the background cleanup goroutine.
 // cleanupInterval: interval at which expired entries are removed. Pass 0 to disable cleanup.
+// opts: optional MemoryOption values, e.g. WithCleanupHook.
 //
 // Example:
 //
 //	ctx := context.Background()
 //	store := store.NewMemory(ctx, time.Minute)
-func NewMemory(ctx context.Context, cleanupInterval time.Duration) ratelimiter.Store {
-	store := &MemoryStore{
-		fixedWindowEntries: make(map[string]fixedWindowEntry),
-		tokenBucketEntries: make(map[string]tokenBucketEntry),
+func NewMemory(ctx context.Context, cleanupInterval time.Duration, opts ...MemoryOption) ratelimiter.Store {
+	store := &MemoryStore{stripeMask: memoryStripes - 1, staleThreshold: cleanupInterval * 10}
+	for _, opt := range opts {
+		opt(store)
+	}
+	for i := range store.stripes {
+		store.stripes[i] = memoryStripe{
+			fixedWindowEntries: make(map[string]fixedWindowEntry),
+			tokenBucketEntries: make(map[string]tokenBucketEntry),
+			leaseEntries:       make(map[string]map[string]time.Time),
+			stateEntries:       make(map[string]stateEntry),
+			logEntries:         make(map[string]*logEntry),
+		}
 	}
 
 	if cleanupInterval > 0 {
-		go store.runCleanup(ctx, cleanupInterval)
+		store.background = startBackground(ctx, func(ctx context.Context) {
+			store.runCleanup(ctx, cleanupInterval)
+		})
+	} else {
+		store.background = startBackground(ctx)
 	}
 
 	return store
 }
 
+// Close stops the background cleanup and waits for it to return.
+func (s *MemoryStore) Close(ctx context.Context) error {
+	return s.background.stop(ctx)
+}
+
+// stripeIndex returns the index of the stripe holding key, using 32-bit FNV-1a.
+func (s *MemoryStore) stripeIndex(key string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return int(h & s.stripeMask)
+}
+
+// stripe returns the stripe holding key.
+func (s *MemoryStore) stripe(key string) *memoryStripe {
+	return &s.stripes[s.stripeIndex(key)]
+}
+
+// lockKeys locks the stripes of all keys in index order, which keeps
+// concurrent batch operations from deadlocking, and returns a function
+// unlocking them.
+func (s *MemoryStore) lockKeys(keys []string) func() {
+	var used [memoryStripes]bool
+	for _, key := range keys {
+		used[s.stripeIndex(key)] = true
+	}
+	for i := range used {
+		if used[i] {
+			s.stripes[i].mu.Lock()
+		}
+	}
+	return func() {
+		for i := range used {
+			if used[i] {
+				s.stripes[i].mu.Unlock()
+			}
+		}
+	}
+}
+
 // Increment atomically increases the counter for a given key in the fixed window.
 //
-// Returns the new counter value or an error.
+// Returns the new counter value and the time left until the window expires, or an error.
 //
 // Example:
 //
-//	count, err := store.Increment(ctx, "user:123", time.Minute)
-func (s *MemoryStore) Increment(ctx context.Context, key string, window time.Duration) (int64, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+//	count, ttl, err := store.Increment(ctx, "user:123", time.Minute)
+func (s *MemoryStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	st := s.stripe(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	c := st.increment(key, 1, window, time.Now())
+	return c.Count, c.TTL, nil
+}
+
+// IncrementBy atomically increases the counter for a given key in the fixed window by n.
+//
+// Example:
+//
+//	count, ttl, err := store.(ratelimiter.CostStore).IncrementBy(ctx, "user:123", 5, time.Minute)
+func (s *MemoryStore) IncrementBy(ctx context.Context, key string, n int64, window time.Duration) (int64, time.Duration, error) {
+	st := s.stripe(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	c := st.increment(key, n, window, time.Now())
+	return c.Count, c.TTL, nil
+}
+
+// IncrementMulti atomically increments the fixed window counters of all keys.
+//
+// Example:
+//
+//	counters, err := store.(ratelimiter.BatchStore).IncrementMulti(ctx, []string{"user:42", "org:7"}, time.Minute)
+func (s *MemoryStore) IncrementMulti(ctx context.Context, keys []string, window time.Duration) ([]ratelimiter.Counter, error) {
+	defer s.lockKeys(keys)()
 
+	now := time.Now()
+	counters := make([]ratelimiter.Counter, len(keys))
+	for i, key := range keys {
+		counters[i] = s.stripe(key).increment(key, 1, window, now)
+	}
+	return counters, nil
+}
+
+// increment adds n to the fixed window counter for key. The caller must hold s.mu.
+func (s *memoryStripe) increment(key string, n int64, window time.Duration, now time.Time) ratelimiter.Counter {
 	e, found := s.fixedWindowEntries[key]
-	if found && time.Now().After(e.expiresAt) {
+	if found && now.After(e.expiresAt) {
 		found = false
 	}
 
 	if !found {
 		e = fixedWindowEntry{
-			count:     1,
-			expiresAt: time.Now().Add(window),
+			count:     n,
+			expiresAt: now.Add(window),
 		}
 	} else {
-		e.count++
+		e.count += n
 	}
 
 	s.fixedWindowEntries[key] = e
-	return e.count, nil
+	return ratelimiter.Counter{Count: e.count, TTL: e.expiresAt.Sub(now)}
 }
 
 // TakeToken atomically consumes a token from the token bucket for the given key.
@@ -111,21 +288,79 @@ func (s *MemoryStore) Increment(ctx context.Context, key string, window time.Dur
 //
 //	allowed, remaining, _ := store.TakeToken(ctx, "user:123", 1.0, 5)
 func (s *MemoryStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	st := s.stripe(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	t := st.takeToken(key, 1, fullBucket(rate, burst), time.Now())
+	return t.Allowed, t.Remaining, nil
+}
+
+// TakeTokens atomically consumes n tokens from the token bucket for the given
+// key, if the bucket holds at least n tokens.
+//
+// Example:
+//
+//	allowed, remaining, _ := store.(ratelimiter.CostStore).TakeTokens(ctx, "user:123", 512, 1024, 4096)
+func (s *MemoryStore) TakeTokens(ctx context.Context, key string, n int64, rate float64, burst int64) (bool, float64, error) {
+	st := s.stripe(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	t := st.takeToken(key, n, fullBucket(rate, burst), time.Now())
+	return t.Allowed, t.Remaining, nil
+}
+
+// TakeTokensWith is like TakeTokens for a bucket with custom initial tokens or
+// idle TTL. Buckets idle for longer than bucket.IdleTTL are started over.
+//
+// Example:
+//
+//	allowed, remaining, _ := store.(ratelimiter.BucketStore).TakeTokensWith(ctx, "signup:1.2.3.4", 1, ratelimiter.Bucket{Rate: 1.0 / 60, Burst: 3})
+func (s *MemoryStore) TakeTokensWith(ctx context.Context, key string, n int64, bucket ratelimiter.Bucket) (bool, float64, error) {
+	st := s.stripe(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	t := st.takeToken(key, n, bucket, time.Now())
+	return t.Allowed, t.Remaining, nil
+}
+
+// TakeTokenMulti atomically takes one token from the bucket of every key.
+//
+// Example:
+//
+//	states, err := store.(ratelimiter.BatchStore).TakeTokenMulti(ctx, []string{"user:42", "org:7"}, 1.0, 5)
+func (s *MemoryStore) TakeTokenMulti(ctx context.Context, keys []string, rate float64, burst int64) ([]ratelimiter.TokenState, error) {
+	defer s.lockKeys(keys)()
 
-	entry, found := s.tokenBucketEntries[key]
 	now := time.Now()
+	bucket := fullBucket(rate, burst)
+	states := make([]ratelimiter.TokenState, len(keys))
+	for i, key := range keys {
+		states[i] = s.stripe(key).takeToken(key, 1, bucket, now)
+	}
+	return states, nil
+}
 
-	if !found {
-		remaining := float64(burst) - 1
+// fullBucket returns the bucket configuration of the plain TakeToken methods.
+func fullBucket(rate float64, burst int64) ratelimiter.Bucket {
+	return ratelimiter.Bucket{Rate: rate, Burst: burst, Initial: burst}
+}
+
+// takeToken refills the bucket for key and consumes n tokens from it, creating
+// it with bucket.Initial tokens if needed. The caller must hold s.mu.
+func (s *memoryStripe) takeToken(key string, n int64, bucket ratelimiter.Bucket, now time.Time) ratelimiter.TokenState {
+	rate, burst := bucket.Rate, bucket.Burst
+	entry, found := s.tokenBucketEntries[key]
+
+	if !found || (bucket.IdleTTL > 0 && now.Sub(entry.lastUpdated) > bucket.IdleTTL) {
 		entry = tokenBucketEntry{
-			tokens:      remaining,
+			tokens:      float64(bucket.Initial),
 			lastUpdated: now,
 		}
-		s.tokenBucketEntries[key] = entry
-		return true, remaining, nil
 	}
+	entry.idleTTL = bucket.IdleTTL
 
 	elapsed := now.Sub(entry.lastUpdated).Seconds()
 	if elapsed > 0 {
@@ -136,47 +371,414 @@ func (s *MemoryStore) TakeToken(ctx context.Context, key string, rate float64, b
 		entry.tokens = float64(burst)
 	}
 
-	if entry.tokens >= 1 {
-		entry.tokens--
+	cost := float64(n)
+	if entry.tokens >= cost {
+		entry.tokens -= cost
 		entry.lastUpdated = now
 		s.tokenBucketEntries[key] = entry
-		return true, entry.tokens, nil
+		return ratelimiter.TokenState{Allowed: true, Remaining: entry.tokens}
 	}
 
 	entry.lastUpdated = now
 	s.tokenBucketEntries[key] = entry
-	return false, entry.tokens, nil
+	return ratelimiter.TokenState{Allowed: false, Remaining: entry.tokens}
+}
+
+// Decrement lowers the fixed window counter for key by n, never below zero.
+//
+// Example:
+//
+//	err := store.(ratelimiter.RefundStore).Decrement(ctx, "user:123", 1)
+func (s *MemoryStore) Decrement(ctx context.Context, key string, n int64) error {
+	st := s.stripe(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	e, found := st.fixedWindowEntries[key]
+	if !found || time.Now().After(e.expiresAt) {
+		return nil
+	}
+
+	e.count -= n
+	if e.count < 0 {
+		e.count = 0
+	}
+	st.fixedWindowEntries[key] = e
+	return nil
+}
+
+// ReturnTokens adds n tokens back to the bucket for key, capped at burst.
+//
+// Example:
+//
+//	err := store.(ratelimiter.RefundStore).ReturnTokens(ctx, "user:123", 1, 5)
+func (s *MemoryStore) ReturnTokens(ctx context.Context, key string, n float64, burst int64) error {
+	st := s.stripe(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	entry, found := st.tokenBucketEntries[key]
+	if !found {
+		return nil
+	}
+
+	entry.tokens += n
+	if entry.tokens > float64(burst) {
+		entry.tokens = float64(burst)
+	}
+	st.tokenBucketEntries[key] = entry
+	return nil
 }
 
-// runCleanup periodically removes expired or stale entries for both fixed window and token bucket.
+// Acquire registers lease id under key if fewer than limit unexpired leases are
+// held, or refreshes it if already held.
 //
-// Entries are considered stale if they haven't been updated for 10 times the cleanup interval.
+// Example:
+//
+//	held, count, err := store.(ratelimiter.ConcurrencyStore).Acquire(ctx, "user:123", leaseID, 5, time.Minute)
+func (s *MemoryStore) Acquire(ctx context.Context, key, id string, limit int64, ttl time.Duration) (bool, int64, error) {
+	st := s.stripe(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	leases := st.leaseEntries[key]
+	if leases == nil {
+		leases = make(map[string]time.Time)
+		st.leaseEntries[key] = leases
+	}
+	for leaseID, expiresAt := range leases {
+		if now.After(expiresAt) {
+			delete(leases, leaseID)
+		}
+	}
+
+	_, held := leases[id]
+	if !held && int64(len(leases)) >= limit {
+		return false, int64(len(leases)), nil
+	}
+
+	leases[id] = now.Add(ttl)
+	return true, int64(len(leases)), nil
+}
+
+// LogRequests trims the sliding window log of key to window and records n
+// requests if the log then holds at most limit.
+//
+// Example:
+//
+//	allowed, count, resetAfter, err := store.(ratelimiter.SlidingLogStore).LogRequests(ctx, "login:alice", 1, 5, 15*time.Minute)
+func (s *MemoryStore) LogRequests(ctx context.Context, key string, n, limit int64, window time.Duration) (bool, int64, time.Duration, error) {
+	st := s.stripe(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	entry := st.logEntries[key]
+	if entry == nil {
+		entry = &logEntry{}
+		st.logEntries[key] = entry
+	}
+	cutoff := now.Add(-window)
+	expired := 0
+	for expired < len(entry.times) && !entry.times[expired].After(cutoff) {
+		expired++
+	}
+	entry.times = entry.times[expired:]
+
+	count := int64(len(entry.times))
+	allowed := count+n <= limit
+	if allowed {
+		for i := int64(0); i < n; i++ {
+			entry.times = append(entry.times, now)
+		}
+		entry.expiresAt = now.Add(window)
+		count += n
+	}
+
+	var resetAfter time.Duration
+	switch {
+	case n > limit:
+		resetAfter = window
+	case count+n > limit:
+		resetAfter = entry.times[count+n-limit-1].Add(window).Sub(now)
+	}
+	if len(entry.times) == 0 {
+		delete(st.logEntries, key)
+	}
+	return allowed, count, resetAfter, nil
+}
+
+// Release removes lease id from key.
+//
+// Example:
+//
+//	err := store.(ratelimiter.ConcurrencyStore).Release(ctx, "user:123", leaseID)
+func (s *MemoryStore) Release(ctx context.Context, key, id string) error {
+	st := s.stripe(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	delete(st.leaseEntries[key], id)
+	if len(st.leaseEntries[key]) == 0 {
+		delete(st.leaseEntries, key)
+	}
+	return nil
+}
+
+// Update applies fn to the state of key under the key's stripe lock, so fn is
+// called exactly once and must be quick: it blocks the other keys of the stripe.
+//
+// Example:
+//
+//	result, err := store.(ratelimiter.StateStore).Update(ctx, "user:123", time.Minute, fn)
+func (s *MemoryStore) Update(ctx context.Context, key string, ttl time.Duration, fn ratelimiter.UpdateFunc) (ratelimiter.Result, error) {
+	st := s.stripe(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	var state []byte
+	if e, found := st.stateEntries[key]; found && (e.expiresAt.IsZero() || now.Before(e.expiresAt)) {
+		state = e.data
+	}
+
+	next, result, err := fn(state)
+	if err != nil {
+		return ratelimiter.Result{Allowed: false}, err
+	}
+
+	if next == nil {
+		delete(st.stateEntries, key)
+		return result, nil
+	}
+	e := stateEntry{data: next}
+	if ttl > 0 {
+		e.expiresAt = now.Add(ttl)
+	}
+	st.stateEntries[key] = e
+	return result, nil
+}
+
+// Inspect returns the state of up to limit keys matching pattern, sorted by key.
+//
+// Example:
+//
+//	states, err := store.(ratelimiter.Inspector).Inspect(ctx, "login:*", 100)
+func (s *MemoryStore) Inspect(ctx context.Context, pattern string, limit int) ([]ratelimiter.KeyState, error) {
+	now := time.Now()
+	var states []ratelimiter.KeyState
+	for i := range s.stripes {
+		states = s.stripes[i].inspect(states, pattern, now)
+	}
+
+	sort.Slice(states, func(i, j int) bool { return states[i].Key < states[j].Key })
+	if limit > 0 && len(states) > limit {
+		states = states[:limit]
+	}
+	return states, nil
+}
+
+// inspect appends the state of the stripe's keys matching pattern to states.
+func (s *memoryStripe) inspect(states []ratelimiter.KeyState, pattern string, now time.Time) []ratelimiter.KeyState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, e := range s.fixedWindowEntries {
+		if ok, _ := path.Match(pattern, key); ok && now.Before(e.expiresAt) {
+			states = append(states, ratelimiter.KeyState{
+				Key:       key,
+				Algorithm: ratelimiter.AlgorithmFixedWindow,
+				Count:     e.count,
+				TTL:       ratelimiter.Duration(e.expiresAt.Sub(now)),
+			})
+		}
+	}
+	for key, e := range s.tokenBucketEntries {
+		if ok, _ := path.Match(pattern, key); ok {
+			states = append(states, ratelimiter.KeyState{
+				Key:       key,
+				Algorithm: ratelimiter.AlgorithmTokenBucket,
+				Tokens:    e.tokens,
+			})
+		}
+	}
+	for key, leases := range s.leaseEntries {
+		if ok, _ := path.Match(pattern, key); ok {
+			states = append(states, ratelimiter.KeyState{
+				Key:       key,
+				Algorithm: ratelimiter.AlgorithmConcurrency,
+				Count:     int64(len(leases)),
+			})
+		}
+	}
+	for key, e := range s.logEntries {
+		if ok, _ := path.Match(pattern, key); ok && now.Before(e.expiresAt) {
+			states = append(states, ratelimiter.KeyState{
+				Key:       key,
+				Algorithm: ratelimiter.AlgorithmSlidingLog,
+				Count:     int64(len(e.times)),
+				TTL:       ratelimiter.Duration(e.expiresAt.Sub(now)),
+			})
+		}
+	}
+	for key, e := range s.stateEntries {
+		if ok, _ := path.Match(pattern, key); ok && (e.expiresAt.IsZero() || now.Before(e.expiresAt)) {
+			state := ratelimiter.KeyState{
+				Key:       key,
+				Algorithm: ratelimiter.AlgorithmState,
+				State:     e.data,
+			}
+			if !e.expiresAt.IsZero() {
+				state.TTL = ratelimiter.Duration(e.expiresAt.Sub(now))
+			}
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+// Restore writes states read from another store, e.g. a snapshot taken with
+// ratelimiter.Inspect before a restart.
+//
+// Example:
+//
+//	err := store.(ratelimiter.Restorer).Restore(ctx, states)
+func (s *MemoryStore) Restore(ctx context.Context, states []ratelimiter.KeyState) error {
+	now := time.Now()
+	for _, state := range states {
+		st := s.stripe(state.Key)
+		st.mu.Lock()
+		switch state.Algorithm {
+		case ratelimiter.AlgorithmFixedWindow:
+			ttl := time.Duration(state.TTL)
+			if ttl <= 0 {
+				ttl = restoreDefaultTTL
+			}
+			st.fixedWindowEntries[state.Key] = fixedWindowEntry{count: state.Count, expiresAt: now.Add(ttl)}
+		case ratelimiter.AlgorithmTokenBucket:
+			st.tokenBucketEntries[state.Key] = tokenBucketEntry{tokens: state.Tokens, lastUpdated: now}
+		case ratelimiter.AlgorithmSlidingLog:
+			ttl := time.Duration(state.TTL)
+			if ttl <= 0 {
+				ttl = restoreDefaultTTL
+			}
+			e := &logEntry{times: make([]time.Time, state.Count), expiresAt: now.Add(ttl)}
+			for i := range e.times {
+				e.times[i] = now
+			}
+			st.logEntries[state.Key] = e
+		case ratelimiter.AlgorithmState:
+			e := stateEntry{data: state.State}
+			if state.TTL > 0 {
+				e.expiresAt = now.Add(time.Duration(state.TTL))
+			}
+			st.stateEntries[state.Key] = e
+		}
+		st.mu.Unlock()
+	}
+	return nil
+}
+
+// Reset removes both the fixed window and token bucket state for the given key.
+//
+// Example:
+//
+//	err := store.(ratelimiter.Resetter).Reset(ctx, "user:123")
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	st := s.stripe(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	delete(st.fixedWindowEntries, key)
+	delete(st.tokenBucketEntries, key)
+	delete(st.stateEntries, key)
+	delete(st.logEntries, key)
+	return nil
+}
+
+// runCleanup periodically removes expired or stale entries for fixed window,
+// token bucket, sliding window log, and concurrency leases.
+//
+// Token buckets are considered stale if they haven't been updated for the
+// stale threshold (10 times the cleanup interval unless set with
+// WithStaleThreshold), or for their idle TTL if one was given (see
+// ratelimiter.WithIdleTTL).
 func (s *MemoryStore) runCleanup(ctx context.Context, interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	staleThreshold := interval * 10
-
 	for {
 		select {
 		case <-ticker.C:
-			s.mu.Lock()
 			now := time.Now()
-
-			for key, e := range s.fixedWindowEntries {
-				if now.After(e.expiresAt) {
-					delete(s.fixedWindowEntries, key)
-				}
+			var stats CleanupStats
+			for i := range s.stripes {
+				scanned, removed := s.stripes[i].cleanup(now, s.staleThreshold)
+				stats.Scanned += scanned
+				stats.Removed += removed
 			}
-
-			for key, e := range s.tokenBucketEntries {
-				if now.Sub(e.lastUpdated) > staleThreshold {
-					delete(s.tokenBucketEntries, key)
-				}
+			stats.Duration = time.Since(now)
+			if s.onCleanup != nil {
+				s.onCleanup(stats)
 			}
-			s.mu.Unlock()
 		case <-ctx.Done():
 			return
 		}
 	}
 }
+
+// cleanup removes the stripe's expired entries and token buckets not updated
+// within staleThreshold, returning the number of entries scanned and removed.
+func (s *memoryStripe) cleanup(now time.Time, staleThreshold time.Duration) (scanned, removed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scanned = len(s.fixedWindowEntries) + len(s.tokenBucketEntries) + len(s.stateEntries) + len(s.logEntries)
+
+	for key, e := range s.fixedWindowEntries {
+		if now.After(e.expiresAt) {
+			delete(s.fixedWindowEntries, key)
+			removed++
+		}
+	}
+
+	for key, e := range s.tokenBucketEntries {
+		threshold := staleThreshold
+		if e.idleTTL > 0 {
+			threshold = e.idleTTL
+		}
+		if now.Sub(e.lastUpdated) > threshold {
+			delete(s.tokenBucketEntries, key)
+			removed++
+		}
+	}
+
+	for key, e := range s.stateEntries {
+		if !e.expiresAt.IsZero() && now.After(e.expiresAt) {
+			delete(s.stateEntries, key)
+			removed++
+		}
+	}
+
+	for key, e := range s.logEntries {
+		if now.After(e.expiresAt) {
+			delete(s.logEntries, key)
+			removed++
+		}
+	}
+
+	for key, leases := range s.leaseEntries {
+		scanned += len(leases)
+		for id, expiresAt := range leases {
+			if now.After(expiresAt) {
+				delete(leases, id)
+				removed++
+			}
+		}
+		if len(leases) == 0 {
+			delete(s.leaseEntries, key)
+		}
+	}
+	return scanned, removed
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestMemory returns a MemoryStore with the given number of lock stripes,
+// which must be a power of two no larger than memoryStripes.
+func newTestMemory(t testing.TB, stripes uint32, cleanupInterval time.Duration) *MemoryStore {
+	t.Helper()
+	s := NewMemory(context.Background(), cleanupInterval).(*MemoryStore)
+	s.stripeMask = stripes - 1
+	t.Cleanup(func() { _ = s.Close(context.Background()) })
+	return s
+}
+
+// runParallel calls fn from workers goroutines, each n times, and waits for
+// them to return.
+func runParallel(workers, n int, fn func(worker, i int)) {
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range n {
+				fn(w, i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMemoryStoreConcurrentIncrement(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemory(t, memoryStripes, time.Millisecond)
+
+	const workers, n, keys = 16, 500, 10
+	runParallel(workers, n, func(_, i int) {
+		if _, _, err := s.Increment(ctx, "key:"+strconv.Itoa(i%keys), time.Minute); err != nil {
+			t.Error(err)
+		}
+	})
+
+	for k := range keys {
+		count, _, err := s.IncrementBy(ctx, "key:"+strconv.Itoa(k), 0, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := int64(workers * n / keys); count != want {
+			t.Errorf("key:%d: count = %d, want %d", k, count, want)
+		}
+	}
+}
+
+func TestMemoryStoreConcurrentTakeTokens(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemory(t, memoryStripes, time.Millisecond)
+
+	const burst = 100
+	var allowed atomic.Int64
+	runParallel(16, 50, func(_, i int) {
+		ok, _, err := s.TakeToken(ctx, "bucket", 1e-9, burst)
+		if err != nil {
+			t.Error(err)
+		}
+		if ok {
+			allowed.Add(1)
+		}
+	})
+
+	if got := allowed.Load(); got != burst {
+		t.Errorf("allowed = %d, want %d", got, burst)
+	}
+}
+
+func TestMemoryStoreConcurrentAcquire(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemory(t, memoryStripes, time.Millisecond)
+
+	const limit = 4
+	var inFlight, peak atomic.Int64
+	runParallel(16, 200, func(w, i int) {
+		id := fmt.Sprintf("%d-%d", w, i)
+		ok, _, err := s.Acquire(ctx, "conns", id, limit, time.Minute)
+		if err != nil || !ok {
+			return
+		}
+		now := inFlight.Add(1)
+		for p := peak.Load(); now > p && !peak.CompareAndSwap(p, now); p = peak.Load() {
+		}
+		inFlight.Add(-1)
+		if err := s.Release(ctx, "conns", id); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if got := peak.Load(); got > limit {
+		t.Errorf("peak in-flight = %d, want at most %d", got, limit)
+	}
+}
+
+func TestMemoryStoreConcurrentMulti(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemory(t, memoryStripes, time.Millisecond)
+
+	// Workers lock overlapping key sets in different orders while the cleanup
+	// runs, which deadlocks unless stripes are always locked in index order.
+	const workers, n = 8, 200
+	runParallel(workers, n, func(w, i int) {
+		keys := []string{"a", "b", "c", "d"}
+		if w%2 == 1 {
+			keys = []string{"d", "c", "b", "a"}
+		}
+		if _, err := s.IncrementMulti(ctx, keys, time.Minute); err != nil {
+			t.Error(err)
+		}
+		if _, err := s.TakeTokenMulti(ctx, keys, 1000, 1000); err != nil {
+			t.Error(err)
+		}
+	})
+
+	count, _, err := s.IncrementBy(ctx, "a", 0, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(workers * n); count != want {
+		t.Errorf("count = %d, want %d", count, want)
+	}
+}
+
+// benchmarkMemoryStore runs op in parallel against a striped and a
+// single-lock MemoryStore, each goroutine using its own keys.
+func benchmarkMemoryStore(b *testing.B, op func(s *MemoryStore, key string)) {
+	for _, bb := range []struct {
+		name    string
+		stripes uint32
+	}{
+		{"striped", memoryStripes},
+		{"single-lock", 1},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			s := newTestMemory(b, bb.stripes, 0)
+			var worker atomic.Int64
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				prefix := "key:" + strconv.FormatInt(worker.Add(1), 10) + ":"
+				keys := make([]string, 16)
+				for i := range keys {
+					keys[i] = prefix + strconv.Itoa(i)
+				}
+				for i := 0; pb.Next(); i++ {
+					op(s, keys[i%len(keys)])
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkMemoryStoreIncrement(b *testing.B) {
+	ctx := context.Background()
+	benchmarkMemoryStore(b, func(s *MemoryStore, key string) {
+		_, _, _ = s.Increment(ctx, key, time.Minute)
+	})
+}
+
+func BenchmarkMemoryStoreTakeToken(b *testing.B) {
+	ctx := context.Background()
+	benchmarkMemoryStore(b, func(s *MemoryStore, key string) {
+		_, _, _ = s.TakeToken(ctx, key, 1e6, 1e6)
+	})
+}
+
+func BenchmarkMemoryStoreLogRequests(b *testing.B) {
+	ctx := context.Background()
+	benchmarkMemoryStore(b, func(s *MemoryStore, key string) {
+		_, _, _, _ = s.LogRequests(ctx, key, 1, 100, time.Millisecond)
+	})
+}
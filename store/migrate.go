@@ -0,0 +1,135 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains Migrate, which copies limiter state between stores.
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// restoreDefaultTTL is the expiration given to restored state whose TTL the
+// source store did not report.
+const restoreDefaultTTL = time.Hour
+
+// MigrateOption configures Migrate.
+type MigrateOption func(*migration)
+
+// migration holds the settings collected from MigrateOption values.
+type migration struct {
+	pattern   string
+	remaps    [][2]string
+	batchSize int
+}
+
+// WithMigratePattern restricts the migration to keys matching pattern, in
+// path.Match syntax. The default is "*".
+func WithMigratePattern(pattern string) MigrateOption {
+	return func(m *migration) {
+		if pattern != "" {
+			m.pattern = pattern
+		}
+	}
+}
+
+// WithPrefixRemap writes keys starting with from under to instead, e.g. when
+// the new deployment uses a different WithKeyPrefix. Remaps are tried in
+// order and the first matching one applies.
+func WithPrefixRemap(from, to string) MigrateOption {
+	return func(m *migration) {
+		m.remaps = append(m.remaps, [2]string{from, to})
+	}
+}
+
+// WithMigrateBatchSize sets how many keys are written per Restore call. The
+// default is 500.
+func WithMigrateBatchSize(n int) MigrateOption {
+	return func(m *migration) {
+		if n > 0 {
+			m.batchSize = n
+		}
+	}
+}
+
+// Migrate copies the fixed window, token bucket, sliding window log and
+// ratelimiter.StateStore state of from into to, so that infrastructure can be
+// migrated (a memory snapshot to Redis, one Redis to another) without
+// resetting every client's quota. from must implement ratelimiter.Inspector
+// and to ratelimiter.Restorer. It returns the number of keys copied.
+//
+// The state is read in full before being written, and usage between the read
+// and the cutover is not copied; run it while traffic is drained or accept
+// that slack.
+//
+// Example:
+//
+//	n, err := store.Migrate(ctx, store.NewRedis(oldClient), store.NewRedis(newClient),
+//	    store.WithMigratePattern("api:*"),
+//	    store.WithPrefixRemap("api:", "api:v2:"),
+//	)
+func Migrate(ctx context.Context, from, to ratelimiter.Store, opts ...MigrateOption) (int, error) {
+	m := newMigration(opts)
+	restorer, ok := ratelimiter.StoreAs[ratelimiter.Restorer](to)
+	if !ok {
+		return 0, fmt.Errorf("%w: destination store does not implement Restorer", ratelimiter.ErrorInvalidConfig)
+	}
+	states, err := ratelimiter.Inspect(ctx, from, m.pattern, 0)
+	if err != nil {
+		return 0, err
+	}
+	for i := range states {
+		states[i].Key = m.remap(states[i].Key)
+	}
+	return m.restore(ctx, restorer, states)
+}
+
+// newMigration applies opts to the default settings.
+func newMigration(opts []MigrateOption) migration {
+	m := migration{pattern: "*", batchSize: 500}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	return m
+}
+
+// restore writes states into restorer in batches, skipping concurrency leases,
+// and returns the number of keys written.
+func (m *migration) restore(ctx context.Context, restorer ratelimiter.Restorer, states []ratelimiter.KeyState) (int, error) {
+	batch := make([]ratelimiter.KeyState, 0, m.batchSize)
+	copied := 0
+	for _, state := range states {
+		if state.Algorithm == ratelimiter.AlgorithmConcurrency {
+			continue
+		}
+		batch = append(batch, state)
+
+		if len(batch) == m.batchSize {
+			if err := restorer.Restore(ctx, batch); err != nil {
+				return copied, err
+			}
+			copied += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		if err := restorer.Restore(ctx, batch); err != nil {
+			return copied, err
+		}
+		copied += len(batch)
+	}
+	return copied, nil
+}
+
+// remap applies the first matching prefix remap to key.
+func (m *migration) remap(key string) string {
+	for _, r := range m.remaps {
+		if rest, ok := strings.CutPrefix(key, r[0]); ok {
+			return r[1] + rest
+		}
+	}
+	return key
+}
@@ -0,0 +1,430 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains MultiRegionStore, which enforces limits against a local
+// store and reconciles them with a global one in the background.
+package store
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// MultiRegionOption configures a MultiRegionStore.
+type MultiRegionOption func(*MultiRegionStore)
+
+// WithSyncInterval sets how often local usage is pushed to the global store and
+// the global view refreshed. The default is one second.
+func WithSyncInterval(d time.Duration) MultiRegionOption {
+	return func(s *MultiRegionStore) {
+		if d > 0 {
+			s.interval = d
+		}
+	}
+}
+
+// WithSkewTolerance sets how many requests a key may consume locally without
+// being pushed to the global store. When a key reaches it, the request waits
+// for a synchronous reconciliation, which bounds how far the regions together
+// can overshoot a global limit to about n requests per region. The default, 0,
+// never blocks on the global store.
+func WithSkewTolerance(n int64) MultiRegionOption {
+	return func(s *MultiRegionStore) {
+		if n > 0 {
+			s.tolerance = n
+		}
+	}
+}
+
+// MultiRegionStore composes a store local to the region, such as a regional
+// Redis, with a global store shared by all regions.
+//
+// Every request is checked synchronously against the local store and against
+// the last known global usage plus the local usage not yet reconciled. Local
+// usage is pushed to the global store asynchronously every sync interval, so
+// requests never pay cross-region latency; the price is that the global budget
+// may be overshot by what the regions consume between two reconciliations.
+// WithSkewTolerance bounds that overshoot.
+//
+// Limits are checked against the same values in both stores, so the local
+// store enforces the full limit within one region and the global store across
+// all of them.
+//
+// Example usage:
+//
+//	store := store.NewMultiRegion(ctx,
+//	    store.NewRedis(regionalClient),
+//	    store.NewRedis(globalClient),
+//	    store.WithSyncInterval(500*time.Millisecond),
+//	    store.WithSkewTolerance(50),
+//	)
+type MultiRegionStore struct {
+	local     ratelimiter.Store
+	global    ratelimiter.Store
+	interval  time.Duration
+	tolerance int64
+
+	background *background
+
+	mu      sync.Mutex
+	entries map[string]*regionEntry
+}
+
+// regionEntry is the reconciliation state of one key.
+type regionEntry struct {
+	bucket bool
+	window time.Duration
+	rate   float64
+	burst  int64
+
+	// pending is the local usage not yet pushed to the global store.
+	pending int64
+	// known reports whether the global view below has been read.
+	known bool
+	// count and tokens are the global usage as of syncedAt.
+	count    int64
+	tokens   float64
+	syncedAt time.Time
+	// expiresAt is when the global counter expires.
+	expiresAt time.Time
+}
+
+// NewMultiRegion creates a MultiRegionStore and starts the reconciliation,
+// which runs until ctx is canceled or Close is called.
+func NewMultiRegion(ctx context.Context, local, global ratelimiter.Store, opts ...MultiRegionOption) ratelimiter.Store {
+	s := &MultiRegionStore{
+		local:    local,
+		global:   global,
+		interval: time.Second,
+		entries:  make(map[string]*regionEntry),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.background = startBackground(ctx, s.runSync)
+	return s
+}
+
+// Close stops the reconciliation, then pushes the pending usage of every key
+// to the global store so that it is not lost.
+func (s *MultiRegionStore) Close(ctx context.Context) error {
+	if err := s.background.stop(ctx); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.entries))
+	for key, e := range s.entries {
+		if e.pending > 0 {
+			keys = append(keys, key)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, key := range keys {
+		s.sync(ctx, key)
+	}
+	return ctx.Err()
+}
+
+// warmUpScanFactor is how many keys WarmUp inspects per key it loads, to pick
+// the hottest among them.
+const warmUpScanFactor = 10
+
+// WarmUp loads the global state of up to limit of the hottest keys matching
+// pattern, in path.Match syntax, from the global store, which must implement
+// ratelimiter.Inspector (e.g. a RedisStore, which scans for them).
+//
+// A freshly started instance otherwise knows nothing of the global usage until
+// its first reconciliation of each key, and enforces only the local limit in
+// the meantime. Call WarmUp before serving traffic to close that gap for the
+// keys that matter most: fixed windows with the highest counts and buckets
+// with the fewest tokens. Keys already tracked are left untouched. It returns
+// the number of keys loaded.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+//	defer cancel()
+//	_, err := multiRegion.(*store.MultiRegionStore).WarmUp(ctx, "api:*", 10000)
+func (s *MultiRegionStore) WarmUp(ctx context.Context, pattern string, limit int) (int, error) {
+	if limit <= 0 {
+		return 0, nil
+	}
+	states, err := ratelimiter.Inspect(ctx, s.global, pattern, limit*warmUpScanFactor)
+	if err != nil {
+		return 0, err
+	}
+
+	var windows, buckets []ratelimiter.KeyState
+	for _, state := range states {
+		switch state.Algorithm {
+		case ratelimiter.AlgorithmFixedWindow:
+			windows = append(windows, state)
+		case ratelimiter.AlgorithmTokenBucket:
+			buckets = append(buckets, state)
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Count > windows[j].Count })
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Tokens < buckets[j].Tokens })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	loaded := 0
+	for i := 0; loaded < limit && (i < len(windows) || i < len(buckets)); i++ {
+		if i < len(windows) && s.warm(windows[i], now) {
+			loaded++
+		}
+		if loaded < limit && i < len(buckets) && s.warm(buckets[i], now) {
+			loaded++
+		}
+	}
+	return loaded, nil
+}
+
+// warm records the global state of an untracked key and reports whether it
+// did. The caller must hold s.mu.
+func (s *MultiRegionStore) warm(state ratelimiter.KeyState, now time.Time) bool {
+	if _, ok := s.entries[state.Key]; ok {
+		return false
+	}
+
+	e := &regionEntry{known: true, syncedAt: now}
+	if state.Algorithm == ratelimiter.AlgorithmTokenBucket {
+		// The bucket's rate and burst are unknown until its first TakeToken;
+		// until then its tokens are taken as is, without refill.
+		e.bucket, e.tokens, e.burst = true, state.Tokens, math.MaxInt64
+	} else {
+		e.count, e.expiresAt = state.Count, now.Add(time.Duration(state.TTL))
+	}
+	s.entries[state.Key] = e
+	return true
+}
+
+// Increment increments the local counter and returns the larger of the local
+// count and the estimated global count.
+func (s *MultiRegionStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	count, ttl, err := s.local.Increment(ctx, key, window)
+	if err != nil {
+		return count, ttl, err
+	}
+
+	s.mu.Lock()
+	e := s.entry(key)
+	e.window = window
+	e.pending++
+	flush := s.tolerance > 0 && e.pending >= s.tolerance
+	s.mu.Unlock()
+
+	if flush {
+		s.sync(ctx, key)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if e.known && now.Before(e.expiresAt) {
+		count = max(count, e.count+e.pending)
+	}
+	return count, ttl, nil
+}
+
+// TakeToken takes a token from the local bucket if the estimated global bucket
+// holds one as well. A token taken locally but refused globally is returned to
+// the local bucket when the local store implements ratelimiter.RefundStore.
+func (s *MultiRegionStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, error) {
+	allowed, remaining, err := s.local.TakeToken(ctx, key, rate, burst)
+	if err != nil || !allowed {
+		return allowed, remaining, err
+	}
+
+	s.mu.Lock()
+	e := s.entry(key)
+	e.bucket, e.rate, e.burst = true, rate, burst
+	if e.known {
+		global := s.globalTokens(e, time.Now()) - float64(e.pending)
+		if global < 1 {
+			s.mu.Unlock()
+			if refunds, ok := ratelimiter.StoreAs[ratelimiter.RefundStore](s.local); ok {
+				_ = refunds.ReturnTokens(ctx, key, 1, burst)
+			}
+			return false, max(global, 0), nil
+		}
+		remaining = math.Min(remaining, global-1)
+	}
+	e.pending++
+	flush := s.tolerance > 0 && e.pending >= s.tolerance
+	s.mu.Unlock()
+
+	if flush {
+		s.sync(ctx, key)
+	}
+	return true, remaining, nil
+}
+
+// globalTokens returns the estimated tokens of the global bucket at now. The
+// caller must hold s.mu.
+func (s *MultiRegionStore) globalTokens(e *regionEntry, now time.Time) float64 {
+	return math.Min(float64(e.burst), e.tokens+now.Sub(e.syncedAt).Seconds()*e.rate)
+}
+
+// entry returns the reconciliation state of key, creating it if needed. The
+// caller must hold s.mu.
+func (s *MultiRegionStore) entry(key string) *regionEntry {
+	e, ok := s.entries[key]
+	if !ok {
+		e = &regionEntry{}
+		s.entries[key] = e
+	}
+	return e
+}
+
+// runSync reconciles every key with pending usage each interval.
+func (s *MultiRegionStore) runSync(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			now := time.Now()
+			keys := make([]string, 0, len(s.entries))
+			for key, e := range s.entries {
+				switch {
+				case e.pending > 0:
+					keys = append(keys, key)
+				case !e.bucket && now.After(e.expiresAt),
+					e.bucket && s.globalTokens(e, now) >= float64(e.burst):
+					delete(s.entries, key)
+				}
+			}
+			s.mu.Unlock()
+
+			for _, key := range keys {
+				s.sync(ctx, key)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sync pushes the pending usage of key to the global store and refreshes the
+// global view. Failures are retried on the next interval.
+func (s *MultiRegionStore) sync(ctx context.Context, key string) {
+	s.mu.Lock()
+	e, ok := s.entries[key]
+	if !ok || e.pending == 0 {
+		s.mu.Unlock()
+		return
+	}
+	n, snapshot := e.pending, *e
+	e.pending = 0
+	s.mu.Unlock()
+
+	var err error
+	if snapshot.bucket {
+		var tokens float64
+		tokens, err = takeTokens(ctx, s.global, key, n, snapshot.rate, snapshot.burst)
+		if err == nil {
+			s.mu.Lock()
+			e.known, e.tokens, e.syncedAt = true, tokens, time.Now()
+			s.mu.Unlock()
+			return
+		}
+	} else {
+		var count int64
+		var ttl time.Duration
+		count, ttl, err = incrementBy(ctx, s.global, key, n, snapshot.window)
+		if err == nil {
+			s.mu.Lock()
+			e.known, e.count, e.expiresAt = true, count, time.Now().Add(ttl)
+			s.mu.Unlock()
+			return
+		}
+	}
+
+	// Keep the usage for the next attempt.
+	s.mu.Lock()
+	e.pending += n
+	s.mu.Unlock()
+}
+
+// incrementBy adds n to the counter for key in store, in one call if the store
+// implements ratelimiter.CostStore.
+func incrementBy(ctx context.Context, store ratelimiter.Store, key string, n int64, window time.Duration) (int64, time.Duration, error) {
+	if costs, ok := ratelimiter.StoreAs[ratelimiter.CostStore](store); ok {
+		return costs.IncrementBy(ctx, key, n, window)
+	}
+
+	var count int64
+	var ttl time.Duration
+	for i := int64(0); i < n; i++ {
+		var err error
+		if count, ttl, err = store.Increment(ctx, key, window); err != nil {
+			return 0, 0, err
+		}
+	}
+	return count, ttl, nil
+}
+
+// takeTokens takes n tokens from the bucket for key in store and returns the
+// tokens left. Tokens the bucket cannot cover are dropped: the empty global
+// bucket then throttles every region until it refills.
+func takeTokens(ctx context.Context, store ratelimiter.Store, key string, n int64, rate float64, burst int64) (float64, error) {
+	if costs, ok := ratelimiter.StoreAs[ratelimiter.CostStore](store); ok {
+		_, remaining, err := costs.TakeTokens(ctx, key, min(n, burst), rate, burst)
+		return remaining, err
+	}
+
+	var remaining float64
+	for i := int64(0); i < n; i++ {
+		allowed, tokens, err := store.TakeToken(ctx, key, rate, burst)
+		if err != nil {
+			return 0, err
+		}
+		remaining = tokens
+		if !allowed {
+			break
+		}
+	}
+	return remaining, nil
+}
+
+// Reset removes the state for key from the local and the global store.
+func (s *MultiRegionStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+
+	if resetter, ok := s.local.(ratelimiter.Resetter); ok {
+		if err := resetter.Reset(ctx, key); err != nil {
+			return err
+		}
+	}
+	if resetter, ok := s.global.(ratelimiter.Resetter); ok {
+		return resetter.Reset(ctx, key)
+	}
+	return nil
+}
+
+// Ping checks both stores when they support it.
+func (s *MultiRegionStore) Ping(ctx context.Context) error {
+	for _, store := range []ratelimiter.Store{s.local, s.global} {
+		if pinger, ok := store.(ratelimiter.Pinger); ok {
+			if err := pinger.Ping(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
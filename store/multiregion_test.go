@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestMultiRegion returns a MultiRegionStore over a fresh local store and
+// global, whose reconciliation only runs when tests call sync.
+func newTestMultiRegion(t *testing.T, global *MemoryStore, opts ...MultiRegionOption) *MultiRegionStore {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := NewMultiRegion(ctx, newTestMemory(t, memoryStripes, time.Minute), global, opts...).(*MultiRegionStore)
+	<-s.background.done
+	return s
+}
+
+func TestMultiRegionIncrement(t *testing.T) {
+	ctx := context.Background()
+	global := newTestMemory(t, memoryStripes, time.Minute)
+	east, west := newTestMultiRegion(t, global), newTestMultiRegion(t, global)
+
+	increment := func(s *MultiRegionStore) int64 {
+		t.Helper()
+		count, _, err := s.Increment(ctx, "key", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	for range 5 {
+		increment(east)
+	}
+	east.sync(ctx, "key")
+
+	// Until it reconciles, west only knows its own usage.
+	if got := increment(west); got != 1 {
+		t.Errorf("west before reconciling = %d, want 1", got)
+	}
+	west.sync(ctx, "key")
+	if got := increment(west); got != 7 {
+		t.Errorf("west after reconciling = %d, want 7", got)
+	}
+	if got := increment(east); got != 6 {
+		t.Errorf("east with stale global view = %d, want 6", got)
+	}
+
+	if err := east.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := west.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if count, _, _ := global.Increment(ctx, "key", time.Hour); count != 9 {
+		t.Errorf("global after Close = %d, want 9", count)
+	}
+}
+
+func TestMultiRegionSkewTolerance(t *testing.T) {
+	ctx := context.Background()
+	global := newTestMemory(t, memoryStripes, time.Minute)
+	s := newTestMultiRegion(t, global, WithSkewTolerance(3))
+
+	for i := range 7 {
+		if _, _, err := s.Increment(ctx, "key", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		s.mu.Lock()
+		pending := s.entries["key"].pending
+		s.mu.Unlock()
+		if want := int64((i + 1) % 3); pending != want {
+			t.Fatalf("increment %d: pending = %d, want %d", i+1, pending, want)
+		}
+	}
+	if count, _, _ := global.Increment(ctx, "key", time.Hour); count != 7 {
+		t.Errorf("global = %d, want 7", count)
+	}
+}
+
+func TestMultiRegionTakeToken(t *testing.T) {
+	ctx := context.Background()
+	global := newTestMemory(t, memoryStripes, time.Minute)
+	east, west := newTestMultiRegion(t, global), newTestMultiRegion(t, global)
+	const rate, burst = 0.001, 4
+
+	for range 3 {
+		if allowed, _, _ := east.TakeToken(ctx, "key", rate, burst); !allowed {
+			t.Fatal("east denied within the burst")
+		}
+	}
+	east.sync(ctx, "key")
+
+	if allowed, _, _ := west.TakeToken(ctx, "key", rate, burst); !allowed {
+		t.Fatal("west denied its first token")
+	}
+	west.sync(ctx, "key")
+
+	// The global bucket is empty: west refuses and returns the local token.
+	if allowed, _, _ := west.TakeToken(ctx, "key", rate, burst); allowed {
+		t.Error("west allowed a token the global bucket no longer holds")
+	}
+	if allowed, remaining, _ := west.local.TakeToken(ctx, "key", rate, burst); !allowed || remaining < 1.9 {
+		t.Errorf("local bucket = %v, %.2f, want the refused token returned", allowed, remaining)
+	}
+}
@@ -10,7 +10,10 @@ package store
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"strconv"
+	"strings"
 	"time"
 
 	ratelimiter2 "github.com/jassus213/go-rate-limiter/ratelimiter"
@@ -29,96 +32,297 @@ import (
 //	    Addr: "localhost:6379",
 //	})
 //	store := store.NewRedis(client)
-//	limiter := ratelimiter.NewFixedWindow(store, 100, time.Minute)
+//	limiter, err := ratelimiter.NewFixedWindow(store, 100, time.Minute)
 type RedisStore struct {
-	client          *redis.Client
-	incrementScript *redis.Script
-	takeTokenScript *redis.Script
+	client               *redis.Client
+	incrementScript      *redis.Script
+	incrementMultiScript *redis.Script
+	takeTokenScript      *redis.Script
+	takeTokenMultiScript *redis.Script
+	takeTokensWithScript *redis.Script
+	decrementScript      *redis.Script
+	returnTokensScript   *redis.Script
+	acquireScript        *redis.Script
+	slidingLogScript     *redis.Script
+	schemaVersion        int
+	graceTTL             time.Duration
+	minTTL               time.Duration
+	alignedExpiry        bool
 }
 
 // NewRedis creates a new RedisStore instance.
 //
 // It pre-compiles Lua scripts for both fixed window and token bucket
-// algorithms to maximize performance.
+// algorithms, in single-key and batched variants, to maximize performance.
 //
 // Example:
 //
 //	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
 //	store := store.NewRedis(client)
-func NewRedis(client *redis.Client) ratelimiter2.Store {
-	const incrementLua = `
-		local current = redis.call("INCR", KEYS[1])
-		if tonumber(current) == 1 then
-			redis.call("PEXPIRE", KEYS[1], ARGV[1])
+func NewRedis(client *redis.Client, opts ...RedisOption) ratelimiter2.Store {
+	const incrementFn = `
+		local function increment(key, n, window, grace, min_ttl, aligned)
+			local current = redis.call("INCRBY", key, n)
+			local ttl = redis.call("PTTL", key)
+			if aligned == 1 and window > 0 then
+				local time = redis.call("TIME")
+				local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+				local window_end = (math.floor(now / window) + 1) * window
+				if tonumber(current) == n or ttl < 0 then
+					redis.call("PEXPIREAT", key, window_end)
+				end
+				return current, window_end - now
+			end
+			if tonumber(current) == n or ttl < 0 then
+				ttl = math.max(window + grace, min_ttl)
+				redis.call("PEXPIRE", key, ttl)
+			end
+			return current, ttl
 		end
-		return current
 	`
 
-	const takeTokenLua = `
-		local key = KEYS[1]
+	const incrementLua = incrementFn + `
+		local current, ttl = increment(KEYS[1], tonumber(ARGV[5]), tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4]))
+		return {current, ttl}
+	`
+
+	const incrementMultiLua = incrementFn + `
+		local window = tonumber(ARGV[1])
+		local grace = tonumber(ARGV[2])
+		local min_ttl = tonumber(ARGV[3])
+		local aligned = tonumber(ARGV[4])
+		local results = {}
+		for i = 1, #KEYS do
+			local current, ttl = increment(KEYS[i], 1, window, grace, min_ttl, aligned)
+			results[#results + 1] = current
+			results[#results + 1] = ttl
+		end
+		return results
+	`
+
+	const takeTokenFn = `
+		local function take_token(key, cost, rate, burst, initial, idle_ms, now, version, max_version)
+			local entry = redis.call("HMGET", key, "tokens", "last_updated", "v")
+			if entry[3] and tonumber(entry[3]) > max_version then
+				return redis.error_reply("ratelimiter: unsupported schema version " .. entry[3] .. " for " .. key)
+			end
+
+			local tokens
+			local last_updated
+
+			if not entry[1] then
+				tokens = initial
+				last_updated = now
+			else
+				tokens = tonumber(entry[1])
+				last_updated = tonumber(entry[2])
+			end
+
+			local elapsed = now - last_updated
+			if elapsed > 0 then
+				local new_tokens = elapsed * rate
+				tokens = tokens + new_tokens
+			end
+
+			if tokens > burst then
+				tokens = burst
+			end
+
+			-- A cost above burst can never be covered: deny without touching
+			-- the bucket.
+			if cost > burst then
+				return 0, tokens
+			end
+
+			local allowed = 0
+			if tokens >= cost then
+				tokens = tokens - cost
+				allowed = 1
+			end
+
+			if version > 0 then
+				redis.call("HSET", key, "tokens", tokens, "last_updated", now, "v", version)
+			else
+				redis.call("HSET", key, "tokens", tokens, "last_updated", now)
+			end
+			if idle_ms > 0 then
+				redis.call("PEXPIRE", key, idle_ms)
+			else
+				local ttl = math.ceil((burst / rate) * 2)
+				if ttl < 10 then
+					ttl = 10
+				end
+				redis.call("EXPIRE", key, ttl)
+			end
+
+			return allowed, tokens
+		end
+	`
+
+	const takeTokenLua = takeTokenFn + `
+		local burst = tonumber(ARGV[2])
+		local allowed, tokens = take_token(KEYS[1], 1, tonumber(ARGV[1]), burst, burst, 0, tonumber(ARGV[3]), tonumber(ARGV[4]), tonumber(ARGV[5]))
+		if type(allowed) == "table" then
+			return allowed
+		end
+		return {allowed, tostring(tokens)}
+	`
+
+	const takeTokensWithLua = takeTokenFn + `
+		local allowed, tokens = take_token(KEYS[1], tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4]), tonumber(ARGV[5]), tonumber(ARGV[6]), tonumber(ARGV[7]), tonumber(ARGV[8]))
+		if type(allowed) == "table" then
+			return allowed
+		end
+		return {allowed, tostring(tokens)}
+	`
+
+	const takeTokenMultiLua = takeTokenFn + `
 		local rate = tonumber(ARGV[1])
 		local burst = tonumber(ARGV[2])
 		local now = tonumber(ARGV[3])
-		local cost = 1
+		local version = tonumber(ARGV[4])
+		local max_version = tonumber(ARGV[5])
+		local results = {}
+		for i = 1, #KEYS do
+			local allowed, tokens = take_token(KEYS[i], 1, rate, burst, burst, 0, now, version, max_version)
+			if type(allowed) == "table" then
+				return allowed
+			end
+			results[#results + 1] = allowed
+			results[#results + 1] = tostring(tokens)
+		end
+		return results
+	`
 
-		local entry = redis.call("HGETALL", key)
-		local tokens
-		local last_updated
+	const decrementLua = `
+		local current = tonumber(redis.call("GET", KEYS[1]))
+		if current == nil then
+			return 0
+		end
+		local n = tonumber(ARGV[1])
+		if n > current then
+			n = current
+		end
+		return redis.call("DECRBY", KEYS[1], n)
+	`
 
-		if #entry == 0 then
-			tokens = burst
-			last_updated = now
-		else
-			tokens = tonumber(entry[2])
-			last_updated = tonumber(entry[4])
+	const returnTokensLua = `
+		local entry = redis.call("HMGET", KEYS[1], "tokens", "v")
+		if entry[2] and tonumber(entry[2]) > tonumber(ARGV[3]) then
+			return redis.error_reply("ratelimiter: unsupported schema version " .. entry[2] .. " for " .. KEYS[1])
 		end
-		
-		local elapsed = now - last_updated
-		if elapsed > 0 then
-			local new_tokens = elapsed * rate
-			tokens = tokens + new_tokens
+		local tokens = tonumber(entry[1])
+		if tokens == nil then
+			return 0
 		end
-		
+		tokens = tokens + tonumber(ARGV[1])
+		local burst = tonumber(ARGV[2])
 		if tokens > burst then
 			tokens = burst
 		end
-		
+		redis.call("HSET", KEYS[1], "tokens", tokens)
+		return 1
+	`
+
+	const acquireLua = `
+		local now = tonumber(ARGV[2])
+		local limit = tonumber(ARGV[3])
+		local ttl = tonumber(ARGV[4])
+		redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+
+		local held = redis.call("ZSCORE", KEYS[1], ARGV[1])
+		local count = redis.call("ZCARD", KEYS[1])
+		if not held and count >= limit then
+			return {0, count}
+		end
+
+		redis.call("ZADD", KEYS[1], now + ttl, ARGV[1])
+		redis.call("PEXPIRE", KEYS[1], ttl)
+		return {1, redis.call("ZCARD", KEYS[1])}
+	`
+
+	const slidingLogLua = `
+		local now = tonumber(ARGV[1])
+		local window = tonumber(ARGV[2])
+		local limit = tonumber(ARGV[3])
+		local n = tonumber(ARGV[4])
+
+		redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+		local count = redis.call("ZCARD", KEYS[1])
+
 		local allowed = 0
-		if tokens >= cost then
-			tokens = tokens - cost
+		if count + n <= limit then
+			for i = 1, n do
+				redis.call("ZADD", KEYS[1], now, ARGV[5] .. ":" .. i)
+			end
+			redis.call("PEXPIRE", KEYS[1], window)
+			count = count + n
 			allowed = 1
 		end
-		
-		redis.call("HSET", key, "tokens", tokens, "last_updated", now)
-		local ttl = math.ceil((burst / rate) * 2)
-		if ttl < 10 then
-			ttl = 10
+
+		local reset = 0
+		if n > limit then
+			reset = window
+		elseif count + n > limit then
+			local oldest = count + n - limit - 1
+			local entry = redis.call("ZRANGE", KEYS[1], oldest, oldest, "WITHSCORES")
+			reset = tonumber(entry[2]) + window - now
 		end
-		redis.call("EXPIRE", key, ttl)
-		
-		return {allowed, tostring(tokens)}
+		return {allowed, count, reset}
 	`
 
-	return &RedisStore{
-		client:          client,
-		incrementScript: redis.NewScript(incrementLua),
-		takeTokenScript: redis.NewScript(takeTokenLua),
+	s := &RedisStore{
+		client:               client,
+		incrementScript:      redis.NewScript(incrementLua),
+		incrementMultiScript: redis.NewScript(incrementMultiLua),
+		takeTokenScript:      redis.NewScript(takeTokenLua),
+		takeTokenMultiScript: redis.NewScript(takeTokenMultiLua),
+		takeTokensWithScript: redis.NewScript(takeTokensWithLua),
+		decrementScript:      redis.NewScript(decrementLua),
+		returnTokensScript:   redis.NewScript(returnTokensLua),
+		acquireScript:        redis.NewScript(acquireLua),
+		slidingLogScript:     redis.NewScript(slidingLogLua),
+		schemaVersion:        RedisSchemaVersion,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // Increment executes the pre-compiled Lua script for the Fixed Window algorithm.
 //
-// Returns the new counter value for the given key or an error if the Redis call fails.
+// Returns the new counter value for the given key and the key's remaining TTL as
+// reported by Redis, or an error if the Redis call fails.
 //
 // Example:
 //
-//	count, err := store.Increment(ctx, "user:123", time.Minute)
-func (s *RedisStore) Increment(ctx context.Context, key string, window time.Duration) (int64, error) {
-	res, err := s.incrementScript.Run(ctx, s.client, []string{key}, window.Milliseconds()).Result()
+//	count, ttl, err := store.Increment(ctx, "user:123", time.Minute)
+func (s *RedisStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	return s.IncrementBy(ctx, key, 1, window)
+}
+
+// IncrementBy runs the fixed window Lua script adding n to the counter for key
+// atomically.
+//
+// Example:
+//
+//	count, ttl, err := store.(ratelimiter.CostStore).IncrementBy(ctx, "user:123", 5, time.Minute)
+func (s *RedisStore) IncrementBy(ctx context.Context, key string, n int64, window time.Duration) (int64, time.Duration, error) {
+	res, err := s.incrementScript.Run(ctx, s.client, []string{key}, append(s.expiryArgs(window), n)...).Result()
 	if err != nil {
-		return 0, err
+		return 0, 0, err
+	}
+
+	arr, ok := res.([]interface{})
+	if !ok || len(arr) < 2 {
+		return 0, 0, ratelimiter2.ErrorExceeded
 	}
-	return res.(int64), nil
+
+	count, _ := arr[0].(int64)
+	ttl, _ := arr[1].(int64)
+
+	return count, time.Duration(ttl) * time.Millisecond, nil
 }
 
 // TakeToken executes the token bucket Lua script for the given key.
@@ -137,7 +341,151 @@ func (s *RedisStore) Increment(ctx context.Context, key string, window time.Dura
 func (s *RedisStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, error) {
 	now := float64(time.Now().UnixNano()) / 1e9
 
-	res, err := s.takeTokenScript.Run(ctx, s.client, []string{key}, rate, burst, now).Result()
+	res, err := s.takeTokenScript.Run(ctx, s.client, []string{key}, rate, burst, now, s.schemaVersion, RedisSchemaVersion).Result()
+	if err != nil {
+		return false, 0, schemaError(err)
+	}
+
+	arr, ok := res.([]interface{})
+	if !ok || len(arr) < 2 {
+		return false, 0, ratelimiter2.ErrorExceeded
+	}
+
+	state := parseTokenState(arr[0], arr[1])
+	return state.Allowed, state.Remaining, nil
+}
+
+// TakeTokens runs the token bucket Lua script taking n tokens atomically, so
+// that cost-based limits hold across instances. Costs above burst are denied
+// without modifying the bucket.
+//
+// Example:
+//
+//	allowed, remaining, err := store.(ratelimiter.CostStore).TakeTokens(ctx, "user:123", 512, 1024, 4096)
+func (s *RedisStore) TakeTokens(ctx context.Context, key string, n int64, rate float64, burst int64) (bool, float64, error) {
+	return s.TakeTokensWith(ctx, key, n, ratelimiter2.Bucket{Rate: rate, Burst: burst, Initial: burst})
+}
+
+// TakeTokensWith runs the token bucket script taking n tokens from a bucket
+// with custom initial tokens or idle TTL. The idle TTL becomes the key's
+// expiration, so dormant buckets are started over by Redis itself.
+//
+// Example:
+//
+//	allowed, remaining, err := store.(ratelimiter.BucketStore).TakeTokensWith(ctx, "signup:1.2.3.4", 1, ratelimiter.Bucket{Rate: 1.0 / 60, Burst: 3})
+func (s *RedisStore) TakeTokensWith(ctx context.Context, key string, n int64, bucket ratelimiter2.Bucket) (bool, float64, error) {
+	now := float64(time.Now().UnixNano()) / 1e9
+
+	res, err := s.takeTokensWithScript.Run(ctx, s.client, []string{key}, n, bucket.Rate, bucket.Burst, bucket.Initial, bucket.IdleTTL.Milliseconds(), now, s.schemaVersion, RedisSchemaVersion).Result()
+	if err != nil {
+		return false, 0, schemaError(err)
+	}
+
+	arr, ok := res.([]interface{})
+	if !ok || len(arr) < 2 {
+		return false, 0, ratelimiter2.ErrorExceeded
+	}
+
+	state := parseTokenState(arr[0], arr[1])
+	return state.Allowed, state.Remaining, nil
+}
+
+// IncrementMulti runs the batched fixed window script, incrementing all keys in
+// a single round trip.
+//
+// With Redis Cluster, all keys must hash to the same slot (use hash tags such as
+// "{tenant}:user:42").
+//
+// Example:
+//
+//	counters, err := store.(ratelimiter.BatchStore).IncrementMulti(ctx, []string{"user:42", "org:7"}, time.Minute)
+func (s *RedisStore) IncrementMulti(ctx context.Context, keys []string, window time.Duration) ([]ratelimiter2.Counter, error) {
+	res, err := s.incrementMultiScript.Run(ctx, s.client, keys, s.expiryArgs(window)...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	arr, ok := res.([]interface{})
+	if !ok || len(arr) != 2*len(keys) {
+		return nil, ratelimiter2.ErrorExceeded
+	}
+
+	counters := make([]ratelimiter2.Counter, len(keys))
+	for i := range counters {
+		count, _ := arr[2*i].(int64)
+		ttl, _ := arr[2*i+1].(int64)
+		counters[i] = ratelimiter2.Counter{Count: count, TTL: time.Duration(ttl) * time.Millisecond}
+	}
+	return counters, nil
+}
+
+// TakeTokenMulti runs the batched token bucket script, taking one token from the
+// bucket of every key in a single round trip.
+//
+// With Redis Cluster, all keys must hash to the same slot.
+//
+// Example:
+//
+//	states, err := store.(ratelimiter.BatchStore).TakeTokenMulti(ctx, []string{"user:42", "org:7"}, 1.0, 5)
+func (s *RedisStore) TakeTokenMulti(ctx context.Context, keys []string, rate float64, burst int64) ([]ratelimiter2.TokenState, error) {
+	now := float64(time.Now().UnixNano()) / 1e9
+
+	res, err := s.takeTokenMultiScript.Run(ctx, s.client, keys, rate, burst, now, s.schemaVersion, RedisSchemaVersion).Result()
+	if err != nil {
+		return nil, schemaError(err)
+	}
+
+	arr, ok := res.([]interface{})
+	if !ok || len(arr) != 2*len(keys) {
+		return nil, ratelimiter2.ErrorExceeded
+	}
+
+	states := make([]ratelimiter2.TokenState, len(keys))
+	for i := range states {
+		states[i] = parseTokenState(arr[2*i], arr[2*i+1])
+	}
+	return states, nil
+}
+
+// parseTokenState converts the allowed flag and token count returned by the
+// token bucket scripts into a TokenState.
+func parseTokenState(allowed, tokens interface{}) ratelimiter2.TokenState {
+	flag, _ := allowed.(int64)
+	tokensStr, _ := tokens.(string)
+	remaining, _ := strconv.ParseFloat(tokensStr, 64)
+
+	return ratelimiter2.TokenState{Allowed: flag == 1, Remaining: remaining}
+}
+
+// Decrement lowers the fixed window counter for key by n, never below zero,
+// without changing its expiration.
+//
+// Example:
+//
+//	err := store.(ratelimiter.RefundStore).Decrement(ctx, "user:123", 1)
+func (s *RedisStore) Decrement(ctx context.Context, key string, n int64) error {
+	return s.decrementScript.Run(ctx, s.client, []string{key}, n).Err()
+}
+
+// ReturnTokens adds n tokens back to the bucket for key, capped at burst.
+//
+// Example:
+//
+//	err := store.(ratelimiter.RefundStore).ReturnTokens(ctx, "user:123", 1, 5)
+func (s *RedisStore) ReturnTokens(ctx context.Context, key string, n float64, burst int64) error {
+	return schemaError(s.returnTokensScript.Run(ctx, s.client, []string{key}, n, burst, RedisSchemaVersion).Err())
+}
+
+// Acquire registers lease id in the sorted set for key, scored by expiration,
+// if fewer than limit unexpired leases are held, or refreshes it if already held.
+//
+// Example:
+//
+//	held, count, err := store.(ratelimiter.ConcurrencyStore).Acquire(ctx, "user:123", leaseID, 5, time.Minute)
+func (s *RedisStore) Acquire(ctx context.Context, key, id string, limit int64, ttl time.Duration) (bool, int64, error) {
+	now := time.Now().UnixMilli()
+
+	res, err := s.acquireScript.Run(ctx, s.client, []string{key}, id, now, limit, ttl.Milliseconds()).Result()
 	if err != nil {
 		return false, 0, err
 	}
@@ -147,10 +495,247 @@ func (s *RedisStore) TakeToken(ctx context.Context, key string, rate float64, bu
 		return false, 0, ratelimiter2.ErrorExceeded
 	}
 
-	allowed := arr[0].(int64) == 1
+	held, _ := arr[0].(int64)
+	count, _ := arr[1].(int64)
+	return held == 1, count, nil
+}
+
+// LogRequests runs the sliding window log script, which trims the sorted set
+// of request timestamps for key to the window, adds n entries if the limit
+// allows, and refreshes the key's expiration to the window, in one round trip.
+//
+// Example:
+//
+//	allowed, count, resetAfter, err := store.(ratelimiter.SlidingLogStore).LogRequests(ctx, "login:alice", 1, 5, 15*time.Minute)
+func (s *RedisStore) LogRequests(ctx context.Context, key string, n, limit int64, window time.Duration) (bool, int64, time.Duration, error) {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return false, 0, 0, err
+	}
+	now := time.Now().UnixMilli()
+
+	res, err := s.slidingLogScript.Run(ctx, s.client, []string{key}, now, window.Milliseconds(), limit, n, strconv.FormatInt(now, 36)+hex.EncodeToString(id[:])).Result()
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	arr, ok := res.([]interface{})
+	if !ok || len(arr) < 3 {
+		return false, 0, 0, ratelimiter2.ErrorExceeded
+	}
 
-	remainingTokensStr, _ := arr[1].(string)
-	remainingTokens, _ := strconv.ParseFloat(remainingTokensStr, 64)
+	allowed, _ := arr[0].(int64)
+	count, _ := arr[1].(int64)
+	reset, _ := arr[2].(int64)
+	return allowed == 1, count, time.Duration(reset) * time.Millisecond, nil
+}
+
+// Release removes lease id from the sorted set for key.
+//
+// Example:
+//
+//	err := store.(ratelimiter.ConcurrencyStore).Release(ctx, "user:123", leaseID)
+func (s *RedisStore) Release(ctx context.Context, key, id string) error {
+	return s.client.ZRem(ctx, key, id).Err()
+}
+
+// maxUpdateAttempts is the number of times Update retries after a concurrent write.
+const maxUpdateAttempts = 10
+
+// Update applies fn to the state of key in an optimistic transaction: the key
+// is watched while fn runs, and fn is run again if the key changed before the
+// new state was written. After maxUpdateAttempts conflicts, Update returns
+// ErrorUpdateConflict.
+//
+// Example:
+//
+//	result, err := store.(ratelimiter.StateStore).Update(ctx, "user:123", time.Minute, fn)
+func (s *RedisStore) Update(ctx context.Context, key string, ttl time.Duration, fn ratelimiter2.UpdateFunc) (ratelimiter2.Result, error) {
+	var result ratelimiter2.Result
+	update := func(tx *redis.Tx) error {
+		state, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			state, err = nil, nil
+		}
+		if err != nil {
+			return err
+		}
+
+		next, res, err := fn(state)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if next == nil {
+				pipe.Del(ctx, key)
+			} else {
+				pipe.Set(ctx, key, next, ttl)
+			}
+			return nil
+		})
+		if err == nil {
+			result = res
+		}
+		return err
+	}
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		err := s.client.Watch(ctx, update, key)
+		if err == redis.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			return ratelimiter2.Result{Allowed: false}, err
+		}
+		return result, nil
+	}
+	return ratelimiter2.Result{Allowed: false}, ratelimiter2.ErrorUpdateConflict
+}
+
+// Ping checks that Redis is reachable.
+//
+// Example:
+//
+//	err := store.(ratelimiter.Pinger).Ping(ctx)
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// Inspect returns the state of up to limit keys matching pattern, using SCAN
+// so that Redis is never blocked. Redis glob syntax is close to path.Match;
+// keys of unknown types are skipped.
+//
+// Example:
+//
+//	states, err := store.(ratelimiter.Inspector).Inspect(ctx, "login:*", 100)
+func (s *RedisStore) Inspect(ctx context.Context, pattern string, limit int) ([]ratelimiter2.KeyState, error) {
+	var states []ratelimiter2.KeyState
+
+	iter := s.client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		if limit > 0 && len(states) >= limit {
+			break
+		}
+
+		key := iter.Val()
+		state, ok, err := s.inspectKey(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			states = append(states, state)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// inspectKey reads the state held under key, reporting false for keys that
+// were not written by this store.
+func (s *RedisStore) inspectKey(ctx context.Context, key string) (ratelimiter2.KeyState, bool, error) {
+	pipe := s.client.Pipeline()
+	typ := pipe.Type(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return ratelimiter2.KeyState{}, false, err
+	}
+
+	state := ratelimiter2.KeyState{Key: key}
+	if ttl.Val() > 0 {
+		state.TTL = ratelimiter2.Duration(ttl.Val())
+	}
+
+	switch typ.Val() {
+	case "string":
+		value, err := s.client.Get(ctx, key).Bytes()
+		if err != nil {
+			return state, false, nil
+		}
+		if count, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+			state.Algorithm, state.Count = ratelimiter2.AlgorithmFixedWindow, count
+		} else {
+			state.Algorithm, state.State = ratelimiter2.AlgorithmState, value
+		}
+	case "hash":
+		tokens, err := s.client.HGet(ctx, key, "tokens").Float64()
+		if err != nil {
+			return state, false, nil
+		}
+		state.Algorithm, state.Tokens = ratelimiter2.AlgorithmTokenBucket, tokens
+	case "zset":
+		// Sliding window logs and concurrency leases are both sorted sets; log
+		// entries are told apart by the ":<n>" suffix of their members, which
+		// lease IDs do not have.
+		pipe := s.client.Pipeline()
+		count := pipe.ZCard(ctx, key)
+		newest := pipe.ZRange(ctx, key, -1, -1)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return state, false, err
+		}
+		state.Algorithm, state.Count = ratelimiter2.AlgorithmConcurrency, count.Val()
+		if members := newest.Val(); len(members) > 0 && strings.Contains(members[0], ":") {
+			state.Algorithm = ratelimiter2.AlgorithmSlidingLog
+		}
+	default:
+		return state, false, nil
+	}
+	return state, true, nil
+}
+
+// Reset deletes the Redis key holding the state for the given key.
+//
+// Example:
+//
+//	err := store.(ratelimiter.Resetter).Reset(ctx, "user:123")
+func (s *RedisStore) Reset(ctx context.Context, key string) error {
+	return s.client.Del(ctx, key).Err()
+}
+
+// Restore writes states read from another store in one pipeline, in the
+// layout the RedisStore scripts use.
+//
+// Example:
+//
+//	err := store.(ratelimiter2.Restorer).Restore(ctx, states)
+func (s *RedisStore) Restore(ctx context.Context, states []ratelimiter2.KeyState) error {
+	now := float64(time.Now().UnixNano()) / 1e9
+	nowMillis := time.Now().UnixMilli()
 
-	return allowed, remainingTokens, nil
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, state := range states {
+			ttl := time.Duration(state.TTL)
+			if ttl <= 0 {
+				ttl = restoreDefaultTTL
+			}
+			switch state.Algorithm {
+			case ratelimiter2.AlgorithmFixedWindow:
+				pipe.Set(ctx, state.Key, state.Count, ttl+s.graceTTL)
+			case ratelimiter2.AlgorithmTokenBucket:
+				pipe.Del(ctx, state.Key)
+				if s.schemaVersion > 0 {
+					pipe.HSet(ctx, state.Key, "tokens", state.Tokens, "last_updated", now, "v", s.schemaVersion)
+				} else {
+					pipe.HSet(ctx, state.Key, "tokens", state.Tokens, "last_updated", now)
+				}
+				pipe.PExpire(ctx, state.Key, ttl)
+			case ratelimiter2.AlgorithmSlidingLog:
+				pipe.Del(ctx, state.Key)
+				if state.Count > 0 {
+					entries := make([]redis.Z, state.Count)
+					for i := range entries {
+						entries[i] = redis.Z{Score: float64(nowMillis), Member: "restored:" + strconv.Itoa(i+1)}
+					}
+					pipe.ZAdd(ctx, state.Key, entries...)
+					pipe.PExpire(ctx, state.Key, ttl)
+				}
+			case ratelimiter2.AlgorithmState:
+				pipe.Set(ctx, state.Key, state.State, time.Duration(state.TTL))
+			}
+		}
+		return nil
+	})
+	return err
 }
@@ -0,0 +1,328 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains ReplicatedStore, which mirrors the state of a primary
+// store to a standby that can take over on failover.
+package store
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// ReplicatedOption configures a ReplicatedStore.
+type ReplicatedOption func(*ReplicatedStore)
+
+// WithReplicationQueue sets how many writes may wait to be mirrored to the
+// standby. Writes arriving while the queue is full are not mirrored and are
+// counted as dropped. The default is 10000.
+func WithReplicationQueue(n int) ReplicatedOption {
+	return func(s *ReplicatedStore) {
+		if n > 0 {
+			s.queueSize = n
+		}
+	}
+}
+
+// ReplicationStats describes the mirroring done by a ReplicatedStore.
+type ReplicationStats struct {
+	// Promoted reports whether the standby has been promoted.
+	Promoted bool `json:"promoted"`
+	// Pending is the number of writes waiting to be mirrored.
+	Pending int `json:"pending"`
+	// Replicated is the number of writes mirrored.
+	Replicated uint64 `json:"replicated"`
+	// Dropped is the number of writes not mirrored because the queue was full.
+	Dropped uint64 `json:"dropped"`
+	// Failed is the number of writes the mirror rejected.
+	Failed uint64 `json:"failed"`
+}
+
+// ReplicatedStore writes to a primary store, typically a Redis, and mirrors
+// every write asynchronously to a standby, typically a Redis in another
+// availability zone or at another provider. When the primary fails, Promote
+// makes the standby serve requests with the quota clients have already used,
+// instead of resetting every client.
+//
+// Writes are mirrored by replaying them against the standby, so its state
+// trails the primary by the replication queue and may drift slightly for
+// token buckets, whose refill is computed from each store's own clock. After
+// promotion, writes are mirrored to the former primary, failing until it is
+// back.
+//
+// It implements ratelimiter.CostStore and ratelimiter.RefundStore on behalf of
+// the two stores, which must both implement them for ratelimiter.StoreAs to
+// report them, since either may be promoted.
+//
+// Example usage:
+//
+//	replicated := store.NewReplicated(ctx, store.NewRedis(primary), store.NewRedis(standby))
+//
+//	// On failover, e.g. from a health check or an admin endpoint:
+//	replicated.(*store.ReplicatedStore).Promote()
+type ReplicatedStore struct {
+	stores    [2]ratelimiter.Store
+	active    atomic.Int32
+	queueSize int
+	queue     chan replicatedWrite
+
+	background *background
+
+	replicated atomic.Uint64
+	dropped    atomic.Uint64
+	failed     atomic.Uint64
+}
+
+// replicatedWrite is a write to replay against the store it targets.
+type replicatedWrite struct {
+	target ratelimiter.Store
+	apply  func(ctx context.Context, s ratelimiter.Store) error
+}
+
+// NewReplicated creates a ReplicatedStore writing to primary and mirroring to
+// standby, and starts the mirroring, which runs until ctx is canceled or
+// Close is called.
+func NewReplicated(ctx context.Context, primary, standby ratelimiter.Store, opts ...ReplicatedOption) ratelimiter.Store {
+	s := &ReplicatedStore{
+		stores:    [2]ratelimiter.Store{primary, standby},
+		queueSize: 10000,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.queue = make(chan replicatedWrite, s.queueSize)
+
+	s.background = startBackground(ctx, s.runReplication)
+	return s
+}
+
+// Close stops the mirroring after replaying the writes already queued, or
+// when ctx expires.
+func (s *ReplicatedStore) Close(ctx context.Context) error {
+	if err := s.background.stop(ctx); err != nil {
+		return err
+	}
+	for {
+		select {
+		case w := <-s.queue:
+			s.replay(ctx, w)
+		default:
+			return ctx.Err()
+		}
+	}
+}
+
+// Promote makes the standby the primary and mirrors subsequent writes to the
+// former primary. Writes queued before promotion are still replayed against
+// the promoted store, bringing it up to date. Calling Promote again swaps the
+// stores back.
+func (s *ReplicatedStore) Promote() {
+	for {
+		old := s.active.Load()
+		if s.active.CompareAndSwap(old, 1-old) {
+			return
+		}
+	}
+}
+
+// Stats returns the mirroring counters.
+func (s *ReplicatedStore) Stats() ReplicationStats {
+	return ReplicationStats{
+		Promoted:   s.active.Load() == 1,
+		Pending:    len(s.queue),
+		Replicated: s.replicated.Load(),
+		Dropped:    s.dropped.Load(),
+		Failed:     s.failed.Load(),
+	}
+}
+
+// Unwrap returns the primary and standby stores, in their original order.
+func (s *ReplicatedStore) Unwrap() []ratelimiter.Store {
+	return s.stores[:]
+}
+
+// primary returns the store serving requests and the one mirroring it.
+func (s *ReplicatedStore) primary() (ratelimiter.Store, ratelimiter.Store) {
+	active := s.active.Load()
+	return s.stores[active], s.stores[1-active]
+}
+
+// mirror queues apply for replay against target without blocking.
+func (s *ReplicatedStore) mirror(target ratelimiter.Store, apply func(ctx context.Context, s ratelimiter.Store) error) {
+	select {
+	case s.queue <- replicatedWrite{target: target, apply: apply}:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// runReplication replays queued writes until ctx is canceled.
+func (s *ReplicatedStore) runReplication(ctx context.Context) {
+	for {
+		select {
+		case w := <-s.queue:
+			s.replay(ctx, w)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// replay applies w to its target, bounding the time a dead mirror can hold
+// up the queue.
+func (s *ReplicatedStore) replay(ctx context.Context, w replicatedWrite) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+	defer cancel()
+	if err := w.apply(ctx, w.target); err != nil {
+		s.failed.Add(1)
+		return
+	}
+	s.replicated.Add(1)
+}
+
+// Increment increments the counter for key in the primary and mirrors the
+// increment.
+func (s *ReplicatedStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	primary, mirror := s.primary()
+	count, ttl, err := primary.Increment(ctx, key, window)
+	if err != nil {
+		return 0, 0, err
+	}
+	s.mirror(mirror, func(ctx context.Context, m ratelimiter.Store) error {
+		_, _, err := m.Increment(ctx, key, window)
+		return err
+	})
+	return count, ttl, nil
+}
+
+// IncrementBy increments the counter for key by n in the primary and mirrors
+// the increment. It returns ratelimiter.ErrorCostUnsupported if the primary
+// does not implement ratelimiter.CostStore.
+func (s *ReplicatedStore) IncrementBy(ctx context.Context, key string, n int64, window time.Duration) (int64, time.Duration, error) {
+	primary, mirror := s.primary()
+	costs, ok := ratelimiter.StoreAs[ratelimiter.CostStore](primary)
+	if !ok {
+		return 0, 0, ratelimiter.ErrorCostUnsupported
+	}
+	count, ttl, err := costs.IncrementBy(ctx, key, n, window)
+	if err != nil {
+		return 0, 0, err
+	}
+	s.mirror(mirror, func(ctx context.Context, m ratelimiter.Store) error {
+		_, _, err := incrementBy(ctx, m, key, n, window)
+		return err
+	})
+	return count, ttl, nil
+}
+
+// TakeToken takes a token from the bucket for key in the primary and, if it
+// was allowed, mirrors the take.
+func (s *ReplicatedStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, error) {
+	primary, mirror := s.primary()
+	allowed, tokens, err := primary.TakeToken(ctx, key, rate, burst)
+	if err != nil {
+		return false, 0, err
+	}
+	if allowed {
+		s.mirror(mirror, func(ctx context.Context, m ratelimiter.Store) error {
+			_, err := takeTokens(ctx, m, key, 1, rate, burst)
+			return err
+		})
+	}
+	return allowed, tokens, nil
+}
+
+// TakeTokens takes n tokens from the bucket for key in the primary and, if
+// they were allowed, mirrors the take. It returns
+// ratelimiter.ErrorCostUnsupported if the primary does not implement
+// ratelimiter.CostStore.
+func (s *ReplicatedStore) TakeTokens(ctx context.Context, key string, n int64, rate float64, burst int64) (bool, float64, error) {
+	primary, mirror := s.primary()
+	costs, ok := ratelimiter.StoreAs[ratelimiter.CostStore](primary)
+	if !ok {
+		return false, 0, ratelimiter.ErrorCostUnsupported
+	}
+	allowed, tokens, err := costs.TakeTokens(ctx, key, n, rate, burst)
+	if err != nil {
+		return false, 0, err
+	}
+	if allowed {
+		s.mirror(mirror, func(ctx context.Context, m ratelimiter.Store) error {
+			_, err := takeTokens(ctx, m, key, n, rate, burst)
+			return err
+		})
+	}
+	return allowed, tokens, nil
+}
+
+// Decrement lowers the counter for key in the primary and mirrors the
+// refund. It returns ratelimiter.ErrorRefundUnsupported if the primary does
+// not implement ratelimiter.RefundStore.
+func (s *ReplicatedStore) Decrement(ctx context.Context, key string, n int64) error {
+	primary, mirror := s.primary()
+	refunds, ok := ratelimiter.StoreAs[ratelimiter.RefundStore](primary)
+	if !ok {
+		return ratelimiter.ErrorRefundUnsupported
+	}
+	if err := refunds.Decrement(ctx, key, n); err != nil {
+		return err
+	}
+	s.mirror(mirror, func(ctx context.Context, m ratelimiter.Store) error {
+		if refunds, ok := ratelimiter.StoreAs[ratelimiter.RefundStore](m); ok {
+			return refunds.Decrement(ctx, key, n)
+		}
+		return ratelimiter.ErrorRefundUnsupported
+	})
+	return nil
+}
+
+// ReturnTokens adds tokens back to the bucket for key in the primary and
+// mirrors the refund. It returns ratelimiter.ErrorRefundUnsupported if the
+// primary does not implement ratelimiter.RefundStore.
+func (s *ReplicatedStore) ReturnTokens(ctx context.Context, key string, n float64, burst int64) error {
+	primary, mirror := s.primary()
+	refunds, ok := ratelimiter.StoreAs[ratelimiter.RefundStore](primary)
+	if !ok {
+		return ratelimiter.ErrorRefundUnsupported
+	}
+	if err := refunds.ReturnTokens(ctx, key, n, burst); err != nil {
+		return err
+	}
+	s.mirror(mirror, func(ctx context.Context, m ratelimiter.Store) error {
+		if refunds, ok := ratelimiter.StoreAs[ratelimiter.RefundStore](m); ok {
+			return refunds.ReturnTokens(ctx, key, n, burst)
+		}
+		return ratelimiter.ErrorRefundUnsupported
+	})
+	return nil
+}
+
+// Reset removes the state for key from the primary and mirrors the removal.
+func (s *ReplicatedStore) Reset(ctx context.Context, key string) error {
+	primary, mirror := s.primary()
+	if resetter, ok := primary.(ratelimiter.Resetter); ok {
+		if err := resetter.Reset(ctx, key); err != nil {
+			return err
+		}
+	}
+	s.mirror(mirror, func(ctx context.Context, m ratelimiter.Store) error {
+		if resetter, ok := m.(ratelimiter.Resetter); ok {
+			return resetter.Reset(ctx, key)
+		}
+		return nil
+	})
+	return nil
+}
+
+// Ping checks the primary when it supports it. The standby is not checked,
+// so that its outage does not fail the health of instances it does not
+// serve.
+func (s *ReplicatedStore) Ping(ctx context.Context) error {
+	primary, _ := s.primary()
+	if pinger, ok := primary.(ratelimiter.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestReplicated returns a ReplicatedStore whose mirroring goroutine has
+// already stopped, so that writes stay queued until Close replays them.
+func newTestReplicated(t *testing.T, opts ...ReplicatedOption) (*ReplicatedStore, *MemoryStore, *MemoryStore) {
+	t.Helper()
+	primary := newTestMemory(t, memoryStripes, time.Minute)
+	standby := newTestMemory(t, memoryStripes, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := NewReplicated(ctx, primary, standby, opts...).(*ReplicatedStore)
+	<-s.background.done
+	return s, primary, standby
+}
+
+func TestReplicatedMirrorsWrites(t *testing.T) {
+	ctx := context.Background()
+	s, primary, standby := newTestReplicated(t)
+
+	for range 3 {
+		if _, _, err := s.Increment(ctx, "key", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Decrement(ctx, "key", 1); err != nil {
+		t.Fatal(err)
+	}
+	if stats := s.Stats(); stats.Pending != 4 || stats.Replicated != 0 {
+		t.Fatalf("stats before replay = %+v, want 4 pending", stats)
+	}
+
+	if err := s.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if stats := s.Stats(); stats.Pending != 0 || stats.Replicated != 4 {
+		t.Errorf("stats after replay = %+v, want 4 replicated", stats)
+	}
+
+	for name, m := range map[string]*MemoryStore{"primary": primary, "standby": standby} {
+		if count, _, _ := m.Increment(ctx, "key", time.Minute); count != 3 {
+			t.Errorf("%s: next count = %d, want 3", name, count)
+		}
+	}
+}
+
+func TestReplicatedPromote(t *testing.T) {
+	ctx := context.Background()
+	s, primary, standby := newTestReplicated(t)
+
+	if _, _, err := s.Increment(ctx, "key", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	s.Promote()
+	if !s.Stats().Promoted {
+		t.Fatal("Promote did not promote the standby")
+	}
+
+	// The promoted standby serves requests even before catching up, and the
+	// write queued before promotion still reaches it.
+	if count, _, _ := s.Increment(ctx, "key", time.Minute); count != 1 {
+		t.Errorf("count on the promoted standby = %d, want 1", count)
+	}
+	if err := s.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if count, _, _ := standby.Increment(ctx, "key", time.Minute); count != 3 {
+		t.Errorf("standby: next count = %d, want 3", count)
+	}
+	if count, _, _ := primary.Increment(ctx, "key", time.Minute); count != 3 {
+		t.Errorf("former primary: next count = %d, want 3", count)
+	}
+
+	s.Promote()
+	if s.Stats().Promoted {
+		t.Error("second Promote did not swap the stores back")
+	}
+}
+
+func TestReplicatedDropsWhenQueueIsFull(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newTestReplicated(t, WithReplicationQueue(2))
+
+	for range 5 {
+		if _, _, err := s.Increment(ctx, "key", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := s.Stats(); stats.Pending != 2 || stats.Dropped != 3 {
+		t.Errorf("stats = %+v, want 2 pending and 3 dropped", stats)
+	}
+}
@@ -0,0 +1,58 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains the versioning of the data layout RedisStore writes.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RedisSchemaVersion is the version of the data layout written by RedisStore.
+//
+// Versions:
+//   - 0: token buckets are hashes with "tokens" and "last_updated" (Unix
+//     seconds) fields. Written by releases predating versioning.
+//   - 1: as 0, with a "v" field holding the version.
+//
+// Fixed window counters are plain integers in every version.
+//
+// A RedisStore reads every version up to RedisSchemaVersion, upgrading
+// older buckets to the version it writes on their next update, and refuses
+// buckets written by a newer release with ErrorUnsupportedSchema rather than
+// misinterpreting them.
+const RedisSchemaVersion = 1
+
+// ErrorUnsupportedSchema is returned by RedisStore when a key was written with
+// a newer data layout than this release understands, e.g. during a rollback.
+var ErrorUnsupportedSchema = errors.New("unsupported store schema version")
+
+// RedisOption configures a RedisStore.
+type RedisOption func(*RedisStore)
+
+// WithSchemaVersion sets the data layout version a RedisStore writes, for
+// fleets running several releases at once: keep writing the version of the
+// oldest release until every instance has been upgraded, then remove the
+// option. Versions above RedisSchemaVersion are ignored.
+//
+// Example:
+//
+//	// Mid-rollout from a release predating versioning.
+//	store := store.NewRedis(client, store.WithSchemaVersion(0))
+func WithSchemaVersion(v int) RedisOption {
+	return func(s *RedisStore) {
+		if v >= 0 && v <= RedisSchemaVersion {
+			s.schemaVersion = v
+		}
+	}
+}
+
+// schemaError converts the script error reporting an unsupported version into
+// an error wrapping ErrorUnsupportedSchema.
+func schemaError(err error) error {
+	if err != nil && strings.Contains(err.Error(), "ratelimiter: unsupported schema version") {
+		return fmt.Errorf("%w: %v", ErrorUnsupportedSchema, err)
+	}
+	return err
+}
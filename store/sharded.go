@@ -0,0 +1,401 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains ShardedRedisStore, which spreads keys over independent
+// Redis servers.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	"github.com/redis/go-redis/v9"
+)
+
+// ShardOption configures a ShardedRedisStore.
+type ShardOption func(*ShardedRedisStore)
+
+// WithShardFailureThreshold sets how many consecutive failed calls mark a
+// shard unhealthy. The default is 3.
+func WithShardFailureThreshold(n int32) ShardOption {
+	return func(s *ShardedRedisStore) {
+		if n > 0 {
+			s.threshold = n
+		}
+	}
+}
+
+// WithShardHealthInterval sets how often unhealthy shards are pinged to detect
+// their recovery. The default is five seconds.
+func WithShardHealthInterval(d time.Duration) ShardOption {
+	return func(s *ShardedRedisStore) {
+		if d > 0 {
+			s.interval = d
+		}
+	}
+}
+
+// WithShardRedisOptions applies opts, e.g. WithSchemaVersion or
+// WithGraceTTL, to the RedisStore of every shard.
+func WithShardRedisOptions(opts ...RedisOption) ShardOption {
+	return func(s *ShardedRedisStore) {
+		s.redisOptions = append(s.redisOptions, opts...)
+	}
+}
+
+// ShardedRedisStore spreads keys over a set of independent Redis servers (not
+// a Redis Cluster), so that rate-limiting throughput scales beyond one server.
+//
+// Keys are placed with rendezvous hashing on the server addresses, so adding or
+// removing a server only moves the keys it gains or loses. Each shard's health
+// is tracked: after consecutive failures a shard is skipped and its keys go to
+// their next-ranked healthy shard until a background ping succeeds again. Keys
+// moved that way start with fresh state, which briefly relaxes their limits
+// rather than failing requests.
+//
+// Example usage:
+//
+//	store, err := store.NewShardedRedis(ctx, []*redis.Client{
+//	    redis.NewClient(&redis.Options{Addr: "redis-a:6379"}),
+//	    redis.NewClient(&redis.Options{Addr: "redis-b:6379"}),
+//	    redis.NewClient(&redis.Options{Addr: "redis-c:6379"}),
+//	})
+type ShardedRedisStore struct {
+	shards     []*redisShard
+	threshold  int32
+	interval   time.Duration
+	background *background
+
+	redisOptions []RedisOption
+}
+
+// redisShard is one server of a ShardedRedisStore.
+type redisShard struct {
+	name      string
+	store     *RedisStore
+	failures  atomic.Int32
+	unhealthy atomic.Bool
+}
+
+// NewShardedRedis creates a ShardedRedisStore over clients and starts the
+// health checks, which run until ctx is canceled or Close is called.
+//
+// Shards are identified by their client's address, which must therefore be
+// the same on every instance for keys to be placed consistently.
+//
+// It returns an error wrapping ratelimiter.ErrorInvalidConfig if clients is
+// empty or contains nil.
+func NewShardedRedis(ctx context.Context, clients []*redis.Client, opts ...ShardOption) (ratelimiter.Store, error) {
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("%w: at least one Redis client is required", ratelimiter.ErrorInvalidConfig)
+	}
+	for i, client := range clients {
+		if client == nil {
+			return nil, fmt.Errorf("%w: Redis client %d is nil", ratelimiter.ErrorInvalidConfig, i)
+		}
+	}
+
+	s := &ShardedRedisStore{threshold: 3, interval: 5 * time.Second}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for _, client := range clients {
+		s.shards = append(s.shards, &redisShard{
+			name:  client.Options().Addr,
+			store: NewRedis(client, s.redisOptions...).(*RedisStore),
+		})
+	}
+
+	s.background = startBackground(ctx, s.runHealthChecks)
+	return s, nil
+}
+
+// Close stops the health checks and waits for them to return. The clients
+// are left open.
+func (s *ShardedRedisStore) Close(ctx context.Context) error {
+	return s.background.stop(ctx)
+}
+
+// AlignedExpiry reports whether the shards were created with
+// WithAlignedExpiry. It implements ratelimiter.AlignedExpiryStore.
+func (s *ShardedRedisStore) AlignedExpiry() bool {
+	return s.shards[0].store.AlignedExpiry()
+}
+
+// shardFor returns the index of the shard holding key: the healthy shard
+// ranking highest for key, or the highest-ranking shard if none is healthy.
+func (s *ShardedRedisStore) shardFor(key string) int {
+	best, bestHealthy := -1, -1
+	var bestScore, bestHealthyScore uint64
+	for i, shard := range s.shards {
+		score := rendezvousScore(shard.name, key)
+		if best < 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+		if !shard.unhealthy.Load() && (bestHealthy < 0 || score > bestHealthyScore) {
+			bestHealthy, bestHealthyScore = i, score
+		}
+	}
+	if bestHealthy >= 0 {
+		return bestHealthy
+	}
+	return best
+}
+
+// rendezvousScore returns the 64-bit FNV-1a hash of shard and key.
+func rendezvousScore(shard, key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(shard); i++ {
+		h ^= uint64(shard[i])
+		h *= 1099511628211
+	}
+	// Hash a zero separator byte, so that ("ab", "c") and ("a", "bc") differ.
+	h *= 1099511628211
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// record updates the health of shard after a call that returned err.
+func (s *ShardedRedisStore) record(shard *redisShard, err error) {
+	if err == nil || errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) {
+		shard.failures.Store(0)
+		return
+	}
+	if shard.failures.Add(1) >= s.threshold {
+		shard.unhealthy.Store(true)
+	}
+}
+
+// runHealthChecks pings unhealthy shards every interval and restores those
+// that answer.
+func (s *ShardedRedisStore) runHealthChecks(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, shard := range s.shards {
+				if !shard.unhealthy.Load() {
+					continue
+				}
+				pingCtx, cancel := context.WithTimeout(ctx, s.interval)
+				if shard.store.Ping(pingCtx) == nil {
+					shard.failures.Store(0)
+					shard.unhealthy.Store(false)
+				}
+				cancel()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Increment runs RedisStore.Increment on the shard holding key.
+func (s *ShardedRedisStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	shard := s.shards[s.shardFor(key)]
+	count, ttl, err := shard.store.Increment(ctx, key, window)
+	s.record(shard, err)
+	return count, ttl, err
+}
+
+// IncrementBy runs RedisStore.IncrementBy on the shard holding key.
+func (s *ShardedRedisStore) IncrementBy(ctx context.Context, key string, n int64, window time.Duration) (int64, time.Duration, error) {
+	shard := s.shards[s.shardFor(key)]
+	count, ttl, err := shard.store.IncrementBy(ctx, key, n, window)
+	s.record(shard, err)
+	return count, ttl, err
+}
+
+// TakeTokens runs RedisStore.TakeTokens on the shard holding key.
+func (s *ShardedRedisStore) TakeTokens(ctx context.Context, key string, n int64, rate float64, burst int64) (bool, float64, error) {
+	shard := s.shards[s.shardFor(key)]
+	allowed, remaining, err := shard.store.TakeTokens(ctx, key, n, rate, burst)
+	s.record(shard, err)
+	return allowed, remaining, err
+}
+
+// TakeToken runs RedisStore.TakeToken on the shard holding key.
+func (s *ShardedRedisStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, error) {
+	shard := s.shards[s.shardFor(key)]
+	allowed, remaining, err := shard.store.TakeToken(ctx, key, rate, burst)
+	s.record(shard, err)
+	return allowed, remaining, err
+}
+
+// TakeTokensWith runs RedisStore.TakeTokensWith on the shard holding key.
+func (s *ShardedRedisStore) TakeTokensWith(ctx context.Context, key string, n int64, bucket ratelimiter.Bucket) (bool, float64, error) {
+	shard := s.shards[s.shardFor(key)]
+	allowed, remaining, err := shard.store.TakeTokensWith(ctx, key, n, bucket)
+	s.record(shard, err)
+	return allowed, remaining, err
+}
+
+// LogRequests runs RedisStore.LogRequests on the shard holding key.
+func (s *ShardedRedisStore) LogRequests(ctx context.Context, key string, n, limit int64, window time.Duration) (bool, int64, time.Duration, error) {
+	shard := s.shards[s.shardFor(key)]
+	allowed, count, resetAfter, err := shard.store.LogRequests(ctx, key, n, limit, window)
+	s.record(shard, err)
+	return allowed, count, resetAfter, err
+}
+
+// groupKeys returns the positions of keys grouped by the shard holding them.
+func (s *ShardedRedisStore) groupKeys(keys []string) map[int][]int {
+	groups := make(map[int][]int)
+	for i, key := range keys {
+		shard := s.shardFor(key)
+		groups[shard] = append(groups[shard], i)
+	}
+	return groups
+}
+
+// IncrementMulti runs RedisStore.IncrementMulti once per shard holding some of
+// the keys. Atomicity holds per shard only.
+func (s *ShardedRedisStore) IncrementMulti(ctx context.Context, keys []string, window time.Duration) ([]ratelimiter.Counter, error) {
+	counters := make([]ratelimiter.Counter, len(keys))
+	for index, positions := range s.groupKeys(keys) {
+		shard := s.shards[index]
+		shardKeys := make([]string, len(positions))
+		for j, pos := range positions {
+			shardKeys[j] = keys[pos]
+		}
+
+		shardCounters, err := shard.store.IncrementMulti(ctx, shardKeys, window)
+		s.record(shard, err)
+		if err != nil {
+			return nil, err
+		}
+		for j, pos := range positions {
+			counters[pos] = shardCounters[j]
+		}
+	}
+	return counters, nil
+}
+
+// TakeTokenMulti runs RedisStore.TakeTokenMulti once per shard holding some of
+// the keys. Atomicity holds per shard only.
+func (s *ShardedRedisStore) TakeTokenMulti(ctx context.Context, keys []string, rate float64, burst int64) ([]ratelimiter.TokenState, error) {
+	states := make([]ratelimiter.TokenState, len(keys))
+	for index, positions := range s.groupKeys(keys) {
+		shard := s.shards[index]
+		shardKeys := make([]string, len(positions))
+		for j, pos := range positions {
+			shardKeys[j] = keys[pos]
+		}
+
+		shardStates, err := shard.store.TakeTokenMulti(ctx, shardKeys, rate, burst)
+		s.record(shard, err)
+		if err != nil {
+			return nil, err
+		}
+		for j, pos := range positions {
+			states[pos] = shardStates[j]
+		}
+	}
+	return states, nil
+}
+
+// Decrement runs RedisStore.Decrement on the shard holding key.
+func (s *ShardedRedisStore) Decrement(ctx context.Context, key string, n int64) error {
+	shard := s.shards[s.shardFor(key)]
+	err := shard.store.Decrement(ctx, key, n)
+	s.record(shard, err)
+	return err
+}
+
+// ReturnTokens runs RedisStore.ReturnTokens on the shard holding key.
+func (s *ShardedRedisStore) ReturnTokens(ctx context.Context, key string, n float64, burst int64) error {
+	shard := s.shards[s.shardFor(key)]
+	err := shard.store.ReturnTokens(ctx, key, n, burst)
+	s.record(shard, err)
+	return err
+}
+
+// Acquire runs RedisStore.Acquire on the shard holding key.
+func (s *ShardedRedisStore) Acquire(ctx context.Context, key, id string, limit int64, ttl time.Duration) (bool, int64, error) {
+	shard := s.shards[s.shardFor(key)]
+	held, count, err := shard.store.Acquire(ctx, key, id, limit, ttl)
+	s.record(shard, err)
+	return held, count, err
+}
+
+// Release runs RedisStore.Release on every shard in parallel, since the lease
+// may have been acquired on another shard than the one now holding key if
+// their health changed in between. Only the error of the shard now holding
+// key is returned.
+func (s *ShardedRedisStore) Release(ctx context.Context, key, id string) error {
+	errs := make([]error, len(s.shards))
+	var wg sync.WaitGroup
+	for i, shard := range s.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = shard.store.Release(ctx, key, id)
+			s.record(shard, errs[i])
+		}()
+	}
+	wg.Wait()
+	return errs[s.shardFor(key)]
+}
+
+// Update runs RedisStore.Update on the shard holding key.
+func (s *ShardedRedisStore) Update(ctx context.Context, key string, ttl time.Duration, fn ratelimiter.UpdateFunc) (ratelimiter.Result, error) {
+	shard := s.shards[s.shardFor(key)]
+	result, err := shard.store.Update(ctx, key, ttl, fn)
+	if !errors.Is(err, ratelimiter.ErrorUpdateConflict) {
+		s.record(shard, err)
+	}
+	return result, err
+}
+
+// Reset deletes the state for key on every shard, since the key may have
+// moved while a shard was unhealthy.
+func (s *ShardedRedisStore) Reset(ctx context.Context, key string) error {
+	var errs []error
+	for _, shard := range s.shards {
+		err := shard.store.Reset(ctx, key)
+		s.record(shard, err)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Ping pings every shard and returns the errors of those that fail.
+func (s *ShardedRedisStore) Ping(ctx context.Context) error {
+	var errs []error
+	for _, shard := range s.shards {
+		if err := shard.store.Ping(ctx); err != nil {
+			errs = append(errs, errors.New(shard.name+": "+err.Error()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Inspect merges the state of up to limit keys matching pattern across all
+// shards, sorted by key.
+func (s *ShardedRedisStore) Inspect(ctx context.Context, pattern string, limit int) ([]ratelimiter.KeyState, error) {
+	var states []ratelimiter.KeyState
+	for _, shard := range s.shards {
+		shardStates, err := shard.store.Inspect(ctx, pattern, limit)
+		s.record(shard, err)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, shardStates...)
+	}
+
+	sort.Slice(states, func(i, j int) bool { return states[i].Key < states[j].Key })
+	if limit > 0 && len(states) > limit {
+		states = states[:limit]
+	}
+	return states, nil
+}
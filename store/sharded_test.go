@@ -0,0 +1,188 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestSharded returns a ShardedRedisStore over n in-process Redis servers.
+func newTestSharded(t *testing.T, n int, opts ...ShardOption) (*ShardedRedisStore, []*miniredis.Miniredis) {
+	t.Helper()
+	var servers []*miniredis.Miniredis
+	var clients []*redis.Client
+	for range n {
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+		t.Cleanup(func() { _ = client.Close() })
+		servers = append(servers, server)
+		clients = append(clients, client)
+	}
+
+	s, err := NewShardedRedis(context.Background(), clients, opts...)
+	if err != nil {
+		t.Fatalf("NewShardedRedis: %v", err)
+	}
+	t.Cleanup(func() { _ = s.(*ShardedRedisStore).Close(context.Background()) })
+	return s.(*ShardedRedisStore), servers
+}
+
+// testKeys returns n distinct keys.
+func testKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = "user:" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+func TestRendezvousPlacement(t *testing.T) {
+	shards := []string{"redis-a:6379", "redis-b:6379", "redis-c:6379", "redis-d:6379"}
+	keys := testKeys(4000)
+
+	// rank returns the shard of key among the given ones.
+	rank := func(shards []string, key string) string {
+		best, bestScore := "", uint64(0)
+		for _, shard := range shards {
+			if score := rendezvousScore(shard, key); best == "" || score > bestScore {
+				best, bestScore = shard, score
+			}
+		}
+		return best
+	}
+
+	counts := make(map[string]int)
+	for _, key := range keys {
+		counts[rank(shards, key)]++
+	}
+	for _, shard := range shards {
+		if share := counts[shard] * len(shards); share < len(keys)*3/4 || share > len(keys)*5/4 {
+			t.Errorf("%s holds %d of %d keys, want about a quarter", shard, counts[shard], len(keys))
+		}
+	}
+
+	// Removing a shard only moves the keys it held.
+	remaining := shards[:3]
+	for _, key := range keys {
+		before, after := rank(shards, key), rank(remaining, key)
+		if before != shards[3] && before != after {
+			t.Fatalf("%s moved from %s to %s when another shard was removed", key, before, after)
+		}
+	}
+
+	if rendezvousScore("ab", "c") == rendezvousScore("a", "bc") {
+		t.Error("shard and key boundaries are not separated in the score")
+	}
+}
+
+func TestShardedRedisPlacement(t *testing.T) {
+	ctx := context.Background()
+	s, servers := newTestSharded(t, 3)
+
+	for _, key := range testKeys(60) {
+		if _, _, err := s.Increment(ctx, key, time.Minute); err != nil {
+			t.Fatalf("Increment: %v", err)
+		}
+		for i, server := range servers {
+			if held := server.Exists(key); held != (i == s.shardFor(key)) {
+				t.Errorf("%s: held by shard %d = %v, placed on shard %d", key, i, held, s.shardFor(key))
+			}
+		}
+	}
+}
+
+func TestShardedRedisFailover(t *testing.T) {
+	ctx := context.Background()
+	s, servers := newTestSharded(t, 3, WithShardFailureThreshold(2), WithShardHealthInterval(time.Hour))
+
+	keys := testKeys(60)
+	placed := make(map[string]int)
+	for _, key := range keys {
+		placed[key] = s.shardFor(key)
+	}
+	moved := keys[0]
+	failed := placed[moved]
+	servers[failed].Close()
+
+	for i := range 2 {
+		if _, _, err := s.Increment(ctx, moved, time.Minute); err == nil {
+			t.Fatalf("Increment %d on a closed shard succeeded", i+1)
+		}
+	}
+	if !s.shards[failed].unhealthy.Load() {
+		t.Fatal("shard not marked unhealthy after reaching the failure threshold")
+	}
+
+	for _, key := range keys {
+		shard := s.shardFor(key)
+		switch {
+		case placed[key] == failed && shard == failed:
+			t.Errorf("%s stayed on the unhealthy shard", key)
+		case placed[key] != failed && shard != placed[key]:
+			t.Errorf("%s moved from healthy shard %d to %d", key, placed[key], shard)
+		}
+	}
+
+	count, _, err := s.Increment(ctx, moved, time.Minute)
+	if err != nil || count != 1 {
+		t.Errorf("Increment after failover = %d, %v, want a fresh count of 1", count, err)
+	}
+}
+
+func TestShardedRedisRecovery(t *testing.T) {
+	s, _ := newTestSharded(t, 2, WithShardFailureThreshold(1), WithShardHealthInterval(10*time.Millisecond))
+
+	s.record(s.shards[1], errors.New("connection refused"))
+	if !s.shards[1].unhealthy.Load() {
+		t.Fatal("shard not marked unhealthy")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.shards[1].unhealthy.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("shard answering pings was not restored")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestShardedRedisRecord(t *testing.T) {
+	tests := []struct {
+		name          string
+		errs          []error
+		wantUnhealthy bool
+	}{
+		{name: "below threshold", errs: []error{errors.New("timeout"), errors.New("timeout")}},
+		{name: "threshold", errs: []error{errors.New("timeout"), errors.New("timeout"), errors.New("timeout")}, wantUnhealthy: true},
+		{name: "success resets", errs: []error{errors.New("timeout"), errors.New("timeout"), nil, errors.New("timeout")}},
+		{name: "missing key is healthy", errs: []error{redis.Nil, redis.Nil, redis.Nil}},
+		{name: "canceled request is healthy", errs: []error{context.Canceled, context.Canceled, context.Canceled}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ShardedRedisStore{threshold: 3}
+			shard := &redisShard{name: "redis-a:6379"}
+			for _, err := range tt.errs {
+				s.record(shard, err)
+			}
+			if got := shard.unhealthy.Load(); got != tt.wantUnhealthy {
+				t.Errorf("unhealthy = %v, want %v", got, tt.wantUnhealthy)
+			}
+		})
+	}
+}
+
+func TestNewShardedRedisValidation(t *testing.T) {
+	for _, clients := range [][]*redis.Client{nil, {nil}} {
+		if _, err := NewShardedRedis(context.Background(), clients); !errors.Is(err, ratelimiter.ErrorInvalidConfig) {
+			t.Errorf("NewShardedRedis(%v) error = %v, want %v", clients, err, ratelimiter.ErrorInvalidConfig)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Package store provides storage backends for github.com/jassus213/go-rate-limiter.
+//
+// This file contains the TierPropagator, which announces tier changes to
+// every application instance.
+package store
+
+import (
+	"context"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultTierChannel is the Pub/Sub channel used when NewTierPropagator is
+// given an empty channel name.
+const DefaultTierChannel = "ratelimiter:tiers:changed"
+
+// TierPropagator propagates tier changes of a ratelimiter.TieredLimiter over
+// Redis Pub/Sub, so that a plan upgrade applies on every instance at once
+// instead of when each instance's cached lookup expires.
+//
+// Example usage:
+//
+//	propagator := store.NewTierPropagator(client, tiered, "")
+//	go propagator.Sync(ctx)
+//
+//	// From the billing webhook, on any instance:
+//	err := propagator.TierChanged(ctx, "tenant:acme")
+type TierPropagator struct {
+	tiered      *ratelimiter.TieredLimiter
+	broadcaster *Broadcaster
+}
+
+// NewTierPropagator creates a TierPropagator for tiered publishing on the
+// given channel. If channel is empty, DefaultTierChannel is used.
+func NewTierPropagator(client *redis.Client, tiered *ratelimiter.TieredLimiter, channel string) *TierPropagator {
+	if channel == "" {
+		channel = DefaultTierChannel
+	}
+	return &TierPropagator{tiered: tiered, broadcaster: NewBroadcaster(client, channel)}
+}
+
+// TierChanged invalidates the tier of key locally, topping up its state if
+// the TieredLimiter was created with ratelimiter.WithTopUp, and announces the
+// change to the other instances.
+//
+// The change is announced even when the top-up fails, so that no instance
+// keeps applying the old tier.
+func (p *TierPropagator) TierChanged(ctx context.Context, key string) error {
+	invalidateErr := p.tiered.Invalidate(ctx, key)
+	if err := p.broadcaster.Publish(ctx, key); err != nil {
+		return err
+	}
+	return invalidateErr
+}
+
+// Sync drops the cached tier of every key announced by TierChanged.
+//
+// It blocks until ctx is canceled, returning nil in that case, or until the
+// subscription cannot be established, returning the Redis error.
+func (p *TierPropagator) Sync(ctx context.Context) error {
+	return p.broadcaster.Subscribe(ctx, p.tiered.Forget)
+}
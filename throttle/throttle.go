@@ -0,0 +1,151 @@
+// Package throttle slows down egress traffic using rate limiters from
+// github.com/jassus213/go-rate-limiter.
+//
+// Unlike the middleware packages, which reject requests over the limit,
+// throttle delays writes so that every client eventually receives its full
+// response, at no more than the configured bandwidth. Limiters count bytes:
+// a token bucket with a rate of 1<<20 allows 1 MiB per second.
+//
+// Example usage:
+//
+//	limiter := ratelimiter.MustNewTokenBucket(store, 1<<20, 256<<10) // 1 MiB/s, 256 KiB burst
+//	mux.Handle("/download/", throttle.Middleware(limiter, keyfunc.ClientIP())(downloadHandler))
+package throttle
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// DefaultChunkSize is the default maximum number of bytes written per limiter check.
+const DefaultChunkSize = 16 << 10
+
+// Option configures a throttled writer.
+type Option func(*config)
+
+// config holds the settings collected from Option values.
+type config struct {
+	chunkSize int
+}
+
+// newConfig applies the given options on top of the defaults.
+func newConfig(opts []Option) config {
+	c := config{chunkSize: DefaultChunkSize}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithChunkSize sets the maximum number of bytes written per limiter check.
+//
+// Smaller chunks give smoother throughput at the cost of more store calls. The
+// chunk size must not exceed the limiter's burst, or writes fail with
+// ratelimiter.ErrorExceeded.
+//
+// Example:
+//
+//	w := throttle.NewWriter(ctx, file, limiter, "backup", throttle.WithChunkSize(4<<10))
+func WithChunkSize(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.chunkSize = n
+		}
+	}
+}
+
+// Writer is an io.Writer that waits for the limiter before passing bytes on.
+type Writer struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter ratelimiter.Limiter
+	key     string
+	cfg     config
+}
+
+// NewWriter returns a Writer that writes to w at the rate allowed by limiter for key.
+//
+// Writes block until the limiter admits each chunk, and fail once ctx is done.
+//
+// Example:
+//
+//	w := throttle.NewWriter(ctx, conn, limiter, "client:42")
+//	_, err := io.Copy(w, file)
+func NewWriter(ctx context.Context, w io.Writer, limiter ratelimiter.Limiter, key string, opts ...Option) *Writer {
+	return &Writer{ctx: ctx, w: w, limiter: limiter, key: key, cfg: newConfig(opts)}
+}
+
+// Write writes p in chunks, waiting for the limiter before each chunk.
+func (t *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > t.cfg.chunkSize {
+			chunk = chunk[:t.cfg.chunkSize]
+		}
+
+		if err := ratelimiter.Wait(t.ctx, t.limiter, t.key, int64(len(chunk))); err != nil {
+			return written, err
+		}
+
+		n, err := t.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// responseWriter is an http.ResponseWriter whose body writes are throttled.
+type responseWriter struct {
+	http.ResponseWriter
+	body *Writer
+}
+
+// NewResponseWriter returns an http.ResponseWriter that throttles the response
+// body written to w at the rate allowed by limiter for key.
+//
+// Headers and status codes pass through unchanged. The original writer remains
+// reachable through http.ResponseController, so flushing keeps working.
+func NewResponseWriter(r *http.Request, w http.ResponseWriter, limiter ratelimiter.Limiter, key string, opts ...Option) http.ResponseWriter {
+	return &responseWriter{
+		ResponseWriter: w,
+		body:           NewWriter(r.Context(), w, limiter, key, opts...),
+	}
+}
+
+// Write writes throttled body bytes.
+func (w *responseWriter) Write(p []byte) (int, error) {
+	return w.body.Write(p)
+}
+
+// Unwrap returns the original http.ResponseWriter.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Middleware returns net/http middleware that throttles response bodies per
+// client, keyed by keyFunc.
+//
+// Requests whose key cannot be extracted are served unthrottled.
+//
+// Example:
+//
+//	handler := throttle.Middleware(limiter, keyfunc.ClientIP())(downloadHandler)
+func Middleware(limiter ratelimiter.Limiter, keyFunc ratelimiter.KeyFunc, opts ...Option) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, err := keyFunc(r)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(NewResponseWriter(r, w, limiter, key, opts...), r)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Package xrate adapts the limiters of github.com/jassus213/go-rate-limiter to
+// the API of golang.org/x/time/rate, so code using rate.Limiter can switch to
+// limits shared across instances through a Redis (or any other) store with
+// minimal changes.
+//
+// A Limiter guards a single key of a token bucket held in the store. Unlike
+// rate.Limiter, it cannot reserve tokens ahead of time, and it uses the
+// store's clock rather than the times passed to AllowN.
+//
+// Example usage:
+//
+//	// Before: limiter := rate.NewLimiter(rate.Every(100*time.Millisecond), 20)
+//	limiter := xrate.NewLimiter(store.NewRedis(client), "crawler:example.com", xrate.Every(100*time.Millisecond), 20)
+//
+//	if err := limiter.Wait(ctx); err != nil {
+//	    return err
+//	}
+package xrate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jassus213/go-rate-limiter/ratelimiter"
+)
+
+// Limit is the maximum frequency of events, in events per second, as in
+// golang.org/x/time/rate.
+type Limit float64
+
+// Inf is the infinite rate limit: every event is allowed, without consulting
+// the store.
+const Inf = Limit(math.MaxFloat64)
+
+// Every converts a minimum time interval between events to a Limit.
+func Every(interval time.Duration) Limit {
+	if interval <= 0 {
+		return Inf
+	}
+	return 1 / Limit(interval.Seconds())
+}
+
+// Limiter is a store-backed counterpart of rate.Limiter for one key.
+type Limiter struct {
+	store ratelimiter.Store
+	key   string
+
+	mu     sync.RWMutex
+	limit  Limit
+	burst  int
+	bucket ratelimiter.Limiter // nil when the limit or burst admits nothing
+}
+
+// NewLimiter returns a Limiter allowing events for key at rate r with bursts
+// of at most b events, held in store.
+//
+// A zero limit or burst denies every event, and Inf allows every event.
+func NewLimiter(store ratelimiter.Store, key string, r Limit, b int) *Limiter {
+	l := &Limiter{store: store, key: key}
+	l.set(r, b)
+	return l
+}
+
+// set replaces the limit and burst. The caller must hold l.mu or own l.
+func (l *Limiter) set(r Limit, b int) {
+	l.limit, l.burst, l.bucket = r, b, nil
+	if r == Inf || r <= 0 || b < 1 {
+		return
+	}
+	bucket, err := ratelimiter.NewTokenBucket(l.store, float64(r), int64(b))
+	if err == nil {
+		l.bucket = bucket
+	}
+}
+
+// Limit returns the maximum overall event rate.
+func (l *Limiter) Limit() Limit {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.limit
+}
+
+// Burst returns the maximum burst size.
+func (l *Limiter) Burst() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.burst
+}
+
+// SetLimit changes the rate limit. The tokens held in the store are kept.
+func (l *Limiter) SetLimit(r Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.set(r, l.burst)
+}
+
+// SetBurst changes the burst size. The tokens held in the store are kept.
+func (l *Limiter) SetBurst(b int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.set(l.limit, b)
+}
+
+// current returns the limit and the bucket to check events against.
+func (l *Limiter) current() (Limit, int, ratelimiter.Limiter) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.limit, l.burst, l.bucket
+}
+
+// Allow reports whether an event may happen now. It is shorthand for
+// AllowN(time.Now(), 1).
+func (l *Limiter) Allow() bool {
+	return l.AllowN(time.Now(), 1)
+}
+
+// AllowN reports whether n events may happen now and consumes n tokens if so.
+// t is ignored: the store's clock is used instead.
+//
+// A store error denies the events; use AllowCtx to observe it.
+func (l *Limiter) AllowN(t time.Time, n int) bool {
+	allowed, _ := l.AllowCtx(context.Background(), n)
+	return allowed
+}
+
+// AllowCtx is like AllowN, but uses ctx for the store call and returns its error.
+func (l *Limiter) AllowCtx(ctx context.Context, n int) (bool, error) {
+	limit, _, bucket := l.current()
+	if limit == Inf {
+		return true, nil
+	}
+	if bucket == nil {
+		return n <= 0, nil
+	}
+	if n <= 0 {
+		return true, nil
+	}
+
+	result, err := ratelimiter.AllowN(ctx, bucket, l.key, int64(n))
+	if err != nil {
+		return false, err
+	}
+	return result.Allowed, nil
+}
+
+// Wait blocks until an event may happen. It is shorthand for WaitN(ctx, 1).
+func (l *Limiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n events may happen, ctx is done, or the store fails.
+// As with rate.Limiter, it returns an error if n exceeds the burst size.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	limit, burst, bucket := l.current()
+	if limit == Inf || n <= 0 {
+		return nil
+	}
+	if n > burst {
+		return fmt.Errorf("xrate: Wait(n=%d) exceeds limiter's burst %d", n, burst)
+	}
+	if bucket == nil {
+		return fmt.Errorf("xrate: Wait(n=%d) would never be admitted with limit %g", n, float64(limit))
+	}
+	return ratelimiter.Wait(ctx, bucket, l.key, int64(n))
+}